@@ -13,15 +13,17 @@ import (
 	"go.uber.org/zap"
 )
 
-// AccountingHandler handles all accounting-related HTTP requests
+// AccountingHandler handles all accounting-related HTTP requests. Each
+// request is served by a copy whose db is bound to the request's tenant.
 type AccountingHandler struct {
-	db     *sqlx.DB
+	db     database
+	pool   *sqlx.DB
 	logger *zap.Logger
 }
 
 // NewAccountingHandler creates a new accounting handler
 func NewAccountingHandler(db *sqlx.DB, logger *zap.Logger) *AccountingHandler {
-	return &AccountingHandler{db: db, logger: logger}
+	return &AccountingHandler{db: db, pool: db, logger: logger}
 }
 
 // Chart of Accounts Handlers
@@ -32,6 +34,8 @@ func (h *AccountingHandler) GetChartOfAccounts(w http.ResponseWriter, r *http.Re
 	isActive := r.URL.Query().Get("is_active")
 
 	qb := sdk.NewQueryBuilder("SELECT * FROM chart_of_accounts WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition("(company_id = $%d OR company_id IS NULL)", companyID(r))
 	qb.AddOptionalCondition("account_type = $%d", accountType)
 	if isActive != "" {
 		qb.AddCondition("is_active = $%d", isActive == "true")
@@ -56,12 +60,15 @@ func (h *AccountingHandler) GetChartOfAccounts(w http.ResponseWriter, r *http.Re
 // CreateChartOfAccount creates a new chart of account
 func (h *AccountingHandler) CreateChartOfAccount(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		AccountCode     string  `json:"account_code"`
-		AccountName     string  `json:"account_name"`
-		AccountType     string  `json:"account_type"`
-		ParentID        *int    `json:"parent_id"`
-		Description     *string `json:"description"`
-		IsSystemAccount bool    `json:"is_system_account"`
+		AccountCode      string  `json:"account_code"`
+		AccountName      string  `json:"account_name"`
+		AccountType      string  `json:"account_type"`
+		ParentID         *int    `json:"parent_id"`
+		Description      *string `json:"description"`
+		IsSystemAccount  bool    `json:"is_system_account"`
+		IsMonetary       bool    `json:"is_monetary"`
+		CashFlowCategory *string `json:"cash_flow_category"`
+		CompanyID        *string `json:"company_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -85,18 +92,48 @@ func (h *AccountingHandler) CreateChartOfAccount(w http.ResponseWriter, r *http.
 		sdk.WriteBadRequest(w, err.Error())
 		return
 	}
+	if err := validateCashFlowCategory(req.AccountType, req.CashFlowCategory); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	// Accounts created without a company are shared by every company
+	tenant := tenantID(r)
+	if req.CompanyID == nil {
+		req.CompanyID = optionalCompany(companyID(r))
+	}
+	if req.CompanyID != nil {
+		if _, err := requireCompany(h.db, tenant, *req.CompanyID); err != nil {
+			h.writeError(w, err, "Failed to create chart of account")
+			return
+		}
+	}
+	if req.ParentID != nil {
+		parentCompany, err := accountCompany(h.db, tenant, *req.ParentID)
+		if err != nil {
+			h.writeError(w, err, "Failed to create chart of account")
+			return
+		}
+		if parentCompany != nil && (req.CompanyID == nil || *parentCompany != *req.CompanyID) {
+			sdk.WriteBadRequest(w, "The parent account belongs to another company")
+			return
+		}
+	}
 
 	query := `
-		INSERT INTO chart_of_accounts (account_code, account_name, account_type, parent_id, description, is_system_account)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO chart_of_accounts
+		(tenant_id, company_id, account_code, account_name, account_type, parent_id, description, is_system_account,
+		 is_monetary, cash_flow_category)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id, created_at, updated_at
 	`
 
 	var id int
 	var createdAt, updatedAt time.Time
 
-	err := h.db.QueryRow(query, req.AccountCode, req.AccountName, req.AccountType,
-		req.ParentID, req.Description, req.IsSystemAccount).Scan(&id, &createdAt, &updatedAt)
+	err := h.db.QueryRow(query, tenant, req.CompanyID, req.AccountCode, req.AccountName, req.AccountType,
+		req.ParentID, req.Description, req.IsSystemAccount, req.IsMonetary, req.CashFlowCategory).Scan(&id, &createdAt,
+		&updatedAt)
 
 	if err != nil {
 		h.logger.Error("Failed to create chart of account", zap.Error(err))
@@ -128,9 +165,10 @@ func (h *AccountingHandler) UpdateChartOfAccount(w http.ResponseWriter, r *http.
 	}
 
 	// Check if account exists
-	var exists bool
-	err = h.db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM chart_of_accounts WHERE id = $1)", id)
-	if err != nil || !exists {
+	tenant := tenantID(r)
+	var accountType string
+	err = h.db.Get(&accountType, "SELECT account_type FROM chart_of_accounts WHERE id = $1 AND tenant_id = $2", id, tenant)
+	if err != nil {
 		sdk.WriteNotFound(w, "Account not found")
 		return
 	}
@@ -155,6 +193,29 @@ func (h *AccountingHandler) UpdateChartOfAccount(w http.ResponseWriter, r *http.
 		args = append(args, val)
 		argIdx++
 	}
+	if val, ok := req["is_monetary"]; ok {
+		updates = append(updates, fmt.Sprintf("is_monetary = $%d", argIdx))
+		args = append(args, val)
+		argIdx++
+	}
+	if val, ok := req["cash_flow_category"]; ok {
+		var category *string
+		if val != nil {
+			value, isString := val.(string)
+			if !isString {
+				sdk.WriteBadRequest(w, "cash_flow_category must be a string")
+				return
+			}
+			category = &value
+		}
+		if err := validateCashFlowCategory(accountType, category); err != nil {
+			sdk.WriteBadRequest(w, err.Error())
+			return
+		}
+		updates = append(updates, fmt.Sprintf("cash_flow_category = $%d", argIdx))
+		args = append(args, category)
+		argIdx++
+	}
 
 	if len(updates) == 0 {
 		sdk.WriteBadRequest(w, "No fields to update")
@@ -165,8 +226,8 @@ func (h *AccountingHandler) UpdateChartOfAccount(w http.ResponseWriter, r *http.
 	for i := 1; i < len(updates); i++ {
 		query += ", " + updates[i]
 	}
-	query += fmt.Sprintf(" WHERE id = $%d", argIdx)
-	args = append(args, id)
+	query += fmt.Sprintf(" WHERE id = $%d AND tenant_id = $%d", argIdx, argIdx+1)
+	args = append(args, id, tenant)
 
 	_, err = h.db.Exec(query, args...)
 	if err != nil {
@@ -188,7 +249,7 @@ func (h *AccountingHandler) DeleteChartOfAccount(w http.ResponseWriter, r *http.
 	}
 
 	// Soft delete
-	_, err = h.db.Exec("UPDATE chart_of_accounts SET is_active = false WHERE id = $1", id)
+	_, err = h.db.Exec("UPDATE chart_of_accounts SET is_active = false WHERE id = $1 AND tenant_id = $2", id, tenantID(r))
 	if err != nil {
 		h.logger.Error("Failed to delete chart of account", zap.Error(err))
 		sdk.WriteInternalError(w, "Failed to delete chart of account")
@@ -203,19 +264,21 @@ func (h *AccountingHandler) GetAccountingTransactions(w http.ResponseWriter, r *
 	startDate := r.URL.Query().Get("start_date")
 	endDate := r.URL.Query().Get("end_date")
 	status := r.URL.Query().Get("status")
-	limit := r.URL.Query().Get("limit")
-
-	if limit == "" {
-		limit = "100"
+	limit, err := listLimit(r, 100)
+	if err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
 	}
 
 	qb := sdk.NewQueryBuilder("SELECT * FROM accounting_transactions WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition("company_id = $%d", companyID(r))
 	qb.AddOptionalCondition("transaction_date >= $%d", startDate)
 	qb.AddOptionalCondition("transaction_date <= $%d", endDate)
 	qb.AddOptionalCondition("status = $%d", status)
 
 	query, args := qb.Build()
-	query += " ORDER BY transaction_date DESC, created_at DESC LIMIT " + limit
+	query += fmt.Sprintf(" ORDER BY transaction_date DESC, created_at DESC LIMIT %d", limit)
 
 	var transactions []AccountingTransaction
 	if err := h.db.Select(&transactions, query, args...); err != nil {
@@ -236,6 +299,7 @@ func (h *AccountingHandler) CreateAccountingTransaction(w http.ResponseWriter, r
 		TransactionDate string                      `json:"transaction_date"`
 		Description     *string                     `json:"description"`
 		Currency        string                      `json:"currency"`
+		Status          string                      `json:"status"`
 		Lines           []AccountingTransactionLine `json:"lines"`
 	}
 
@@ -249,78 +313,264 @@ func (h *AccountingHandler) CreateAccountingTransaction(w http.ResponseWriter, r
 		return
 	}
 
+	tenant := tenantID(r)
+	settings, err := loadSettings(h.db, tenant)
+	if err != nil {
+		h.writeError(w, err, "Failed to create transaction")
+		return
+	}
 	if req.Currency == "" {
-		req.Currency = "USD"
+		req.Currency = settings.DefaultCurrency
+	}
+	if req.Status == "" {
+		req.Status = "posted"
+	}
+	if err := sdk.ValidateEnum("status", req.Status, []string{"draft", "posted"}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
 	}
 
-	// Validate debits equal credits
-	var totalDebits, totalCredits float64
-	for _, line := range req.Lines {
-		totalDebits += line.DebitAmount
-		totalCredits += line.CreditAmount
+	txn := &AccountingTransaction{
+		CompanyID:   optionalCompany(companyID(r)),
+		Description: req.Description,
+		Currency:    req.Currency,
+		CreatedBy:   currentUserID(r),
+		Lines:       req.Lines,
 	}
 
-	if totalDebits != totalCredits {
-		sdk.WriteBadRequest(w, "Total debits must equal total credits")
+	transactionDate, err := parseDate(req.TransactionDate)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid transaction date")
 		return
 	}
+	txn.TransactionDate = transactionDate
 
-	transactionNumber := fmt.Sprintf("TXN-%d", time.Now().Unix())
+	// Lines with tax codes are split into net and tax lines
+	txn.Lines, err = applyTransactionTaxes(h.db, tenant, txn.Lines, transactionDate, txn.Currency)
+	if err != nil {
+		h.writeError(w, err, "Failed to create transaction")
+		return
+	}
 
-	err := sdk.WithTransaction(h.db, func(tx *sqlx.Tx) error {
-		var txnID int
-		err := tx.QueryRow(`
-			INSERT INTO accounting_transactions 
-			(transaction_number, transaction_date, description, total_amount, currency, created_by)
-			VALUES ($1, $2, $3, $4, $5, $6)
-			RETURNING id
-		`, transactionNumber, req.TransactionDate, req.Description, totalDebits, req.Currency, 1).
-			Scan(&txnID)
+	// Validate debits equal credits
+	total, err := balancedTotal(txn.Lines, txn.Currency)
+	if err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		if req.Status == "draft" {
+			txn.Status = "draft"
+			return h.postTransaction(tx, tenant, txn)
+		}
+
+		// Approval amounts are in the functional currency
+		rate, err := transactionExchangeRate(tx, tenant, settings, txn.Currency, txn.TransactionDate)
 		if err != nil {
 			return err
 		}
+		txn.ExchangeRate = rate
+		functionalTotal := total.Mul(rate, minorUnits(settings.DefaultCurrency), settings.RoundingMode)
 
-		// Insert lines
-		for _, line := range req.Lines {
-			_, err = tx.Exec(`
-				INSERT INTO accounting_transaction_lines 
-				(transaction_id, account_id, debit_amount, credit_amount, description)
-				VALUES ($1, $2, $3, $4, $5)
-			`, txnID, line.AccountID, line.DebitAmount, line.CreditAmount, line.Description)
-			if err != nil {
-				return err
-			}
+		steps, err := transactionApprovalChain(tx, tenant, functionalTotal, txn.Lines)
+		if err != nil {
+			return err
+		}
+		if len(steps) > 0 {
+			txn.Status = "pending_approval"
 		}
 
-		return nil
+		if err := h.postTransaction(tx, tenant, txn); err != nil {
+			return err
+		}
+		return createApprovalSteps(tx, tenant, txn.ID, steps)
 	})
 
 	if err != nil {
-		h.logger.Error("Failed to create transaction", zap.Error(err))
-		sdk.WriteInternalError(w, "Failed to create transaction")
+		h.writeError(w, err, "Failed to create transaction")
 		return
 	}
 
 	sdk.WriteCreated(w, map[string]interface{}{
-		"transaction_number": transactionNumber,
+		"id":                 txn.ID,
+		"transaction_number": txn.TransactionNumber,
+		"status":             txn.Status,
 		"message":            "Transaction created successfully",
 	})
 }
 
+// balancedTotal returns the total debit of the lines, or an error when
+// debits and credits do not agree or an amount has more decimal places than
+// the currency
+func balancedTotal(lines []AccountingTransactionLine, currency string) (Money, error) {
+	var totalDebits, totalCredits Money
+	for _, line := range lines {
+		if err := checkMinorUnits(line.DebitAmount, currency); err != nil {
+			return 0, err
+		}
+		if err := checkMinorUnits(line.CreditAmount, currency); err != nil {
+			return 0, err
+		}
+		totalDebits += line.DebitAmount
+		totalCredits += line.CreditAmount
+	}
+
+	if totalDebits != totalCredits {
+		return 0, errUnbalancedTransaction
+	}
+
+	return totalDebits, nil
+}
+
+// postTransaction writes a balanced transaction and its lines to the general
+// ledger inside tx. Every flow that posts to the ledger goes through here so
+// that validation, currency conversion and numbering stay in one place. Line
+// amounts are given in txn.Currency and converted to the functional currency
+// at txn.ExchangeRate, or at the rate on the transaction date when it is not
+// set. The transaction is posted unless txn.Status is set, e.g. to draft.
+func (h *AccountingHandler) postTransaction(tx *sqlx.Tx, tenant string, txn *AccountingTransaction) error {
+	if len(txn.Lines) == 0 {
+		return newBadRequestError("At least one transaction line is required")
+	}
+
+	settings, err := loadSettings(tx, tenant)
+	if err != nil {
+		return err
+	}
+	if txn.Currency == "" {
+		txn.Currency = settings.DefaultCurrency
+	}
+
+	if _, err := balancedTotal(txn.Lines, txn.Currency); err != nil {
+		return err
+	}
+	var accountIDs []int
+	for _, line := range txn.Lines {
+		accountIDs = append(accountIDs, line.AccountID)
+	}
+	if txn.CompanyID, err = postingCompany(tx, tenant, txn.CompanyID, accountIDs); err != nil {
+		return err
+	}
+
+	if err := checkPostingPeriod(tx, tenant, txn.TransactionDate); err != nil {
+		return err
+	}
+	if txn.ExchangeRate == 0 {
+		rate, err := transactionExchangeRate(tx, tenant, settings, txn.Currency, txn.TransactionDate)
+		if err != nil {
+			return err
+		}
+		txn.ExchangeRate = rate
+	}
+	if txn.Status == "" {
+		txn.Status = "posted"
+	}
+	txn.TotalAmount = convertToFunctional(txn, settings.DefaultCurrency, settings.RoundingMode)
+	txn.TransactionNumber = generateNumber("TXN")
+	txn.TenantID = &tenant
+
+	err = tx.QueryRow(`
+		INSERT INTO accounting_transactions 
+		(tenant_id, company_id, transaction_number, transaction_date, reference_type, reference_id, description,
+		 total_amount, currency, exchange_rate, status, created_by, reversal_of_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, status, created_at, updated_at
+	`, tenant, txn.CompanyID, txn.TransactionNumber, txn.TransactionDate, txn.ReferenceType, txn.ReferenceID,
+		txn.Description, txn.TotalAmount, txn.Currency, txn.ExchangeRate, txn.Status, txn.CreatedBy, txn.ReversalOfID).
+		Scan(&txn.ID, &txn.Status, &txn.CreatedAt, &txn.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Insert lines
+	for i := range txn.Lines {
+		line := &txn.Lines[i]
+		line.TransactionID = txn.ID
+		line.TenantID = &tenant
+		err = tx.QueryRow(`
+			INSERT INTO accounting_transaction_lines 
+			(tenant_id, transaction_id, account_id, debit_amount, credit_amount, currency_debit_amount,
+			 currency_credit_amount, description)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id, created_at
+		`, tenant, txn.ID, line.AccountID, line.DebitAmount, line.CreditAmount, line.CurrencyDebitAmount,
+			line.CurrencyCreditAmount, line.Description).
+			Scan(&line.ID, &line.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if txn.Status == "posted" {
+		return h.checkBudgets(tx, tenant, txn)
+	}
+	return nil
+}
+
+// reverseTransaction posts a new transaction that swaps the debits and credits
+// of an existing one, cancelling its effect on the ledger without deleting it.
+// The reversal is converted at the rate of the original, whatever its date.
+func (h *AccountingHandler) reverseTransaction(tx *sqlx.Tx, tenant string, originalID int, date time.Time, description string) (*AccountingTransaction, error) {
+	var original AccountingTransaction
+	if err := tx.Get(&original, `
+		SELECT id, company_id, reference_type, reference_id, currency, exchange_rate, created_by
+		FROM accounting_transactions WHERE id = $1 AND tenant_id = $2
+	`, originalID, tenant); err != nil {
+		return nil, err
+	}
+
+	var lines []AccountingTransactionLine
+	if err := tx.Select(&lines, `
+		SELECT account_id, currency_debit_amount, currency_credit_amount, description
+		FROM accounting_transaction_lines WHERE transaction_id = $1 AND tenant_id = $2 ORDER BY id
+	`, originalID, tenant); err != nil {
+		return nil, err
+	}
+
+	reversal := &AccountingTransaction{
+		CompanyID:       original.CompanyID,
+		TransactionDate: date,
+		ReferenceType:   original.ReferenceType,
+		ReferenceID:     original.ReferenceID,
+		Description:     &description,
+		Currency:        original.Currency,
+		ExchangeRate:    original.ExchangeRate,
+		CreatedBy:       original.CreatedBy,
+		ReversalOfID:    &original.ID,
+	}
+	for _, line := range lines {
+		reversal.Lines = append(reversal.Lines, AccountingTransactionLine{
+			AccountID:    line.AccountID,
+			DebitAmount:  line.CurrencyCreditAmount,
+			CreditAmount: line.CurrencyDebitAmount,
+			Description:  line.Description,
+		})
+	}
+
+	if err := h.postTransaction(tx, tenant, reversal); err != nil {
+		return nil, err
+	}
+
+	return reversal, nil
+}
+
 // GetJournalEntries retrieves journal entries
 func (h *AccountingHandler) GetJournalEntries(w http.ResponseWriter, r *http.Request) {
 	status := r.URL.Query().Get("status")
-	limit := r.URL.Query().Get("limit")
-
-	if limit == "" {
-		limit = "50"
+	limit, err := listLimit(r, 50)
+	if err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
 	}
 
 	qb := sdk.NewQueryBuilder("SELECT * FROM accounting_journal_entries WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition("company_id = $%d", companyID(r))
 	qb.AddOptionalCondition("status = $%d", status)
 
 	query, args := qb.Build()
-	query += " ORDER BY entry_date DESC LIMIT " + limit
+	query += fmt.Sprintf(" ORDER BY entry_date DESC LIMIT %d", limit)
 
 	var entries []JournalEntry
 	if err := h.db.Select(&entries, query, args...); err != nil {
@@ -338,9 +588,12 @@ func (h *AccountingHandler) GetJournalEntries(w http.ResponseWriter, r *http.Req
 // CreateJournalEntry creates a new journal entry
 func (h *AccountingHandler) CreateJournalEntry(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		EntryDate   string             `json:"entry_date"`
-		Description *string            `json:"description"`
-		Lines       []JournalEntryLine `json:"lines"`
+		EntryDate     string             `json:"entry_date"`
+		Description   *string            `json:"description"`
+		Reference     *string            `json:"reference"`
+		AutoReverseOn string             `json:"auto_reverse_on"`
+		Status        string             `json:"status"`
+		Lines         []JournalEntryLine `json:"lines"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -353,66 +606,324 @@ func (h *AccountingHandler) CreateJournalEntry(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Validate debits equal credits
-	var totalDebits, totalCredits float64
-	for _, line := range req.Lines {
-		totalDebits += line.DebitAmount
-		totalCredits += line.CreditAmount
+	entryDate, err := parseDate(req.EntryDate)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid entry date")
+		return
 	}
 
-	if totalDebits != totalCredits {
-		sdk.WriteBadRequest(w, "Total debits must equal total credits")
+	if req.Status == "" {
+		req.Status = "posted"
+	}
+	if err := sdk.ValidateEnum("status", req.Status, []string{"draft", "posted"}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
 		return
 	}
 
-	entryNumber := fmt.Sprintf("JE-%d", time.Now().Unix())
+	entry := &JournalEntry{
+		CompanyID:   optionalCompany(companyID(r)),
+		EntryDate:   entryDate,
+		Description: req.Description,
+		Reference:   req.Reference,
+		CreatedBy:   currentUserID(r),
+		Lines:       req.Lines,
+	}
 
-	err := sdk.WithTransaction(h.db, func(tx *sqlx.Tx) error {
-		var entryID int
-		err := tx.QueryRow(`
-			INSERT INTO accounting_journal_entries 
-			(entry_number, entry_date, description, total_debit, total_credit, created_by)
-			VALUES ($1, $2, $3, $4, $5, $6)
-			RETURNING id
-		`, entryNumber, req.EntryDate, req.Description, totalDebits, totalCredits, 1).Scan(&entryID)
+	if req.AutoReverseOn != "" {
+		autoReverseOn, err := parseDate(req.AutoReverseOn)
 		if err != nil {
-			return err
+			sdk.WriteBadRequest(w, "Invalid auto_reverse_on date")
+			return
+		}
+		if !autoReverseOn.After(entryDate) {
+			sdk.WriteBadRequest(w, "auto_reverse_on must be after the entry date")
+			return
 		}
+		entry.AutoReverseOn = &autoReverseOn
+	}
 
-		// Insert lines
-		for _, line := range req.Lines {
-			_, err = tx.Exec(`
-				INSERT INTO accounting_journal_entry_lines 
-				(journal_entry_id, account_id, debit_amount, credit_amount, description)
-				VALUES ($1, $2, $3, $4, $5)
-			`, entryID, line.AccountID, line.DebitAmount, line.CreditAmount, line.Description)
-			if err != nil {
-				return err
-			}
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		if err := checkPostingPeriod(tx, tenant, entryDate); err != nil {
+			return err
 		}
 
-		return nil
+		if req.Status == "draft" {
+			entry.Status = "draft"
+			return h.insertJournalEntry(tx, tenant, entry)
+		}
+		return h.submitJournalEntry(tx, tenant, entry)
 	})
 
 	if err != nil {
-		h.logger.Error("Failed to create journal entry", zap.Error(err))
-		sdk.WriteInternalError(w, "Failed to create journal entry")
+		h.writeError(w, err, "Failed to create journal entry")
 		return
 	}
 
 	sdk.WriteCreated(w, map[string]interface{}{
-		"entry_number": entryNumber,
+		"id":           entry.ID,
+		"entry_number": entry.EntryNumber,
+		"status":       entry.Status,
 		"message":      "Journal entry created successfully",
 	})
 }
 
-// GetBalanceSheet generates a balance sheet report
+// journalEntryApprovalChain returns the approval steps a journal entry must
+// go through before it is posted
+func journalEntryApprovalChain(q sqlx.Queryer, tenant string, entry *JournalEntry) ([]ApprovalStep, error) {
+	settings, err := loadSettings(q, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	var accountIDs []int
+	var totalDebits Money
+	for _, line := range entry.Lines {
+		accountIDs = append(accountIDs, line.AccountID)
+		totalDebits += line.DebitAmount
+	}
+	required := settings.RequireApprovalForJournalEntries && totalDebits >= settings.TransactionApprovalAmount
+	return approvalChain(q, tenant, "journal_entry", totalDebits, accountIDs, required)
+}
+
+// submitJournalEntry stores a new journal entry as posted, or as pending
+// approval when an approval chain applies to it
+func (h *AccountingHandler) submitJournalEntry(tx *sqlx.Tx, tenant string, entry *JournalEntry) error {
+	steps, err := journalEntryApprovalChain(tx, tenant, entry)
+	if err != nil {
+		return err
+	}
+	entry.Status = "posted"
+	if len(steps) > 0 {
+		entry.Status = "pending_approval"
+	}
+
+	if err := h.insertJournalEntry(tx, tenant, entry); err != nil {
+		return err
+	}
+	return createApprovalSteps(tx, tenant, entry.ID, steps)
+}
+
+// PostDraftJournalEntry submits a draft journal entry. It is posted, or held
+// for approval when an approval chain applies to it.
+func (h *AccountingHandler) PostDraftJournalEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid journal entry ID")
+		return
+	}
+
+	tenant := tenantID(r)
+	var entry JournalEntry
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		if err := tx.Get(&entry,
+			"SELECT * FROM accounting_journal_entries WHERE id = $1 AND tenant_id = $2 FOR UPDATE", id, tenant); err != nil {
+			return err
+		}
+		if entry.Status != "draft" {
+			return newBadRequestError("Only draft journal entries can be posted, status is %s", entry.Status)
+		}
+		if err := checkPostingPeriod(tx, tenant, entry.EntryDate); err != nil {
+			return err
+		}
+
+		if err := tx.Select(&entry.Lines, `
+			SELECT id, account_id, debit_amount, credit_amount, description, created_at
+			FROM accounting_journal_entry_lines WHERE journal_entry_id = $1 AND tenant_id = $2 ORDER BY id
+		`, id, tenant); err != nil {
+			return err
+		}
+
+		steps, err := journalEntryApprovalChain(tx, tenant, &entry)
+		if err != nil {
+			return err
+		}
+		entry.Status = "posted"
+		if len(steps) > 0 {
+			entry.Status = "pending_approval"
+		}
+
+		if _, err := tx.Exec("UPDATE accounting_journal_entries SET status = $1 WHERE id = $2 AND tenant_id = $3",
+			entry.Status, id, tenant); err != nil {
+			return err
+		}
+		if len(steps) > 0 {
+			return createApprovalSteps(tx, tenant, id, steps)
+		}
+		return h.postJournalEntry(tx, tenant, &entry)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to post journal entry")
+		return
+	}
+
+	message := "Journal entry posted successfully"
+	if entry.Status == "pending_approval" {
+		message = "Journal entry submitted for approval"
+	}
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"entry":   entry,
+		"message": message,
+	})
+}
+
+// insertJournalEntry validates and stores a journal entry and its lines.
+// The entry is posted unless entry.Status is set, in which case it reaches
+// the ledger when it is posted later.
+func (h *AccountingHandler) insertJournalEntry(tx *sqlx.Tx, tenant string, entry *JournalEntry) error {
+	if len(entry.Lines) == 0 {
+		return newBadRequestError("At least one line is required")
+	}
+
+	// Journal entries are in the default currency
+	settings, err := loadSettings(tx, tenant)
+	if err != nil {
+		return err
+	}
+
+	// Validate debits equal credits
+	var totalDebits, totalCredits Money
+	var accountIDs []int
+	for _, line := range entry.Lines {
+		if err := checkMinorUnits(line.DebitAmount, settings.DefaultCurrency); err != nil {
+			return err
+		}
+		if err := checkMinorUnits(line.CreditAmount, settings.DefaultCurrency); err != nil {
+			return err
+		}
+		accountIDs = append(accountIDs, line.AccountID)
+		totalDebits += line.DebitAmount
+		totalCredits += line.CreditAmount
+	}
+	if totalDebits != totalCredits {
+		return newBadRequestError("Total debits must equal total credits")
+	}
+	if entry.CompanyID, err = postingCompany(tx, tenant, entry.CompanyID, accountIDs); err != nil {
+		return err
+	}
+
+	if entry.Status == "" {
+		entry.Status = "posted"
+	}
+	entry.EntryNumber = generateNumber("JE")
+	entry.TotalDebit = totalDebits
+	entry.TotalCredit = totalCredits
+	entry.TenantID = &tenant
+
+	err = tx.QueryRow(`
+		INSERT INTO accounting_journal_entries
+		(tenant_id, company_id, entry_number, entry_date, description, reference, total_debit, total_credit, status,
+		 created_by, reversal_of_id, auto_reverse_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at, updated_at
+	`, tenant, entry.CompanyID, entry.EntryNumber, entry.EntryDate, entry.Description, entry.Reference,
+		entry.TotalDebit, entry.TotalCredit, entry.Status, entry.CreatedBy, entry.ReversalOfID, entry.AutoReverseOn).
+		Scan(&entry.ID, &entry.CreatedAt, &entry.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Insert lines
+	for i := range entry.Lines {
+		line := &entry.Lines[i]
+		line.JournalEntryID = entry.ID
+		line.TenantID = &tenant
+		err = tx.QueryRow(`
+			INSERT INTO accounting_journal_entry_lines
+			(tenant_id, journal_entry_id, account_id, debit_amount, credit_amount, description)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at
+		`, tenant, entry.ID, line.AccountID, line.DebitAmount, line.CreditAmount, line.Description).
+			Scan(&line.ID, &line.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if entry.Status == "posted" {
+		return h.postJournalEntry(tx, tenant, entry)
+	}
+	return nil
+}
+
+// postJournalEntry posts a journal entry and its lines to the general ledger
+// as a transaction referencing it, which is what makes manual journals count
+// in reports. The entry is linked to the transaction through transaction_id,
+// and the transaction of a reversal to the transaction it reverses.
+func (h *AccountingHandler) postJournalEntry(tx *sqlx.Tx, tenant string, entry *JournalEntry) error {
+	if entry.Lines == nil {
+		if err := tx.Select(&entry.Lines, `
+			SELECT id, account_id, debit_amount, credit_amount, description, created_at
+			FROM accounting_journal_entry_lines WHERE journal_entry_id = $1 AND tenant_id = $2 ORDER BY id
+		`, entry.ID, tenant); err != nil {
+			return err
+		}
+	}
+
+	referenceType := "journal_entry"
+	description := "Journal entry " + entry.EntryNumber
+	if entry.Description != nil {
+		description += ": " + *entry.Description
+	}
+	txn := &AccountingTransaction{
+		CompanyID:       entry.CompanyID,
+		TransactionDate: entry.EntryDate,
+		ReferenceType:   &referenceType,
+		ReferenceID:     &entry.ID,
+		Description:     &description,
+		CreatedBy:       entry.CreatedBy,
+	}
+	if entry.ReversalOfID != nil {
+		if err := tx.Get(&txn.ReversalOfID,
+			"SELECT transaction_id FROM accounting_journal_entries WHERE id = $1 AND tenant_id = $2",
+			*entry.ReversalOfID, tenant); err != nil {
+			return err
+		}
+	}
+	for _, line := range entry.Lines {
+		txn.Lines = append(txn.Lines, AccountingTransactionLine{
+			AccountID:    line.AccountID,
+			DebitAmount:  line.DebitAmount,
+			CreditAmount: line.CreditAmount,
+			Description:  line.Description,
+		})
+	}
+
+	// Journal entries are in the functional currency
+	if err := h.postTransaction(tx, tenant, txn); err != nil {
+		return err
+	}
+
+	entry.TransactionID = &txn.ID
+	_, err := tx.Exec("UPDATE accounting_journal_entries SET transaction_id = $1 WHERE id = $2 AND tenant_id = $3",
+		txn.ID, entry.ID, tenant)
+	return err
+}
+
+// GetBalanceSheet generates a balance sheet report in the functional
+// currency, for the company of the request or for every company
 func (h *AccountingHandler) GetBalanceSheet(w http.ResponseWriter, r *http.Request) {
 	asOfDate := r.URL.Query().Get("as_of_date")
 	if asOfDate == "" {
 		asOfDate = time.Now().Format("2006-01-02")
 	}
 
+	tenant := tenantID(r)
+	settings, err := loadSettings(h.db, tenant)
+	if err != nil {
+		h.logger.Error("Failed to generate balance sheet", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to generate balance sheet")
+		return
+	}
+	scope, err := requestReportScope(h.db, r)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate balance sheet")
+		return
+	}
+	accountScope, transactionScope, scopeArgs := scope.conditions(3)
+	args := append([]interface{}{asOfDate, tenant}, scopeArgs...)
+
 	query := `
 		SELECT 
 			coa.account_type,
@@ -427,15 +938,18 @@ func (h *AccountingHandler) GetBalanceSheet(w http.ResponseWriter, r *http.Reque
 		FROM chart_of_accounts coa
 		LEFT JOIN accounting_transaction_lines atl ON coa.id = atl.account_id
 		LEFT JOIN accounting_transactions at ON atl.transaction_id = at.id
-		WHERE coa.is_active = true 
+		WHERE coa.tenant_id = $2
+		  AND coa.is_active = true 
 		  AND coa.account_type IN ('asset', 'liability', 'equity')
 		  AND (at.transaction_date IS NULL OR at.transaction_date <= $1)
-		  AND (at.status IS NULL OR at.status = 'posted')
+		  AND (at.status IS NULL OR at.status IN ('posted', 'void'))
+		  AND ` + accountScope + `
+		  AND (at.id IS NULL OR ` + transactionScope + `)
 		GROUP BY coa.account_type, coa.account_code, coa.account_name
 		ORDER BY coa.account_type, coa.account_code
 	`
 
-	rows, err := h.db.Query(query, asOfDate)
+	rows, err := h.db.Query(query, args...)
 	if err != nil {
 		h.logger.Error("Failed to generate balance sheet", zap.Error(err))
 		sdk.WriteInternalError(w, "Failed to generate balance sheet")
@@ -447,14 +961,17 @@ func (h *AccountingHandler) GetBalanceSheet(w http.ResponseWriter, r *http.Reque
 		Assets           []AccountBalance `json:"assets"`
 		Liabilities      []AccountBalance `json:"liabilities"`
 		Equity           []AccountBalance `json:"equity"`
-		TotalAssets      float64          `json:"total_assets"`
-		TotalLiabilities float64          `json:"total_liabilities"`
-		TotalEquity      float64          `json:"total_equity"`
+		TotalAssets      Money            `json:"total_assets"`
+		TotalLiabilities Money            `json:"total_liabilities"`
+		TotalEquity      Money            `json:"total_equity"`
+		Currency         string           `json:"currency"`
+		Eliminations     []AccountBalance `json:"eliminations,omitempty"`
 	}
+	balanceSheet.Currency = settings.DefaultCurrency
 
 	for rows.Next() {
 		var accountType, accountCode, accountName string
-		var balance float64
+		var balance Money
 
 		err := rows.Scan(&accountType, &accountCode, &accountName, &balance)
 		if err != nil {
@@ -480,21 +997,73 @@ func (h *AccountingHandler) GetBalanceSheet(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	sdk.WriteSuccess(w, balanceSheet)
-}
+	// Revenue and expense that has not been closed to retained earnings by a
+	// year-end close still belongs to equity
+	var unclosedEarnings Money
+	err = h.db.Get(&unclosedEarnings, `
+		SELECT COALESCE(SUM(atl.credit_amount - atl.debit_amount), 0)
+		FROM chart_of_accounts coa
+		JOIN accounting_transaction_lines atl ON coa.id = atl.account_id
+		JOIN accounting_transactions at ON atl.transaction_id = at.id
+		WHERE coa.tenant_id = $2
+		  AND coa.account_type IN ('revenue', 'expense')
+		  AND at.transaction_date <= $1
+		  AND at.status IN ('posted', 'void')
+		  AND `+accountScope+`
+		  AND `+transactionScope+`
+	`, args...)
+	if err != nil {
+		h.logger.Error("Failed to compute current year earnings", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to generate balance sheet")
+		return
+	}
 
-// GetIncomeStatement generates an income statement report
-func (h *AccountingHandler) GetIncomeStatement(w http.ResponseWriter, r *http.Request) {
-	startDate := r.URL.Query().Get("start_date")
-	endDate := r.URL.Query().Get("end_date")
+	if unclosedEarnings != 0 {
+		balanceSheet.Equity = append(balanceSheet.Equity, AccountBalance{
+			AccountName: "Current Year Earnings",
+			Balance:     unclosedEarnings,
+		})
+		balanceSheet.TotalEquity += unclosedEarnings
+	}
 
-	if startDate == "" || endDate == "" {
-		sdk.WriteBadRequest(w, "Start date and end date are required")
-		return
+	// A consolidated balance sheet lists what it eliminated
+	if scope.Consolidated {
+		eliminations, err := intercompanyEliminations(h.db, tenant, "", asOfDate)
+		if err != nil {
+			h.writeError(w, err, "Failed to generate balance sheet")
+			return
+		}
+		for _, row := range eliminations {
+			balanceSheet.Eliminations = append(balanceSheet.Eliminations, AccountBalance{
+				AccountCode: row.AccountCode,
+				AccountName: row.AccountName,
+				Balance:     row.Amount,
+			})
+		}
 	}
 
+	sdk.WriteSuccess(w, balanceSheet)
+}
+
+// incomeAccountAmount is the net amount of a revenue or expense account over
+// a date range, positive when it adds to revenue or expense respectively
+type incomeAccountAmount struct {
+	AccountID   int    `db:"account_id"`
+	AccountType string `db:"account_type"`
+	AccountCode string `db:"account_code"`
+	AccountName string `db:"account_name"`
+	Amount      Money  `db:"amount"`
+}
+
+// incomeAccountAmounts sums the posted revenue and expense activity of a
+// tenant per account between startDate and endDate inclusive, within scope.
+// Year-end closing entries and their reversals are left out, since they only
+// move the year's result into retained earnings.
+func incomeAccountAmounts(q sqlx.Queryer, tenant string, scope reportScope, startDate, endDate interface{}) ([]incomeAccountAmount, error) {
+	accountScope, transactionScope, scopeArgs := scope.conditions(4)
 	query := `
 		SELECT 
+			coa.id as account_id,
 			coa.account_type,
 			coa.account_code,
 			coa.account_name,
@@ -508,68 +1077,128 @@ func (h *AccountingHandler) GetIncomeStatement(w http.ResponseWriter, r *http.Re
 		FROM chart_of_accounts coa
 		LEFT JOIN accounting_transaction_lines atl ON coa.id = atl.account_id
 		LEFT JOIN accounting_transactions at ON atl.transaction_id = at.id
-		WHERE coa.is_active = true 
+		WHERE coa.tenant_id = $3
+		  AND coa.is_active = true 
 		  AND coa.account_type IN ('revenue', 'expense')
 		  AND at.transaction_date BETWEEN $1 AND $2
-		  AND at.status = 'posted'
-		GROUP BY coa.account_type, coa.account_code, coa.account_name
+		  AND at.status IN ('posted', 'void')
+		  AND at.reference_type IS DISTINCT FROM 'year_end_close'
+		  AND ` + accountScope + `
+		  AND ` + transactionScope + `
+		GROUP BY coa.id, coa.account_type, coa.account_code, coa.account_name
 		ORDER BY coa.account_type, coa.account_code
 	`
 
-	rows, err := h.db.Query(query, startDate, endDate)
+	args := append([]interface{}{startDate, endDate, tenant}, scopeArgs...)
+	var amounts []incomeAccountAmount
+	if err := sqlx.Select(q, &amounts, query, args...); err != nil {
+		return nil, err
+	}
+
+	return amounts, nil
+}
+
+// GetIncomeStatement generates an income statement report in the functional
+// currency, for the company of the request or for every company
+func (h *AccountingHandler) GetIncomeStatement(w http.ResponseWriter, r *http.Request) {
+	startDate := r.URL.Query().Get("start_date")
+	endDate := r.URL.Query().Get("end_date")
+
+	if startDate == "" || endDate == "" {
+		sdk.WriteBadRequest(w, "Start date and end date are required")
+		return
+	}
+
+	tenant := tenantID(r)
+	settings, err := loadSettings(h.db, tenant)
+	if err != nil {
+		h.logger.Error("Failed to generate income statement", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to generate income statement")
+		return
+	}
+	scope, err := requestReportScope(h.db, r)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate income statement")
+		return
+	}
+
+	amounts, err := incomeAccountAmounts(h.db, tenant, scope, startDate, endDate)
 	if err != nil {
 		h.logger.Error("Failed to generate income statement", zap.Error(err))
 		sdk.WriteInternalError(w, "Failed to generate income statement")
 		return
 	}
-	defer rows.Close()
 
 	var incomeStatement struct {
 		Revenues      []AccountAmount `json:"revenues"`
 		Expenses      []AccountAmount `json:"expenses"`
-		TotalRevenue  float64         `json:"total_revenue"`
-		TotalExpenses float64         `json:"total_expenses"`
-		NetIncome     float64         `json:"net_income"`
+		TotalRevenue  Money           `json:"total_revenue"`
+		TotalExpenses Money           `json:"total_expenses"`
+		NetIncome     Money           `json:"net_income"`
+		Currency      string          `json:"currency"`
+		Eliminations  []AccountAmount `json:"eliminations,omitempty"`
 	}
+	incomeStatement.Currency = settings.DefaultCurrency
 
-	for rows.Next() {
-		var accountType, accountCode, accountName string
-		var amount float64
-
-		err := rows.Scan(&accountType, &accountCode, &accountName, &amount)
-		if err != nil {
-			continue
-		}
-
+	for _, row := range amounts {
 		accountAmount := AccountAmount{
-			AccountCode: accountCode,
-			AccountName: accountName,
-			Amount:      amount,
+			AccountCode: row.AccountCode,
+			AccountName: row.AccountName,
+			Amount:      row.Amount,
 		}
 
-		switch accountType {
+		switch row.AccountType {
 		case "revenue":
 			incomeStatement.Revenues = append(incomeStatement.Revenues, accountAmount)
-			incomeStatement.TotalRevenue += amount
+			incomeStatement.TotalRevenue += row.Amount
 		case "expense":
 			incomeStatement.Expenses = append(incomeStatement.Expenses, accountAmount)
-			incomeStatement.TotalExpenses += amount
+			incomeStatement.TotalExpenses += row.Amount
 		}
 	}
 
 	incomeStatement.NetIncome = incomeStatement.TotalRevenue - incomeStatement.TotalExpenses
 
+	// A consolidated income statement lists the inter-company revenue and
+	// expense it eliminated
+	if scope.Consolidated {
+		eliminations, err := intercompanyEliminations(h.db, tenant, startDate, endDate)
+		if err != nil {
+			h.writeError(w, err, "Failed to generate income statement")
+			return
+		}
+		for _, row := range eliminations {
+			if row.AccountType != "revenue" && row.AccountType != "expense" {
+				continue
+			}
+			incomeStatement.Eliminations = append(incomeStatement.Eliminations, AccountAmount{
+				AccountCode: row.AccountCode,
+				AccountName: row.AccountName,
+				Amount:      row.Amount,
+			})
+		}
+	}
+
 	sdk.WriteSuccess(w, incomeStatement)
 }
 
-// GetAnalytics retrieves aggregated analytics data for the accounting dashboard
+// GetAnalytics retrieves aggregated analytics data for the accounting
+// dashboard, for the company of the request or for every company
 func (h *AccountingHandler) GetAnalytics(w http.ResponseWriter, r *http.Request) {
 	// Get totals for assets, liabilities, equity
-	var totalAssets, totalLiabilities, totalEquity float64
-	var totalRevenue, totalExpenses, netIncome float64
-	var grossProfit, operatingProfit float64
+	var totalAssets, totalLiabilities, totalEquity Money
+	var totalRevenue, totalExpenses, netIncome Money
+	var grossProfit, operatingProfit Money
 	var currentRatio, quickRatio, debtToEquity float64
 	var returnOnEquity float64
+	tenant := tenantID(r)
+	scope, err := requestReportScope(h.db, r)
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch analytics")
+		return
+	}
+	accountScope, transactionScope, scopeArgs := scope.conditions(2)
+	args := append([]interface{}{tenant}, scopeArgs...)
 
 	// Assets
 	h.db.Get(&totalAssets, `
@@ -582,8 +1211,10 @@ func (h *AccountingHandler) GetAnalytics(w http.ResponseWriter, r *http.Request)
 		FROM chart_of_accounts coa
 		LEFT JOIN accounting_transaction_lines atl ON coa.id = atl.account_id
 		LEFT JOIN accounting_transactions at ON atl.transaction_id = at.id
-		WHERE coa.is_active = true AND coa.account_type = 'asset'
-	`)
+		WHERE coa.tenant_id = $1 AND coa.is_active = true AND coa.account_type = 'asset'
+		  AND (at.status IS NULL OR at.status IN ('posted', 'void'))
+		  AND `+accountScope+` AND (at.id IS NULL OR `+transactionScope+`)
+	`, args...)
 
 	// Liabilities
 	h.db.Get(&totalLiabilities, `
@@ -596,8 +1227,10 @@ func (h *AccountingHandler) GetAnalytics(w http.ResponseWriter, r *http.Request)
 		FROM chart_of_accounts coa
 		LEFT JOIN accounting_transaction_lines atl ON coa.id = atl.account_id
 		LEFT JOIN accounting_transactions at ON atl.transaction_id = at.id
-		WHERE coa.is_active = true AND coa.account_type = 'liability'
-	`)
+		WHERE coa.tenant_id = $1 AND coa.is_active = true AND coa.account_type = 'liability'
+		  AND (at.status IS NULL OR at.status IN ('posted', 'void'))
+		  AND `+accountScope+` AND (at.id IS NULL OR `+transactionScope+`)
+	`, args...)
 
 	// Equity
 	h.db.Get(&totalEquity, `
@@ -610,8 +1243,10 @@ func (h *AccountingHandler) GetAnalytics(w http.ResponseWriter, r *http.Request)
 		FROM chart_of_accounts coa
 		LEFT JOIN accounting_transaction_lines atl ON coa.id = atl.account_id
 		LEFT JOIN accounting_transactions at ON atl.transaction_id = at.id
-		WHERE coa.is_active = true AND coa.account_type = 'equity'
-	`)
+		WHERE coa.tenant_id = $1 AND coa.is_active = true AND coa.account_type = 'equity'
+		  AND (at.status IS NULL OR at.status IN ('posted', 'void'))
+		  AND `+accountScope+` AND (at.id IS NULL OR `+transactionScope+`)
+	`, args...)
 
 	// Revenue
 	h.db.Get(&totalRevenue, `
@@ -619,8 +1254,10 @@ func (h *AccountingHandler) GetAnalytics(w http.ResponseWriter, r *http.Request)
 		FROM chart_of_accounts coa
 		JOIN accounting_transaction_lines atl ON coa.id = atl.account_id
 		JOIN accounting_transactions at ON atl.transaction_id = at.id
-		WHERE coa.is_active = true AND coa.account_type = 'revenue' AND at.status = 'posted'
-	`)
+		WHERE coa.tenant_id = $1 AND coa.is_active = true AND coa.account_type = 'revenue' AND at.status IN ('posted', 'void')
+		  AND at.reference_type IS DISTINCT FROM 'year_end_close'
+		  AND `+accountScope+` AND `+transactionScope+`
+	`, args...)
 
 	// Expenses
 	h.db.Get(&totalExpenses, `
@@ -628,8 +1265,10 @@ func (h *AccountingHandler) GetAnalytics(w http.ResponseWriter, r *http.Request)
 		FROM chart_of_accounts coa
 		JOIN accounting_transaction_lines atl ON coa.id = atl.account_id
 		JOIN accounting_transactions at ON atl.transaction_id = at.id
-		WHERE coa.is_active = true AND coa.account_type = 'expense' AND at.status = 'posted'
-	`)
+		WHERE coa.tenant_id = $1 AND coa.is_active = true AND coa.account_type = 'expense' AND at.status IN ('posted', 'void')
+		  AND at.reference_type IS DISTINCT FROM 'year_end_close'
+		  AND `+accountScope+` AND `+transactionScope+`
+	`, args...)
 
 	netIncome = totalRevenue - totalExpenses
 	grossProfit = totalRevenue
@@ -637,13 +1276,13 @@ func (h *AccountingHandler) GetAnalytics(w http.ResponseWriter, r *http.Request)
 
 	// Calculate ratios (simplified for demo)
 	if totalLiabilities != 0 {
-		currentRatio = totalAssets / totalLiabilities
-		quickRatio = totalAssets / totalLiabilities
-		debtToEquity = totalLiabilities / totalEquity
+		currentRatio = totalAssets.Float64() / totalLiabilities.Float64()
+		quickRatio = totalAssets.Float64() / totalLiabilities.Float64()
+		debtToEquity = totalLiabilities.Float64() / totalEquity.Float64()
 	}
 
 	if totalEquity != 0 {
-		returnOnEquity = netIncome / totalEquity
+		returnOnEquity = netIncome.Float64() / totalEquity.Float64()
 	}
 
 	analytics := map[string]interface{}{
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+)
+
+// Transactions and journal entries held for approval stay in
+// pending_approval, outside the ledger and the reports, until every step of
+// their approval chain is approved. Each step is decided by someone other
+// than the creator and other than the approvers of earlier steps. Rejecting
+// any step rejects the entry, which stays on file with its reason.
+
+// decodeApprovalDecision reads the optional reason of an approve or reject
+// request
+func decodeApprovalDecision(r *http.Request) (string, error) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return "", newBadRequestError("Invalid request body")
+		}
+	}
+	return strings.TrimSpace(req.Reason), nil
+}
+
+// checkApprover enforces segregation of duties: whoever created an entry
+// cannot approve or reject it
+func checkApprover(status string, createdBy, userID int) error {
+	if status != "pending_approval" {
+		return newBadRequestError("Only entries pending approval can be approved or rejected, status is %s", status)
+	}
+	if createdBy == userID {
+		return newBadRequestError("The creator of an entry cannot approve or reject it")
+	}
+	return nil
+}
+
+// decideApprovalStep records userID's decision on the current step of an
+// entry's approval chain and reports whether the chain is finished, either
+// because the last step was approved or because the entry was rejected.
+// Entries submitted before approval chains existed have no steps and are
+// decided in one go.
+func decideApprovalStep(tx *sqlx.Tx, tenant string, entityType string, entityID, userID int, approve bool, comment string) (bool, error) {
+	var steps []ApprovalStep
+	if err := tx.Select(&steps, `
+		SELECT * FROM accounting_approval_steps
+		WHERE tenant_id = $1 AND entity_type = $2 AND entity_id = $3
+		ORDER BY step_order
+		FOR UPDATE
+	`, tenant, entityType, entityID); err != nil {
+		return false, err
+	}
+
+	var current *ApprovalStep
+	for i := range steps {
+		step := &steps[i]
+		if step.Status == "pending" {
+			current = step
+			break
+		}
+		if step.DecidedBy != nil && *step.DecidedBy == userID {
+			return false, newBadRequestError("User %d already approved step %s of this entry", userID, step.StepName)
+		}
+	}
+	if current == nil {
+		return true, nil
+	}
+	if current.ApproverID != nil && *current.ApproverID != userID {
+		return false, newBadRequestError("Step %s must be decided by user %d", current.StepName, *current.ApproverID)
+	}
+
+	status := "rejected"
+	if approve {
+		status = "approved"
+	}
+	var note *string
+	if comment != "" {
+		note = &comment
+	}
+	if _, err := tx.Exec(`
+		UPDATE accounting_approval_steps
+		SET status = $1, decided_by = $2, decided_at = CURRENT_TIMESTAMP, comment = $3
+		WHERE id = $4 AND tenant_id = $5
+	`, status, userID, note, current.ID, tenant); err != nil {
+		return false, err
+	}
+
+	if !approve {
+		_, err := tx.Exec(`
+			UPDATE accounting_approval_steps SET status = 'cancelled'
+			WHERE tenant_id = $1 AND entity_type = $2 AND entity_id = $3 AND status = 'pending'
+		`, tenant, entityType, entityID)
+		return true, err
+	}
+
+	return current.ID == steps[len(steps)-1].ID, nil
+}
+
+// loadPendingTransaction locks a transaction and checks that userID may
+// decide on it
+func loadPendingTransaction(tx *sqlx.Tx, tenant string, id, userID int) (*AccountingTransaction, error) {
+	var txn AccountingTransaction
+	if err := tx.Get(&txn, transactionSelect+" WHERE id = $1 AND tenant_id = $2 FOR UPDATE", id, tenant); err != nil {
+		return nil, err
+	}
+	if err := checkApprover(txn.Status, txn.CreatedBy, userID); err != nil {
+		return nil, err
+	}
+	if err := checkPostingPeriod(tx, tenant, txn.TransactionDate); err != nil {
+		return nil, err
+	}
+	return &txn, nil
+}
+
+// ApproveTransaction posts a transaction that is pending approval
+func (h *AccountingHandler) ApproveTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid transaction ID")
+		return
+	}
+
+	comment, err := decodeApprovalDecision(r)
+	if err != nil {
+		h.writeError(w, err, "Failed to approve transaction")
+		return
+	}
+
+	userID := currentUserID(r)
+	tenant := tenantID(r)
+	var txn *AccountingTransaction
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadPendingTransaction(tx, tenant, id, userID)
+		if err != nil {
+			return err
+		}
+		txn = loaded
+
+		done, err := decideApprovalStep(tx, tenant, "transaction", id, userID, true, comment)
+		if err != nil || !done {
+			return err
+		}
+
+		now := time.Now()
+		txn.Status = "posted"
+		txn.ApprovedBy = &userID
+		txn.ApprovedAt = &now
+		if _, err := tx.Exec(`
+			UPDATE accounting_transactions
+			SET status = 'posted', approved_by = $1, approved_at = $2
+			WHERE id = $3 AND tenant_id = $4
+		`, userID, now, id, tenant); err != nil {
+			return err
+		}
+
+		return h.checkBudgets(tx, tenant, txn)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to approve transaction")
+		return
+	}
+
+	h.writeApprovalResult(w, tenant, "transaction", txn.ID, txn.Status, map[string]interface{}{"transaction": txn})
+}
+
+// RejectTransaction rejects a transaction that is pending approval
+func (h *AccountingHandler) RejectTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid transaction ID")
+		return
+	}
+
+	reason, err := decodeApprovalDecision(r)
+	if err != nil {
+		h.writeError(w, err, "Failed to reject transaction")
+		return
+	}
+	if reason == "" {
+		sdk.WriteBadRequest(w, "A rejection reason is required")
+		return
+	}
+
+	userID := currentUserID(r)
+	tenant := tenantID(r)
+	var txn *AccountingTransaction
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadPendingTransaction(tx, tenant, id, userID)
+		if err != nil {
+			return err
+		}
+		txn = loaded
+
+		if _, err := decideApprovalStep(tx, tenant, "transaction", id, userID, false, reason); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		txn.Status = "rejected"
+		txn.RejectedBy = &userID
+		txn.RejectedAt = &now
+		txn.RejectionReason = &reason
+		_, err = tx.Exec(`
+			UPDATE accounting_transactions
+			SET status = 'rejected', rejected_by = $1, rejected_at = $2, rejection_reason = $3
+			WHERE id = $4 AND tenant_id = $5
+		`, userID, now, reason, id, tenant)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to reject transaction")
+		return
+	}
+
+	h.writeApprovalResult(w, tenant, "transaction", txn.ID, txn.Status, map[string]interface{}{"transaction": txn})
+}
+
+// loadPendingJournalEntry locks a journal entry and checks that userID may
+// decide on it
+func loadPendingJournalEntry(tx *sqlx.Tx, tenant string, id, userID int) (*JournalEntry, error) {
+	var entry JournalEntry
+	if err := tx.Get(&entry,
+		"SELECT * FROM accounting_journal_entries WHERE id = $1 AND tenant_id = $2 FOR UPDATE", id, tenant); err != nil {
+		return nil, err
+	}
+	if err := checkApprover(entry.Status, entry.CreatedBy, userID); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ApproveJournalEntry approves a journal entry that is pending approval
+func (h *AccountingHandler) ApproveJournalEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid journal entry ID")
+		return
+	}
+
+	comment, err := decodeApprovalDecision(r)
+	if err != nil {
+		h.writeError(w, err, "Failed to approve journal entry")
+		return
+	}
+
+	userID := currentUserID(r)
+	tenant := tenantID(r)
+	var entry *JournalEntry
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadPendingJournalEntry(tx, tenant, id, userID)
+		if err != nil {
+			return err
+		}
+		entry = loaded
+
+		if err := checkPostingPeriod(tx, tenant, entry.EntryDate); err != nil {
+			return err
+		}
+
+		done, err := decideApprovalStep(tx, tenant, "journal_entry", id, userID, true, comment)
+		if err != nil || !done {
+			return err
+		}
+
+		now := time.Now()
+		entry.Status = "posted"
+		entry.ApprovedBy = &userID
+		entry.ApprovedAt = &now
+		if _, err := tx.Exec(`
+			UPDATE accounting_journal_entries
+			SET status = 'posted', approved_by = $1, approved_at = $2
+			WHERE id = $3 AND tenant_id = $4
+		`, userID, now, id, tenant); err != nil {
+			return err
+		}
+		return h.postJournalEntry(tx, tenant, entry)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to approve journal entry")
+		return
+	}
+
+	h.writeApprovalResult(w, tenant, "journal_entry", entry.ID, entry.Status, map[string]interface{}{"entry": entry})
+}
+
+// RejectJournalEntry rejects a journal entry that is pending approval
+func (h *AccountingHandler) RejectJournalEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid journal entry ID")
+		return
+	}
+
+	reason, err := decodeApprovalDecision(r)
+	if err != nil {
+		h.writeError(w, err, "Failed to reject journal entry")
+		return
+	}
+	if reason == "" {
+		sdk.WriteBadRequest(w, "A rejection reason is required")
+		return
+	}
+
+	userID := currentUserID(r)
+	tenant := tenantID(r)
+	var entry *JournalEntry
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadPendingJournalEntry(tx, tenant, id, userID)
+		if err != nil {
+			return err
+		}
+		entry = loaded
+
+		if _, err := decideApprovalStep(tx, tenant, "journal_entry", id, userID, false, reason); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		entry.Status = "rejected"
+		entry.RejectedBy = &userID
+		entry.RejectedAt = &now
+		entry.RejectionReason = &reason
+		_, err = tx.Exec(`
+			UPDATE accounting_journal_entries
+			SET status = 'rejected', rejected_by = $1, rejected_at = $2, rejection_reason = $3
+			WHERE id = $4 AND tenant_id = $5
+		`, userID, now, reason, id, tenant)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to reject journal entry")
+		return
+	}
+
+	h.writeApprovalResult(w, tenant, "journal_entry", entry.ID, entry.Status, map[string]interface{}{"entry": entry})
+}
+
+// writeApprovalResult responds to an approval decision with the entry and
+// its approval history
+func (h *AccountingHandler) writeApprovalResult(w http.ResponseWriter, tenant string, entityType string, entityID int, status string, result map[string]interface{}) {
+	steps, err := loadApprovalSteps(h.db, tenant, entityType, entityID)
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch approval history")
+		return
+	}
+
+	switch status {
+	case "posted":
+		result["message"] = "Approved and posted successfully"
+	case "rejected":
+		result["message"] = "Rejected successfully"
+	default:
+		result["message"] = "Approval step recorded, awaiting the next approver"
+	}
+	result["approvals"] = steps
+	sdk.WriteSuccess(w, result)
+}
+
+// GetTransactionApprovals retrieves the approval history of a transaction
+func (h *AccountingHandler) GetTransactionApprovals(w http.ResponseWriter, r *http.Request) {
+	h.getApprovals(w, r, "transaction")
+}
+
+// GetJournalEntryApprovals retrieves the approval history of a journal entry
+func (h *AccountingHandler) GetJournalEntryApprovals(w http.ResponseWriter, r *http.Request) {
+	h.getApprovals(w, r, "journal_entry")
+}
+
+func (h *AccountingHandler) getApprovals(w http.ResponseWriter, r *http.Request, entityType string) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid ID")
+		return
+	}
+
+	steps, err := loadApprovalSteps(h.db, tenantID(r), entityType, id)
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch approval history")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"approvals": steps,
+		"count":     len(steps),
+	})
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// Approval policies turn the single approval of an entry into a chain of
+// steps, e.g. a controller and then the CFO for entries touching equity. When
+// an entry is submitted the active policies are tried in priority order and
+// the first one that matches supplies the chain. Entries that match no policy
+// but are above the approval amount get a single approval step.
+
+const defaultApprovalStepName = "Approval"
+
+// approvalPolicyMatches reports whether a policy applies to an entry of
+// amount touching accounts, given as account ID to account type
+func approvalPolicyMatches(policy *ApprovalPolicy, entityType string, amount Money, accounts map[int]string) bool {
+	if policy.EntityType != "any" && policy.EntityType != entityType {
+		return false
+	}
+	if amount < policy.MinAmount {
+		return false
+	}
+	if policy.MaxAmount != nil && amount >= *policy.MaxAmount {
+		return false
+	}
+	if policy.AccountID != nil {
+		if _, ok := accounts[*policy.AccountID]; !ok {
+			return false
+		}
+	}
+	if policy.AccountType != nil {
+		found := false
+		for _, accountType := range accounts {
+			if accountType == *policy.AccountType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// approvalChain returns the approval steps an entry must go through, or none
+// when it can be posted straight away. required is set when the approval
+// settings hold the entry for approval regardless of policies.
+func approvalChain(q sqlx.Queryer, tenant string, entityType string, amount Money, accountIDs []int, required bool) ([]ApprovalStep, error) {
+	accounts := map[int]string{}
+	for _, accountID := range accountIDs {
+		if _, ok := accounts[accountID]; ok {
+			continue
+		}
+		var accountType string
+		err := sqlx.Get(q, &accountType,
+			"SELECT account_type FROM chart_of_accounts WHERE id = $1 AND tenant_id = $2", accountID, tenant)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, newBadRequestError("Account %d not found", accountID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		accounts[accountID] = accountType
+	}
+
+	policies, err := loadApprovalPolicies(q, tenant, "is_active = true AND entity_type IN ('any', $2)", entityType)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range policies {
+		policy := &policies[i]
+		if !approvalPolicyMatches(policy, entityType, amount, accounts) || len(policy.Steps) == 0 {
+			continue
+		}
+		var steps []ApprovalStep
+		for _, step := range policy.Steps {
+			steps = append(steps, ApprovalStep{
+				EntityType: entityType,
+				PolicyID:   &policy.ID,
+				StepOrder:  step.StepOrder,
+				StepName:   step.Name,
+				ApproverID: step.ApproverID,
+				Status:     "pending",
+			})
+		}
+		return steps, nil
+	}
+
+	if required {
+		return []ApprovalStep{{
+			EntityType: entityType,
+			StepOrder:  1,
+			StepName:   defaultApprovalStepName,
+			Status:     "pending",
+		}}, nil
+	}
+	return nil, nil
+}
+
+// createApprovalSteps stores the approval chain of a submitted entry
+func createApprovalSteps(tx *sqlx.Tx, tenant string, entityID int, steps []ApprovalStep) error {
+	for i := range steps {
+		step := &steps[i]
+		step.EntityID = entityID
+		step.TenantID = &tenant
+		err := tx.QueryRow(`
+			INSERT INTO accounting_approval_steps
+			(tenant_id, entity_type, entity_id, policy_id, step_order, step_name, approver_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, status, created_at
+		`, tenant, step.EntityType, step.EntityID, step.PolicyID, step.StepOrder, step.StepName, step.ApproverID).
+			Scan(&step.ID, &step.Status, &step.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadApprovalSteps fetches the approval history of an entry in step order
+func loadApprovalSteps(q sqlx.Queryer, tenant string, entityType string, entityID int) ([]ApprovalStep, error) {
+	steps := []ApprovalStep{}
+	err := sqlx.Select(q, &steps, `
+		SELECT * FROM accounting_approval_steps
+		WHERE tenant_id = $1 AND entity_type = $2 AND entity_id = $3
+		ORDER BY step_order
+	`, tenant, entityType, entityID)
+	return steps, err
+}
+
+// loadApprovalPolicies fetches the policies of a tenant matching where, with
+// their steps, in priority order. The tenant is argument $1 of where, so its
+// own arguments start at $2.
+func loadApprovalPolicies(q sqlx.Queryer, tenant string, where string, args ...interface{}) ([]ApprovalPolicy, error) {
+	var policies []ApprovalPolicy
+	if err := sqlx.Select(q, &policies, `
+		SELECT * FROM accounting_approval_policies
+		WHERE tenant_id = $1 AND `+where+`
+		ORDER BY priority, id
+	`, append([]interface{}{tenant}, args...)...); err != nil {
+		return nil, err
+	}
+
+	for i := range policies {
+		policies[i].Steps = []ApprovalPolicyStep{}
+		if err := sqlx.Select(q, &policies[i].Steps, `
+			SELECT * FROM accounting_approval_policy_steps
+			WHERE policy_id = $1 AND tenant_id = $2
+			ORDER BY step_order
+		`, policies[i].ID, tenant); err != nil {
+			return nil, err
+		}
+	}
+	return policies, nil
+}
+
+// validateApprovalPolicy checks a policy and fills in its defaults
+func validateApprovalPolicy(q sqlx.Queryer, tenant string, policy *ApprovalPolicy) error {
+	if policy.EntityType == "" {
+		policy.EntityType = "any"
+	}
+
+	if strings.TrimSpace(policy.Name) == "" {
+		return newBadRequestError("name is required")
+	}
+	if err := sdk.ValidateEnum("entity_type", policy.EntityType, []string{"any", "transaction", "journal_entry"}); err != nil {
+		return newBadRequestError("%s", err.Error())
+	}
+	if policy.AccountType != nil {
+		if err := sdk.ValidateEnum("account_type", *policy.AccountType, []string{"asset", "liability", "equity", "revenue", "expense"}); err != nil {
+			return newBadRequestError("%s", err.Error())
+		}
+	}
+	if policy.MinAmount < 0 {
+		return newBadRequestError("min_amount cannot be negative")
+	}
+	if policy.MaxAmount != nil && *policy.MaxAmount <= policy.MinAmount {
+		return newBadRequestError("max_amount must be greater than min_amount")
+	}
+	if len(policy.Steps) == 0 {
+		return newBadRequestError("At least one approval step is required")
+	}
+
+	for i := range policy.Steps {
+		step := &policy.Steps[i]
+		step.StepOrder = i + 1
+		if strings.TrimSpace(step.Name) == "" {
+			return newBadRequestError("Approval step %d requires a name", i+1)
+		}
+	}
+
+	if policy.AccountID != nil {
+		if err := checkTenantAccount(q, tenant, *policy.AccountID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveApprovalPolicySteps replaces the steps of a policy
+func saveApprovalPolicySteps(tx *sqlx.Tx, tenant string, policy *ApprovalPolicy) error {
+	if _, err := tx.Exec("DELETE FROM accounting_approval_policy_steps WHERE policy_id = $1 AND tenant_id = $2",
+		policy.ID, tenant); err != nil {
+		return err
+	}
+	for i := range policy.Steps {
+		step := &policy.Steps[i]
+		step.PolicyID = policy.ID
+		if err := tx.Get(step, `
+			INSERT INTO accounting_approval_policy_steps (tenant_id, policy_id, step_order, name, approver_id)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING *
+		`, tenant, policy.ID, step.StepOrder, step.Name, step.ApproverID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetApprovalPolicies retrieves approval policies with their steps
+func (h *AccountingHandler) GetApprovalPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := loadApprovalPolicies(h.db, tenantID(r), "1=1")
+	if err != nil {
+		h.logger.Error("Failed to fetch approval policies", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch approval policies")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"policies": policies,
+		"count":    len(policies),
+	})
+}
+
+// GetApprovalPolicy retrieves a single approval policy
+func (h *AccountingHandler) GetApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid approval policy ID")
+		return
+	}
+
+	policies, err := loadApprovalPolicies(h.db, tenantID(r), "id = $2", id)
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch approval policy")
+		return
+	}
+	if len(policies) == 0 {
+		sdk.WriteNotFound(w, "Approval policy not found")
+		return
+	}
+
+	sdk.WriteSuccess(w, policies[0])
+}
+
+// CreateApprovalPolicy creates an approval policy and its steps
+func (h *AccountingHandler) CreateApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy ApprovalPolicy
+	policy.IsActive = true
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	tenant := tenantID(r)
+	if err := validateApprovalPolicy(h.db, tenant, &policy); err != nil {
+		h.writeError(w, err, "Failed to create approval policy")
+		return
+	}
+
+	err := h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		steps := policy.Steps
+		if err := tx.Get(&policy, `
+			INSERT INTO accounting_approval_policies
+			(tenant_id, name, entity_type, min_amount, max_amount, account_type, account_id, priority, is_active)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING *
+		`, tenant, policy.Name, policy.EntityType, policy.MinAmount, policy.MaxAmount, policy.AccountType,
+			policy.AccountID, policy.Priority, policy.IsActive); err != nil {
+			return err
+		}
+		policy.Steps = steps
+		return saveApprovalPolicySteps(tx, tenant, &policy)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to create approval policy")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"policy":  policy,
+		"message": "Approval policy created successfully",
+	})
+}
+
+// UpdateApprovalPolicy replaces an approval policy and its steps. Entries
+// already submitted keep the chain they were submitted with.
+func (h *AccountingHandler) UpdateApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid approval policy ID")
+		return
+	}
+
+	var policy ApprovalPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	tenant := tenantID(r)
+	if err := validateApprovalPolicy(h.db, tenant, &policy); err != nil {
+		h.writeError(w, err, "Failed to update approval policy")
+		return
+	}
+
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		steps := policy.Steps
+		if err := tx.Get(&policy, `
+			UPDATE accounting_approval_policies
+			SET name = $1, entity_type = $2, min_amount = $3, max_amount = $4, account_type = $5,
+			    account_id = $6, priority = $7, is_active = $8
+			WHERE id = $9 AND tenant_id = $10
+			RETURNING *
+		`, policy.Name, policy.EntityType, policy.MinAmount, policy.MaxAmount, policy.AccountType,
+			policy.AccountID, policy.Priority, policy.IsActive, id, tenant); err != nil {
+			return err
+		}
+		policy.Steps = steps
+		return saveApprovalPolicySteps(tx, tenant, &policy)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to update approval policy")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"policy":  policy,
+		"message": "Approval policy updated successfully",
+	})
+}
+
+// DeleteApprovalPolicy deletes an approval policy. The history of entries
+// approved under it is kept.
+func (h *AccountingHandler) DeleteApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid approval policy ID")
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM accounting_approval_policies WHERE id = $1 AND tenant_id = $2", id, tenantID(r))
+	if err != nil {
+		h.logger.Error("Failed to delete approval policy", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to delete approval policy")
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		sdk.WriteNotFound(w, "Approval policy not found")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Approval policy deleted successfully"})
+}
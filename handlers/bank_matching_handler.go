@@ -0,0 +1,393 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+)
+
+// Auto-matching proposes ledger lines for the unmatched statement lines of a
+// reconciliation. A candidate must have exactly the statement amount and be
+// dated within the date window; it is then scored on date proximity and on
+// how well the statement description and reference agree with the
+// transaction description, number and reference ID.
+
+const (
+	defaultMatchDateWindow = 3
+	defaultAutoMatchScore  = 80
+)
+
+// textTokens splits text into lower-case words and numbers, dropping
+// single characters that carry no meaning on bank statements
+func textTokens(text string) map[string]bool {
+	tokens := map[string]bool{}
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(field) > 1 {
+			tokens[field] = true
+		}
+	}
+	return tokens
+}
+
+// textSimilarity returns the share of the smaller token set found in the
+// larger one, from 0 to 1
+func textSimilarity(a, b string) float64 {
+	ta, tb := textTokens(a), textTokens(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	if len(ta) > len(tb) {
+		ta, tb = tb, ta
+	}
+	common := 0
+	for token := range ta {
+		if tb[token] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta))
+}
+
+// scoreCandidate scores a ledger line with the statement amount as a match
+// for a statement line. The amount counts for 40 points, the date for up to
+// 30 and the text for up to 30.
+func scoreCandidate(line BankStatementLine, ledger LedgerLine, window int) MatchCandidate {
+	candidate := MatchCandidate{LedgerLine: ledger, Score: 40, Reasons: []string{"exact amount"}}
+
+	days := int(line.TransactionDate.Sub(ledger.TransactionDate).Hours() / 24)
+	if days < 0 {
+		days = -days
+	}
+	if days == 0 {
+		candidate.Reasons = append(candidate.Reasons, "same date")
+	} else {
+		candidate.Reasons = append(candidate.Reasons, fmt.Sprintf("%d days apart", days))
+	}
+	candidate.Score += 30 * float64(window+1-days) / float64(window+1)
+
+	var statementText string
+	if line.Description != nil {
+		statementText = *line.Description
+	}
+	if line.Reference != nil {
+		statementText += " " + *line.Reference
+	}
+	statementTokens := textTokens(statementText)
+
+	var text float64
+	if ledger.ReferenceID != nil && statementTokens[strconv.Itoa(*ledger.ReferenceID)] {
+		text = 1
+		candidate.Reasons = append(candidate.Reasons, "reference matches")
+	} else if statementTokens[strings.ToLower(ledger.TransactionNumber)] ||
+		strings.Contains(strings.ToLower(statementText), strings.ToLower(ledger.TransactionNumber)) {
+		text = 1
+		candidate.Reasons = append(candidate.Reasons, "transaction number matches")
+	} else if ledger.Description != nil {
+		text = textSimilarity(statementText, *ledger.Description)
+		if text > 0 {
+			candidate.Reasons = append(candidate.Reasons, fmt.Sprintf("description %.0f%% similar", text*100))
+		}
+	}
+	candidate.Score += 30 * text
+
+	candidate.Score = roundPercent(candidate.Score)
+	return candidate
+}
+
+// buildMatchSuggestions proposes matches for the unmatched statement lines of
+// a reconciliation, best candidate first
+func buildMatchSuggestions(q sqlx.Queryer, tenant string, rec *Reconciliation, window int) ([]MatchSuggestion, error) {
+	var lines []BankStatementLine
+	if err := sqlx.Select(q, &lines, `
+		SELECT * FROM accounting_bank_statement_lines
+		WHERE tenant_id = $1 AND reconciliation_id = $2 AND status = 'unmatched'
+		ORDER BY transaction_date, id
+	`, tenant, rec.ID); err != nil {
+		return nil, err
+	}
+
+	rules, err := loadBankRules(q, tenant, rec.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []MatchSuggestion{}
+	for _, line := range lines {
+		var ledgerLines []LedgerLine
+		if err := sqlx.Select(q, &ledgerLines, ledgerLineSelect+`
+			WHERE atl.tenant_id = $1 AND atl.account_id = $2 AND atl.reconciliation_id IS NULL
+			  AND at.status IN ('posted', 'void')
+			  AND atl.debit_amount - atl.credit_amount = $3
+			  AND at.transaction_date BETWEEN $4::date - $5::int AND LEAST($4::date + $5::int, $6::date)
+			ORDER BY at.transaction_date, atl.id
+		`, tenant, rec.AccountID, line.Amount, line.TransactionDate, window, rec.StatementDate); err != nil {
+			return nil, err
+		}
+
+		suggestion := MatchSuggestion{StatementLine: line, Candidates: []MatchCandidate{}}
+		for _, ledger := range ledgerLines {
+			suggestion.Candidates = append(suggestion.Candidates, scoreCandidate(line, ledger, window))
+		}
+		sort.SliceStable(suggestion.Candidates, func(i, j int) bool {
+			return suggestion.Candidates[i].Score > suggestion.Candidates[j].Score
+		})
+
+		if len(suggestion.Candidates) == 0 {
+			suggestion.Rule = matchBankRule(rules, line)
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, nil
+}
+
+// dateWindow reads the date_window_days query parameter
+func dateWindow(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("date_window_days")
+	if value == "" {
+		return defaultMatchDateWindow, nil
+	}
+	window, err := strconv.Atoi(value)
+	if err != nil || window < 0 {
+		return 0, newBadRequestError("date_window_days must be a non-negative number")
+	}
+	return window, nil
+}
+
+// GetMatchSuggestions proposes ledger matches and bank rules for the
+// unmatched statement lines of a reconciliation
+func (h *AccountingHandler) GetMatchSuggestions(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid reconciliation ID")
+		return
+	}
+
+	window, err := dateWindow(r)
+	if err != nil {
+		h.writeError(w, err, "Failed to suggest matches")
+		return
+	}
+
+	tenant := tenantID(r)
+	rec, err := loadReconciliation(h.db, tenant, id, false)
+	if err != nil {
+		h.writeError(w, err, "Failed to suggest matches")
+		return
+	}
+
+	suggestions, err := buildMatchSuggestions(h.db, tenant, rec, window)
+	if err != nil {
+		h.writeError(w, err, "Failed to suggest matches")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"suggestions": suggestions,
+		"count":       len(suggestions),
+	})
+}
+
+// AutoMatchReconciliation applies the suggestions that score at least
+// min_score and are unambiguous: the best candidate of a statement line must
+// score higher than the next one, and a ledger line is used only once.
+func (h *AccountingHandler) AutoMatchReconciliation(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid reconciliation ID")
+		return
+	}
+
+	var req struct {
+		DateWindowDays *int     `json:"date_window_days"`
+		MinScore       *float64 `json:"min_score"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sdk.WriteBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	window := defaultMatchDateWindow
+	if req.DateWindowDays != nil {
+		if *req.DateWindowDays < 0 {
+			sdk.WriteBadRequest(w, "date_window_days must be a non-negative number")
+			return
+		}
+		window = *req.DateWindowDays
+	}
+	minScore := float64(defaultAutoMatchScore)
+	if req.MinScore != nil {
+		minScore = *req.MinScore
+	}
+
+	var matches []ReconciliationMatch
+	var rec *Reconciliation
+	tenant := tenantID(r)
+	userID := currentUserID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadOpenReconciliation(tx, tenant, id)
+		if err != nil {
+			return err
+		}
+		rec = loaded
+
+		suggestions, err := buildMatchSuggestions(tx, tenant, rec, window)
+		if err != nil {
+			return err
+		}
+
+		// Strongest matches are applied first so a ledger line shared by
+		// several statement lines goes to the best one
+		sort.SliceStable(suggestions, func(i, j int) bool {
+			return topScore(suggestions[i]) > topScore(suggestions[j])
+		})
+
+		used := map[int]bool{}
+		for _, suggestion := range suggestions {
+			var unused []MatchCandidate
+			for _, candidate := range suggestion.Candidates {
+				if !used[candidate.ID] {
+					unused = append(unused, candidate)
+				}
+			}
+			if len(unused) == 0 || unused[0].Score < minScore {
+				continue
+			}
+			if len(unused) > 1 && unused[1].Score == unused[0].Score {
+				continue
+			}
+			best := unused[0]
+
+			match, err := h.matchReconciliationLines(tx, tenant, rec, []int{suggestion.StatementLine.ID}, []int{best.ID},
+				userID)
+			if err != nil {
+				return err
+			}
+			used[best.ID] = true
+			matches = append(matches, match)
+		}
+
+		return refreshReconciliationBalances(tx, tenant, rec)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to auto-match reconciliation")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"matches":        matches,
+		"count":          len(matches),
+		"reconciliation": rec,
+		"message":        "Auto-match completed successfully",
+	})
+}
+
+func topScore(suggestion MatchSuggestion) float64 {
+	if len(suggestion.Candidates) == 0 {
+		return 0
+	}
+	return suggestion.Candidates[0].Score
+}
+
+// ApplyBankRules creates a draft transaction for every unmatched statement
+// line of a reconciliation that has no ledger candidate and matches a bank
+// rule. Drafts do not affect the ledger until they are reviewed and posted.
+func (h *AccountingHandler) ApplyBankRules(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid reconciliation ID")
+		return
+	}
+
+	window, err := dateWindow(r)
+	if err != nil {
+		h.writeError(w, err, "Failed to apply bank rules")
+		return
+	}
+
+	var drafts []AccountingTransaction
+	tenant := tenantID(r)
+	userID := currentUserID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		rec, err := loadOpenReconciliation(tx, tenant, id)
+		if err != nil {
+			return err
+		}
+
+		suggestions, err := buildMatchSuggestions(tx, tenant, rec, window)
+		if err != nil {
+			return err
+		}
+
+		referenceType := "bank_statement_line"
+		for _, suggestion := range suggestions {
+			line := suggestion.StatementLine
+			if suggestion.Rule == nil || line.DraftTransactionID != nil {
+				continue
+			}
+
+			description := suggestion.Rule.Description
+			if description == nil {
+				description = line.Description
+			}
+
+			amount := line.Amount
+			bankLine := AccountingTransactionLine{AccountID: rec.AccountID, Description: description}
+			targetLine := AccountingTransactionLine{AccountID: suggestion.Rule.TargetAccountID, Description: description}
+			if amount > 0 {
+				bankLine.DebitAmount = amount
+				targetLine.CreditAmount = amount
+			} else {
+				bankLine.CreditAmount = -amount
+				targetLine.DebitAmount = -amount
+			}
+
+			lineID := line.ID
+			draft := AccountingTransaction{
+				TransactionDate: line.TransactionDate,
+				ReferenceType:   &referenceType,
+				ReferenceID:     &lineID,
+				Description:     description,
+				Status:          "draft",
+				CreatedBy:       userID,
+				Lines:           []AccountingTransactionLine{bankLine, targetLine},
+			}
+			if err := h.postTransaction(tx, tenant, &draft); err != nil {
+				return err
+			}
+
+			if _, err := tx.Exec(`
+				UPDATE accounting_bank_statement_lines SET rule_id = $1, draft_transaction_id = $2
+				WHERE id = $3 AND tenant_id = $4
+			`, suggestion.Rule.ID, draft.ID, line.ID, tenant); err != nil {
+				return err
+			}
+			drafts = append(drafts, draft)
+		}
+		return nil
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to apply bank rules")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"transactions": drafts,
+		"count":        len(drafts),
+		"message":      "Draft transactions created for review",
+	})
+}
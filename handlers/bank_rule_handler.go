@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// loadBankRules fetches the active rules that apply to a bank account in
+// priority order
+func loadBankRules(q sqlx.Queryer, tenant string, accountID int) ([]BankRule, error) {
+	var rules []BankRule
+	err := sqlx.Select(q, &rules, `
+		SELECT * FROM accounting_bank_rules
+		WHERE tenant_id = $1 AND is_active = true AND (account_id IS NULL OR account_id = $2)
+		ORDER BY priority, id
+	`, tenant, accountID)
+	return rules, err
+}
+
+// matchBankRule returns the first rule that matches a statement line
+func matchBankRule(rules []BankRule, line BankStatementLine) *BankRule {
+	for i := range rules {
+		if bankRuleMatches(&rules[i], line) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// bankRuleMatches reports whether a statement line satisfies a rule. Text is
+// compared case-insensitively.
+func bankRuleMatches(rule *BankRule, line BankStatementLine) bool {
+	switch rule.Direction {
+	case "deposit":
+		if line.Amount <= 0 {
+			return false
+		}
+	case "withdrawal":
+		if line.Amount >= 0 {
+			return false
+		}
+	}
+
+	amount := line.Amount
+	if amount < 0 {
+		amount = -amount
+	}
+	if rule.AmountMin != nil && amount < *rule.AmountMin {
+		return false
+	}
+	if rule.AmountMax != nil && amount > *rule.AmountMax {
+		return false
+	}
+
+	var texts []string
+	if (rule.MatchField == "description" || rule.MatchField == "any") && line.Description != nil {
+		texts = append(texts, *line.Description)
+	}
+	if (rule.MatchField == "reference" || rule.MatchField == "any") && line.Reference != nil {
+		texts = append(texts, *line.Reference)
+	}
+
+	pattern := strings.ToLower(rule.Pattern)
+	for _, text := range texts {
+		text = strings.ToLower(strings.TrimSpace(text))
+		switch rule.MatchType {
+		case "contains":
+			if strings.Contains(text, pattern) {
+				return true
+			}
+		case "equals":
+			if text == pattern {
+				return true
+			}
+		case "starts_with":
+			if strings.HasPrefix(text, pattern) {
+				return true
+			}
+		case "regex":
+			if re, err := regexp.Compile("(?i)" + rule.Pattern); err == nil && re.MatchString(text) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// validateBankRule checks a rule and fills in its defaults
+func validateBankRule(q sqlx.Queryer, tenant string, rule *BankRule) error {
+	if rule.MatchField == "" {
+		rule.MatchField = "description"
+	}
+	if rule.MatchType == "" {
+		rule.MatchType = "contains"
+	}
+	if rule.Direction == "" {
+		rule.Direction = "any"
+	}
+
+	if err := sdk.ValidateRequired(map[string]interface{}{
+		"name":              rule.Name,
+		"pattern":           rule.Pattern,
+		"target_account_id": rule.TargetAccountID,
+	}); err != nil {
+		return newBadRequestError("%s", err.Error())
+	}
+	if err := sdk.ValidateEnum("match_field", rule.MatchField, []string{"description", "reference", "any"}); err != nil {
+		return newBadRequestError("%s", err.Error())
+	}
+	if err := sdk.ValidateEnum("match_type", rule.MatchType, []string{"contains", "equals", "starts_with", "regex"}); err != nil {
+		return newBadRequestError("%s", err.Error())
+	}
+	if err := sdk.ValidateEnum("direction", rule.Direction, []string{"any", "deposit", "withdrawal"}); err != nil {
+		return newBadRequestError("%s", err.Error())
+	}
+	if rule.MatchType == "regex" {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return newBadRequestError("Invalid regex pattern: %v", err)
+		}
+	}
+	if rule.AmountMin != nil && rule.AmountMax != nil && *rule.AmountMin > *rule.AmountMax {
+		return newBadRequestError("amount_min cannot exceed amount_max")
+	}
+
+	if rule.AccountID != nil {
+		if err := requireBankAccount(q, tenant, *rule.AccountID); err != nil {
+			return err
+		}
+	}
+
+	var exists bool
+	if err := sqlx.Get(q, &exists, `
+		SELECT EXISTS(SELECT 1 FROM chart_of_accounts WHERE id = $1 AND tenant_id = $2 AND is_active = true)
+	`, rule.TargetAccountID, tenant); err != nil {
+		return err
+	}
+	if !exists {
+		return newBadRequestError("Target account %d not found", rule.TargetAccountID)
+	}
+
+	return nil
+}
+
+// GetBankRules retrieves bank rules
+func (h *AccountingHandler) GetBankRules(w http.ResponseWriter, r *http.Request) {
+	accountID := r.URL.Query().Get("account_id")
+	isActive := r.URL.Query().Get("is_active")
+
+	qb := sdk.NewQueryBuilder("SELECT * FROM accounting_bank_rules WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition("account_id = $%d", accountID)
+	qb.AddOptionalCondition("is_active = $%d", isActive)
+
+	query, args := qb.Build()
+	query += " ORDER BY priority, id"
+
+	var rules []BankRule
+	if err := h.db.Select(&rules, query, args...); err != nil {
+		h.logger.Error("Failed to fetch bank rules", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch bank rules")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// CreateBankRule creates a bank rule
+func (h *AccountingHandler) CreateBankRule(w http.ResponseWriter, r *http.Request) {
+	var rule BankRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	tenant := tenantID(r)
+	if err := validateBankRule(h.db, tenant, &rule); err != nil {
+		h.writeError(w, err, "Failed to create bank rule")
+		return
+	}
+
+	err := h.db.Get(&rule, `
+		INSERT INTO accounting_bank_rules
+		(tenant_id, name, account_id, match_field, match_type, pattern, direction, amount_min, amount_max,
+		 target_account_id, description, priority)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING *
+	`, tenant, rule.Name, rule.AccountID, rule.MatchField, rule.MatchType, rule.Pattern, rule.Direction,
+		rule.AmountMin, rule.AmountMax, rule.TargetAccountID, rule.Description, rule.Priority)
+	if err != nil {
+		h.logger.Error("Failed to create bank rule", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to create bank rule")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"rule":    rule,
+		"message": "Bank rule created successfully",
+	})
+}
+
+// UpdateBankRule replaces a bank rule. The rule keeps its active state when
+// is_active is left out.
+func (h *AccountingHandler) UpdateBankRule(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid bank rule ID")
+		return
+	}
+
+	var req struct {
+		BankRule
+		IsActive *bool `json:"is_active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+	rule := req.BankRule
+
+	tenant := tenantID(r)
+	if err := validateBankRule(h.db, tenant, &rule); err != nil {
+		h.writeError(w, err, "Failed to update bank rule")
+		return
+	}
+
+	err = h.db.Get(&rule, `
+		UPDATE accounting_bank_rules
+		SET name = $1, account_id = $2, match_field = $3, match_type = $4, pattern = $5, direction = $6,
+		    amount_min = $7, amount_max = $8, target_account_id = $9, description = $10, priority = $11,
+		    is_active = COALESCE($12, is_active)
+		WHERE id = $13 AND tenant_id = $14
+		RETURNING *
+	`, rule.Name, rule.AccountID, rule.MatchField, rule.MatchType, rule.Pattern, rule.Direction,
+		rule.AmountMin, rule.AmountMax, rule.TargetAccountID, rule.Description, rule.Priority, req.IsActive, id, tenant)
+	if err != nil {
+		h.writeError(w, err, "Failed to update bank rule")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"rule":    rule,
+		"message": "Bank rule updated successfully",
+	})
+}
+
+// DeleteBankRule deletes a bank rule
+func (h *AccountingHandler) DeleteBankRule(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid bank rule ID")
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM accounting_bank_rules WHERE id = $1 AND tenant_id = $2", id, tenantID(r))
+	if err != nil {
+		h.logger.Error("Failed to delete bank rule", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to delete bank rule")
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		sdk.WriteNotFound(w, "Bank rule not found")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Bank rule deleted successfully"})
+}
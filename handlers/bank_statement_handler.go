@@ -0,0 +1,372 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// Bank statements are imported from CSV, OFX/QFX or camt.053 files into
+// statement lines of a cash account. Every line carries the bank's reference
+// for it (the OFX FITID, the camt.053 servicer reference, or a hash of the
+// line when the file has none) and lines already imported for the account
+// are skipped, so importing an overlapping file is safe.
+
+// GetBankImportProfiles retrieves the saved CSV column mappings
+func (h *AccountingHandler) GetBankImportProfiles(w http.ResponseWriter, r *http.Request) {
+	accountID := r.URL.Query().Get("account_id")
+
+	qb := sdk.NewQueryBuilder("SELECT * FROM accounting_bank_import_profiles WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition(
+		"account_id IN (SELECT id FROM chart_of_accounts WHERE company_id IS NULL OR company_id = $%d)", companyID(r))
+	qb.AddOptionalCondition("account_id = $%d", accountID)
+
+	query, args := qb.Build()
+	query += " ORDER BY name"
+
+	var profiles []BankImportProfile
+	if err := h.db.Select(&profiles, query, args...); err != nil {
+		h.logger.Error("Failed to fetch import profiles", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch import profiles")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"profiles": profiles,
+		"count":    len(profiles),
+	})
+}
+
+// validateImportProfile checks a CSV mapping and fills in its defaults
+func validateImportProfile(profile *BankImportProfile) error {
+	if profile.Delimiter == "" {
+		profile.Delimiter = ","
+	}
+	if profile.DecimalSeparator == "" {
+		profile.DecimalSeparator = "."
+	}
+	if profile.DateFormat == "" {
+		profile.DateFormat = "YYYY-MM-DD"
+	}
+	if len([]rune(profile.Delimiter)) != 1 {
+		return newBadRequestError("delimiter must be a single character")
+	}
+	if err := sdk.ValidateEnum("decimal_separator", profile.DecimalSeparator, []string{".", ","}); err != nil {
+		return newBadRequestError("%s", err.Error())
+	}
+	if profile.DateColumn == "" {
+		return newBadRequestError("date_column is required")
+	}
+	hasAmount := profile.AmountColumn != nil && *profile.AmountColumn != ""
+	hasDebitCredit := profile.DebitColumn != nil && *profile.DebitColumn != "" &&
+		profile.CreditColumn != nil && *profile.CreditColumn != ""
+	if !hasAmount && !hasDebitCredit {
+		return newBadRequestError("Either amount_column or both debit_column and credit_column are required")
+	}
+	return nil
+}
+
+// insertImportProfile saves a CSV column mapping of a tenant
+func insertImportProfile(q sqlx.Queryer, tenant string, profile *BankImportProfile) error {
+	if profile.AccountID != nil {
+		if err := requireBankAccount(q, tenant, *profile.AccountID); err != nil {
+			return err
+		}
+	}
+
+	return sqlx.Get(q, profile, `
+		INSERT INTO accounting_bank_import_profiles
+		(tenant_id, name, account_id, delimiter, has_header, skip_rows, date_column, date_format, description_column,
+		 reference_column, amount_column, debit_column, credit_column, decimal_separator, negate_amounts)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING *
+	`, tenant, profile.Name, profile.AccountID, profile.Delimiter, profile.HasHeader, profile.SkipRows, profile.DateColumn,
+		profile.DateFormat, profile.DescriptionColumn, profile.ReferenceColumn, profile.AmountColumn,
+		profile.DebitColumn, profile.CreditColumn, profile.DecimalSeparator, profile.NegateAmounts)
+}
+
+// CreateBankImportProfile saves a CSV column mapping for reuse
+func (h *AccountingHandler) CreateBankImportProfile(w http.ResponseWriter, r *http.Request) {
+	var profile BankImportProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+	if profile.Name == "" {
+		sdk.WriteBadRequest(w, "name is required")
+		return
+	}
+	if err := validateImportProfile(&profile); err != nil {
+		h.writeError(w, err, "Failed to create import profile")
+		return
+	}
+
+	if err := insertImportProfile(h.db, tenantID(r), &profile); err != nil {
+		h.writeError(w, err, "Failed to create import profile")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"profile": profile,
+		"message": "Import profile created successfully",
+	})
+}
+
+// DeleteBankImportProfile deletes a saved CSV column mapping
+func (h *AccountingHandler) DeleteBankImportProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid profile ID")
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM accounting_bank_import_profiles WHERE id = $1 AND tenant_id = $2", id, tenantID(r))
+	if err != nil {
+		h.logger.Error("Failed to delete import profile", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to delete import profile")
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		sdk.WriteNotFound(w, "Import profile not found")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Import profile deleted successfully"})
+}
+
+// GetBankStatementImports retrieves the history of statement imports
+func (h *AccountingHandler) GetBankStatementImports(w http.ResponseWriter, r *http.Request) {
+	accountID := r.URL.Query().Get("account_id")
+
+	qb := sdk.NewQueryBuilder("SELECT * FROM accounting_bank_statement_imports WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition(
+		"account_id IN (SELECT id FROM chart_of_accounts WHERE company_id IS NULL OR company_id = $%d)", companyID(r))
+	qb.AddOptionalCondition("account_id = $%d", accountID)
+
+	query, args := qb.Build()
+	query += " ORDER BY created_at DESC"
+
+	var imports []BankStatementImport
+	if err := h.db.Select(&imports, query, args...); err != nil {
+		h.logger.Error("Failed to fetch statement imports", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch statement imports")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"imports": imports,
+		"count":   len(imports),
+	})
+}
+
+// GetBankStatementLines retrieves statement lines
+func (h *AccountingHandler) GetBankStatementLines(w http.ResponseWriter, r *http.Request) {
+	accountID := r.URL.Query().Get("account_id")
+	status := r.URL.Query().Get("status")
+	importID := r.URL.Query().Get("import_id")
+
+	qb := sdk.NewQueryBuilder("SELECT * FROM accounting_bank_statement_lines WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition(
+		"account_id IN (SELECT id FROM chart_of_accounts WHERE company_id IS NULL OR company_id = $%d)", companyID(r))
+	qb.AddOptionalCondition("account_id = $%d", accountID)
+	qb.AddOptionalCondition("status = $%d", status)
+	qb.AddOptionalCondition("import_id = $%d", importID)
+
+	query, args := qb.Build()
+	query += " ORDER BY transaction_date DESC, id DESC"
+
+	var lines []BankStatementLine
+	if err := h.db.Select(&lines, query, args...); err != nil {
+		h.logger.Error("Failed to fetch statement lines", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch statement lines")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"lines": lines,
+		"count": len(lines),
+	})
+}
+
+// ImportBankStatement parses a statement file and records its lines for a
+// cash account. A CSV file is read with a saved profile (profile_id) or an
+// inline mapping (profile), which is saved when save_profile is set. The
+// content is the file text, or base64 when encoding is "base64".
+func (h *AccountingHandler) ImportBankStatement(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AccountID   int                `json:"account_id"`
+		Format      string             `json:"format"`
+		FileName    *string            `json:"file_name"`
+		Content     string             `json:"content"`
+		Encoding    string             `json:"encoding"`
+		ProfileID   *int               `json:"profile_id"`
+		Profile     *BankImportProfile `json:"profile"`
+		SaveProfile bool               `json:"save_profile"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if err := sdk.ValidateRequired(map[string]interface{}{
+		"account_id": req.AccountID,
+		"format":     req.Format,
+		"content":    req.Content,
+	}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	req.Format = strings.ToLower(req.Format)
+	if err := sdk.ValidateEnum("format", req.Format, []string{"csv", "ofx", "qfx", "camt053"}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	content := []byte(req.Content)
+	if req.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(req.Content)
+		if err != nil {
+			sdk.WriteBadRequest(w, "Content is not valid base64")
+			return
+		}
+		content = decoded
+	}
+
+	var statementImport BankStatementImport
+	var lines []BankStatementLine
+	tenant := tenantID(r)
+	err := h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		if err := requireBankAccount(tx, tenant, req.AccountID); err != nil {
+			return err
+		}
+
+		var profile *BankImportProfile
+		if req.Format == "csv" {
+			switch {
+			case req.ProfileID != nil:
+				profile = &BankImportProfile{}
+				if err := tx.Get(profile, "SELECT * FROM accounting_bank_import_profiles WHERE id = $1 AND tenant_id = $2",
+					*req.ProfileID, tenant); err != nil {
+					return newBadRequestError("Import profile %d not found", *req.ProfileID)
+				}
+			case req.Profile != nil:
+				profile = req.Profile
+				if err := validateImportProfile(profile); err != nil {
+					return err
+				}
+				if req.SaveProfile {
+					if profile.Name == "" {
+						return newBadRequestError("A profile name is required to save the profile")
+					}
+					if profile.AccountID == nil {
+						profile.AccountID = &req.AccountID
+					}
+					if err := insertImportProfile(tx, tenant, profile); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		parsed, err := parseStatement(req.Format, content, profile)
+		if err != nil {
+			return err
+		}
+
+		statementImport = BankStatementImport{
+			AccountID: req.AccountID,
+			Format:    req.Format,
+			FileName:  req.FileName,
+			CreatedBy: currentUserID(r),
+		}
+		if profile != nil && profile.ID != 0 {
+			statementImport.ProfileID = &profile.ID
+		}
+
+		err = tx.QueryRow(`
+			INSERT INTO accounting_bank_statement_imports
+			(tenant_id, account_id, format, file_name, profile_id, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at
+		`, tenant, statementImport.AccountID, statementImport.Format, statementImport.FileName,
+			statementImport.ProfileID, statementImport.CreatedBy).Scan(&statementImport.ID, &statementImport.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		// Serialize imports into the same account so concurrent imports of
+		// overlapping files cannot both insert a line
+		if _, err := tx.Exec("SELECT id FROM chart_of_accounts WHERE id = $1 AND tenant_id = $2 FOR UPDATE",
+			req.AccountID, tenant); err != nil {
+			return err
+		}
+
+		for _, p := range parsed {
+			var exists bool
+			if err := tx.Get(&exists, `
+				SELECT EXISTS(SELECT 1 FROM accounting_bank_statement_lines
+				              WHERE tenant_id = $1 AND account_id = $2 AND bank_reference = $3)
+			`, tenant, req.AccountID, p.BankReference); err != nil {
+				return err
+			}
+			if exists {
+				statementImport.LinesSkipped++
+				continue
+			}
+
+			var line BankStatementLine
+			err := tx.Get(&line, `
+				INSERT INTO accounting_bank_statement_lines
+				(tenant_id, account_id, import_id, transaction_date, description, reference, bank_reference, amount)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				RETURNING *
+			`, tenant, req.AccountID, statementImport.ID, p.TransactionDate, p.Description, p.Reference, p.BankReference, p.Amount)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, line)
+			statementImport.LinesImported++
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE accounting_bank_statement_imports SET lines_imported = $1, lines_skipped = $2
+			WHERE id = $3 AND tenant_id = $4
+		`, statementImport.LinesImported, statementImport.LinesSkipped, statementImport.ID, tenant); err != nil {
+			return err
+		}
+
+		// Lines dated within a reconciliation in progress join it directly
+		var rec Reconciliation
+		err = tx.Get(&rec, reconciliationSelect+" WHERE r.tenant_id = $1 AND r.account_id = $2 AND r.status = 'in_progress'",
+			tenant, req.AccountID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return attachStatementLines(tx, tenant, &rec)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to import bank statement")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"import":  statementImport,
+		"lines":   lines,
+		"message": "Bank statement imported successfully",
+	})
+}
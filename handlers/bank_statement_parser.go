@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parsedStatementLine is a statement line read from an imported file. The
+// bank reference identifies the line at the bank and is used to skip lines
+// that were already imported.
+type parsedStatementLine struct {
+	TransactionDate time.Time
+	Description     *string
+	Reference       *string
+	BankReference   string
+	Amount          Money
+}
+
+// parseStatement parses an imported statement in the given format
+func parseStatement(format string, content []byte, profile *BankImportProfile) ([]parsedStatementLine, error) {
+	switch format {
+	case "csv":
+		if profile == nil {
+			return nil, newBadRequestError("A CSV import requires a column mapping profile")
+		}
+		return parseCSVStatement(content, profile)
+	case "ofx", "qfx":
+		return parseOFXStatement(content)
+	case "camt053":
+		return parseCAMTStatement(content)
+	default:
+		return nil, newBadRequestError("Unsupported statement format: %s", format)
+	}
+}
+
+// csvDateLayout converts a date format such as DD/MM/YYYY to a Go layout.
+// Formats that are already Go layouts are returned unchanged.
+func csvDateLayout(format string) string {
+	if format == "" {
+		return "2006-01-02"
+	}
+	return strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02").Replace(format)
+}
+
+// parseStatementAmount parses an amount as printed by banks: currency
+// symbols, spaces and thousands separators are ignored and parentheses or a
+// trailing minus mark a negative amount
+func parseStatementAmount(value, decimalSeparator string) (Money, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	negative := false
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = true
+		value = value[1 : len(value)-1]
+	}
+	if strings.HasSuffix(value, "-") {
+		negative = true
+		value = strings.TrimSuffix(value, "-")
+	}
+
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+	thousands := ","
+	if decimalSeparator == "," {
+		thousands = "."
+	}
+
+	var b strings.Builder
+	for _, c := range value {
+		switch {
+		case c >= '0' && c <= '9', c == '-', c == '+':
+			b.WriteRune(c)
+		case string(c) == decimalSeparator:
+			b.WriteRune('.')
+		case string(c) == thousands:
+		}
+	}
+
+	amount, err := ParseMoney(b.String())
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// fallbackBankReference identifies a statement line without a bank reference
+// by its content. The occurrence number keeps identical lines within one
+// file apart while still matching the same lines in an overlapping file.
+func fallbackBankReference(line parsedStatementLine, occurrence int) string {
+	description := ""
+	if line.Description != nil {
+		description = *line.Description
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%s|%d",
+		line.TransactionDate.Format("2006-01-02"), line.Amount.Format(2), description, occurrence)))
+	return "hash:" + hex.EncodeToString(sum[:])
+}
+
+// assignFallbackReferences fills in the bank reference of lines without one
+func assignFallbackReferences(lines []parsedStatementLine) {
+	seen := map[string]int{}
+	for i := range lines {
+		if lines[i].BankReference != "" {
+			continue
+		}
+		key := fallbackBankReference(lines[i], 0)
+		seen[key]++
+		lines[i].BankReference = fallbackBankReference(lines[i], seen[key])
+	}
+}
+
+func optionalString(value string) *string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// parseCSVStatement parses a CSV statement using a column mapping profile.
+// Columns are referenced by header name, or by 1-based position when the
+// file has no header row.
+func parseCSVStatement(content []byte, profile *BankImportProfile) ([]parsedStatementLine, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if profile.Delimiter != "" {
+		reader.Comma = []rune(profile.Delimiter)[0]
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, newBadRequestError("Invalid CSV file: %v", err)
+	}
+	if profile.SkipRows > 0 {
+		if profile.SkipRows >= len(records) {
+			return nil, nil
+		}
+		records = records[profile.SkipRows:]
+	}
+
+	var header []string
+	if profile.HasHeader && len(records) > 0 {
+		header = records[0]
+		records = records[1:]
+	}
+
+	column := func(name *string) (int, error) {
+		if name == nil || *name == "" {
+			return -1, nil
+		}
+		if position, err := strconv.Atoi(*name); err == nil {
+			return position - 1, nil
+		}
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), *name) {
+				return i, nil
+			}
+		}
+		return -1, newBadRequestError("Column %s not found in CSV header", *name)
+	}
+
+	dateCol, err := column(&profile.DateColumn)
+	if err != nil {
+		return nil, err
+	}
+	descriptionCol, err := column(profile.DescriptionColumn)
+	if err != nil {
+		return nil, err
+	}
+	referenceCol, err := column(profile.ReferenceColumn)
+	if err != nil {
+		return nil, err
+	}
+	amountCol, err := column(profile.AmountColumn)
+	if err != nil {
+		return nil, err
+	}
+	debitCol, err := column(profile.DebitColumn)
+	if err != nil {
+		return nil, err
+	}
+	creditCol, err := column(profile.CreditColumn)
+	if err != nil {
+		return nil, err
+	}
+	if dateCol < 0 || (amountCol < 0 && (debitCol < 0 || creditCol < 0)) {
+		return nil, newBadRequestError("The profile must map a date column and an amount column or debit and credit columns")
+	}
+
+	field := func(record []string, col int) string {
+		if col < 0 || col >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[col])
+	}
+
+	layout := csvDateLayout(profile.DateFormat)
+	var lines []parsedStatementLine
+	for i, record := range records {
+		row := i + 1 + profile.SkipRows
+		if profile.HasHeader {
+			row++
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		date, err := time.Parse(layout, field(record, dateCol))
+		if err != nil {
+			return nil, newBadRequestError("Row %d: invalid date %q", row, field(record, dateCol))
+		}
+
+		var amount Money
+		if amountCol >= 0 {
+			amount, err = parseStatementAmount(field(record, amountCol), profile.DecimalSeparator)
+			if err != nil {
+				return nil, newBadRequestError("Row %d: invalid amount %q", row, field(record, amountCol))
+			}
+		} else {
+			debit, err := parseStatementAmount(field(record, debitCol), profile.DecimalSeparator)
+			if err != nil {
+				return nil, newBadRequestError("Row %d: invalid debit %q", row, field(record, debitCol))
+			}
+			credit, err := parseStatementAmount(field(record, creditCol), profile.DecimalSeparator)
+			if err != nil {
+				return nil, newBadRequestError("Row %d: invalid credit %q", row, field(record, creditCol))
+			}
+			// Bank statements show money out as debits and money in as credits
+			amount = credit - debit.Abs()
+		}
+		if profile.NegateAmounts {
+			amount = -amount
+		}
+
+		reference := optionalString(field(record, referenceCol))
+		line := parsedStatementLine{
+			TransactionDate: date,
+			Description:     optionalString(field(record, descriptionCol)),
+			Reference:       reference,
+			Amount:          amount,
+		}
+		if reference != nil {
+			line.BankReference = *reference
+		}
+		lines = append(lines, line)
+	}
+
+	assignFallbackReferences(lines)
+	return lines, nil
+}
+
+var (
+	ofxTransactionPattern = regexp.MustCompile(`(?i)<STMTTRN>`)
+	ofxFieldPattern       = regexp.MustCompile(`(?i)<([A-Z0-9.]+)>([^<\r\n]*)`)
+)
+
+// parseOFXStatement parses the transactions of an OFX or QFX statement. Both
+// the SGML form of OFX 1.x, where elements are not closed, and the XML form
+// of OFX 2.x are accepted.
+func parseOFXStatement(content []byte) ([]parsedStatementLine, error) {
+	// A transaction block ends at the next opening tag in the SGML form, so
+	// the opening tags are split rather than matched as pairs
+	blocks := ofxTransactionPattern.Split(string(content), -1)
+	if len(blocks) < 2 {
+		return nil, newBadRequestError("No transactions found in OFX file")
+	}
+
+	var lines []parsedStatementLine
+	for i, block := range blocks[1:] {
+		if end := strings.Index(strings.ToUpper(block), "</STMTTRN>"); end >= 0 {
+			block = block[:end]
+		}
+
+		fields := map[string]string{}
+		for _, m := range ofxFieldPattern.FindAllStringSubmatch(block, -1) {
+			fields[strings.ToUpper(m[1])] = strings.TrimSpace(m[2])
+		}
+
+		posted := fields["DTPOSTED"]
+		if len(posted) < 8 {
+			return nil, newBadRequestError("Transaction %d: missing DTPOSTED", i+1)
+		}
+		date, err := time.Parse("20060102", posted[:8])
+		if err != nil {
+			return nil, newBadRequestError("Transaction %d: invalid DTPOSTED %q", i+1, posted)
+		}
+
+		amount, err := parseStatementAmount(fields["TRNAMT"], ".")
+		if err != nil || fields["TRNAMT"] == "" {
+			return nil, newBadRequestError("Transaction %d: invalid TRNAMT %q", i+1, fields["TRNAMT"])
+		}
+
+		description := fields["NAME"]
+		if memo := fields["MEMO"]; memo != "" {
+			if description != "" {
+				description += " - "
+			}
+			description += memo
+		}
+
+		reference := fields["CHECKNUM"]
+		if reference == "" {
+			reference = fields["REFNUM"]
+		}
+
+		lines = append(lines, parsedStatementLine{
+			TransactionDate: date,
+			Description:     optionalString(description),
+			Reference:       optionalString(reference),
+			BankReference:   fields["FITID"],
+			Amount:          amount,
+		})
+	}
+
+	assignFallbackReferences(lines)
+	return lines, nil
+}
+
+// camtDocument is the part of an ISO 20022 camt.053 bank-to-customer
+// statement that is imported
+type camtDocument struct {
+	Statements []struct {
+		Entries []camtEntry `xml:"Ntry"`
+	} `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtEntry struct {
+	Amount struct {
+		Value    string `xml:",chardata"`
+		Currency string `xml:"Ccy,attr"`
+	} `xml:"Amt"`
+	CreditDebit    string `xml:"CdtDbtInd"`
+	Status         string `xml:"Sts"`
+	BookingDate    string `xml:"BookgDt>Dt"`
+	BookingTime    string `xml:"BookgDt>DtTm"`
+	ValueDate      string `xml:"ValDt>Dt"`
+	EntryReference string `xml:"NtryRef"`
+	ServicerRef    string `xml:"AcctSvcrRef"`
+	AdditionalInfo string `xml:"AddtlNtryInf"`
+	Details        []struct {
+		EndToEndID   string   `xml:"Refs>EndToEndId"`
+		ServicerRef  string   `xml:"Refs>AcctSvcrRef"`
+		Unstructured []string `xml:"RmtInf>Ustrd"`
+	} `xml:"NtryDtls>TxDtls"`
+}
+
+// parseCAMTStatement parses the booked entries of a camt.053 statement
+func parseCAMTStatement(content []byte) ([]parsedStatementLine, error) {
+	var doc camtDocument
+	if err := xml.Unmarshal(content, &doc); err != nil {
+		return nil, newBadRequestError("Invalid CAMT.053 file: %v", err)
+	}
+
+	var lines []parsedStatementLine
+	for _, stmt := range doc.Statements {
+		for i, entry := range stmt.Entries {
+			if entry.Status != "" && !strings.EqualFold(entry.Status, "BOOK") {
+				continue
+			}
+
+			dateValue := entry.BookingDate
+			if dateValue == "" && len(entry.BookingTime) >= 10 {
+				dateValue = entry.BookingTime[:10]
+			}
+			if dateValue == "" {
+				dateValue = entry.ValueDate
+			}
+			date, err := time.Parse("2006-01-02", dateValue)
+			if err != nil {
+				return nil, newBadRequestError("Entry %d: invalid booking date %q", i+1, dateValue)
+			}
+
+			amount, err := ParseMoney(entry.Amount.Value)
+			if err != nil {
+				return nil, newBadRequestError("Entry %d: invalid amount %q", i+1, entry.Amount.Value)
+			}
+			if entry.CreditDebit == "DBIT" {
+				amount = -amount
+			}
+
+			description := entry.AdditionalInfo
+			reference := ""
+			bankReference := entry.ServicerRef
+			if bankReference == "" {
+				bankReference = entry.EntryReference
+			}
+			if len(entry.Details) > 0 {
+				detail := entry.Details[0]
+				if len(detail.Unstructured) > 0 {
+					description = strings.Join(detail.Unstructured, " ")
+				}
+				if detail.EndToEndID != "NOTPROVIDED" {
+					reference = detail.EndToEndID
+				}
+				if bankReference == "" {
+					bankReference = detail.ServicerRef
+				}
+			}
+
+			lines = append(lines, parsedStatementLine{
+				TransactionDate: date,
+				Description:     optionalString(description),
+				Reference:       optionalString(reference),
+				BankReference:   bankReference,
+				Amount:          amount,
+			})
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, newBadRequestError("No booked entries found in CAMT.053 file")
+	}
+
+	assignFallbackReferences(lines)
+	return lines, nil
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func strPtr(value string) *string {
+	return &value
+}
+
+// wantLine is the expected content of a parsed statement line
+type wantLine struct {
+	date          string
+	amount        string
+	description   string
+	reference     string
+	bankReference string
+}
+
+// checkLines compares parsed lines with the expected ones. An empty
+// bankReference expects a fallback reference derived from the content.
+func checkLines(t *testing.T, got []parsedStatementLine, want []wantLine) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		line := got[i]
+		if date := line.TransactionDate.Format("2006-01-02"); date != w.date {
+			t.Errorf("line %d: date = %s, want %s", i+1, date, w.date)
+		}
+		if amount := line.Amount.String(); amount != w.amount {
+			t.Errorf("line %d: amount = %s, want %s", i+1, amount, w.amount)
+		}
+		if description := deref(line.Description); description != w.description {
+			t.Errorf("line %d: description = %q, want %q", i+1, description, w.description)
+		}
+		if reference := deref(line.Reference); reference != w.reference {
+			t.Errorf("line %d: reference = %q, want %q", i+1, reference, w.reference)
+		}
+		if w.bankReference == "" {
+			if !strings.HasPrefix(line.BankReference, "hash:") {
+				t.Errorf("line %d: bank reference = %q, want a fallback reference", i+1, line.BankReference)
+			}
+		} else if line.BankReference != w.bankReference {
+			t.Errorf("line %d: bank reference = %q, want %q", i+1, line.BankReference, w.bankReference)
+		}
+	}
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func TestCSVDateLayout(t *testing.T) {
+	tests := map[string]string{
+		"":           "2006-01-02",
+		"DD/MM/YYYY": "02/01/2006",
+		"MM-DD-YY":   "01-02-06",
+		"YYYYMMDD":   "20060102",
+		"02.01.2006": "02.01.2006",
+	}
+	for format, want := range tests {
+		if got := csvDateLayout(format); got != want {
+			t.Errorf("csvDateLayout(%q) = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestParseStatementAmount(t *testing.T) {
+	tests := []struct {
+		value     string
+		separator string
+		want      string
+	}{
+		{value: "1,234.56", separator: ".", want: "1234.56"},
+		{value: "-42.10", separator: "", want: "-42.10"},
+		{value: "(42.10)", separator: ".", want: "-42.10"},
+		{value: "42.10-", separator: ".", want: "-42.10"},
+		{value: "$ 1,000.00", separator: ".", want: "1000.00"},
+		{value: "1.234,56", separator: ",", want: "1234.56"},
+		{value: "€ -7,5", separator: ",", want: "-7.50"},
+		{value: "", separator: ".", want: "0.00"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseStatementAmount(tt.value, tt.separator)
+		if err != nil {
+			t.Errorf("parseStatementAmount(%q) error = %v", tt.value, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("parseStatementAmount(%q, %q) = %s, want %s", tt.value, tt.separator, got, tt.want)
+		}
+	}
+}
+
+func TestParseCSVStatement(t *testing.T) {
+	t.Run("header with amount column", func(t *testing.T) {
+		content := "Date,Description,Reference,Amount\n" +
+			"15/01/2025,Coffee shop,,-4.50\n" +
+			"16/01/2025,Salary,PAY-01,\"2,500.00\"\n" +
+			"\n" +
+			"17/01/2025,Coffee shop,,-4.50\n" +
+			"17/01/2025,Coffee shop,,-4.50\n"
+		profile := &BankImportProfile{
+			HasHeader:         true,
+			DateColumn:        "date",
+			DateFormat:        "DD/MM/YYYY",
+			DescriptionColumn: strPtr("Description"),
+			ReferenceColumn:   strPtr("Reference"),
+			AmountColumn:      strPtr("Amount"),
+		}
+
+		lines, err := parseStatement("csv", []byte(content), profile)
+		if err != nil {
+			t.Fatalf("parseStatement() error = %v", err)
+		}
+		checkLines(t, lines, []wantLine{
+			{date: "2025-01-15", amount: "-4.50", description: "Coffee shop"},
+			{date: "2025-01-16", amount: "2500.00", description: "Salary", reference: "PAY-01", bankReference: "PAY-01"},
+			{date: "2025-01-17", amount: "-4.50", description: "Coffee shop"},
+			{date: "2025-01-17", amount: "-4.50", description: "Coffee shop"},
+		})
+		if lines[2].BankReference == lines[3].BankReference {
+			t.Error("identical lines got the same fallback reference")
+		}
+	})
+
+	t.Run("positional debit and credit columns", func(t *testing.T) {
+		content := "Account statement\n" +
+			"2025-02-01;Rent;1.200,00;\n" +
+			"2025-02-03;Refund;;35,99\n"
+		profile := &BankImportProfile{
+			Delimiter:         ";",
+			SkipRows:          1,
+			DateColumn:        "1",
+			DescriptionColumn: strPtr("2"),
+			DebitColumn:       strPtr("3"),
+			CreditColumn:      strPtr("4"),
+			DecimalSeparator:  ",",
+		}
+
+		lines, err := parseStatement("csv", []byte(content), profile)
+		if err != nil {
+			t.Fatalf("parseStatement() error = %v", err)
+		}
+		checkLines(t, lines, []wantLine{
+			{date: "2025-02-01", amount: "-1200.00", description: "Rent"},
+			{date: "2025-02-03", amount: "35.99", description: "Refund"},
+		})
+	})
+
+	t.Run("negated amounts", func(t *testing.T) {
+		profile := &BankImportProfile{DateColumn: "1", AmountColumn: strPtr("2"), NegateAmounts: true}
+		lines, err := parseStatement("csv", []byte("2025-03-01,10.00\n"), profile)
+		if err != nil {
+			t.Fatalf("parseStatement() error = %v", err)
+		}
+		checkLines(t, lines, []wantLine{{date: "2025-03-01", amount: "-10.00"}})
+	})
+
+	errorTests := []struct {
+		name    string
+		content string
+		profile *BankImportProfile
+	}{
+		{name: "no profile", content: "2025-03-01,10.00\n"},
+		{
+			name:    "unknown column",
+			content: "Date,Amount\n2025-03-01,10.00\n",
+			profile: &BankImportProfile{HasHeader: true, DateColumn: "Posted", AmountColumn: strPtr("Amount")},
+		},
+		{
+			name:    "no amount column",
+			content: "2025-03-01,10.00\n",
+			profile: &BankImportProfile{DateColumn: "1"},
+		},
+		{
+			name:    "invalid date",
+			content: "03/01/2025,10.00\n",
+			profile: &BankImportProfile{DateColumn: "1", AmountColumn: strPtr("2")},
+		},
+		{
+			name:    "invalid amount",
+			content: "2025-03-01,ten\n",
+			profile: &BankImportProfile{DateColumn: "1", AmountColumn: strPtr("2")},
+		},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseStatement("csv", []byte(tt.content), tt.profile); err == nil {
+				t.Error("parseStatement() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestParseOFXStatement(t *testing.T) {
+	t.Run("SGML", func(t *testing.T) {
+		content := `OFXHEADER:100
+DATA:OFXSGML
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20250110120000[-5:EST]
+<TRNAMT>-25.00
+<FITID>202501101
+<NAME>GROCERY STORE
+<MEMO>Card 1234
+<STMTTRN>
+<TRNTYPE>CHECK
+<DTPOSTED>20250111
+<TRNAMT>-100.00
+<FITID>202501111
+<CHECKNUM>1001
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+		lines, err := parseStatement("ofx", []byte(content), nil)
+		if err != nil {
+			t.Fatalf("parseStatement() error = %v", err)
+		}
+		checkLines(t, lines, []wantLine{
+			{date: "2025-01-10", amount: "-25.00", description: "GROCERY STORE - Card 1234", bankReference: "202501101"},
+			{date: "2025-01-11", amount: "-100.00", reference: "1001", bankReference: "202501111"},
+		})
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		content := `<?xml version="1.0"?>
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKTRANLIST>
+<STMTTRN><TRNTYPE>CREDIT</TRNTYPE><DTPOSTED>20250201</DTPOSTED><TRNAMT>1500.00</TRNAMT><FITID>A1</FITID><NAME>ACME PAYROLL</NAME><REFNUM>R-9</REFNUM></STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20250202</DTPOSTED><TRNAMT>-9.99</TRNAMT><NAME>STREAMING</NAME></STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>
+`
+		lines, err := parseStatement("qfx", []byte(content), nil)
+		if err != nil {
+			t.Fatalf("parseStatement() error = %v", err)
+		}
+		checkLines(t, lines, []wantLine{
+			{date: "2025-02-01", amount: "1500.00", description: "ACME PAYROLL", reference: "R-9", bankReference: "A1"},
+			{date: "2025-02-02", amount: "-9.99", description: "STREAMING"},
+		})
+	})
+
+	errorTests := map[string]string{
+		"no transactions": "<OFX></OFX>",
+		"missing date":    "<STMTTRN><TRNAMT>1.00<FITID>1",
+		"missing amount":  "<STMTTRN><DTPOSTED>20250101<FITID>1",
+	}
+	for name, content := range errorTests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseStatement("ofx", []byte(content), nil); err == nil {
+				t.Error("parseStatement() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestParseCAMTStatement(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Ntry>
+        <NtryRef>E1</NtryRef>
+        <Amt Ccy="EUR">150.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2025-03-03</Dt></BookgDt>
+        <AcctSvcrRef>SVC-1</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <Refs><EndToEndId>INV-2025-001</EndToEndId></Refs>
+          <RmtInf><Ustrd>Invoice</Ustrd><Ustrd>2025-001</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">42.50</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><DtTm>2025-03-04T09:30:00</DtTm></BookgDt>
+        <AddtlNtryInf>Card payment</AddtlNtryInf>
+        <NtryDtls><TxDtls>
+          <Refs><EndToEndId>NOTPROVIDED</EndToEndId><AcctSvcrRef>SVC-2</AcctSvcrRef></Refs>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">10.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+        <BookgDt><Dt>2025-03-05</Dt></BookgDt>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">5.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <ValDt><Dt>2025-03-06</Dt></ValDt>
+        <AddtlNtryInf>Fee</AddtlNtryInf>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>
+`
+	lines, err := parseStatement("camt053", []byte(content), nil)
+	if err != nil {
+		t.Fatalf("parseStatement() error = %v", err)
+	}
+	checkLines(t, lines, []wantLine{
+		{date: "2025-03-03", amount: "150.00", description: "Invoice 2025-001", reference: "INV-2025-001", bankReference: "SVC-1"},
+		{date: "2025-03-04", amount: "-42.50", description: "Card payment", bankReference: "SVC-2"},
+		{date: "2025-03-06", amount: "-5.00", description: "Fee"},
+	})
+
+	errorTests := map[string]string{
+		"invalid XML":     "<Document>",
+		"no entries":      `<Document><BkToCstmrStmt><Stmt></Stmt></BkToCstmrStmt></Document>`,
+		"invalid amount":  `<Document><BkToCstmrStmt><Stmt><Ntry><Amt>x</Amt><BookgDt><Dt>2025-03-03</Dt></BookgDt></Ntry></Stmt></BkToCstmrStmt></Document>`,
+		"no booking date": `<Document><BkToCstmrStmt><Stmt><Ntry><Amt>1.00</Amt></Ntry></Stmt></BkToCstmrStmt></Document>`,
+	}
+	for name, content := range errorTests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseStatement("camt053", []byte(content), nil); err == nil {
+				t.Error("parseStatement() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestParseStatementUnsupportedFormat(t *testing.T) {
+	if _, err := parseStatement("mt940", []byte("x"), nil); err == nil {
+		t.Error("parseStatement(mt940) succeeded, want an error")
+	}
+}
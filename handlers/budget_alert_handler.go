@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+const budgetAlertSelect = `
+	SELECT a.*, b.budget_name, b.account_id, coa.account_name
+	FROM accounting_budget_alerts a
+	JOIN accounting_budgets b ON b.id = a.budget_id
+	JOIN chart_of_accounts coa ON coa.id = b.account_id
+`
+
+// checkBudgets runs after a transaction is posted. For every budget of a
+// posted account or one of its ancestors whose period covers the
+// transaction date, it records an alert and an event when the posting moves
+// the actual past budget_alert_threshold percent or past the budget, and
+// rejects the posting when it exceeds a hard-limit budget.
+func (h *AccountingHandler) checkBudgets(tx *sqlx.Tx, tenant string, txn *AccountingTransaction) error {
+	settings, err := loadSettings(tx, tenant)
+	if err != nil {
+		return err
+	}
+	if !settings.EnableBudgetTracking {
+		return nil
+	}
+
+	var budgets []AccountingBudget
+	err = tx.Select(&budgets, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM chart_of_accounts
+			WHERE id IN (SELECT account_id FROM accounting_transaction_lines WHERE transaction_id = $1)
+			UNION
+			SELECT c.id, c.parent_id FROM chart_of_accounts c JOIN ancestors a ON c.id = a.parent_id
+		)
+	`+budgetSelect+`
+		WHERE b.tenant_id = $4
+		  AND b.account_id IN (SELECT id FROM ancestors)
+		  AND b.budget_amount > 0
+		  AND b.fiscal_year IN ($2, $3)
+		  AND (b.company_id IS NULL OR b.company_id = $5)
+		FOR UPDATE OF b
+	`, txn.ID, txn.TransactionDate.Year()-1, txn.TransactionDate.Year(), tenant, txn.CompanyID)
+	if err != nil {
+		return err
+	}
+
+	for _, budget := range budgets {
+		start, end, err := fiscalPeriodBounds(tx, tenant, budget.FiscalYear, budget.FiscalPeriod)
+		if err != nil {
+			return err
+		}
+		if txn.TransactionDate.Before(start) || txn.TransactionDate.After(end) {
+			continue
+		}
+
+		after, err := accountActual(tx, tenant, budget.CompanyID, budget.AccountID, start, end)
+		if err != nil {
+			return err
+		}
+
+		var delta Money
+		err = tx.Get(&delta, `
+			WITH RECURSIVE tree AS (
+				SELECT id FROM chart_of_accounts WHERE id = $1
+				UNION
+				SELECT c.id FROM chart_of_accounts c JOIN tree t ON c.parent_id = t.id
+			)
+			SELECT COALESCE(SUM(debit_amount - credit_amount), 0)
+			FROM accounting_transaction_lines
+			WHERE transaction_id = $2 AND account_id IN (SELECT id FROM tree)
+		`, budget.AccountID, txn.ID)
+		if err != nil {
+			return err
+		}
+
+		actualAfter := naturalAmount(budget.AccountType, after)
+		actualBefore := naturalAmount(budget.AccountType, after-delta)
+		if actualAfter <= actualBefore {
+			continue
+		}
+
+		if budget.IsHardLimit && actualAfter > budget.BudgetAmount {
+			return newBadRequestError("Posting exceeds the hard-limit budget %s for %s: %s of %s",
+				budget.BudgetName, budget.AccountName, actualAfter, budget.BudgetAmount)
+		}
+
+		threshold := settings.BudgetAlertThreshold
+		thresholdAmount := budget.BudgetAmount.Percent(threshold, moneyScale, RoundHalfUp)
+		var alertType string
+		switch {
+		case actualBefore <= budget.BudgetAmount && actualAfter > budget.BudgetAmount:
+			alertType = "exceeded"
+		case actualBefore < thresholdAmount && actualAfter >= thresholdAmount:
+			alertType = "threshold"
+		default:
+			continue
+		}
+
+		alert := BudgetAlert{
+			BudgetID:         budget.ID,
+			BudgetName:       budget.BudgetName,
+			AccountID:        budget.AccountID,
+			AccountName:      budget.AccountName,
+			TransactionID:    &txn.ID,
+			AlertType:        alertType,
+			ThresholdPercent: threshold,
+			BudgetAmount:     budget.BudgetAmount,
+			ActualAmount:     actualAfter,
+			PercentUsed:      roundPercent(actualAfter.Float64() / budget.BudgetAmount.Float64() * 100),
+			Status:           "open",
+		}
+		err = tx.QueryRow(`
+			INSERT INTO accounting_budget_alerts
+			(tenant_id, budget_id, transaction_id, alert_type, threshold_percent, budget_amount, actual_amount,
+			 percent_used)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id, created_at
+		`, tenant, alert.BudgetID, alert.TransactionID, alert.AlertType, alert.ThresholdPercent, alert.BudgetAmount,
+			alert.ActualAmount, alert.PercentUsed).Scan(&alert.ID, &alert.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		if err := recordEvent(tx, tenant, "accounting.budget."+alertType, alert); err != nil {
+			return err
+		}
+		h.logger.Warn("Budget alert raised",
+			zap.Int("budget_id", budget.ID),
+			zap.String("alert_type", alertType),
+			zap.Float64("percent_used", alert.PercentUsed))
+	}
+
+	return nil
+}
+
+// GetBudgetAlerts retrieves budget alerts
+func (h *AccountingHandler) GetBudgetAlerts(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	budgetID := r.URL.Query().Get("budget_id")
+	alertType := r.URL.Query().Get("alert_type")
+
+	qb := sdk.NewQueryBuilder(budgetAlertSelect + " WHERE 1=1")
+	qb.AddCondition("a.tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition("b.company_id = $%d", companyID(r))
+	qb.AddOptionalCondition("a.status = $%d", status)
+	qb.AddOptionalCondition("a.budget_id = $%d", budgetID)
+	qb.AddOptionalCondition("a.alert_type = $%d", alertType)
+
+	query, args := qb.Build()
+	query += " ORDER BY a.created_at DESC"
+
+	var alerts []BudgetAlert
+	if err := h.db.Select(&alerts, query, args...); err != nil {
+		h.logger.Error("Failed to fetch budget alerts", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch budget alerts")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"alerts": alerts,
+		"count":  len(alerts),
+	})
+}
+
+// AcknowledgeBudgetAlert marks a budget alert as acknowledged
+func (h *AccountingHandler) AcknowledgeBudgetAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid alert ID")
+		return
+	}
+
+	var req struct {
+		Note *string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sdk.WriteBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	var alert BudgetAlert
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		if err := tx.Get(&alert, budgetAlertSelect+" WHERE a.id = $1 AND a.tenant_id = $2 FOR UPDATE OF a", id, tenant); err != nil {
+			return err
+		}
+		if alert.Status == "acknowledged" {
+			return newBadRequestError("Alert is already acknowledged")
+		}
+
+		now := time.Now()
+		acknowledgedBy := 1
+		alert.Status = "acknowledged"
+		alert.AcknowledgedBy = &acknowledgedBy
+		alert.AcknowledgedAt = &now
+		alert.AcknowledgeNote = req.Note
+
+		_, err := tx.Exec(`
+			UPDATE accounting_budget_alerts
+			SET status = 'acknowledged', acknowledged_by = $1, acknowledged_at = $2, acknowledge_note = $3
+			WHERE id = $4 AND tenant_id = $5
+		`, acknowledgedBy, now, req.Note, id, tenant)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to acknowledge budget alert")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"alert":   alert,
+		"message": "Budget alert acknowledged successfully",
+	})
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// Budgets are set per account per fiscal period, or for the whole fiscal year
+// with period 0. Actuals are never stored: they are computed from posted
+// ledger lines when a budget is read, and include every descendant of the
+// budgeted account in the ParentID hierarchy.
+
+const budgetSelect = `
+	SELECT b.*, coa.account_code, coa.account_name, coa.account_type
+	FROM accounting_budgets b
+	JOIN chart_of_accounts coa ON coa.id = b.account_id
+`
+
+// naturalAmount turns a debit-minus-credit amount into the account's normal
+// balance, so revenue and liabilities are positive when credited
+func naturalAmount(accountType string, debitMinusCredit Money) Money {
+	switch accountType {
+	case "liability", "equity", "revenue":
+		return -debitMinusCredit
+	default:
+		return debitMinusCredit
+	}
+}
+
+// budgetVariance returns actual minus budget and that difference as a
+// percentage of the budget
+func budgetVariance(budget, actual Money) (Money, float64) {
+	variance := actual - budget
+	if budget == 0 {
+		return variance, 0
+	}
+	return variance, roundPercent(variance.Float64() / budget.Float64() * 100)
+}
+
+// accountActual sums the posted movement of an account and its descendants
+// between two dates as debit minus credit, counting only transactions of
+// company when it is set
+func accountActual(q sqlx.Queryer, tenant string, company *string, accountID int, start, end time.Time) (Money, error) {
+	var actual Money
+	err := sqlx.Get(q, &actual, `
+		WITH RECURSIVE tree AS (
+			SELECT id FROM chart_of_accounts WHERE id = $1 AND tenant_id = $4
+			UNION
+			SELECT c.id FROM chart_of_accounts c JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT COALESCE(SUM(atl.debit_amount - atl.credit_amount), 0)
+		FROM accounting_transaction_lines atl
+		JOIN accounting_transactions at ON at.id = atl.transaction_id
+		WHERE atl.account_id IN (SELECT id FROM tree)
+		  AND at.status IN ('posted', 'void')
+		  AND at.transaction_date BETWEEN $2 AND $3
+		  AND ($5::uuid IS NULL OR at.company_id = $5)
+	`, accountID, start, end, tenant, company)
+	return actual, err
+}
+
+// fillBudgetActuals computes the actual and variance amounts of budgets
+func fillBudgetActuals(q sqlx.Queryer, tenant string, budgets []AccountingBudget) error {
+	type periodKey struct{ year, period int }
+	bounds := map[periodKey][2]time.Time{}
+
+	for i := range budgets {
+		b := &budgets[i]
+		key := periodKey{b.FiscalYear, b.FiscalPeriod}
+		if _, ok := bounds[key]; !ok {
+			start, end, err := fiscalPeriodBounds(q, tenant, b.FiscalYear, b.FiscalPeriod)
+			if err != nil {
+				return err
+			}
+			bounds[key] = [2]time.Time{start, end}
+		}
+
+		actual, err := accountActual(q, tenant, b.CompanyID, b.AccountID, bounds[key][0], bounds[key][1])
+		if err != nil {
+			return err
+		}
+		b.ActualAmount = naturalAmount(b.AccountType, actual)
+		b.VarianceAmount, b.VariancePercent = budgetVariance(b.BudgetAmount, b.ActualAmount)
+	}
+
+	return nil
+}
+
+// GetBudgets retrieves budgets with their actuals
+func (h *AccountingHandler) GetBudgets(w http.ResponseWriter, r *http.Request) {
+	fiscalYear := r.URL.Query().Get("fiscal_year")
+	fiscalPeriod := r.URL.Query().Get("fiscal_period")
+	accountID := r.URL.Query().Get("account_id")
+	budgetName := r.URL.Query().Get("budget_name")
+
+	tenant := tenantID(r)
+	qb := sdk.NewQueryBuilder(budgetSelect + " WHERE 1=1")
+	qb.AddCondition("b.tenant_id = $%d", tenant)
+	qb.AddOptionalCondition("b.company_id = $%d", companyID(r))
+	qb.AddOptionalCondition("b.fiscal_year = $%d", fiscalYear)
+	qb.AddOptionalCondition("b.fiscal_period = $%d", fiscalPeriod)
+	qb.AddOptionalCondition("b.account_id = $%d", accountID)
+	qb.AddOptionalCondition("b.budget_name = $%d", budgetName)
+
+	query, args := qb.Build()
+	query += " ORDER BY b.fiscal_year DESC, b.fiscal_period, coa.account_code"
+
+	var budgets []AccountingBudget
+	if err := h.db.Select(&budgets, query, args...); err != nil {
+		h.logger.Error("Failed to fetch budgets", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch budgets")
+		return
+	}
+
+	if err := fillBudgetActuals(h.db, tenant, budgets); err != nil {
+		h.writeError(w, err, "Failed to fetch budgets")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"budgets": budgets,
+		"count":   len(budgets),
+	})
+}
+
+// GetBudget retrieves a single budget with its actual
+func (h *AccountingHandler) GetBudget(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid budget ID")
+		return
+	}
+
+	tenant := tenantID(r)
+	budgets := make([]AccountingBudget, 1)
+	if err := h.db.Get(&budgets[0], budgetSelect+" WHERE b.id = $1 AND b.tenant_id = $2", id, tenant); err != nil {
+		h.writeError(w, err, "Failed to fetch budget")
+		return
+	}
+
+	if err := fillBudgetActuals(h.db, tenant, budgets); err != nil {
+		h.writeError(w, err, "Failed to fetch budget")
+		return
+	}
+
+	sdk.WriteSuccess(w, budgets[0])
+}
+
+type budgetRequest struct {
+	BudgetName   string  `json:"budget_name"`
+	FiscalYear   int     `json:"fiscal_year"`
+	FiscalPeriod int     `json:"fiscal_period"`
+	AccountID    int     `json:"account_id"`
+	BudgetAmount Money   `json:"budget_amount"`
+	IsHardLimit  bool    `json:"is_hard_limit"`
+	CompanyID    *string `json:"company_id"`
+}
+
+// validate checks a budget request against the settings and the fiscal
+// calendar. A budget belongs to the company of its account, or to the
+// requested company when the account is shared.
+func (req *budgetRequest) validate(q sqlx.Queryer, tenant string) error {
+	if err := sdk.ValidateRequired(map[string]interface{}{
+		"budget_name": req.BudgetName,
+		"fiscal_year": req.FiscalYear,
+		"account_id":  req.AccountID,
+	}); err != nil {
+		return newBadRequestError("%s", err.Error())
+	}
+
+	settings, err := loadSettings(q, tenant)
+	if err != nil {
+		return err
+	}
+	if !settings.EnableBudgetTracking {
+		return newBadRequestError("Budget tracking is disabled")
+	}
+
+	if req.FiscalPeriod < 0 {
+		return newBadRequestError("fiscal_period must be 0 for a yearly budget or a period number")
+	}
+	if _, _, err := fiscalPeriodBounds(q, tenant, req.FiscalYear, req.FiscalPeriod); err != nil {
+		return err
+	}
+
+	var account ChartOfAccount
+	err = sqlx.Get(q, &account,
+		"SELECT * FROM chart_of_accounts WHERE id = $1 AND tenant_id = $2 AND is_active = true", req.AccountID, tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newBadRequestError("Account %d not found", req.AccountID)
+	}
+	if err != nil {
+		return err
+	}
+	if req.CompanyID, err = postingCompany(q, tenant, req.CompanyID, []int{account.ID}); err != nil {
+		return err
+	}
+
+	return checkMinorUnits(req.BudgetAmount, settings.DefaultCurrency)
+}
+
+// CreateBudget creates a budget for an account and fiscal period
+func (h *AccountingHandler) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	var req budgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	tenant := tenantID(r)
+	if req.CompanyID == nil {
+		req.CompanyID = optionalCompany(companyID(r))
+	}
+	if err := req.validate(h.db, tenant); err != nil {
+		h.writeError(w, err, "Failed to create budget")
+		return
+	}
+
+	var duplicate bool
+	if err := h.db.Get(&duplicate, `
+		SELECT EXISTS(SELECT 1 FROM accounting_budgets
+		WHERE tenant_id = $1 AND budget_name = $2 AND fiscal_year = $3 AND fiscal_period = $4 AND account_id = $5
+		  AND company_id IS NOT DISTINCT FROM $6)
+	`, tenant, req.BudgetName, req.FiscalYear, req.FiscalPeriod, req.AccountID, req.CompanyID); err != nil {
+		h.writeError(w, err, "Failed to create budget")
+		return
+	}
+	if duplicate {
+		sdk.WriteBadRequest(w, "A budget for this account and period already exists")
+		return
+	}
+
+	var id int
+	err := h.db.QueryRow(`
+		INSERT INTO accounting_budgets
+		(tenant_id, company_id, budget_name, fiscal_year, fiscal_period, account_id, budget_amount, is_hard_limit,
+		 created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, tenant, req.CompanyID, req.BudgetName, req.FiscalYear, req.FiscalPeriod, req.AccountID, req.BudgetAmount,
+		req.IsHardLimit, currentUserID(r)).Scan(&id)
+	if err != nil {
+		h.logger.Error("Failed to create budget", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to create budget")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"id":      id,
+		"message": "Budget created successfully",
+	})
+}
+
+// UpdateBudget updates a budget
+func (h *AccountingHandler) UpdateBudget(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid budget ID")
+		return
+	}
+
+	var req budgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	tenant := tenantID(r)
+	if req.CompanyID == nil {
+		req.CompanyID = optionalCompany(companyID(r))
+	}
+	if err := req.validate(h.db, tenant); err != nil {
+		h.writeError(w, err, "Failed to update budget")
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE accounting_budgets
+		SET budget_name = $1, fiscal_year = $2, fiscal_period = $3, account_id = $4, budget_amount = $5,
+		    is_hard_limit = $6, company_id = $7
+		WHERE id = $8 AND tenant_id = $9
+	`, req.BudgetName, req.FiscalYear, req.FiscalPeriod, req.AccountID, req.BudgetAmount, req.IsHardLimit,
+		req.CompanyID, id, tenant)
+	if err != nil {
+		h.logger.Error("Failed to update budget", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to update budget")
+		return
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		sdk.WriteNotFound(w, "Budget not found")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Budget updated successfully"})
+}
+
+// DeleteBudget deletes a budget
+func (h *AccountingHandler) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid budget ID")
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM accounting_budgets WHERE id = $1 AND tenant_id = $2", id, tenantID(r))
+	if err != nil {
+		h.logger.Error("Failed to delete budget", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to delete budget")
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		sdk.WriteNotFound(w, "Budget not found")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Budget deleted successfully"})
+}
+
+// GetBudgetVsActual compares budgets with actuals for a fiscal year or one of
+// its periods. Each account's figures include its descendants, and rows are
+// returned in hierarchy order. Accounts with activity but no budget are
+// included when include_unbudgeted is true.
+func (h *AccountingHandler) GetBudgetVsActual(w http.ResponseWriter, r *http.Request) {
+	fiscalYear, err := strconv.Atoi(r.URL.Query().Get("fiscal_year"))
+	if err != nil {
+		sdk.WriteBadRequest(w, "A valid fiscal_year is required")
+		return
+	}
+	fiscalPeriod := 0
+	if value := r.URL.Query().Get("fiscal_period"); value != "" {
+		if fiscalPeriod, err = strconv.Atoi(value); err != nil {
+			sdk.WriteBadRequest(w, "Invalid fiscal_period")
+			return
+		}
+	}
+	budgetName := r.URL.Query().Get("budget_name")
+	includeUnbudgeted := r.URL.Query().Get("include_unbudgeted") == "true"
+
+	tenant := tenantID(r)
+	company := companyID(r)
+	start, end, err := fiscalPeriodBounds(h.db, tenant, fiscalYear, fiscalPeriod)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate budget report")
+		return
+	}
+
+	// A company's report covers its own and the shared accounts
+	var accounts []ChartOfAccount
+	if err := h.db.Select(&accounts, `
+		SELECT * FROM chart_of_accounts
+		WHERE tenant_id = $1 AND is_active = true AND ($2 = '' OR company_id IS NULL OR company_id = NULLIF($2, '')::uuid)
+		ORDER BY account_code
+	`, tenant, company); err != nil {
+		h.writeError(w, err, "Failed to generate budget report")
+		return
+	}
+
+	qb := sdk.NewQueryBuilder("SELECT account_id, SUM(budget_amount) AS amount FROM accounting_budgets WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenant)
+	qb.AddOptionalCondition("company_id = $%d", company)
+	qb.AddCondition("fiscal_year = $%d", fiscalYear)
+	if fiscalPeriod > 0 {
+		qb.AddCondition("fiscal_period = $%d", fiscalPeriod)
+	}
+	qb.AddOptionalCondition("budget_name = $%d", budgetName)
+	query, args := qb.Build()
+	query += " GROUP BY account_id"
+
+	var budgetRows []struct {
+		AccountID int   `db:"account_id"`
+		Amount    Money `db:"amount"`
+	}
+	if err := h.db.Select(&budgetRows, query, args...); err != nil {
+		h.writeError(w, err, "Failed to generate budget report")
+		return
+	}
+
+	var actualRows []struct {
+		AccountID int   `db:"account_id"`
+		Amount    Money `db:"amount"`
+	}
+	if err := h.db.Select(&actualRows, `
+		SELECT atl.account_id, SUM(atl.debit_amount - atl.credit_amount) AS amount
+		FROM accounting_transaction_lines atl
+		JOIN accounting_transactions at ON at.id = atl.transaction_id
+		WHERE atl.tenant_id = $1 AND at.status IN ('posted', 'void') AND at.transaction_date BETWEEN $2 AND $3
+		  AND ($4 = '' OR at.company_id = NULLIF($4, '')::uuid)
+		GROUP BY atl.account_id
+	`, tenant, start, end, company); err != nil {
+		h.writeError(w, err, "Failed to generate budget report")
+		return
+	}
+
+	byID := map[int]*ChartOfAccount{}
+	children := map[int][]int{}
+	var roots []int
+	for i := range accounts {
+		byID[accounts[i].ID] = &accounts[i]
+	}
+	for _, account := range accounts {
+		if account.ParentID != nil && byID[*account.ParentID] != nil {
+			children[*account.ParentID] = append(children[*account.ParentID], account.ID)
+		} else {
+			roots = append(roots, account.ID)
+		}
+	}
+
+	// Roll each account's own figures up through its ancestors
+	budgets := map[int]Money{}
+	actuals := map[int]Money{}
+	budgeted := map[int]bool{}
+	rollUp := func(accountID int, amount Money, totals map[int]Money, mark bool) {
+		seen := map[int]bool{}
+		for id := accountID; byID[id] != nil && !seen[id]; {
+			seen[id] = true
+			totals[id] += amount
+			if mark {
+				budgeted[id] = true
+			}
+			if byID[id].ParentID == nil {
+				break
+			}
+			id = *byID[id].ParentID
+		}
+	}
+	for _, row := range budgetRows {
+		rollUp(row.AccountID, row.Amount, budgets, true)
+	}
+	for _, row := range actualRows {
+		rollUp(row.AccountID, row.Amount, actuals, false)
+	}
+
+	rows := []BudgetVsActual{}
+	var walk func(id, level int)
+	walk = func(id, level int) {
+		account := byID[id]
+		if budgeted[id] || (includeUnbudgeted && actuals[id] != 0) {
+			row := BudgetVsActual{
+				AccountID:    account.ID,
+				AccountCode:  account.AccountCode,
+				AccountName:  account.AccountName,
+				AccountType:  account.AccountType,
+				ParentID:     account.ParentID,
+				Level:        level,
+				BudgetAmount: budgets[id],
+				ActualAmount: naturalAmount(account.AccountType, actuals[id]),
+			}
+			row.VarianceAmount, row.VariancePercent = budgetVariance(row.BudgetAmount, row.ActualAmount)
+			rows = append(rows, row)
+		}
+		kids := children[id]
+		sort.Slice(kids, func(i, j int) bool { return byID[kids[i]].AccountCode < byID[kids[j]].AccountCode })
+		for _, child := range kids {
+			walk(child, level+1)
+		}
+	}
+	for _, id := range roots {
+		walk(id, 0)
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"fiscal_year":   fiscalYear,
+		"fiscal_period": fiscalPeriod,
+		"start_date":    start.Format("2006-01-02"),
+		"end_date":      end.Format("2006-01-02"),
+		"accounts":      rows,
+	})
+}
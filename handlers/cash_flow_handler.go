@@ -0,0 +1,290 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+)
+
+// The cash flow statement explains the change in the balance of the accounts
+// classified as cash over a period. Every other account's cash flows fall
+// under operating, investing or financing activities according to its
+// cash_flow_category, by default financing for equity and operating for the
+// rest. The indirect method starts from net income and adjusts it for the
+// change in each balance sheet account; the direct method attributes the
+// cash lines of each transaction to its other lines. Year-end closing
+// entries move no cash and are left out.
+
+// cashFlowActivities are the sections of a cash flow statement, in order
+var cashFlowActivities = []string{"operating", "investing", "financing"}
+
+// validateCashFlowCategory checks the cash flow category of an account.
+// Only asset accounts can hold cash.
+func validateCashFlowCategory(accountType string, category *string) error {
+	if category == nil {
+		return nil
+	}
+	if err := sdk.ValidateEnum("cash_flow_category", *category,
+		[]string{"cash", "operating", "investing", "financing"}); err != nil {
+		return err
+	}
+	if *category == "cash" && accountType != "asset" {
+		return newBadRequestError("Only asset accounts can be classified as cash")
+	}
+	return nil
+}
+
+// cashFlowActivity returns the activity the cash flows of an account are
+// classified under
+func cashFlowActivity(accountType string, category *string) string {
+	if category != nil && *category != "cash" {
+		return *category
+	}
+	if accountType == "equity" {
+		return "financing"
+	}
+	return "operating"
+}
+
+// cashFlowAccount is the debit-minus-credit activity of an account for the
+// cash flow statement
+type cashFlowAccount struct {
+	AccountID        int     `db:"account_id"`
+	AccountCode      string  `db:"account_code"`
+	AccountName      string  `db:"account_name"`
+	AccountType      string  `db:"account_type"`
+	CashFlowCategory *string `db:"cash_flow_category"`
+	OpeningBalance   Money   `db:"opening_balance"`
+	Movement         Money   `db:"movement"`
+}
+
+// isCash reports whether the account holds cash
+func (a cashFlowAccount) isCash() bool {
+	return a.CashFlowCategory != nil && *a.CashFlowCategory == "cash"
+}
+
+// cashFlowBalances returns, per account with activity up to endDate, its
+// balance before startDate and its movement between startDate and endDate
+// within scope, leaving out year-end closing entries
+func cashFlowBalances(q sqlx.Queryer, tenant string, startDate, endDate time.Time, scope reportScope) ([]cashFlowAccount, error) {
+	accountConditions, transactionConditions, args := generalLedgerConditions(tenant, startDate, endDate, scope, nil)
+	accounts := []cashFlowAccount{}
+	err := sqlx.Select(q, &accounts, `
+		SELECT
+			coa.id AS account_id,
+			coa.account_code,
+			coa.account_name,
+			coa.account_type,
+			coa.cash_flow_category,
+			COALESCE(SUM(CASE WHEN at.transaction_date < $2 THEN atl.debit_amount - atl.credit_amount END), 0)
+				AS opening_balance,
+			COALESCE(SUM(CASE WHEN at.transaction_date >= $2 THEN atl.debit_amount - atl.credit_amount END), 0)
+				AS movement
+		FROM chart_of_accounts coa
+		JOIN accounting_transaction_lines atl ON atl.account_id = coa.id
+		JOIN accounting_transactions at ON at.id = atl.transaction_id
+		WHERE `+accountConditions+`
+		  AND `+transactionConditions+`
+		  AND at.transaction_date <= $3
+		  AND at.reference_type IS DISTINCT FROM 'year_end_close'
+		GROUP BY coa.id, coa.account_code, coa.account_name, coa.account_type, coa.cash_flow_category
+		ORDER BY coa.account_code, coa.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// cashFlowCounterparts returns, per account not holding cash, its movement
+// between startDate and endDate within scope in transactions that also
+// have a cash line. The movements of a transaction offset its cash lines.
+func cashFlowCounterparts(q sqlx.Queryer, tenant string, startDate, endDate time.Time, scope reportScope) ([]cashFlowAccount, error) {
+	accountConditions, transactionConditions, args := generalLedgerConditions(tenant, startDate, endDate, scope, nil)
+	accounts := []cashFlowAccount{}
+	err := sqlx.Select(q, &accounts, `
+		SELECT
+			coa.id AS account_id,
+			coa.account_code,
+			coa.account_name,
+			coa.account_type,
+			coa.cash_flow_category,
+			0 AS opening_balance,
+			SUM(atl.debit_amount - atl.credit_amount) AS movement
+		FROM chart_of_accounts coa
+		JOIN accounting_transaction_lines atl ON atl.account_id = coa.id
+		JOIN accounting_transactions at ON at.id = atl.transaction_id
+		WHERE `+accountConditions+`
+		  AND `+transactionConditions+`
+		  AND at.transaction_date BETWEEN $2 AND $3
+		  AND coa.cash_flow_category IS DISTINCT FROM 'cash'
+		  AND EXISTS (
+			  SELECT 1
+			  FROM accounting_transaction_lines cash_line
+			  JOIN chart_of_accounts cash_account ON cash_account.id = cash_line.account_id
+			  WHERE cash_line.transaction_id = at.id AND cash_account.cash_flow_category = 'cash'
+		  )
+		GROUP BY coa.id, coa.account_code, coa.account_name, coa.account_type, coa.cash_flow_category
+		ORDER BY coa.account_code, coa.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// GetCashFlowStatement generates a cash flow statement in the functional
+// currency by the indirect or direct method, for the company of the request
+// or for every company
+func (h *AccountingHandler) GetCashFlowStatement(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	startDate, err := parseDate(params.Get("start_date"))
+	if err != nil {
+		sdk.WriteBadRequest(w, "Start date and end date are required")
+		return
+	}
+	endDate, err := parseDate(params.Get("end_date"))
+	if err != nil {
+		sdk.WriteBadRequest(w, "Start date and end date are required")
+		return
+	}
+	if endDate.Before(startDate) {
+		sdk.WriteBadRequest(w, "end_date cannot be before start_date")
+		return
+	}
+	method := params.Get("method")
+	if method == "" {
+		method = "indirect"
+	}
+	if err := sdk.ValidateEnum("method", method, []string{"indirect", "direct"}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	tenant := tenantID(r)
+	settings, err := loadSettings(h.db, tenant)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate cash flow statement")
+		return
+	}
+	scope, err := requestReportScope(h.db, r)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate cash flow statement")
+		return
+	}
+
+	// Only the cash accounts the scope reports on count
+	accountScope, _, scopeArgs := scope.conditions(2)
+	var hasCash bool
+	if err := h.db.Get(&hasCash, `
+		SELECT EXISTS(
+			SELECT 1 FROM chart_of_accounts coa
+			WHERE coa.tenant_id = $1 AND coa.cash_flow_category = 'cash' AND `+accountScope+`
+		)
+	`, append([]interface{}{tenant}, scopeArgs...)...); err != nil {
+		h.writeError(w, err, "Failed to generate cash flow statement")
+		return
+	}
+	if !hasCash {
+		sdk.WriteBadRequest(w, "No account is classified as cash; set cash_flow_category to cash on the cash accounts")
+		return
+	}
+
+	balances, err := cashFlowBalances(h.db, tenant, startDate, endDate, scope)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate cash flow statement")
+		return
+	}
+
+	var statement struct {
+		Method              string           `json:"method"`
+		StartDate           string           `json:"start_date"`
+		EndDate             string           `json:"end_date"`
+		Currency            string           `json:"currency"`
+		NetIncome           *Money           `json:"net_income,omitempty"`
+		OperatingActivities CashFlowActivity `json:"operating_activities"`
+		InvestingActivities CashFlowActivity `json:"investing_activities"`
+		FinancingActivities CashFlowActivity `json:"financing_activities"`
+		NetChangeInCash     Money            `json:"net_change_in_cash"`
+		OpeningCash         Money            `json:"opening_cash"`
+		ClosingCash         Money            `json:"closing_cash"`
+	}
+	statement.Method = method
+	statement.StartDate = startDate.Format("2006-01-02")
+	statement.EndDate = endDate.Format("2006-01-02")
+	statement.Currency = settings.DefaultCurrency
+
+	activities := map[string]*CashFlowActivity{
+		"operating": &statement.OperatingActivities,
+		"investing": &statement.InvestingActivities,
+		"financing": &statement.FinancingActivities,
+	}
+	for _, name := range cashFlowActivities {
+		activities[name].Items = []AccountAmount{}
+	}
+	add := func(activity string, account cashFlowAccount, amount Money) {
+		if amount == 0 {
+			return
+		}
+		activities[activity].Items = append(activities[activity].Items, AccountAmount{
+			AccountCode: account.AccountCode,
+			AccountName: account.AccountName,
+			Amount:      amount,
+		})
+		activities[activity].Total += amount
+	}
+
+	for _, account := range balances {
+		if account.isCash() {
+			statement.OpeningCash += account.OpeningBalance
+			statement.ClosingCash += account.OpeningBalance + account.Movement
+		}
+	}
+	statement.NetChangeInCash = statement.ClosingCash - statement.OpeningCash
+
+	if method == "indirect" {
+		// Net income, less the income and expense classified under other
+		// activities, plus the cash effect of each balance sheet account,
+		// which is the opposite of its debit-minus-credit change
+		var netIncome Money
+		for _, account := range balances {
+			if account.AccountType == "revenue" || account.AccountType == "expense" {
+				netIncome -= account.Movement
+			}
+		}
+		statement.NetIncome = &netIncome
+		statement.OperatingActivities.Items = append(statement.OperatingActivities.Items, AccountAmount{
+			AccountName: "Net Income",
+			Amount:      netIncome,
+		})
+		statement.OperatingActivities.Total += netIncome
+
+		for _, account := range balances {
+			if account.isCash() {
+				continue
+			}
+			activity := cashFlowActivity(account.AccountType, account.CashFlowCategory)
+			if account.AccountType == "revenue" || account.AccountType == "expense" {
+				if activity != "operating" {
+					add("operating", account, account.Movement)
+					add(activity, account, -account.Movement)
+				}
+				continue
+			}
+			add(activity, account, -account.Movement)
+		}
+	} else {
+		counterparts, err := cashFlowCounterparts(h.db, tenant, startDate, endDate, scope)
+		if err != nil {
+			h.writeError(w, err, "Failed to generate cash flow statement")
+			return
+		}
+		for _, account := range counterparts {
+			add(cashFlowActivity(account.AccountType, account.CashFlowCategory), account, -account.Movement)
+		}
+	}
+
+	sdk.WriteSuccess(w, statement)
+}
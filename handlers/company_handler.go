@@ -0,0 +1,383 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// A tenant can hold several companies, each a legal entity with its own
+// books. Accounts either belong to one company or, without a company, are
+// shared by all of them. A transaction or journal entry belongs to the
+// company of its accounts; accounts of two companies are never posted
+// together, which is what inter-company transactions are for. Requests are
+// narrowed to a company with the X-Company-ID header, and reports cover one
+// company, every company, or every company consolidated with inter-company
+// transactions eliminated.
+
+// loadCompany fetches a company of the tenant
+func loadCompany(q sqlx.Queryer, tenant, id string) (*Company, error) {
+	if !uuidPattern.MatchString(id) {
+		return nil, newNotFoundError("Company not found")
+	}
+
+	var company Company
+	err := sqlx.Get(q, &company, "SELECT * FROM accounting_companies WHERE id = $1 AND tenant_id = $2", id, tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newNotFoundError("Company not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &company, nil
+}
+
+// requireCompany checks that a company referenced by a request is an active
+// company of the tenant
+func requireCompany(q sqlx.Queryer, tenant, id string) (*Company, error) {
+	company, err := loadCompany(q, tenant, id)
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return nil, newBadRequestError("Company %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !company.IsActive {
+		return nil, newBadRequestError("Company %s is inactive", company.Code)
+	}
+	return company, nil
+}
+
+// accountCompany returns the company of an account of the tenant, nil for a
+// shared account
+func accountCompany(q sqlx.Queryer, tenant string, accountID int) (*string, error) {
+	var company *string
+	err := sqlx.Get(q, &company,
+		"SELECT company_id FROM chart_of_accounts WHERE id = $1 AND tenant_id = $2", accountID, tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newBadRequestError("Account %d not found", accountID)
+	}
+	return company, err
+}
+
+// postingCompany resolves the company that lines posting to accountIDs
+// belong to, and checks that every account belongs to the tenant. company is
+// the company the caller asked for, if any. Shared accounts can be used by
+// any company, but accounts of two companies cannot be combined.
+func postingCompany(q sqlx.Queryer, tenant string, company *string, accountIDs []int) (*string, error) {
+	if company != nil {
+		if _, err := requireCompany(q, tenant, *company); err != nil {
+			return nil, err
+		}
+	}
+
+	resolved := company
+	for _, accountID := range accountIDs {
+		owner, err := accountCompany(q, tenant, accountID)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case owner == nil:
+		case resolved == nil:
+			resolved = owner
+		case *owner != *resolved && company != nil:
+			return nil, newBadRequestError("Account %d belongs to another company", accountID)
+		case *owner != *resolved:
+			return nil, newBadRequestError(
+				"Accounts of different companies cannot be posted together; record an inter-company transaction instead")
+		}
+	}
+	return resolved, nil
+}
+
+// accountByCode finds an active account by code for a company, preferring
+// the company's own account to a shared one. Without a company only shared
+// accounts are considered. It returns sql.ErrNoRows when there is none.
+func accountByCode(q sqlx.Queryer, tenant, company, code string) (*ChartOfAccount, error) {
+	var account ChartOfAccount
+	err := sqlx.Get(q, &account, `
+		SELECT * FROM chart_of_accounts
+		WHERE tenant_id = $1 AND account_code = $2 AND is_active = true
+		  AND (company_id IS NULL OR company_id = NULLIF($3, '')::uuid)
+		ORDER BY company_id NULLS LAST
+		LIMIT 1
+	`, tenant, code, company)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// optionalCompany converts the company of a request to a field value
+func optionalCompany(company string) *string {
+	if company == "" {
+		return nil
+	}
+	return &company
+}
+
+// reportScope is the part of a tenant's ledger a report covers: one company,
+// every company, optionally consolidated so that inter-company transactions
+// are eliminated, or only what belongs to no company
+type reportScope struct {
+	CompanyID    string
+	Consolidated bool
+	Unassigned   bool
+}
+
+// companyBooks returns the scope of the books a company closes and
+// revalues on its own: its transactions, or the transactions without a
+// company when company is empty
+func companyBooks(company string) reportScope {
+	return reportScope{CompanyID: company, Unassigned: company == ""}
+}
+
+// requestReportScope reads the scope of a report from the X-Company-ID
+// header and the consolidated query parameter
+func requestReportScope(q sqlx.Queryer, r *http.Request) (reportScope, error) {
+	scope := reportScope{
+		CompanyID:    companyID(r),
+		Consolidated: r.URL.Query().Get("consolidated") == "true",
+	}
+	if scope.CompanyID != "" {
+		if scope.Consolidated {
+			return scope, newBadRequestError("A consolidated report covers every company and cannot be requested for one")
+		}
+		if _, err := requireCompany(q, tenantID(r), scope.CompanyID); err != nil {
+			return scope, err
+		}
+	}
+	return scope, nil
+}
+
+// conditions returns the SQL conditions restricting the accounts aliased coa
+// and the transactions aliased at to the scope. Their arguments, if any,
+// are numbered from $n.
+func (s reportScope) conditions(n int) (accounts, transactions string, args []interface{}) {
+	switch {
+	case s.CompanyID != "":
+		return fmt.Sprintf("(coa.company_id IS NULL OR coa.company_id = $%d)", n),
+			fmt.Sprintf("at.company_id = $%d", n),
+			[]interface{}{s.CompanyID}
+	case s.Unassigned:
+		return "coa.company_id IS NULL", "at.company_id IS NULL", nil
+	case s.Consolidated:
+		return "true", "at.reference_type IS DISTINCT FROM 'intercompany'", nil
+	}
+	return "true", "true", nil
+}
+
+// intercompanyEliminations returns, per account, the posted inter-company
+// activity a consolidated report leaves out between startDate, when set, and
+// endDate. Amounts have the natural sign of the account type.
+func intercompanyEliminations(q sqlx.Queryer, tenant, startDate, endDate string) ([]incomeAccountAmount, error) {
+	qb := sdk.NewQueryBuilder(`
+		SELECT
+			coa.id AS account_id,
+			coa.account_type,
+			coa.account_code,
+			coa.account_name,
+			SUM(
+				CASE
+					WHEN coa.account_type IN ('asset', 'expense') THEN atl.debit_amount - atl.credit_amount
+					ELSE atl.credit_amount - atl.debit_amount
+				END
+			) AS amount
+		FROM accounting_transaction_lines atl
+		JOIN accounting_transactions at ON at.id = atl.transaction_id
+		JOIN chart_of_accounts coa ON coa.id = atl.account_id
+		WHERE at.reference_type = 'intercompany'
+		  AND at.status IN ('posted', 'void')
+	`)
+	qb.AddCondition("at.tenant_id = $%d", tenant)
+	qb.AddOptionalCondition("at.transaction_date >= $%d", startDate)
+	qb.AddCondition("at.transaction_date <= $%d", endDate)
+
+	query, args := qb.Build()
+	query += `
+		GROUP BY coa.id, coa.account_type, coa.account_code, coa.account_name
+		HAVING SUM(atl.debit_amount - atl.credit_amount) <> 0
+		ORDER BY coa.account_type, coa.account_code
+	`
+
+	var amounts []incomeAccountAmount
+	if err := sqlx.Select(q, &amounts, query, args...); err != nil {
+		return nil, err
+	}
+	return amounts, nil
+}
+
+// validateCompany checks a company and its inter-company accounts, which
+// must be accounts the company can post to: due-from an asset, due-to a
+// liability
+func validateCompany(q sqlx.Queryer, tenant string, company *Company) error {
+	company.Code = strings.TrimSpace(company.Code)
+	company.Name = strings.TrimSpace(company.Name)
+	if company.Code == "" || company.Name == "" {
+		return newBadRequestError("code and name are required")
+	}
+
+	check := func(field string, accountID *int, accountType string) error {
+		if accountID == nil {
+			return nil
+		}
+		var account ChartOfAccount
+		err := sqlx.Get(q, &account,
+			"SELECT * FROM chart_of_accounts WHERE id = $1 AND tenant_id = $2 AND is_active = true", *accountID, tenant)
+		if errors.Is(err, sql.ErrNoRows) {
+			return newBadRequestError("%s: account %d not found", field, *accountID)
+		}
+		if err != nil {
+			return err
+		}
+		if account.AccountType != accountType {
+			return newBadRequestError("%s: account %s must be an %s account", field, account.AccountCode, accountType)
+		}
+		if account.CompanyID != nil && *account.CompanyID != company.ID {
+			return newBadRequestError("%s: account %s belongs to another company", field, account.AccountCode)
+		}
+		return nil
+	}
+
+	if err := check("due_from_account_id", company.DueFromAccountID, "asset"); err != nil {
+		return err
+	}
+	return check("due_to_account_id", company.DueToAccountID, "liability")
+}
+
+// GetCompanies lists the companies of the tenant
+func (h *AccountingHandler) GetCompanies(w http.ResponseWriter, r *http.Request) {
+	isActive := r.URL.Query().Get("is_active")
+
+	qb := sdk.NewQueryBuilder("SELECT * FROM accounting_companies WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	if isActive != "" {
+		qb.AddCondition("is_active = $%d", isActive == "true")
+	}
+
+	query, args := qb.Build()
+	query += " ORDER BY code"
+
+	companies := []Company{}
+	if err := h.db.Select(&companies, query, args...); err != nil {
+		h.logger.Error("Failed to fetch companies", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch companies")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"companies": companies,
+		"count":     len(companies),
+	})
+}
+
+// GetCompany retrieves a single company
+func (h *AccountingHandler) GetCompany(w http.ResponseWriter, r *http.Request) {
+	company, err := loadCompany(h.db, tenantID(r), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch company")
+		return
+	}
+
+	sdk.WriteSuccess(w, company)
+}
+
+// CreateCompany creates a company. Its inter-company accounts can be set
+// once its chart of accounts exists.
+func (h *AccountingHandler) CreateCompany(w http.ResponseWriter, r *http.Request) {
+	company := Company{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&company); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	tenant := tenantID(r)
+	if err := validateCompany(h.db, tenant, &company); err != nil {
+		h.writeError(w, err, "Failed to create company")
+		return
+	}
+
+	err := h.db.Get(&company, `
+		INSERT INTO accounting_companies (tenant_id, code, name, due_from_account_id, due_to_account_id, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING *
+	`, tenant, company.Code, company.Name, company.DueFromAccountID, company.DueToAccountID, company.IsActive)
+	if err != nil {
+		h.writeError(w, err, "Failed to create company")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"company": company,
+		"message": "Company created successfully",
+	})
+}
+
+// UpdateCompany replaces the attributes of a company
+func (h *AccountingHandler) UpdateCompany(w http.ResponseWriter, r *http.Request) {
+	tenant := tenantID(r)
+	current, err := loadCompany(h.db, tenant, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err, "Failed to update company")
+		return
+	}
+
+	company := *current
+	if err := json.NewDecoder(r.Body).Decode(&company); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+	company.ID = current.ID
+
+	if err := validateCompany(h.db, tenant, &company); err != nil {
+		h.writeError(w, err, "Failed to update company")
+		return
+	}
+
+	err = h.db.Get(&company, `
+		UPDATE accounting_companies
+		SET code = $1, name = $2, due_from_account_id = $3, due_to_account_id = $4, is_active = $5
+		WHERE id = $6 AND tenant_id = $7
+		RETURNING *
+	`, company.Code, company.Name, company.DueFromAccountID, company.DueToAccountID, company.IsActive,
+		company.ID, tenant)
+	if err != nil {
+		h.writeError(w, err, "Failed to update company")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"company": company,
+		"message": "Company updated successfully",
+	})
+}
+
+// DeleteCompany deactivates a company. Companies are never removed because
+// their accounts and transactions refer to them.
+func (h *AccountingHandler) DeleteCompany(w http.ResponseWriter, r *http.Request) {
+	tenant := tenantID(r)
+	company, err := loadCompany(h.db, tenant, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err, "Failed to delete company")
+		return
+	}
+
+	if _, err := h.db.Exec("UPDATE accounting_companies SET is_active = false WHERE id = $1 AND tenant_id = $2",
+		company.ID, tenant); err != nil {
+		h.logger.Error("Failed to delete company", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to delete company")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Company deleted successfully"})
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// recordEvent writes a module event to the accounting_events outbox inside
+// the caller's transaction, so the event exists exactly when the change that
+// raised it is committed. The platform delivers events from the outbox to
+// the tenant that raised them.
+func recordEvent(tx *sqlx.Tx, tenant string, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO accounting_events (tenant_id, event_type, payload) VALUES ($1, $2, $3)
+	`, tenant, eventType, string(data))
+	return err
+}
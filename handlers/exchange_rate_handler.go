@@ -0,0 +1,373 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// The ledger is kept in the functional currency, the default_currency
+// setting. A transaction in another currency keeps its line amounts in that
+// currency and is converted at the latest rate on or before its date; the
+// converted amounts are what reports add up. Reversals reuse the rate of the
+// transaction they reverse, so they cancel it exactly. Transactions in a
+// foreign currency require enable_multi_currency.
+
+// exchangeRatePlaces is the number of decimal places a rate is stored with
+const exchangeRatePlaces = 10
+
+// exchangeRateRequest is the body of a request that records an exchange rate
+type exchangeRateRequest struct {
+	FromCurrency string  `json:"from_currency"`
+	ToCurrency   string  `json:"to_currency"`
+	Rate         float64 `json:"rate"`
+	RateDate     string  `json:"rate_date"`
+}
+
+// toExchangeRate validates the request and converts it to a rate
+func (req exchangeRateRequest) toExchangeRate(source string, createdBy int) (*ExchangeRate, error) {
+	rate := &ExchangeRate{
+		FromCurrency: strings.ToUpper(strings.TrimSpace(req.FromCurrency)),
+		ToCurrency:   strings.ToUpper(strings.TrimSpace(req.ToCurrency)),
+		Rate:         req.Rate,
+		Source:       source,
+		CreatedBy:    &createdBy,
+	}
+	if !validCurrencyCode(rate.FromCurrency) || !validCurrencyCode(rate.ToCurrency) {
+		return nil, newBadRequestError("from_currency and to_currency must be three-letter currency codes")
+	}
+	if rate.FromCurrency == rate.ToCurrency {
+		return nil, newBadRequestError("from_currency and to_currency must differ")
+	}
+	if rate.Rate <= 0 {
+		return nil, newBadRequestError("rate must be positive")
+	}
+
+	date, err := parseDate(req.RateDate)
+	if err != nil {
+		return nil, newBadRequestError("Invalid rate_date")
+	}
+	rate.RateDate = date
+	return rate, nil
+}
+
+// validCurrencyCode reports whether code looks like an ISO 4217 code
+func validCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// checkCurrency rejects a currency other than the functional currency when
+// multi-currency is disabled
+func checkCurrency(settings Settings, currency string) error {
+	if currency != settings.DefaultCurrency && !settings.EnableMultiCurrency {
+		return newBadRequestError("Multi-currency is disabled, amounts must be in %s", settings.DefaultCurrency)
+	}
+	return nil
+}
+
+// lookupExchangeRate returns the latest rate from one currency to another on
+// or before date. A rate recorded the other way round is inverted; a rate in
+// the requested direction wins when both exist for the same date.
+func lookupExchangeRate(q sqlx.Queryer, tenant string, from, to string, date time.Time) (float64, error) {
+	var found struct {
+		FromCurrency string  `db:"from_currency"`
+		Rate         float64 `db:"rate"`
+	}
+	err := sqlx.Get(q, &found, `
+		SELECT from_currency, rate FROM accounting_exchange_rates
+		WHERE tenant_id = $4
+		  AND ((from_currency = $1 AND to_currency = $2) OR (from_currency = $2 AND to_currency = $1))
+		  AND rate_date <= $3
+		ORDER BY rate_date DESC, from_currency = $1 DESC
+		LIMIT 1
+	`, from, to, date.Format("2006-01-02"), tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, newBadRequestError("No exchange rate from %s to %s on or before %s", from, to, date.Format("2006-01-02"))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if found.FromCurrency == from {
+		return found.Rate, nil
+	}
+	scale := math.Pow10(exchangeRatePlaces)
+	return math.Round(scale/found.Rate) / scale, nil
+}
+
+// transactionExchangeRate returns the rate converting currency into the
+// functional currency on date
+func transactionExchangeRate(q sqlx.Queryer, tenant string, settings Settings, currency string, date time.Time) (float64, error) {
+	if err := checkCurrency(settings, currency); err != nil {
+		return 0, err
+	}
+	if currency == settings.DefaultCurrency {
+		return 1, nil
+	}
+	return lookupExchangeRate(q, tenant, currency, settings.DefaultCurrency, date)
+}
+
+// convertToFunctional converts the lines of txn into the functional currency
+// at txn.ExchangeRate, keeping their amounts in the transaction currency, and
+// returns the functional total. Converting line by line can leave debits and
+// credits a few minor units apart; the difference goes to the largest line on
+// the short side, the first one when several are equally large, so that the
+// ledger stays balanced and a reversal at the same rate mirrors it exactly.
+func convertToFunctional(txn *AccountingTransaction, functional string, mode RoundingMode) Money {
+	places := minorUnits(functional)
+	var debits, credits Money
+	for i := range txn.Lines {
+		line := &txn.Lines[i]
+		line.CurrencyDebitAmount = line.DebitAmount
+		line.CurrencyCreditAmount = line.CreditAmount
+		line.DebitAmount = line.CurrencyDebitAmount.Mul(txn.ExchangeRate, places, mode)
+		line.CreditAmount = line.CurrencyCreditAmount.Mul(txn.ExchangeRate, places, mode)
+		debits += line.DebitAmount
+		credits += line.CreditAmount
+	}
+	if debits == credits {
+		return debits
+	}
+
+	var largest *AccountingTransactionLine
+	for i := range txn.Lines {
+		line := &txn.Lines[i]
+		switch {
+		case debits < credits && (largest == nil || line.DebitAmount > largest.DebitAmount):
+			largest = line
+		case debits > credits && (largest == nil || line.CreditAmount > largest.CreditAmount):
+			largest = line
+		}
+	}
+	if debits < credits {
+		largest.DebitAmount += credits - debits
+		return credits
+	}
+	largest.CreditAmount += debits - credits
+	return debits
+}
+
+// upsertExchangeRate records a rate of a tenant, replacing the rate of the
+// same currency pair and date if there is one
+func upsertExchangeRate(q sqlx.Queryer, tenant string, rate *ExchangeRate) error {
+	err := sqlx.Get(q, rate, `
+		UPDATE accounting_exchange_rates SET rate = $1, source = $2, created_by = $3
+		WHERE tenant_id = $4 AND from_currency = $5 AND to_currency = $6 AND rate_date = $7
+		RETURNING *
+	`, rate.Rate, rate.Source, rate.CreatedBy, tenant, rate.FromCurrency, rate.ToCurrency, rate.RateDate)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	return sqlx.Get(q, rate, `
+		INSERT INTO accounting_exchange_rates
+		(tenant_id, from_currency, to_currency, rate, rate_date, source, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING *
+	`, tenant, rate.FromCurrency, rate.ToCurrency, rate.Rate, rate.RateDate, rate.Source, rate.CreatedBy)
+}
+
+// GetExchangeRates retrieves exchange rates, newest first
+func (h *AccountingHandler) GetExchangeRates(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	fromCurrency := strings.ToUpper(query.Get("from_currency"))
+	toCurrency := strings.ToUpper(query.Get("to_currency"))
+	startDate := query.Get("start_date")
+	endDate := query.Get("end_date")
+
+	qb := sdk.NewQueryBuilder("SELECT * FROM accounting_exchange_rates WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition("from_currency = $%d", fromCurrency)
+	qb.AddOptionalCondition("to_currency = $%d", toCurrency)
+	qb.AddOptionalCondition("rate_date >= $%d", startDate)
+	qb.AddOptionalCondition("rate_date <= $%d", endDate)
+
+	sqlQuery, args := qb.Build()
+	sqlQuery += " ORDER BY rate_date DESC, from_currency, to_currency"
+
+	var rates []ExchangeRate
+	if err := h.db.Select(&rates, sqlQuery, args...); err != nil {
+		h.logger.Error("Failed to fetch exchange rates", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch exchange rates")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"exchange_rates": rates,
+		"count":          len(rates),
+	})
+}
+
+// CreateExchangeRate records the rate of a currency pair for a date,
+// replacing any rate already recorded for that pair and date
+func (h *AccountingHandler) CreateExchangeRate(w http.ResponseWriter, r *http.Request) {
+	var req exchangeRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	rate, err := req.toExchangeRate("manual", currentUserID(r))
+	if err != nil {
+		h.writeError(w, err, "Failed to create exchange rate")
+		return
+	}
+
+	if err := upsertExchangeRate(h.db, tenantID(r), rate); err != nil {
+		h.writeError(w, err, "Failed to create exchange rate")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"exchange_rate": rate,
+		"message":       "Exchange rate recorded successfully",
+	})
+}
+
+// parseExchangeRateCSV reads rates from CSV text with a header row naming
+// the from_currency, to_currency, rate and rate_date columns
+func parseExchangeRateCSV(content string) ([]exchangeRateRequest, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, newBadRequestError("CSV content must start with a header row")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"from_currency", "to_currency", "rate", "rate_date"} {
+		if _, ok := columns[name]; !ok {
+			return nil, newBadRequestError("CSV header is missing the %s column", name)
+		}
+	}
+
+	var requests []exchangeRateRequest
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, newBadRequestError("Row %d: %s", row, err.Error())
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[columns["rate"]]), 64)
+		if err != nil {
+			return nil, newBadRequestError("Row %d: invalid rate %q", row, record[columns["rate"]])
+		}
+		requests = append(requests, exchangeRateRequest{
+			FromCurrency: record[columns["from_currency"]],
+			ToCurrency:   record[columns["to_currency"]],
+			Rate:         rate,
+			RateDate:     strings.TrimSpace(record[columns["rate_date"]]),
+		})
+	}
+
+	return requests, nil
+}
+
+// ImportExchangeRates records many rates at once, from a list of rates or
+// from CSV content with from_currency, to_currency, rate and rate_date
+// columns. Rates already recorded for a pair and date are replaced. The
+// import is all or nothing.
+func (h *AccountingHandler) ImportExchangeRates(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Rates   []exchangeRateRequest `json:"rates"`
+		Content string                `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	requests := req.Rates
+	if req.Content != "" {
+		parsed, err := parseExchangeRateCSV(req.Content)
+		if err != nil {
+			h.writeError(w, err, "Failed to import exchange rates")
+			return
+		}
+		requests = append(requests, parsed...)
+	}
+	if len(requests) == 0 {
+		sdk.WriteBadRequest(w, "Either rates or content is required")
+		return
+	}
+
+	userID := currentUserID(r)
+	var rates []*ExchangeRate
+	for i, rateReq := range requests {
+		rate, err := rateReq.toExchangeRate("import", userID)
+		if err != nil {
+			h.writeError(w, newBadRequestError("Rate %d: %s", i+1, err.Error()), "Failed to import exchange rates")
+			return
+		}
+		rates = append(rates, rate)
+	}
+
+	tenant := tenantID(r)
+	err := h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		for _, rate := range rates {
+			if err := upsertExchangeRate(tx, tenant, rate); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to import exchange rates")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"exchange_rates": rates,
+		"count":          len(rates),
+		"message":        "Exchange rates imported successfully",
+	})
+}
+
+// DeleteExchangeRate deletes an exchange rate. Transactions already
+// converted at the rate keep it.
+func (h *AccountingHandler) DeleteExchangeRate(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid exchange rate ID")
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM accounting_exchange_rates WHERE id = $1 AND tenant_id = $2", id, tenantID(r))
+	if err != nil {
+		h.logger.Error("Failed to delete exchange rate", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to delete exchange rate")
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		sdk.WriteNotFound(w, "Exchange rate not found")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Exchange rate deleted successfully"})
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// Fiscal periods are open, soft closed or hard closed. Postings dated into a
+// period that is not open are rejected. A soft close can be reopened by an
+// authorized user, a hard close is final.
+
+const fiscalPeriodSelect = `
+	SELECT *, CURRENT_DATE BETWEEN start_date AND end_date AS is_current
+	FROM accounting_fiscal_periods
+`
+
+// generateFiscalCalendar builds the periods of a fiscal year. A fiscal year
+// is named after the calendar year in which it starts, so with a July start
+// FY2025 runs from July 2025 to June 2026.
+func generateFiscalCalendar(fiscalYear, startMonth int, length string) ([]FiscalPeriod, error) {
+	var months int
+	switch length {
+	case "monthly":
+		months = 1
+	case "quarterly":
+		months = 3
+	case "yearly":
+		months = 12
+	default:
+		return nil, newBadRequestError("Unsupported fiscal period length: %s", length)
+	}
+
+	yearStart := time.Date(fiscalYear, time.Month(startMonth), 1, 0, 0, 0, 0, time.UTC)
+
+	var periods []FiscalPeriod
+	for i := 0; i < 12/months; i++ {
+		start := yearStart.AddDate(0, i*months, 0)
+		end := start.AddDate(0, months, -1)
+
+		var name string
+		switch length {
+		case "monthly":
+			name = start.Format("Jan 2006")
+		case "quarterly":
+			name = fmt.Sprintf("Q%d FY%d", i+1, fiscalYear)
+		default:
+			name = fmt.Sprintf("FY%d", fiscalYear)
+		}
+
+		periods = append(periods, FiscalPeriod{
+			PeriodName:   name,
+			FiscalYear:   fiscalYear,
+			PeriodNumber: i + 1,
+			StartDate:    start,
+			EndDate:      end,
+			Status:       "open",
+		})
+	}
+
+	return periods, nil
+}
+
+// fiscalYearBounds returns the first and last day of a fiscal year
+func fiscalYearBounds(fiscalYear, startMonth int) (time.Time, time.Time) {
+	start := time.Date(fiscalYear, time.Month(startMonth), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(1, 0, -1)
+}
+
+// fiscalPeriodBounds returns the first and last day of a period of a fiscal
+// year, or of the whole year when period is 0. Periods are read from the
+// stored calendar, falling back to the calendar the settings would generate.
+func fiscalPeriodBounds(q sqlx.Queryer, tenant string, fiscalYear, period int) (time.Time, time.Time, error) {
+	settings, err := loadSettings(q, tenant)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if period == 0 {
+		start, end := fiscalYearBounds(fiscalYear, settings.FiscalYearStart)
+		return start, end, nil
+	}
+
+	var stored FiscalPeriod
+	err = sqlx.Get(q, &stored, fiscalPeriodSelect+" WHERE tenant_id = $1 AND fiscal_year = $2 AND period_number = $3",
+		tenant, fiscalYear, period)
+	if err == nil {
+		return stored.StartDate, stored.EndDate, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, time.Time{}, err
+	}
+
+	periods, err := generateFiscalCalendar(fiscalYear, settings.FiscalYearStart, settings.FiscalPeriodLength)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if period < 1 || period > len(periods) {
+		return time.Time{}, time.Time{}, newBadRequestError("Fiscal year %d has no period %d", fiscalYear, period)
+	}
+	return periods[period-1].StartDate, periods[period-1].EndDate, nil
+}
+
+// checkPostingPeriod rejects a posting dated into a fiscal period that is not
+// open. Dates without a defined period are accepted so that enabling fiscal
+// periods does not block posting before a calendar has been generated.
+func checkPostingPeriod(q sqlx.Queryer, tenant string, date time.Time) error {
+	period, err := postingPeriod(q, tenant, date)
+	if err != nil {
+		return err
+	}
+	if period != nil && period.Status != "open" {
+		return newBadRequestError("Posting date %s falls in closed fiscal period %s",
+			date.Format("2006-01-02"), period.PeriodName)
+	}
+	return nil
+}
+
+// postingPeriod returns the fiscal period covering date, or nil when fiscal
+// periods are disabled or no period covers it
+func postingPeriod(q sqlx.Queryer, tenant string, date time.Time) (*FiscalPeriod, error) {
+	settings, err := loadSettings(q, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.EnableFiscalPeriods {
+		return nil, nil
+	}
+
+	var period FiscalPeriod
+	err = sqlx.Get(q, &period, fiscalPeriodSelect+`
+		WHERE tenant_id = $1 AND $2 BETWEEN start_date AND end_date
+		LIMIT 1
+	`, tenant, date.Format("2006-01-02"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &period, nil
+}
+
+// GetFiscalPeriods retrieves fiscal periods
+func (h *AccountingHandler) GetFiscalPeriods(w http.ResponseWriter, r *http.Request) {
+	fiscalYear := r.URL.Query().Get("fiscal_year")
+	status := r.URL.Query().Get("status")
+
+	qb := sdk.NewQueryBuilder(fiscalPeriodSelect + " WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition("fiscal_year = $%d", fiscalYear)
+	qb.AddOptionalCondition("status = $%d", status)
+
+	query, args := qb.Build()
+	query += " ORDER BY start_date"
+
+	var periods []FiscalPeriod
+	if err := h.db.Select(&periods, query, args...); err != nil {
+		h.logger.Error("Failed to fetch fiscal periods", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch fiscal periods")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"periods": periods,
+		"count":   len(periods),
+	})
+}
+
+// GetFiscalPeriod retrieves a single fiscal period
+func (h *AccountingHandler) GetFiscalPeriod(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid fiscal period ID")
+		return
+	}
+
+	var period FiscalPeriod
+	if err := h.db.Get(&period, fiscalPeriodSelect+" WHERE id = $1 AND tenant_id = $2", id, tenantID(r)); err != nil {
+		h.writeError(w, err, "Failed to fetch fiscal period")
+		return
+	}
+
+	sdk.WriteSuccess(w, period)
+}
+
+// GenerateFiscalPeriods creates the fiscal calendar for a year from the
+// fiscal_year_start and fiscal_period_length settings. A year that already
+// has periods is left untouched, so generating it twice is safe.
+func (h *AccountingHandler) GenerateFiscalPeriods(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FiscalYear int `json:"fiscal_year"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+	if req.FiscalYear < 1900 || req.FiscalYear > 9999 {
+		sdk.WriteBadRequest(w, "A valid fiscal_year is required")
+		return
+	}
+
+	tenant := tenantID(r)
+	settings, err := loadSettings(h.db, tenant)
+	if err != nil {
+		h.logger.Error("Failed to load settings", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to generate fiscal periods")
+		return
+	}
+	if !settings.EnableFiscalPeriods {
+		sdk.WriteBadRequest(w, "Fiscal periods are disabled")
+		return
+	}
+
+	periods, err := generateFiscalCalendar(req.FiscalYear, settings.FiscalYearStart, settings.FiscalPeriodLength)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate fiscal periods")
+		return
+	}
+
+	created := 0
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		var existing int
+		if err := tx.Get(&existing, "SELECT COUNT(*) FROM accounting_fiscal_periods WHERE tenant_id = $1 AND fiscal_year = $2",
+			tenant, req.FiscalYear); err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		for _, period := range periods {
+			var overlaps bool
+			err := tx.Get(&overlaps, `
+				SELECT EXISTS(SELECT 1 FROM accounting_fiscal_periods
+				              WHERE tenant_id = $3 AND start_date <= $2 AND end_date >= $1)
+			`, period.StartDate, period.EndDate, tenant)
+			if err != nil {
+				return err
+			}
+			if overlaps {
+				return newBadRequestError("Period %s overlaps an existing fiscal period", period.PeriodName)
+			}
+
+			_, err = tx.Exec(`
+				INSERT INTO accounting_fiscal_periods
+				(tenant_id, period_name, fiscal_year, period_number, start_date, end_date)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, tenant, period.PeriodName, period.FiscalYear, period.PeriodNumber, period.StartDate, period.EndDate)
+			if err != nil {
+				return err
+			}
+			created++
+		}
+		return nil
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to generate fiscal periods")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"fiscal_year": req.FiscalYear,
+		"created":     created,
+		"message":     "Fiscal periods generated successfully",
+	})
+}
+
+// CloseFiscalPeriod soft or hard closes a fiscal period
+func (h *AccountingHandler) CloseFiscalPeriod(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid fiscal period ID")
+		return
+	}
+
+	var req struct {
+		Mode string `json:"mode"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sdk.WriteBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+	if req.Mode == "" {
+		req.Mode = "soft"
+	}
+	if err := sdk.ValidateEnum("mode", req.Mode, []string{"soft", "hard"}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	status := req.Mode + "_closed"
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		var period FiscalPeriod
+		if err := tx.Get(&period, fiscalPeriodSelect+" WHERE id = $1 AND tenant_id = $2 FOR UPDATE", id, tenant); err != nil {
+			return err
+		}
+		if period.Status == "hard_closed" {
+			return newBadRequestError("Fiscal period %s is hard closed", period.PeriodName)
+		}
+		if period.Status == status {
+			return newBadRequestError("Fiscal period %s is already %s", period.PeriodName, status)
+		}
+
+		_, err := tx.Exec(`
+			UPDATE accounting_fiscal_periods
+			SET status = $1, closed_by = $2, closed_at = CURRENT_TIMESTAMP
+			WHERE id = $3 AND tenant_id = $4
+		`, status, currentUserID(r), id, tenant)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to close fiscal period")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"status":  status,
+		"message": "Fiscal period closed successfully",
+	})
+}
+
+// ReopenFiscalPeriod reopens a soft closed fiscal period
+func (h *AccountingHandler) ReopenFiscalPeriod(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid fiscal period ID")
+		return
+	}
+
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		var period FiscalPeriod
+		if err := tx.Get(&period, fiscalPeriodSelect+" WHERE id = $1 AND tenant_id = $2 FOR UPDATE", id, tenant); err != nil {
+			return err
+		}
+		switch period.Status {
+		case "open":
+			return newBadRequestError("Fiscal period %s is already open", period.PeriodName)
+		case "hard_closed":
+			return newBadRequestError("Fiscal period %s is hard closed and cannot be reopened", period.PeriodName)
+		}
+
+		_, err := tx.Exec(`
+			UPDATE accounting_fiscal_periods
+			SET status = 'open', closed_by = NULL, closed_at = NULL
+			WHERE id = $1 AND tenant_id = $2
+		`, id, tenant)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to reopen fiscal period")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Fiscal period reopened successfully"})
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// A revaluation restates the foreign currency balances of monetary accounts
+// (cash, receivables, payables) at the exchange rate of a period-end date.
+// For each account and currency the balance in that currency is converted at
+// the period-end rate and compared with the amount booked for it in the
+// functional currency; the differences are posted as one journal entry
+// against the unrealized FX gain and loss accounts, which reverses
+// automatically at the start of the next period. Only transactions in a
+// foreign currency count towards the balances, so earlier revaluations do not.
+
+// fxRevaluationPlan is the revaluation computed for a date
+type fxRevaluationPlan struct {
+	RevaluationDate string              `json:"revaluation_date"`
+	ReverseOn       string              `json:"reverse_on"`
+	Currency        string              `json:"currency"`
+	GainAccount     ChartOfAccount      `json:"gain_account"`
+	LossAccount     ChartOfAccount      `json:"loss_account"`
+	TotalGain       Money               `json:"total_gain"`
+	TotalLoss       Money               `json:"total_loss"`
+	NetAdjustment   Money               `json:"net_adjustment"`
+	Accounts        []FXRevaluationLine `json:"accounts"`
+	Lines           []JournalEntryLine  `json:"lines"`
+}
+
+// fxAccount loads the unrealized FX gain or loss account named by a setting,
+// preferring the company's own account to a shared one
+func fxAccount(q sqlx.Queryer, tenant, company string, setting, code string) (*ChartOfAccount, error) {
+	if code == "" {
+		return nil, newBadRequestError("The %s setting is not configured", setting)
+	}
+
+	account, err := accountByCode(q, tenant, company, code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newBadRequestError("Account %s of the %s setting not found", code, setting)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if account.AccountType != "revenue" && account.AccountType != "expense" {
+		return nil, newBadRequestError("Account %s of the %s setting must be a revenue or expense account", code, setting)
+	}
+	return account, nil
+}
+
+// buildFXRevaluation computes the revaluation of the monetary accounts of a
+// company on date, or of the books of no company when company is empty
+func buildFXRevaluation(q sqlx.Queryer, tenant, company string, date time.Time) (*fxRevaluationPlan, error) {
+	settings, err := loadSettings(q, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.EnableMultiCurrency {
+		return nil, newBadRequestError("Multi-currency is disabled")
+	}
+	if company != "" {
+		if _, err := requireCompany(q, tenant, company); err != nil {
+			return nil, err
+		}
+	}
+
+	gain, err := fxAccount(q, tenant, company, "unrealized_fx_gain_account_code", settings.UnrealizedFXGainAccountCode)
+	if err != nil {
+		return nil, err
+	}
+	loss, err := fxAccount(q, tenant, company, "unrealized_fx_loss_account_code", settings.UnrealizedFXLossAccountCode)
+	if err != nil {
+		return nil, err
+	}
+
+	reverseOn, err := nextPeriodStart(q, tenant, date)
+	if err != nil {
+		return nil, err
+	}
+
+	// Revaluations must not overlap, or both would be in the ledger at once
+	var overlapping FXRevaluation
+	err = sqlx.Get(q, &overlapping, `
+		SELECT * FROM accounting_fx_revaluations
+		WHERE tenant_id = $3 AND revaluation_date < $2 AND reverse_on > $1
+		  AND company_id IS NOT DISTINCT FROM NULLIF($4, '')::uuid
+		ORDER BY revaluation_date DESC
+		LIMIT 1
+	`, date.Format("2006-01-02"), reverseOn.Format("2006-01-02"), tenant, company)
+	if err == nil {
+		return nil, newBadRequestError("The revaluation of %s is in effect until %s",
+			overlapping.RevaluationDate.Format("2006-01-02"), overlapping.ReverseOn.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	accountScope, transactionScope, scopeArgs := companyBooks(company).conditions(4)
+	args := append([]interface{}{settings.DefaultCurrency, date.Format("2006-01-02"), tenant}, scopeArgs...)
+	var balances []FXRevaluationLine
+	if err := sqlx.Select(q, &balances, `
+		SELECT coa.id AS account_id, coa.account_code, coa.account_name, at.currency,
+		       SUM(atl.currency_debit_amount - atl.currency_credit_amount) AS foreign_balance,
+		       SUM(atl.debit_amount - atl.credit_amount) AS booked_amount
+		FROM chart_of_accounts coa
+		JOIN accounting_transaction_lines atl ON atl.account_id = coa.id
+		JOIN accounting_transactions at ON at.id = atl.transaction_id
+		WHERE coa.tenant_id = $3
+		  AND coa.is_monetary = true
+		  AND at.currency <> $1
+		  AND at.transaction_date <= $2
+		  AND at.status IN ('posted', 'void')
+		  AND `+accountScope+`
+		  AND `+transactionScope+`
+		GROUP BY coa.id, coa.account_code, coa.account_name, at.currency
+		ORDER BY coa.account_code, at.currency
+	`, args...); err != nil {
+		return nil, err
+	}
+
+	plan := &fxRevaluationPlan{
+		RevaluationDate: date.Format("2006-01-02"),
+		ReverseOn:       reverseOn.Format("2006-01-02"),
+		Currency:        settings.DefaultCurrency,
+		GainAccount:     *gain,
+		LossAccount:     *loss,
+	}
+
+	places := minorUnits(settings.DefaultCurrency)
+	rates := map[string]float64{}
+	for _, balance := range balances {
+		if balance.ForeignBalance == 0 && balance.BookedAmount == 0 {
+			continue
+		}
+
+		rate, ok := rates[balance.Currency]
+		if !ok {
+			if rate, err = lookupExchangeRate(q, tenant, balance.Currency, settings.DefaultCurrency, date); err != nil {
+				return nil, err
+			}
+			rates[balance.Currency] = rate
+		}
+
+		balance.ExchangeRate = rate
+		balance.RevaluedAmount = balance.ForeignBalance.Mul(rate, places, settings.RoundingMode)
+		balance.Adjustment = balance.RevaluedAmount - balance.BookedAmount
+		plan.Accounts = append(plan.Accounts, balance)
+		if balance.Adjustment == 0 {
+			continue
+		}
+
+		description := fmt.Sprintf("FX revaluation of %s %s at %s", balance.ForeignBalance, balance.Currency,
+			strconv.FormatFloat(rate, 'f', -1, 64))
+		line := JournalEntryLine{AccountID: balance.AccountID, Description: &description}
+		if balance.Adjustment > 0 {
+			line.DebitAmount = balance.Adjustment
+			plan.TotalGain += balance.Adjustment
+		} else {
+			line.CreditAmount = -balance.Adjustment
+			plan.TotalLoss -= balance.Adjustment
+		}
+		plan.Lines = append(plan.Lines, line)
+	}
+
+	if len(plan.Lines) == 0 {
+		return nil, newBadRequestError("No foreign currency balances need revaluing on %s", plan.RevaluationDate)
+	}
+
+	if plan.TotalGain > 0 {
+		description := "Unrealized FX gain"
+		plan.Lines = append(plan.Lines, JournalEntryLine{
+			AccountID:    gain.ID,
+			CreditAmount: plan.TotalGain,
+			Description:  &description,
+			Account:      gain,
+		})
+	}
+	if plan.TotalLoss > 0 {
+		description := "Unrealized FX loss"
+		plan.Lines = append(plan.Lines, JournalEntryLine{
+			AccountID:   loss.ID,
+			DebitAmount: plan.TotalLoss,
+			Description: &description,
+			Account:     loss,
+		})
+	}
+	plan.NetAdjustment = plan.TotalGain - plan.TotalLoss
+
+	return plan, nil
+}
+
+// loadFXRevaluation fetches a revaluation with its account lines
+func loadFXRevaluation(q sqlx.Queryer, tenant string, id int) (*FXRevaluation, error) {
+	var revaluation FXRevaluation
+	if err := sqlx.Get(q, &revaluation,
+		"SELECT * FROM accounting_fx_revaluations WHERE id = $1 AND tenant_id = $2", id, tenant); err != nil {
+		return nil, err
+	}
+	if err := sqlx.Select(q, &revaluation.Lines, `
+		SELECT l.*, coa.account_code, coa.account_name
+		FROM accounting_fx_revaluation_lines l
+		JOIN chart_of_accounts coa ON coa.id = l.account_id
+		WHERE l.revaluation_id = $1 AND l.tenant_id = $2
+		ORDER BY coa.account_code, l.currency
+	`, id, tenant); err != nil {
+		return nil, err
+	}
+	return &revaluation, nil
+}
+
+// GetFXRevaluations lists revaluation runs, newest first
+func (h *AccountingHandler) GetFXRevaluations(w http.ResponseWriter, r *http.Request) {
+	qb := sdk.NewQueryBuilder("SELECT * FROM accounting_fx_revaluations WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition("company_id = $%d", companyID(r))
+
+	query, args := qb.Build()
+	query += " ORDER BY revaluation_date DESC, id DESC"
+
+	var revaluations []FXRevaluation
+	if err := h.db.Select(&revaluations, query, args...); err != nil {
+		h.logger.Error("Failed to fetch FX revaluations", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch FX revaluations")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"revaluations": revaluations,
+		"count":        len(revaluations),
+	})
+}
+
+// GetFXRevaluation retrieves a revaluation run with its account lines
+func (h *AccountingHandler) GetFXRevaluation(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid revaluation ID")
+		return
+	}
+
+	revaluation, err := loadFXRevaluation(h.db, tenantID(r), id)
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch FX revaluation")
+		return
+	}
+
+	sdk.WriteSuccess(w, revaluation)
+}
+
+// PreviewFXRevaluation shows the revaluation for a date without posting it
+func (h *AccountingHandler) PreviewFXRevaluation(w http.ResponseWriter, r *http.Request) {
+	date, err := parseDate(r.URL.Query().Get("date"))
+	if err != nil {
+		sdk.WriteBadRequest(w, "A valid date is required")
+		return
+	}
+
+	plan, err := buildFXRevaluation(h.db, tenantID(r), companyID(r), date)
+	if err != nil {
+		h.writeError(w, err, "Failed to preview FX revaluation")
+		return
+	}
+
+	sdk.WriteSuccess(w, plan)
+}
+
+// RunFXRevaluation revalues monetary accounts at the rates of a period-end
+// date and posts the result as a journal entry that reverses on the first day
+// of the next period
+func (h *AccountingHandler) RunFXRevaluation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Date string `json:"date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	date, err := parseDate(req.Date)
+	if err != nil {
+		sdk.WriteBadRequest(w, "A valid date is required")
+		return
+	}
+
+	userID := currentUserID(r)
+	tenant := tenantID(r)
+	company := companyID(r)
+	var revaluation *FXRevaluation
+	var entry *JournalEntry
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		// Serialize concurrent runs so that they cannot overlap
+		if _, err := tx.Exec("LOCK TABLE accounting_fx_revaluations IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+
+		plan, err := buildFXRevaluation(tx, tenant, company, date)
+		if err != nil {
+			return err
+		}
+		if err := checkPostingPeriod(tx, tenant, date); err != nil {
+			return err
+		}
+
+		var id int
+		err = tx.QueryRow(`
+			INSERT INTO accounting_fx_revaluations
+			(tenant_id, company_id, revaluation_date, reverse_on, currency, gain_account_id, loss_account_id,
+			 total_gain, total_loss, net_adjustment, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING id
+		`, tenant, optionalCompany(company), plan.RevaluationDate, plan.ReverseOn, plan.Currency, plan.GainAccount.ID,
+			plan.LossAccount.ID, plan.TotalGain, plan.TotalLoss, plan.NetAdjustment, userID).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		for _, line := range plan.Accounts {
+			if _, err := tx.Exec(`
+				INSERT INTO accounting_fx_revaluation_lines
+				(tenant_id, revaluation_id, account_id, currency, foreign_balance, booked_amount, exchange_rate,
+				 revalued_amount, adjustment)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			`, tenant, id, line.AccountID, line.Currency, line.ForeignBalance, line.BookedAmount, line.ExchangeRate,
+				line.RevaluedAmount, line.Adjustment); err != nil {
+				return err
+			}
+		}
+
+		reverseOn, _ := parseDate(plan.ReverseOn)
+		description := "Unrealized FX revaluation " + plan.RevaluationDate
+		reference := fmt.Sprintf("FX-REVALUATION-%d", id)
+		entry = &JournalEntry{
+			CompanyID:     optionalCompany(company),
+			EntryDate:     date,
+			Description:   &description,
+			Reference:     &reference,
+			Status:        "posted",
+			CreatedBy:     userID,
+			AutoReverseOn: &reverseOn,
+			Lines:         plan.Lines,
+		}
+		if err := h.insertJournalEntry(tx, tenant, entry); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE accounting_fx_revaluations SET journal_entry_id = $1 WHERE id = $2 AND tenant_id = $3",
+			entry.ID, id, tenant); err != nil {
+			return err
+		}
+
+		revaluation, err = loadFXRevaluation(tx, tenant, id)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to run FX revaluation")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"revaluation": revaluation,
+		"entry":       entry,
+		"message":     "FX revaluation posted successfully",
+	})
+}
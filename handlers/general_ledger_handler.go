@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// The general ledger report lists the posted lines behind account balances,
+// ordered by account and then by date, transaction and line. Each account
+// starts from its balance brought forward from before the start date, and
+// each line carries the running balance of its account after it. Balances
+// have the natural sign of the account type, as on the balance sheet. JSON
+// output is paginated with an opaque cursor; CSV output is the whole ledger.
+
+const (
+	generalLedgerPageSize    = 100
+	generalLedgerMaxPageSize = 1000
+)
+
+// generalLedgerCursor is the position of the last line of a page
+type generalLedgerCursor struct {
+	AccountCode     string `json:"c"`
+	AccountID       int    `json:"a"`
+	TransactionDate string `json:"d"`
+	TransactionID   int    `json:"t"`
+	LineID          int    `json:"l"`
+}
+
+// encode returns the cursor as an opaque URL-safe string
+func (c generalLedgerCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeGeneralLedgerCursor parses a cursor returned by a previous page
+func decodeGeneralLedgerCursor(value string) (*generalLedgerCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, newBadRequestError("Invalid cursor")
+	}
+	var cursor generalLedgerCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.LineID <= 0 {
+		return nil, newBadRequestError("Invalid cursor")
+	}
+	if _, err := parseDate(cursor.TransactionDate); err != nil {
+		return nil, newBadRequestError("Invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// parseAccountIDs reads account IDs given as repeated or comma-separated
+// values
+func parseAccountIDs(values []string) ([]int, error) {
+	var ids []int
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			id, err := strconv.Atoi(part)
+			if err != nil || id <= 0 {
+				return nil, newBadRequestError("Invalid account_id %q", part)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// generalLedgerConditions returns the SQL conditions restricting the accounts
+// aliased coa and the transactions aliased at to the report, and their
+// arguments. $1 is the tenant, $2 the start date and $3 the end date.
+func generalLedgerConditions(tenant string, startDate, endDate time.Time, scope reportScope, accountIDs []int) (accounts, transactions string, args []interface{}) {
+	accountScope, transactionScope, scopeArgs := scope.conditions(4)
+	args = append([]interface{}{tenant, startDate, endDate}, scopeArgs...)
+
+	accounts = "coa.tenant_id = $1 AND " + accountScope
+	if len(accountIDs) > 0 {
+		placeholders := make([]string, len(accountIDs))
+		for i, id := range accountIDs {
+			args = append(args, id)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		accounts += " AND coa.id IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	transactions = "at.tenant_id = $1 AND at.status IN ('posted', 'void') AND " + transactionScope
+	return accounts, transactions, args
+}
+
+// accountActivity is the activity of an account over a report period: its
+// debit-minus-credit balance brought forward and its debits and credits
+// within the period
+type accountActivity struct {
+	AccountID      int    `db:"account_id"`
+	AccountCode    string `db:"account_code"`
+	AccountName    string `db:"account_name"`
+	AccountType    string `db:"account_type"`
+	ParentID       *int   `db:"parent_id"`
+	OpeningBalance Money  `db:"opening_balance"`
+	Debit          Money  `db:"debit"`
+	Credit         Money  `db:"credit"`
+}
+
+// loadAccountActivity returns the activity of the given accounts, or of
+// every account, between startDate and endDate within scope, in account code
+// order. Inactive accounts are included when they are asked for or have
+// activity up to endDate.
+func loadAccountActivity(q sqlx.Queryer, tenant string, startDate, endDate time.Time, scope reportScope, accountIDs []int) ([]accountActivity, error) {
+	accountConditions, transactionConditions, args := generalLedgerConditions(tenant, startDate, endDate, scope, accountIDs)
+	having := ""
+	if len(accountIDs) == 0 {
+		having = "HAVING coa.is_active OR COUNT(atl.id) > 0"
+	}
+
+	activity := []accountActivity{}
+	err := sqlx.Select(q, &activity, `
+		SELECT
+			coa.id AS account_id,
+			coa.account_code,
+			coa.account_name,
+			coa.account_type,
+			coa.parent_id,
+			COALESCE(SUM(CASE WHEN at.transaction_date < $2 THEN atl.debit_amount - atl.credit_amount END), 0)
+				AS opening_balance,
+			COALESCE(SUM(CASE WHEN at.transaction_date >= $2 THEN atl.debit_amount END), 0) AS debit,
+			COALESCE(SUM(CASE WHEN at.transaction_date >= $2 THEN atl.credit_amount END), 0) AS credit
+		FROM chart_of_accounts coa
+		LEFT JOIN (
+			accounting_transaction_lines atl
+			JOIN accounting_transactions at ON at.id = atl.transaction_id
+		) ON atl.account_id = coa.id AND at.transaction_date <= $3 AND `+transactionConditions+`
+		WHERE `+accountConditions+`
+		GROUP BY coa.id, coa.account_code, coa.account_name, coa.account_type, coa.parent_id, coa.is_active
+		`+having+`
+		ORDER BY coa.account_code, coa.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
+
+// GetGeneralLedger lists the posted lines of one or more accounts, or of
+// every account, between start_date and end_date with running balances, for
+// the company of the request or for every company
+func (h *AccountingHandler) GetGeneralLedger(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	startDate, err := parseDate(params.Get("start_date"))
+	if err != nil {
+		sdk.WriteBadRequest(w, "A valid start_date is required")
+		return
+	}
+	endDate, err := parseDate(params.Get("end_date"))
+	if err != nil {
+		sdk.WriteBadRequest(w, "A valid end_date is required")
+		return
+	}
+	if endDate.Before(startDate) {
+		sdk.WriteBadRequest(w, "end_date cannot be before start_date")
+		return
+	}
+
+	format := params.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if err := sdk.ValidateEnum("format", format, []string{"json", "csv"}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	limit := generalLedgerPageSize
+	if value := params.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > generalLedgerMaxPageSize {
+			sdk.WriteBadRequest(w, fmt.Sprintf("limit must be between 1 and %d", generalLedgerMaxPageSize))
+			return
+		}
+	}
+	var cursor *generalLedgerCursor
+	if value := params.Get("cursor"); value != "" {
+		if cursor, err = decodeGeneralLedgerCursor(value); err != nil {
+			h.writeError(w, err, "Failed to generate general ledger")
+			return
+		}
+	}
+
+	accountIDs, err := parseAccountIDs(params["account_id"])
+	if err != nil {
+		h.writeError(w, err, "Failed to generate general ledger")
+		return
+	}
+
+	tenant := tenantID(r)
+	settings, err := loadSettings(h.db, tenant)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate general ledger")
+		return
+	}
+	scope, err := requestReportScope(h.db, r)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate general ledger")
+		return
+	}
+	accountConditions, transactionConditions, args := generalLedgerConditions(tenant, startDate, endDate, scope, accountIDs)
+
+	activity, err := loadAccountActivity(h.db, tenant, startDate, endDate, scope, accountIDs)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate general ledger")
+		return
+	}
+
+	accounts := make([]GeneralLedgerAccount, len(activity))
+	found := make(map[int]*GeneralLedgerAccount, len(activity))
+	for i, row := range activity {
+		opening := naturalAmount(row.AccountType, row.OpeningBalance)
+		accounts[i] = GeneralLedgerAccount{
+			AccountID:      row.AccountID,
+			AccountCode:    row.AccountCode,
+			AccountName:    row.AccountName,
+			AccountType:    row.AccountType,
+			OpeningBalance: opening,
+			TotalDebit:     row.Debit,
+			TotalCredit:    row.Credit,
+			ClosingBalance: opening + naturalAmount(row.AccountType, row.Debit-row.Credit),
+		}
+		found[row.AccountID] = &accounts[i]
+	}
+	for _, id := range accountIDs {
+		if found[id] == nil {
+			sdk.WriteNotFound(w, fmt.Sprintf("Account %d not found", id))
+			return
+		}
+	}
+
+	// Movement is the running total of the account's lines within the
+	// period, computed before the cursor filter so that it carries over
+	// between pages
+	query := `
+		SELECT * FROM (
+			SELECT
+				atl.id AS line_id,
+				atl.account_id,
+				coa.account_code,
+				at.id AS transaction_id,
+				at.transaction_number,
+				at.transaction_date,
+				at.reference_type,
+				at.reference_id,
+				COALESCE(atl.description, at.description) AS description,
+				atl.debit_amount,
+				atl.credit_amount,
+				SUM(
+					CASE
+						WHEN coa.account_type IN ('asset', 'expense') THEN atl.debit_amount - atl.credit_amount
+						ELSE atl.credit_amount - atl.debit_amount
+					END
+				) OVER (PARTITION BY atl.account_id ORDER BY at.transaction_date, at.id, atl.id) AS movement
+			FROM accounting_transaction_lines atl
+			JOIN accounting_transactions at ON at.id = atl.transaction_id
+			JOIN chart_of_accounts coa ON coa.id = atl.account_id
+			WHERE ` + accountConditions + `
+			  AND ` + transactionConditions + `
+			  AND at.transaction_date BETWEEN $2 AND $3
+		) ledger
+	`
+	const order = " ORDER BY account_code, account_id, transaction_date, transaction_id, line_id"
+
+	if format == "csv" {
+		h.writeGeneralLedgerCSV(w, query+order, args, accounts, startDate, endDate)
+		return
+	}
+
+	if cursor != nil {
+		n := len(args)
+		query += fmt.Sprintf(
+			" WHERE (account_code, account_id, transaction_date, transaction_id, line_id) > ($%d, $%d, $%d, $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5)
+		cursorDate, _ := parseDate(cursor.TransactionDate)
+		args = append(args, cursor.AccountCode, cursor.AccountID, cursorDate, cursor.TransactionID, cursor.LineID)
+	}
+	query += order + fmt.Sprintf(" LIMIT %d", limit+1)
+	lines := []GeneralLedgerLine{}
+	if err := h.db.Select(&lines, query, args...); err != nil {
+		h.writeError(w, err, "Failed to generate general ledger")
+		return
+	}
+
+	var nextCursor *string
+	if len(lines) > limit {
+		lines = lines[:limit]
+		last := lines[limit-1]
+		encoded := generalLedgerCursor{
+			AccountCode:     last.AccountCode,
+			AccountID:       last.AccountID,
+			TransactionDate: last.TransactionDate.Format("2006-01-02"),
+			TransactionID:   last.TransactionID,
+			LineID:          last.LineID,
+		}.encode()
+		nextCursor = &encoded
+	}
+	for i := range lines {
+		lines[i].RunningBalance = found[lines[i].AccountID].OpeningBalance + lines[i].Movement
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"start_date":  startDate.Format("2006-01-02"),
+		"end_date":    endDate.Format("2006-01-02"),
+		"currency":    settings.DefaultCurrency,
+		"accounts":    accounts,
+		"lines":       lines,
+		"count":       len(lines),
+		"next_cursor": nextCursor,
+	})
+}
+
+// writeGeneralLedgerCSV streams the lines of query as CSV, each account
+// framed by its balance brought forward and its closing balance. Accounts
+// and lines are in the same order, so they are merged as the rows arrive.
+func (h *AccountingHandler) writeGeneralLedgerCSV(w http.ResponseWriter, query string, args []interface{}, accounts []GeneralLedgerAccount, startDate, endDate time.Time) {
+	rows, err := h.db.Queryx(query, args...)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate general ledger")
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"general-ledger-%s-%s.csv\"",
+		startDate.Format("2006-01-02"), endDate.Format("2006-01-02")))
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"account_code", "account_name", "transaction_date", "transaction_number", "description",
+		"debit", "credit", "balance"})
+
+	next := 0
+	open := func(account GeneralLedgerAccount) {
+		_ = out.Write([]string{account.AccountCode, account.AccountName, startDate.Format("2006-01-02"), "",
+			"Balance brought forward", "", "", account.OpeningBalance.String()})
+	}
+	closeAccount := func(account GeneralLedgerAccount) {
+		_ = out.Write([]string{account.AccountCode, account.AccountName, endDate.Format("2006-01-02"), "",
+			"Closing balance", account.TotalDebit.String(), account.TotalCredit.String(),
+			account.ClosingBalance.String()})
+	}
+
+	current := -1
+	for rows.Next() {
+		var line GeneralLedgerLine
+		if err := rows.StructScan(&line); err != nil {
+			h.logger.Error("Failed to write general ledger", zap.Error(err))
+			break
+		}
+		for current < 0 || accounts[current].AccountID != line.AccountID {
+			if current >= 0 {
+				closeAccount(accounts[current])
+			}
+			if next == len(accounts) {
+				h.logger.Error("General ledger line outside the report accounts", zap.Int("line_id", line.LineID))
+				out.Flush()
+				return
+			}
+			current = next
+			next++
+			open(accounts[current])
+		}
+
+		description := ""
+		if line.Description != nil {
+			description = *line.Description
+		}
+		_ = out.Write([]string{line.AccountCode, accounts[current].AccountName,
+			line.TransactionDate.Format("2006-01-02"), line.TransactionNumber, description,
+			line.DebitAmount.String(), line.CreditAmount.String(),
+			(accounts[current].OpeningBalance + line.Movement).String()})
+	}
+	if err := rows.Err(); err != nil {
+		h.logger.Error("Failed to write general ledger", zap.Error(err))
+	}
+
+	if current >= 0 {
+		closeAccount(accounts[current])
+	}
+	for ; next < len(accounts); next++ {
+		open(accounts[next])
+		closeAccount(accounts[next])
+	}
+	out.Flush()
+}
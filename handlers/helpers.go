@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// errUnbalancedTransaction is returned when debits and credits do not agree
+var errUnbalancedTransaction = newBadRequestError("Total debits must equal total credits")
+
+// requestError is a failure caused by the caller rather than the database.
+// It can be returned from inside withTransaction to roll back and still
+// produce the right HTTP status.
+type requestError struct {
+	status  int
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// newBadRequestError creates an error reported to the client as 400
+func newBadRequestError(format string, args ...interface{}) error {
+	return &requestError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// newNotFoundError creates an error reported to the client as 404
+func newNotFoundError(format string, args ...interface{}) error {
+	return &requestError{status: http.StatusNotFound, message: fmt.Sprintf(format, args...)}
+}
+
+// closedPeriodSQLState is the error code the database raises for a change to
+// a transaction in a closed fiscal period
+const closedPeriodSQLState = "AC001"
+
+// isClosedPeriodError reports whether the database rejected a change because
+// it falls in a closed fiscal period. The PostgreSQL drivers expose the error
+// code through a SQLState method.
+func isClosedPeriodError(err error) bool {
+	var stateErr interface{ SQLState() string }
+	return errors.As(err, &stateErr) && stateErr.SQLState() == closedPeriodSQLState
+}
+
+// writeError writes err as a client error when it is a requestError or a
+// rejection by the fiscal period check of the database, and otherwise logs it
+// and responds with a generic internal error
+func (h *AccountingHandler) writeError(w http.ResponseWriter, err error, message string) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		if reqErr.status == http.StatusNotFound {
+			sdk.WriteNotFound(w, reqErr.message)
+		} else {
+			sdk.WriteBadRequest(w, reqErr.message)
+		}
+		return
+	}
+
+	if isClosedPeriodError(err) {
+		sdk.WriteBadRequest(w, "The transaction is in a closed fiscal period")
+		return
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		sdk.WriteNotFound(w, "Record not found")
+		return
+	}
+
+	h.logger.Error(message, zap.Error(err))
+	sdk.WriteInternalError(w, message)
+}
+
+// urlParamID parses the {id} route parameter
+func urlParamID(r *http.Request) (int, error) {
+	return strconv.Atoi(chi.URLParam(r, "id"))
+}
+
+// maxListLimit bounds the limit query parameter of list endpoints
+const maxListLimit = 1000
+
+// listLimit parses the limit query parameter of a list endpoint, which
+// defaults to defaultLimit
+func listLimit(r *http.Request, defaultLimit int) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > maxListLimit {
+		return 0, newBadRequestError("limit must be between 1 and %d", maxListLimit)
+	}
+	return limit, nil
+}
+
+// currentUserID returns the user of a request resolved by withTenant
+func currentUserID(r *http.Request) int {
+	user, _ := r.Context().Value(userContextKey{}).(int)
+	return user
+}
+
+// tenantContextKey is the request context key of the tenant ID
+type tenantContextKey struct{}
+
+// companyContextKey is the request context key of the company ID
+type companyContextKey struct{}
+
+// userContextKey is the request context key of the user ID
+type userContextKey struct{}
+
+// uuidPattern matches a UUID such as a tenant ID
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// handlerFunc is a route handler, called on a copy of the handler bound to
+// the tenant of the request
+type handlerFunc func(h *AccountingHandler, w http.ResponseWriter, r *http.Request)
+
+// withTenant resolves the tenant and the authenticated user the platform
+// forwards in the X-Tenant-ID and X-User-ID headers and stores them in the
+// request context before calling next on a handler whose queries run on a
+// connection bound to the tenant. Requests without a valid tenant or user are
+// rejected, since every query is scoped to a tenant and every change is
+// attributed to a user. The optional X-Company-ID header narrows the request
+// to one company of the tenant.
+func (h *AccountingHandler) withTenant(next handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get("X-Tenant-ID")
+		if !uuidPattern.MatchString(tenant) {
+			sdk.WriteBadRequest(w, "A valid X-Tenant-ID header is required")
+			return
+		}
+		user, err := strconv.Atoi(r.Header.Get("X-User-ID"))
+		if err != nil || user <= 0 {
+			sdk.WriteBadRequest(w, "A valid X-User-ID header is required")
+			return
+		}
+		company := r.Header.Get("X-Company-ID")
+		if company != "" && !uuidPattern.MatchString(company) {
+			sdk.WriteBadRequest(w, "X-Company-ID must be a valid company ID")
+			return
+		}
+
+		conn, err := acquireTenantConn(r.Context(), h.pool, tenant)
+		if err != nil {
+			h.writeError(w, err, "Failed to connect to the database")
+			return
+		}
+		defer func() {
+			if err := conn.release(); err != nil {
+				h.logger.Warn("Failed to release tenant connection", zap.Error(err))
+			}
+		}()
+		scoped := *h
+		scoped.db = conn
+
+		ctx := context.WithValue(r.Context(), tenantContextKey{}, tenant)
+		ctx = context.WithValue(ctx, companyContextKey{}, company)
+		ctx = context.WithValue(ctx, userContextKey{}, user)
+		next(&scoped, w, r.WithContext(ctx))
+	}
+}
+
+// tenantID returns the tenant of a request resolved by withTenant
+func tenantID(r *http.Request) string {
+	tenant, _ := r.Context().Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// companyID returns the company of a request resolved by withTenant, or an
+// empty string when the request covers every company of the tenant
+func companyID(r *http.Request) string {
+	company, _ := r.Context().Value(companyContextKey{}).(string)
+	return company
+}
+
+// withTransaction runs fn in a database transaction scoped to tenant. Besides
+// the tenant_id conditions of every query, the row-level security policies
+// restrict the transaction to the tenant through the app.tenant_id setting.
+func (h *AccountingHandler) withTransaction(tenant string, fn func(tx *sqlx.Tx) error) error {
+	tx, err := h.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT set_config('app.tenant_id', $1, true)", tenant); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkTenantAccount ensures an account referenced by a request belongs to
+// tenant, since foreign keys alone would accept any tenant's account
+func checkTenantAccount(q sqlx.Queryer, tenant string, accountID int) error {
+	var exists bool
+	err := sqlx.Get(q, &exists,
+		"SELECT EXISTS(SELECT 1 FROM chart_of_accounts WHERE id = $1 AND tenant_id = $2)", accountID, tenant)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return newBadRequestError("Account %d not found", accountID)
+	}
+	return nil
+}
+
+// parseDate parses a YYYY-MM-DD date as used throughout the API
+func parseDate(value string) (time.Time, error) {
+	return time.Parse("2006-01-02", value)
+}
+
+// generateNumber creates a document number such as TXN-1700000000000000000.
+// Nanosecond precision keeps numbers unique when several documents are
+// created inside the same database transaction.
+func generateNumber(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
+}
+
+// roundPercent rounds a percentage or score to two decimal places. Amounts
+// are Money and are rounded with Money.Round.
+func roundPercent(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListLimit(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{query: "", want: 50},
+		{query: "?limit=1", want: 1},
+		{query: "?limit=1000", want: 1000},
+		{query: "?limit=0", wantErr: true},
+		{query: "?limit=-5", wantErr: true},
+		{query: "?limit=1001", wantErr: true},
+		{query: "?limit=10%3B%20DROP%20TABLE%20accounting_transactions", wantErr: true},
+		{query: "?limit=abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/transactions"+tt.query, nil)
+			got, err := listLimit(r, 50)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("listLimit() = %d, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("listLimit() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("listLimit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithTenantRejectsMissingIdentity(t *testing.T) {
+	const tenant = "5f0c6f2e-8d7a-4b3c-9e1f-2a3b4c5d6e7f"
+	tests := []struct {
+		name   string
+		tenant string
+		user   string
+	}{
+		{name: "no tenant", user: "7"},
+		{name: "invalid tenant", tenant: "acme", user: "7"},
+		{name: "no user", tenant: tenant},
+		{name: "invalid user", tenant: tenant, user: "admin"},
+		{name: "zero user", tenant: tenant, user: "0"},
+		{name: "negative user", tenant: tenant, user: "-1"},
+	}
+
+	h := &AccountingHandler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := h.withTenant(func(*AccountingHandler, http.ResponseWriter, *http.Request) { called = true })
+
+			r := httptest.NewRequest("GET", "/transactions", nil)
+			r.Header.Set("X-Tenant-ID", tt.tenant)
+			r.Header.Set("X-User-ID", tt.user)
+			handler(httptest.NewRecorder(), r)
+			if called {
+				t.Error("withTenant() called the handler, want the request rejected")
+			}
+		})
+	}
+}
+
+// stateError is a database error carrying a SQLSTATE code, like the errors of
+// the PostgreSQL drivers
+type stateError string
+
+func (e stateError) Error() string    { return "database error " + string(e) }
+func (e stateError) SQLState() string { return string(e) }
+
+func TestIsClosedPeriodError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "closed period", err: stateError(closedPeriodSQLState), want: true},
+		{name: "wrapped closed period", err: fmt.Errorf("post transaction: %w", stateError(closedPeriodSQLState)), want: true},
+		{name: "other database error", err: stateError("23505")},
+		{name: "plain error", err: errors.New("connection reset")},
+		{name: "request error", err: newBadRequestError("Invalid date")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isClosedPeriodError(tt.err); got != tt.want {
+				t.Errorf("isClosedPeriodError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
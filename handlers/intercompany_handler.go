@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// An inter-company transaction charges an amount from one company of the
+// tenant to another. It is posted as a mirrored transaction in each company:
+// the from company debits its due-from account and credits from_account,
+// typically revenue, and the to company debits to_account, typically an
+// expense, and credits its due-to account. Both transactions carry the
+// reference type intercompany, which consolidated reports eliminate.
+
+// loadIntercompanyTransaction fetches an inter-company transaction
+func loadIntercompanyTransaction(q sqlx.Queryer, tenant string, id int, forUpdate bool) (*IntercompanyTransaction, error) {
+	query := "SELECT * FROM accounting_intercompany_transactions WHERE id = $1 AND tenant_id = $2"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	var ict IntercompanyTransaction
+	if err := sqlx.Get(q, &ict, query, id, tenant); err != nil {
+		return nil, err
+	}
+	return &ict, nil
+}
+
+// GetIntercompanyTransactions lists inter-company transactions, those of
+// the company of the request when it is set
+func (h *AccountingHandler) GetIntercompanyTransactions(w http.ResponseWriter, r *http.Request) {
+	startDate := r.URL.Query().Get("start_date")
+	endDate := r.URL.Query().Get("end_date")
+	status := r.URL.Query().Get("status")
+
+	qb := sdk.NewQueryBuilder("SELECT * FROM accounting_intercompany_transactions WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition("$%d IN (from_company_id, to_company_id)", companyID(r))
+	qb.AddOptionalCondition("transaction_date >= $%d", startDate)
+	qb.AddOptionalCondition("transaction_date <= $%d", endDate)
+	qb.AddOptionalCondition("status = $%d", status)
+
+	query, args := qb.Build()
+	query += " ORDER BY transaction_date DESC, id DESC"
+
+	transactions := []IntercompanyTransaction{}
+	if err := h.db.Select(&transactions, query, args...); err != nil {
+		h.logger.Error("Failed to fetch inter-company transactions", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch inter-company transactions")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"transactions": transactions,
+		"count":        len(transactions),
+	})
+}
+
+// GetIntercompanyTransaction retrieves a single inter-company transaction
+func (h *AccountingHandler) GetIntercompanyTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid inter-company transaction ID")
+		return
+	}
+
+	ict, err := loadIntercompanyTransaction(h.db, tenantID(r), id, false)
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch inter-company transaction")
+		return
+	}
+
+	sdk.WriteSuccess(w, ict)
+}
+
+// CreateIntercompanyTransaction records an inter-company transaction and
+// posts its transaction in each company
+func (h *AccountingHandler) CreateIntercompanyTransaction(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FromCompanyID   string  `json:"from_company_id"`
+		ToCompanyID     string  `json:"to_company_id"`
+		TransactionDate string  `json:"transaction_date"`
+		Description     *string `json:"description"`
+		Amount          Money   `json:"amount"`
+		Currency        string  `json:"currency"`
+		FromAccountID   int     `json:"from_account_id"`
+		ToAccountID     int     `json:"to_account_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if err := sdk.ValidateRequired(map[string]interface{}{
+		"from_company_id": req.FromCompanyID,
+		"to_company_id":   req.ToCompanyID,
+		"from_account_id": req.FromAccountID,
+		"to_account_id":   req.ToAccountID,
+	}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+	if req.FromCompanyID == req.ToCompanyID {
+		sdk.WriteBadRequest(w, "from_company_id and to_company_id must be different companies")
+		return
+	}
+	if req.Amount <= 0 {
+		sdk.WriteBadRequest(w, "amount must be positive")
+		return
+	}
+	transactionDate, err := parseDate(req.TransactionDate)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid transaction date")
+		return
+	}
+
+	tenant := tenantID(r)
+	userID := currentUserID(r)
+	var ict IntercompanyTransaction
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		from, err := requireCompany(tx, tenant, req.FromCompanyID)
+		if err != nil {
+			return err
+		}
+		to, err := requireCompany(tx, tenant, req.ToCompanyID)
+		if err != nil {
+			return err
+		}
+		if from.DueFromAccountID == nil {
+			return newBadRequestError("Company %s has no due-from account", from.Code)
+		}
+		if to.DueToAccountID == nil {
+			return newBadRequestError("Company %s has no due-to account", to.Code)
+		}
+
+		if req.Currency == "" {
+			settings, err := loadSettings(tx, tenant)
+			if err != nil {
+				return err
+			}
+			req.Currency = settings.DefaultCurrency
+		}
+
+		err = tx.Get(&ict, `
+			INSERT INTO accounting_intercompany_transactions
+			(tenant_id, from_company_id, to_company_id, transaction_date, description, amount, currency,
+			 from_account_id, to_account_id, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING *
+		`, tenant, from.ID, to.ID, transactionDate, req.Description, req.Amount, req.Currency, req.FromAccountID,
+			req.ToAccountID, userID)
+		if err != nil {
+			return err
+		}
+
+		referenceType := "intercompany"
+		post := func(company *Company, debitAccountID, creditAccountID int, counterparty string) (*AccountingTransaction, error) {
+			description := fmt.Sprintf("Inter-company with %s", counterparty)
+			if req.Description != nil {
+				description += ": " + *req.Description
+			}
+			txn := &AccountingTransaction{
+				CompanyID:       &company.ID,
+				TransactionDate: transactionDate,
+				ReferenceType:   &referenceType,
+				ReferenceID:     &ict.ID,
+				Description:     &description,
+				Currency:        req.Currency,
+				CreatedBy:       userID,
+				Lines: []AccountingTransactionLine{
+					{AccountID: debitAccountID, DebitAmount: req.Amount, Description: &description},
+					{AccountID: creditAccountID, CreditAmount: req.Amount, Description: &description},
+				},
+			}
+			if err := h.postTransaction(tx, tenant, txn); err != nil {
+				return nil, err
+			}
+			return txn, nil
+		}
+
+		fromTxn, err := post(from, *from.DueFromAccountID, req.FromAccountID, to.Code)
+		if err != nil {
+			return err
+		}
+		toTxn, err := post(to, req.ToAccountID, *to.DueToAccountID, from.Code)
+		if err != nil {
+			return err
+		}
+
+		ict.FromTransactionID = &fromTxn.ID
+		ict.ToTransactionID = &toTxn.ID
+		_, err = tx.Exec(`
+			UPDATE accounting_intercompany_transactions
+			SET from_transaction_id = $1, to_transaction_id = $2
+			WHERE id = $3 AND tenant_id = $4
+		`, fromTxn.ID, toTxn.ID, ict.ID, tenant)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to create inter-company transaction")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"transaction": ict,
+		"message":     "Inter-company transaction created successfully",
+	})
+}
+
+// VoidIntercompanyTransaction voids both transactions of an inter-company
+// transaction, posting a reversal of each in its company
+func (h *AccountingHandler) VoidIntercompanyTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid inter-company transaction ID")
+		return
+	}
+
+	var req struct {
+		Date   string `json:"date"`
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sdk.WriteBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	date := time.Now()
+	if req.Date != "" {
+		if date, err = parseDate(req.Date); err != nil {
+			sdk.WriteBadRequest(w, "Invalid date")
+			return
+		}
+	}
+	var reason *string
+	if trimmed := strings.TrimSpace(req.Reason); trimmed != "" {
+		reason = &trimmed
+	}
+
+	tenant := tenantID(r)
+	userID := currentUserID(r)
+	var ict *IntercompanyTransaction
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadIntercompanyTransaction(tx, tenant, id, true)
+		if err != nil {
+			return err
+		}
+		ict = loaded
+
+		if ict.Status != "posted" {
+			return newBadRequestError("Only posted inter-company transactions can be voided, status is %s", ict.Status)
+		}
+		if date.Before(ict.TransactionDate) {
+			return newBadRequestError("Void date cannot be before the transaction date")
+		}
+
+		now := time.Now()
+		for _, transactionID := range []*int{ict.FromTransactionID, ict.ToTransactionID} {
+			if transactionID == nil {
+				continue
+			}
+			description := fmt.Sprintf("Void of inter-company transaction %d", ict.ID)
+			if reason != nil {
+				description += ": " + *reason
+			}
+			if _, err := h.reverseTransaction(tx, tenant, *transactionID, date, description); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`
+				UPDATE accounting_transactions
+				SET status = 'void', voided_by = $1, voided_at = $2, void_reason = $3
+				WHERE id = $4 AND tenant_id = $5
+			`, userID, now, reason, *transactionID, tenant); err != nil {
+				return err
+			}
+		}
+
+		ict.Status = "void"
+		ict.VoidedBy = &userID
+		ict.VoidedAt = &now
+		_, err = tx.Exec(`
+			UPDATE accounting_intercompany_transactions
+			SET status = 'void', voided_by = $1, voided_at = $2
+			WHERE id = $3 AND tenant_id = $4
+		`, userID, now, id, tenant)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to void inter-company transaction")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"transaction": ict,
+		"message":     "Inter-company transaction voided successfully",
+	})
+}
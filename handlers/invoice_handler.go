@@ -0,0 +1,596 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+const invoiceSelect = `
+	SELECT i.*, c.name AS customer_name
+	FROM accounting_invoices i
+	LEFT JOIN customers c ON c.id = i.customer_id
+`
+
+// invoiceRequest is the body accepted when creating or updating an invoice
+type invoiceRequest struct {
+	CustomerID          int     `json:"customer_id"`
+	InvoiceDate         string  `json:"invoice_date"`
+	DueDate             string  `json:"due_date"`
+	Currency            string  `json:"currency"`
+	ReceivableAccountID int     `json:"receivable_account_id"`
+	TaxAccountID        *int    `json:"tax_account_id"`
+	Notes               *string `json:"notes"`
+	Lines               []struct {
+		Description      string   `json:"description"`
+		Quantity         float64  `json:"quantity"`
+		UnitPrice        Money    `json:"unit_price"`
+		TaxRate          *float64 `json:"tax_rate"`
+		TaxCodes         []string `json:"tax_codes"`
+		RevenueAccountID int      `json:"revenue_account_id"`
+	} `json:"lines"`
+}
+
+// toInvoice validates the request and computes line and header totals. Lines
+// with tax codes are taxed through the tax engine and post to the tax code
+// accounts; other lines use tax_rate, or the default_tax_rate setting when it
+// is omitted, and post to the invoice tax account.
+func (req *invoiceRequest) toInvoice(q sqlx.Queryer, tenant string) (*Invoice, error) {
+	if req.CustomerID == 0 {
+		return nil, newBadRequestError("customer_id is required")
+	}
+	if req.ReceivableAccountID == 0 {
+		return nil, newBadRequestError("receivable_account_id is required")
+	}
+	if len(req.Lines) == 0 {
+		return nil, newBadRequestError("At least one invoice line is required")
+	}
+
+	invoiceDate := time.Now().Truncate(24 * time.Hour)
+	if req.InvoiceDate != "" {
+		date, err := parseDate(req.InvoiceDate)
+		if err != nil {
+			return nil, newBadRequestError("Invalid invoice date")
+		}
+		invoiceDate = date
+	}
+
+	dueDate, err := parseDate(req.DueDate)
+	if err != nil {
+		return nil, newBadRequestError("Invalid due date")
+	}
+	if dueDate.Before(invoiceDate) {
+		return nil, newBadRequestError("Due date cannot be before invoice date")
+	}
+
+	invoice := &Invoice{
+		CustomerID:          req.CustomerID,
+		InvoiceDate:         invoiceDate,
+		DueDate:             dueDate,
+		Currency:            req.Currency,
+		ReceivableAccountID: req.ReceivableAccountID,
+		TaxAccountID:        req.TaxAccountID,
+		Notes:               req.Notes,
+	}
+	if err := checkTenantAccount(q, tenant, invoice.ReceivableAccountID); err != nil {
+		return nil, err
+	}
+	if invoice.TaxAccountID != nil {
+		if err := checkTenantAccount(q, tenant, *invoice.TaxAccountID); err != nil {
+			return nil, err
+		}
+	}
+	settings, err := loadSettings(q, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Currency == "" {
+		invoice.Currency = settings.DefaultCurrency
+	}
+	if err := checkCurrency(settings, invoice.Currency); err != nil {
+		return nil, err
+	}
+
+	places := minorUnits(invoice.Currency)
+	var rateTax Money
+	for i, l := range req.Lines {
+		if l.RevenueAccountID == 0 {
+			return nil, newBadRequestError("Line %d: revenue_account_id is required", i+1)
+		}
+		if l.Quantity <= 0 {
+			return nil, newBadRequestError("Line %d: quantity must be greater than zero", i+1)
+		}
+		if err := checkTenantAccount(q, tenant, l.RevenueAccountID); err != nil {
+			return nil, err
+		}
+
+		line := InvoiceLine{
+			LineNumber:       i + 1,
+			Description:      l.Description,
+			Quantity:         l.Quantity,
+			UnitPrice:        l.UnitPrice,
+			RevenueAccountID: l.RevenueAccountID,
+		}
+
+		if len(l.TaxCodes) > 0 {
+			codes, err := resolveTaxCodes(q, tenant, l.TaxCodes, invoiceDate)
+			if err != nil {
+				return nil, err
+			}
+			amount := l.UnitPrice.Mul(l.Quantity, places, settings.RoundingMode)
+			calc, err := calculateTax(amount, codes, "sales", invoice.Currency, settings.RoundingMode)
+			if err != nil {
+				return nil, err
+			}
+			line.LineSubtotal = calc.NetAmount
+			line.TaxAmount = calc.TaxAmount
+			line.Taxes = calc.Taxes
+			for _, tax := range calc.Taxes {
+				line.TaxRate += tax.Rate
+			}
+		} else {
+			line.TaxRate = settings.DefaultTaxRate
+			if l.TaxRate != nil {
+				line.TaxRate = *l.TaxRate
+			}
+			if line.TaxRate < 0 {
+				return nil, newBadRequestError("Line %d: tax_rate cannot be negative", i+1)
+			}
+			line.LineSubtotal = l.UnitPrice.Mul(l.Quantity, places, settings.RoundingMode)
+			line.TaxAmount = line.LineSubtotal.Percent(line.TaxRate, places, settings.RoundingMode)
+			rateTax += line.TaxAmount
+		}
+		line.LineTotal = line.LineSubtotal + line.TaxAmount
+
+		invoice.Subtotal += line.LineSubtotal
+		invoice.TaxAmount += line.TaxAmount
+		invoice.Lines = append(invoice.Lines, line)
+	}
+
+	invoice.TotalAmount = invoice.Subtotal + invoice.TaxAmount
+	invoice.BalanceAmount = invoice.TotalAmount
+
+	if rateTax != 0 && invoice.TaxAccountID == nil {
+		return nil, newBadRequestError("tax_account_id is required when lines carry a tax rate")
+	}
+
+	return invoice, nil
+}
+
+// loadInvoice fetches an invoice and its lines. When forUpdate is set the
+// invoice row is locked for the rest of the transaction.
+func loadInvoice(q sqlx.Queryer, tenant string, id int, forUpdate bool) (*Invoice, error) {
+	query := invoiceSelect + " WHERE i.id = $1 AND i.tenant_id = $2"
+	if forUpdate {
+		query += " FOR UPDATE OF i"
+	}
+
+	var invoice Invoice
+	if err := sqlx.Get(q, &invoice, query, id, tenant); err != nil {
+		return nil, err
+	}
+
+	if err := sqlx.Select(q, &invoice.Lines,
+		"SELECT * FROM accounting_invoice_lines WHERE invoice_id = $1 AND tenant_id = $2 ORDER BY line_number",
+		id, tenant); err != nil {
+		return nil, err
+	}
+
+	for i := range invoice.Lines {
+		err := sqlx.Select(q, &invoice.Lines[i].Taxes, `
+			SELECT tax_code_id, code, rate, is_compound, taxable_amount, tax_amount, account_id
+			FROM accounting_invoice_line_taxes
+			WHERE invoice_line_id = $1 AND tenant_id = $2
+			ORDER BY id
+		`, invoice.Lines[i].ID, tenant)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &invoice, nil
+}
+
+// insertInvoiceLines writes the lines of an invoice
+func insertInvoiceLines(tx *sqlx.Tx, tenant string, invoice *Invoice) error {
+	for i := range invoice.Lines {
+		line := &invoice.Lines[i]
+		line.InvoiceID = invoice.ID
+		err := tx.QueryRow(`
+			INSERT INTO accounting_invoice_lines
+			(tenant_id, invoice_id, line_number, description, quantity, unit_price, tax_rate,
+			 line_subtotal, tax_amount, line_total, revenue_account_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING id, created_at
+		`, tenant, invoice.ID, line.LineNumber, line.Description, line.Quantity, line.UnitPrice, line.TaxRate,
+			line.LineSubtotal, line.TaxAmount, line.LineTotal, line.RevenueAccountID).
+			Scan(&line.ID, &line.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		for _, tax := range line.Taxes {
+			_, err := tx.Exec(`
+				INSERT INTO accounting_invoice_line_taxes
+				(tenant_id, invoice_line_id, tax_code_id, code, rate, is_compound, taxable_amount, tax_amount,
+				 account_id)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			`, tenant, line.ID, tax.TaxCodeID, tax.Code, tax.Rate, tax.IsCompound, tax.TaxableAmount, tax.TaxAmount, tax.AccountID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetInvoices retrieves invoices
+func (h *AccountingHandler) GetInvoices(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	customerID := r.URL.Query().Get("customer_id")
+	startDate := r.URL.Query().Get("start_date")
+	endDate := r.URL.Query().Get("end_date")
+	limit, err := listLimit(r, 100)
+	if err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	qb := sdk.NewQueryBuilder(invoiceSelect + " WHERE 1=1")
+	qb.AddCondition("i.tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition(
+		"i.receivable_account_id IN (SELECT id FROM chart_of_accounts WHERE company_id IS NULL OR company_id = $%d)",
+		companyID(r))
+	qb.AddOptionalCondition("i.status = $%d", status)
+	qb.AddOptionalCondition("i.customer_id = $%d", customerID)
+	qb.AddOptionalCondition("i.invoice_date >= $%d", startDate)
+	qb.AddOptionalCondition("i.invoice_date <= $%d", endDate)
+
+	query, args := qb.Build()
+	query += fmt.Sprintf(" ORDER BY i.invoice_date DESC, i.id DESC LIMIT %d", limit)
+
+	var invoices []Invoice
+	if err := h.db.Select(&invoices, query, args...); err != nil {
+		h.logger.Error("Failed to fetch invoices", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch invoices")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"invoices": invoices,
+		"count":    len(invoices),
+	})
+}
+
+// GetInvoice retrieves a single invoice with its lines
+func (h *AccountingHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid invoice ID")
+		return
+	}
+
+	invoice, err := loadInvoice(h.db, tenantID(r), id, false)
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch invoice")
+		return
+	}
+
+	sdk.WriteSuccess(w, invoice)
+}
+
+// CreateInvoice creates a new draft invoice
+func (h *AccountingHandler) CreateInvoice(w http.ResponseWriter, r *http.Request) {
+	var req invoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	tenant := tenantID(r)
+	invoice, err := req.toInvoice(h.db, tenant)
+	if err != nil {
+		h.writeError(w, err, "Failed to create invoice")
+		return
+	}
+
+	invoice.InvoiceNumber = generateNumber("INV")
+	invoice.CreatedBy = currentUserID(r)
+
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		var exists bool
+		if err := tx.Get(&exists, "SELECT EXISTS(SELECT 1 FROM customers WHERE id = $1 AND tenant_id = $2)",
+			invoice.CustomerID, tenant); err != nil {
+			return err
+		}
+		if !exists {
+			return newBadRequestError("Customer not found")
+		}
+
+		err := tx.QueryRow(`
+			INSERT INTO accounting_invoices
+			(tenant_id, invoice_number, customer_id, invoice_date, due_date, currency, receivable_account_id,
+			 tax_account_id, subtotal, tax_amount, total_amount, balance_amount, notes, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			RETURNING id, status
+		`, tenant, invoice.InvoiceNumber, invoice.CustomerID, invoice.InvoiceDate, invoice.DueDate, invoice.Currency,
+			invoice.ReceivableAccountID, invoice.TaxAccountID, invoice.Subtotal, invoice.TaxAmount,
+			invoice.TotalAmount, invoice.BalanceAmount, invoice.Notes, invoice.CreatedBy).
+			Scan(&invoice.ID, &invoice.Status)
+		if err != nil {
+			return err
+		}
+
+		return insertInvoiceLines(tx, tenant, invoice)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to create invoice")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"id":             invoice.ID,
+		"invoice_number": invoice.InvoiceNumber,
+		"total_amount":   invoice.TotalAmount,
+		"message":        "Invoice created successfully",
+	})
+}
+
+// UpdateInvoice replaces the header and lines of a draft invoice
+func (h *AccountingHandler) UpdateInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid invoice ID")
+		return
+	}
+
+	var req invoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	tenant := tenantID(r)
+	invoice, err := req.toInvoice(h.db, tenant)
+	if err != nil {
+		h.writeError(w, err, "Failed to update invoice")
+		return
+	}
+	invoice.ID = id
+
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		current, err := loadInvoice(tx, tenant, id, true)
+		if err != nil {
+			return err
+		}
+		if current.Status != "draft" {
+			return newBadRequestError("Only draft invoices can be edited")
+		}
+
+		var exists bool
+		if err := tx.Get(&exists, "SELECT EXISTS(SELECT 1 FROM customers WHERE id = $1 AND tenant_id = $2)",
+			invoice.CustomerID, tenant); err != nil {
+			return err
+		}
+		if !exists {
+			return newBadRequestError("Customer not found")
+		}
+
+		_, err = tx.Exec(`
+			UPDATE accounting_invoices
+			SET customer_id = $1, invoice_date = $2, due_date = $3, currency = $4, receivable_account_id = $5,
+			    tax_account_id = $6, subtotal = $7, tax_amount = $8, total_amount = $9, balance_amount = $10, notes = $11
+			WHERE id = $12 AND tenant_id = $13
+		`, invoice.CustomerID, invoice.InvoiceDate, invoice.DueDate, invoice.Currency, invoice.ReceivableAccountID,
+			invoice.TaxAccountID, invoice.Subtotal, invoice.TaxAmount, invoice.TotalAmount, invoice.BalanceAmount,
+			invoice.Notes, id, tenant)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM accounting_invoice_lines WHERE invoice_id = $1 AND tenant_id = $2",
+			id, tenant); err != nil {
+			return err
+		}
+
+		return insertInvoiceLines(tx, tenant, invoice)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to update invoice")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"total_amount": invoice.TotalAmount,
+		"message":      "Invoice updated successfully",
+	})
+}
+
+// IssueInvoice issues a draft invoice and posts it to the general ledger:
+// accounts receivable is debited with the total, revenue accounts are credited
+// with the line subtotals and the tax account with the tax
+func (h *AccountingHandler) IssueInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid invoice ID")
+		return
+	}
+
+	tenant := tenantID(r)
+	var invoice *Invoice
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadInvoice(tx, tenant, id, true)
+		if err != nil {
+			return err
+		}
+		if loaded.Status != "draft" {
+			return newBadRequestError("Only draft invoices can be issued")
+		}
+		invoice = loaded
+
+		txn := invoicePostingTransaction(invoice)
+		if err := h.postTransaction(tx, tenant, txn); err != nil {
+			return err
+		}
+
+		invoice.TransactionID = &txn.ID
+		return tx.QueryRow(`
+			UPDATE accounting_invoices
+			SET status = 'issued', transaction_id = $1, issued_at = CURRENT_TIMESTAMP
+			WHERE id = $2 AND tenant_id = $3
+			RETURNING status, issued_at
+		`, txn.ID, id, tenant).Scan(&invoice.Status, &invoice.IssuedAt)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to issue invoice")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"invoice":        invoice,
+		"transaction_id": invoice.TransactionID,
+		"message":        "Invoice issued successfully",
+	})
+}
+
+// invoicePostingTransaction builds the ledger transaction for an issued invoice
+func invoicePostingTransaction(invoice *Invoice) *AccountingTransaction {
+	referenceType := "invoice"
+	description := fmt.Sprintf("Invoice %s", invoice.InvoiceNumber)
+
+	txn := &AccountingTransaction{
+		TransactionDate: invoice.InvoiceDate,
+		ReferenceType:   &referenceType,
+		ReferenceID:     &invoice.ID,
+		Description:     &description,
+		Currency:        invoice.Currency,
+		CreatedBy:       invoice.CreatedBy,
+	}
+
+	txn.Lines = append(txn.Lines, AccountingTransactionLine{
+		AccountID:   invoice.ReceivableAccountID,
+		DebitAmount: invoice.TotalAmount,
+		Description: &description,
+	})
+
+	// One credit per revenue account, in the order the accounts first appear
+	revenue := map[int]Money{}
+	var accounts []int
+	for _, line := range invoice.Lines {
+		if _, ok := revenue[line.RevenueAccountID]; !ok {
+			accounts = append(accounts, line.RevenueAccountID)
+		}
+		revenue[line.RevenueAccountID] += line.LineSubtotal
+	}
+	for _, accountID := range accounts {
+		txn.Lines = append(txn.Lines, AccountingTransactionLine{
+			AccountID:    accountID,
+			CreditAmount: revenue[accountID],
+			Description:  &description,
+		})
+	}
+
+	// Tax from tax codes posts to each code's payable account, tax from a
+	// plain rate to the invoice tax account
+	tax := map[int]Money{}
+	accounts = nil
+	for _, line := range invoice.Lines {
+		if len(line.Taxes) == 0 {
+			if line.TaxAmount != 0 && invoice.TaxAccountID != nil {
+				if _, ok := tax[*invoice.TaxAccountID]; !ok {
+					accounts = append(accounts, *invoice.TaxAccountID)
+				}
+				tax[*invoice.TaxAccountID] += line.TaxAmount
+			}
+			continue
+		}
+		for _, component := range line.Taxes {
+			if _, ok := tax[component.AccountID]; !ok {
+				accounts = append(accounts, component.AccountID)
+			}
+			tax[component.AccountID] += component.TaxAmount
+		}
+	}
+	for _, accountID := range accounts {
+		txn.Lines = append(txn.Lines, AccountingTransactionLine{
+			AccountID:    accountID,
+			CreditAmount: tax[accountID],
+			Description:  &description,
+		})
+	}
+
+	return txn
+}
+
+// VoidInvoice voids an invoice. Issued invoices are reversed in the ledger
+// rather than deleted, so the original posting remains auditable.
+func (h *AccountingHandler) VoidInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid invoice ID")
+		return
+	}
+
+	var req struct {
+		Reason *string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sdk.WriteBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	tenant := tenantID(r)
+	var voidTransactionID *int
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		invoice, err := loadInvoice(tx, tenant, id, true)
+		if err != nil {
+			return err
+		}
+
+		switch invoice.Status {
+		case "void":
+			return newBadRequestError("Invoice is already void")
+		case "draft":
+		default:
+			if invoice.PaidAmount != 0 {
+				return newBadRequestError("Invoices with payments applied cannot be voided")
+			}
+			if invoice.TransactionID != nil {
+				reversal, err := h.reverseTransaction(tx, tenant, *invoice.TransactionID, time.Now(),
+					fmt.Sprintf("Void of invoice %s", invoice.InvoiceNumber))
+				if err != nil {
+					return err
+				}
+				voidTransactionID = &reversal.ID
+			}
+		}
+
+		_, err = tx.Exec(`
+			UPDATE accounting_invoices
+			SET status = 'void', balance_amount = 0, void_transaction_id = $1, void_reason = $2,
+			    voided_at = CURRENT_TIMESTAMP
+			WHERE id = $3 AND tenant_id = $4
+		`, voidTransactionID, req.Reason, id, tenant)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to void invoice")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"void_transaction_id": voidTransactionID,
+		"message":             "Invoice voided successfully",
+	})
+}
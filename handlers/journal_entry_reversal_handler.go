@@ -0,0 +1,209 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// A reversal is a new posted journal entry with the debits and credits of
+// every line swapped, linked to its source through reversal_of_id. An entry
+// can be reversed once. Accruals are usually reversed on the first day of the
+// next period, which is the default date, and can be reversed automatically
+// by setting auto_reverse_on when the entry is created.
+
+// nextPeriodStart returns the first day after the fiscal period containing
+// date, or the first day of the next month when no period is defined
+func nextPeriodStart(q sqlx.Queryer, tenant string, date time.Time) (time.Time, error) {
+	var end time.Time
+	err := sqlx.Get(q, &end, `
+		SELECT end_date FROM accounting_fiscal_periods
+		WHERE tenant_id = $2 AND $1 BETWEEN start_date AND end_date
+		LIMIT 1
+	`, date.Format("2006-01-02"), tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Date(date.Year(), date.Month()+1, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return end.AddDate(0, 0, 1), nil
+}
+
+// reverseJournalEntry posts the reversal of a posted journal entry dated date
+func (h *AccountingHandler) reverseJournalEntry(tx *sqlx.Tx, tenant string, sourceID int, date time.Time, createdBy int) (*JournalEntry, error) {
+	var source JournalEntry
+	if err := tx.Get(&source, "SELECT * FROM accounting_journal_entries WHERE id = $1 AND tenant_id = $2 FOR UPDATE",
+		sourceID, tenant); err != nil {
+		return nil, err
+	}
+	if source.Status != "posted" {
+		return nil, newBadRequestError("Only posted journal entries can be reversed, status is %s", source.Status)
+	}
+	if source.ReversalOfID != nil {
+		return nil, newBadRequestError("Journal entry %s is a reversal and cannot be reversed", source.EntryNumber)
+	}
+
+	var reversedBy string
+	err := tx.Get(&reversedBy,
+		"SELECT entry_number FROM accounting_journal_entries WHERE reversal_of_id = $1 AND tenant_id = $2", sourceID, tenant)
+	if err == nil {
+		return nil, newBadRequestError("Journal entry %s is already reversed by %s", source.EntryNumber, reversedBy)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if date.Before(source.EntryDate) {
+		return nil, newBadRequestError("Reversal date cannot be before the entry date")
+	}
+	if err := checkPostingPeriod(tx, tenant, date); err != nil {
+		return nil, err
+	}
+
+	var lines []JournalEntryLine
+	if err := tx.Select(&lines, `
+		SELECT account_id, debit_amount, credit_amount, description
+		FROM accounting_journal_entry_lines WHERE journal_entry_id = $1 AND tenant_id = $2 ORDER BY id
+	`, sourceID, tenant); err != nil {
+		return nil, err
+	}
+
+	description := "Reversal of " + source.EntryNumber
+	reversal := &JournalEntry{
+		CompanyID:    source.CompanyID,
+		EntryDate:    date,
+		Description:  &description,
+		Reference:    source.Reference,
+		CreatedBy:    createdBy,
+		ReversalOfID: &source.ID,
+	}
+	for _, line := range lines {
+		reversal.Lines = append(reversal.Lines, JournalEntryLine{
+			AccountID:    line.AccountID,
+			DebitAmount:  line.CreditAmount,
+			CreditAmount: line.DebitAmount,
+			Description:  line.Description,
+		})
+	}
+
+	if err := h.insertJournalEntry(tx, tenant, reversal); err != nil {
+		return nil, err
+	}
+	return reversal, nil
+}
+
+// ReverseJournalEntry reverses a posted journal entry, on the first day of
+// the next period unless a date is given
+func (h *AccountingHandler) ReverseJournalEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid journal entry ID")
+		return
+	}
+
+	var req struct {
+		Date string `json:"date"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sdk.WriteBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	var date time.Time
+	if req.Date != "" {
+		if date, err = parseDate(req.Date); err != nil {
+			sdk.WriteBadRequest(w, "Invalid date")
+			return
+		}
+	}
+
+	tenant := tenantID(r)
+	var reversal *JournalEntry
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		if date.IsZero() {
+			var entryDate time.Time
+			if err := tx.Get(&entryDate, "SELECT entry_date FROM accounting_journal_entries WHERE id = $1 AND tenant_id = $2",
+				id, tenant); err != nil {
+				return err
+			}
+			next, err := nextPeriodStart(tx, tenant, entryDate)
+			if err != nil {
+				return err
+			}
+			date = next
+		}
+
+		reversed, err := h.reverseJournalEntry(tx, tenant, id, date, currentUserID(r))
+		reversal = reversed
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to reverse journal entry")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"entry":   reversal,
+		"message": "Journal entry reversed successfully",
+	})
+}
+
+// runAutoReversals posts the reversals of posted journal entries whose
+// auto_reverse_on date has arrived. An entry that cannot be reversed yet,
+// e.g. because its reversal date is in a closed period, is retried on the
+// next run. Each tenant is scanned, and each entry reversed, in a
+// transaction of its own tenant.
+func (h *AccountingHandler) runAutoReversals(now time.Time) error {
+	tenants, err := h.tenantIDs()
+	if err != nil {
+		return err
+	}
+
+	for _, tenant := range tenants {
+		var due []int
+		if err := h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+			return tx.Select(&due, `
+				SELECT je.id FROM accounting_journal_entries je
+				WHERE je.tenant_id = $1 AND je.status = 'posted' AND je.auto_reverse_on <= $2
+				  AND NOT EXISTS (SELECT 1 FROM accounting_journal_entries r WHERE r.reversal_of_id = je.id)
+				ORDER BY je.auto_reverse_on, je.id
+			`, tenant, now.Format("2006-01-02"))
+		}); err != nil {
+			return err
+		}
+
+		for _, id := range due {
+			var reversal *JournalEntry
+			err := h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+				var source JournalEntry
+				if err := tx.Get(&source, "SELECT * FROM accounting_journal_entries WHERE id = $1 AND tenant_id = $2",
+					id, tenant); err != nil {
+					return err
+				}
+				reversed, err := h.reverseJournalEntry(tx, tenant, id, *source.AutoReverseOn, source.CreatedBy)
+				reversal = reversed
+				return err
+			})
+			if err != nil {
+				h.logger.Warn("Failed to auto-reverse journal entry", zap.Int("journal_entry_id", id), zap.Error(err))
+				continue
+			}
+			h.logger.Info("Journal entry auto-reversed",
+				zap.Int("journal_entry_id", id),
+				zap.String("tenant_id", tenant),
+				zap.String("reversal_number", reversal.EntryNumber))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount in ten-thousandths of a currency unit, which
+// covers the minor units of every currency with room for intermediate
+// results. Amounts are added, subtracted and compared as plain integers;
+// multiplication and division go through methods that round explicitly. In
+// JSON and in the database an amount is a decimal number and is read and
+// written without passing through float64.
+type Money int64
+
+// moneyScale is the number of decimal places Money keeps
+const moneyScale = 4
+
+// moneyUnit is one whole currency unit
+const moneyUnit Money = 10000
+
+// RoundingMode selects how an amount is rounded to fewer decimal places
+type RoundingMode string
+
+const (
+	// RoundHalfUp rounds halves away from zero, the usual commercial rounding
+	RoundHalfUp RoundingMode = "half_up"
+	// RoundHalfEven rounds halves to the even neighbour, as bankers do
+	RoundHalfEven RoundingMode = "half_even"
+	// RoundDown truncates toward zero
+	RoundDown RoundingMode = "down"
+	// RoundUp rounds away from zero
+	RoundUp RoundingMode = "up"
+)
+
+// roundingModes lists the valid rounding modes
+var roundingModes = []string{string(RoundHalfUp), string(RoundHalfEven), string(RoundDown), string(RoundUp)}
+
+// currencyMinorUnits lists the ISO 4217 currencies that do not have two
+// decimal places
+var currencyMinorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// minorUnits returns the number of decimal places of a currency
+func minorUnits(currency string) int {
+	if places, ok := currencyMinorUnits[strings.ToUpper(currency)]; ok {
+		return places
+	}
+	return 2
+}
+
+// ParseMoney parses a decimal amount such as -1234.56 exactly. Amounts
+// with more than four significant decimal places are rejected.
+func ParseMoney(value string) (Money, error) {
+	rat, err := parseDecimal(value)
+	if err != nil {
+		return 0, err
+	}
+	if !rat.IsInt() {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", value, moneyScale)
+	}
+	if !rat.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %q is out of range", value)
+	}
+	return Money(rat.Num().Int64()), nil
+}
+
+// parseDecimal parses a decimal number into ten-thousandths of a unit
+func parseDecimal(value string) (*big.Rat, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.Contains(value, "/") {
+		return nil, fmt.Errorf("invalid amount %q", value)
+	}
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", value)
+	}
+	return rat.Mul(rat, big.NewRat(int64(moneyUnit), 1)), nil
+}
+
+// moneyFromFloat converts a float64, such as a value scanned from a
+// floating point column, rounding it to Money's precision
+func moneyFromFloat(value float64) Money {
+	return Money(math.Round(value * float64(moneyUnit)))
+}
+
+// String formats the amount with at least two decimal places and without
+// trailing zeros beyond them
+func (m Money) String() string {
+	s := m.Format(moneyScale)
+	for strings.HasSuffix(s, "0") && len(s)-strings.IndexByte(s, '.') > 3 {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// Format formats the amount rounded half up to a fixed number of decimal
+// places
+func (m Money) Format(places int) string {
+	rounded := m.Round(places, RoundHalfUp)
+	sign := ""
+	units := int64(rounded)
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	whole := units / int64(moneyUnit)
+	if places <= 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	fraction := fmt.Sprintf("%04d", units%int64(moneyUnit))
+	if places < moneyScale {
+		fraction = fraction[:places]
+	}
+	return fmt.Sprintf("%s%d.%s", sign, whole, fraction)
+}
+
+// Float64 returns the amount as a float64, for ratios and percentages only
+func (m Money) Float64() float64 {
+	return float64(m) / float64(moneyUnit)
+}
+
+// Abs returns the absolute amount
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Round rounds the amount to a number of decimal places
+func (m Money) Round(places int, mode RoundingMode) Money {
+	if places >= moneyScale {
+		return m
+	}
+	factor := big.NewInt(int64(math.Pow10(moneyScale - places)))
+	q := roundQuotient(big.NewInt(int64(m)), factor, mode)
+	return Money(q.Mul(q, factor).Int64())
+}
+
+// RoundTo rounds the amount to the minor units of a currency
+func (m Money) RoundTo(currency string, mode RoundingMode) Money {
+	return m.Round(minorUnits(currency), mode)
+}
+
+// FitsCurrency reports whether the amount has no more decimal places than
+// the minor units of a currency
+func (m Money) FitsCurrency(currency string) bool {
+	return m.RoundTo(currency, RoundDown) == m
+}
+
+// Mul multiplies the amount by a factor such as a quantity, rounding the
+// result to a number of decimal places
+func (m Money) Mul(factor float64, places int, mode RoundingMode) Money {
+	return m.MulRat(decimalRat(factor), places, mode)
+}
+
+// Percent returns percent per cent of the amount, rounded to a number of
+// decimal places
+func (m Money) Percent(percent float64, places int, mode RoundingMode) Money {
+	rate := decimalRat(percent)
+	return m.MulRat(rate.Quo(rate, big.NewRat(100, 1)), places, mode)
+}
+
+// MulDiv multiplies the amount by num/den, e.g. to allocate it in
+// proportion to other amounts, rounding the result to a number of decimal
+// places
+func (m Money) MulDiv(num, den Money, places int, mode RoundingMode) Money {
+	if den == 0 {
+		return 0
+	}
+	return m.MulRat(big.NewRat(int64(num), int64(den)), places, mode)
+}
+
+// MulRat multiplies the amount by an exact rational factor, rounding the
+// result to a number of decimal places
+func (m Money) MulRat(factor *big.Rat, places int, mode RoundingMode) Money {
+	if places > moneyScale {
+		places = moneyScale
+	}
+	scale := big.NewInt(int64(math.Pow10(moneyScale - places)))
+	product := new(big.Rat).Mul(big.NewRat(int64(m), 1), factor)
+	den := new(big.Int).Mul(product.Denom(), scale)
+	q := roundQuotient(product.Num(), den, mode)
+	return Money(q.Mul(q, scale).Int64())
+}
+
+// roundQuotient divides num by a positive den, rounding the quotient to an
+// integer
+func roundQuotient(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	away := false
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	switch mode {
+	case RoundDown:
+	case RoundUp:
+		away = true
+	case RoundHalfEven:
+		cmp := twice.Cmp(den)
+		away = cmp > 0 || (cmp == 0 && q.Bit(0) == 1)
+	default:
+		away = twice.Cmp(den) >= 0
+	}
+
+	if away {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// decimalRat converts a decimal value held in a float64, such as a rate or
+// quantity read from a DECIMAL column, to the exact decimal it represents
+func decimalRat(value float64) *big.Rat {
+	rat, _ := new(big.Rat).SetString(strconv.FormatFloat(value, 'f', -1, 64))
+	return rat
+}
+
+// checkMinorUnits rejects an amount with more decimal places than its
+// currency has
+func checkMinorUnits(amount Money, currency string) error {
+	if !amount.FitsCurrency(currency) {
+		return newBadRequestError("Amount %s has more decimal places than %s allows", amount, currency)
+	}
+	return nil
+}
+
+// MarshalJSON writes the amount as a JSON number
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads the amount from a JSON number or a numeric string
+func (m *Money) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if value == "null" {
+		return nil
+	}
+	parsed, err := ParseMoney(strings.Trim(value, `"`))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner
+func (m *Money) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*m = 0
+	case []byte:
+		return m.scanString(string(value))
+	case string:
+		return m.scanString(value)
+	case int64:
+		*m = Money(value) * moneyUnit
+	case float64:
+		*m = moneyFromFloat(value)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+// scanString reads a database numeric, rounding computed values such as
+// averages that carry more decimal places than Money keeps
+func (m *Money) scanString(value string) error {
+	rat, err := parseDecimal(value)
+	if err != nil {
+		return err
+	}
+	units := roundQuotient(rat.Num(), rat.Denom(), RoundHalfUp)
+	if !units.IsInt64() {
+		return fmt.Errorf("amount %q is out of range", value)
+	}
+	*m = Money(units.Int64())
+	return nil
+}
+
+// Value implements driver.Valuer
+func (m Money) Value() (driver.Value, error) {
+	return m.Format(moneyScale), nil
+}
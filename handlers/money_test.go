@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Money
+		wantErr bool
+	}{
+		{value: "0", want: 0},
+		{value: "1234.56", want: 12345600},
+		{value: "-1234.56", want: -12345600},
+		{value: " 10.5 ", want: 105000},
+		{value: "0.0001", want: 1},
+		{value: "1.23450", want: 12345},
+		{value: "1e3", want: 10000000},
+		{value: "0.00001", wantErr: true},
+		{value: "1/3", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseMoney(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseMoney(%q) = %d, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q) error = %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMoney(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		amount Money
+		places int
+		want   string
+	}{
+		{amount: 12345600, places: 2, want: "1234.56"},
+		{amount: 12345, places: 2, want: "1.23"},
+		{amount: 12355, places: 2, want: "1.24"},
+		{amount: -12355, places: 2, want: "-1.24"},
+		{amount: 12345, places: 4, want: "1.2345"},
+		{amount: 15000, places: 0, want: "2"},
+		{amount: -5, places: 3, want: "-0.001"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.Format(tt.places); got != tt.want {
+			t.Errorf("Money(%d).Format(%d) = %q, want %q", tt.amount, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount Money
+		want   string
+	}{
+		{amount: 0, want: "0.00"},
+		{amount: 10000, want: "1.00"},
+		{amount: 15000, want: "1.50"},
+		{amount: 12345, want: "1.2345"},
+		{amount: 12340, want: "1.234"},
+		{amount: -500, want: "-0.05"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyRound(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Money
+		places int
+		mode   RoundingMode
+		want   Money
+	}{
+		{name: "half up rounds half away from zero", amount: 12350, places: 2, mode: RoundHalfUp, want: 12400},
+		{name: "half up negative", amount: -12350, places: 2, mode: RoundHalfUp, want: -12400},
+		{name: "half up below half", amount: 12349, places: 2, mode: RoundHalfUp, want: 12300},
+		{name: "half even rounds to even", amount: 12350, places: 2, mode: RoundHalfEven, want: 12400},
+		{name: "half even keeps even", amount: 12250, places: 2, mode: RoundHalfEven, want: 12200},
+		{name: "half even above half", amount: 12251, places: 2, mode: RoundHalfEven, want: 12300},
+		{name: "down truncates", amount: 12399, places: 2, mode: RoundDown, want: 12300},
+		{name: "down truncates negative toward zero", amount: -12399, places: 2, mode: RoundDown, want: -12300},
+		{name: "up rounds away from zero", amount: 12301, places: 2, mode: RoundUp, want: 12400},
+		{name: "up negative", amount: -12301, places: 2, mode: RoundUp, want: -12400},
+		{name: "exact amount is unchanged", amount: 12300, places: 2, mode: RoundUp, want: 12300},
+		{name: "zero places", amount: 25000, places: 0, mode: RoundHalfEven, want: 20000},
+		{name: "full scale is unchanged", amount: 12345, places: 4, mode: RoundDown, want: 12345},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.amount.Round(tt.places, tt.mode); got != tt.want {
+				t.Errorf("Money(%d).Round(%d, %s) = %d, want %d", tt.amount, tt.places, tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyRoundTo(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     Money
+	}{
+		{currency: "USD", want: 12300},
+		{currency: "JPY", want: 10000},
+		{currency: "KWD", want: 12350},
+		{currency: "CLF", want: 12346},
+	}
+
+	for _, tt := range tests {
+		if got := Money(12346).RoundTo(tt.currency, RoundHalfUp); got != tt.want {
+			t.Errorf("Money(12346).RoundTo(%s) = %d, want %d", tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyMulRat(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Money
+		factor *big.Rat
+		places int
+		mode   RoundingMode
+		want   Money
+	}{
+		{name: "one third half up", amount: 1000000, factor: big.NewRat(1, 3), places: 2, mode: RoundHalfUp, want: 333300},
+		{name: "two thirds half up", amount: 1000000, factor: big.NewRat(2, 3), places: 2, mode: RoundHalfUp, want: 666700},
+		{name: "two thirds down", amount: 1000000, factor: big.NewRat(2, 3), places: 2, mode: RoundDown, want: 666600},
+		{name: "one third up", amount: 1000000, factor: big.NewRat(1, 3), places: 2, mode: RoundUp, want: 333400},
+		{name: "negative two thirds half up", amount: -1000000, factor: big.NewRat(2, 3), places: 2, mode: RoundHalfUp, want: -666700},
+		{name: "half even tie", amount: 250, factor: big.NewRat(1, 1), places: 2, mode: RoundHalfEven, want: 200},
+		{name: "exact product", amount: 100000, factor: big.NewRat(3, 2), places: 2, mode: RoundDown, want: 150000},
+		{name: "places above scale", amount: 10000, factor: big.NewRat(1, 3), places: 6, mode: RoundHalfUp, want: 3333},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.amount.MulRat(tt.factor, tt.places, tt.mode); got != tt.want {
+				t.Errorf("MulRat() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyPercentAndMulDiv(t *testing.T) {
+	if got := Money(199900).Percent(7.25, 2, RoundHalfUp); got != 14500 {
+		t.Errorf("Percent(7.25) = %d, want 14500", got)
+	}
+	if got := Money(30000).Mul(0.1, 2, RoundHalfUp); got != 3000 {
+		t.Errorf("Mul(0.1) = %d, want 3000", got)
+	}
+	if got := Money(1000000).MulDiv(1, 3, 2, RoundHalfEven); got != 333300 {
+		t.Errorf("MulDiv(1, 3) = %d, want 333300", got)
+	}
+	if got := Money(1000000).MulDiv(1, 0, 2, RoundHalfEven); got != 0 {
+		t.Errorf("MulDiv(1, 0) = %d, want 0", got)
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	for _, amount := range []Money{0, 1, 12345600, -12345600, 15000, 12340} {
+		data, err := json.Marshal(amount)
+		if err != nil {
+			t.Fatalf("Marshal(%d) error = %v", amount, err)
+		}
+		var got Money
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s) error = %v", data, err)
+		}
+		if got != amount {
+			t.Errorf("JSON round-trip of %d gave %d via %s", amount, got, data)
+		}
+	}
+
+	var fromString Money
+	if err := json.Unmarshal([]byte(`"12.34"`), &fromString); err != nil || fromString != 123400 {
+		t.Errorf(`Unmarshal("12.34") = %d, %v, want 123400`, fromString, err)
+	}
+	fromNull := Money(5)
+	if err := json.Unmarshal([]byte(`null`), &fromNull); err != nil || fromNull != 5 {
+		t.Errorf("Unmarshal(null) = %d, %v, want the value unchanged", fromNull, err)
+	}
+	var tooPrecise Money
+	if err := json.Unmarshal([]byte(`1.00001`), &tooPrecise); err == nil {
+		t.Error("Unmarshal(1.00001) succeeded, want an error")
+	}
+}
+
+func TestMoneySQLRoundTrip(t *testing.T) {
+	for _, amount := range []Money{0, 1, 12345600, -12345600, 15000} {
+		value, err := amount.Value()
+		if err != nil {
+			t.Fatalf("Value() error = %v", err)
+		}
+		var got Money
+		if err := got.Scan([]byte(value.(string))); err != nil {
+			t.Fatalf("Scan(%v) error = %v", value, err)
+		}
+		if got != amount {
+			t.Errorf("SQL round-trip of %d gave %d via %v", amount, got, value)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    Money
+		wantErr bool
+	}{
+		{name: "nil", src: nil, want: 0},
+		{name: "numeric bytes", src: []byte("1234.5600"), want: 12345600},
+		{name: "string", src: "-0.05", want: -500},
+		{name: "computed average is rounded", src: "3.33333333", want: 33333},
+		{name: "int64", src: int64(7), want: 70000},
+		{name: "float64", src: 1.1, want: 11000},
+		{name: "invalid", src: "abc", wantErr: true},
+		{name: "unsupported type", src: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Money(99)
+			err := got.Scan(tt.src)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Scan(%v) = %d, want an error", tt.src, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scan(%v) error = %v", tt.src, err)
+			}
+			if got != tt.want {
+				t.Errorf("Scan(%v) = %d, want %d", tt.src, got, tt.want)
+			}
+		})
+	}
+}
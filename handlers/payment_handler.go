@@ -0,0 +1,625 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// Payments are posted to the ledger in two parts. Every allocation posts its
+// own cash/AR transaction (debit cash, credit the invoice's receivable
+// account) so that unapplying it is an exact reversal of that entry. Whatever
+// is not allocated is held as customer credit (debit cash, credit the
+// payment's credit account); applying or releasing credit posts the matching
+// adjustment between cash and the credit account.
+
+const paymentSelect = `
+	SELECT p.*, c.name AS customer_name
+	FROM accounting_payments p
+	LEFT JOIN customers c ON c.id = p.customer_id
+`
+
+// allocationRequest applies part of a payment to an invoice
+type allocationRequest struct {
+	InvoiceID int   `json:"invoice_id"`
+	Amount    Money `json:"amount"`
+}
+
+// loadPayment fetches a payment and its allocations. When forUpdate is set
+// the payment row is locked for the rest of the transaction.
+func loadPayment(q sqlx.Queryer, tenant string, id int, forUpdate bool) (*Payment, error) {
+	query := paymentSelect + " WHERE p.id = $1 AND p.tenant_id = $2"
+	if forUpdate {
+		query += " FOR UPDATE OF p"
+	}
+
+	var payment Payment
+	if err := sqlx.Get(q, &payment, query, id, tenant); err != nil {
+		return nil, err
+	}
+
+	err := sqlx.Select(q, &payment.Allocations, `
+		SELECT pa.*, i.invoice_number
+		FROM accounting_payment_allocations pa
+		JOIN accounting_invoices i ON i.id = pa.invoice_id
+		WHERE pa.payment_id = $1 AND pa.tenant_id = $2
+		ORDER BY pa.id
+	`, id, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// savePaymentTotals persists the allocated and unapplied amounts of a payment
+func savePaymentTotals(tx *sqlx.Tx, tenant string, payment *Payment) error {
+	_, err := tx.Exec(`
+		UPDATE accounting_payments SET allocated_amount = $1, unapplied_amount = $2 WHERE id = $3 AND tenant_id = $4
+	`, payment.AllocatedAmount, payment.UnappliedAmount, payment.ID, tenant)
+	return err
+}
+
+// changeInvoicePaid moves delta between the paid and open balance of an
+// invoice and derives its status from the result
+func changeInvoicePaid(tx *sqlx.Tx, tenant string, invoiceID int, delta Money) error {
+	_, err := tx.Exec(`
+		UPDATE accounting_invoices
+		SET paid_amount = paid_amount + $1,
+		    balance_amount = balance_amount - $1,
+		    status = CASE
+		        WHEN balance_amount - $1 <= 0 THEN 'paid'
+		        WHEN paid_amount + $1 > 0 THEN 'partially_paid'
+		        ELSE 'issued'
+		    END
+		WHERE id = $2 AND tenant_id = $3
+	`, delta, invoiceID, tenant)
+	return err
+}
+
+// allocatePayment applies amount of a payment to an invoice and posts the
+// cash/AR transaction for it
+func (h *AccountingHandler) allocatePayment(tx *sqlx.Tx, tenant string, payment *Payment, invoiceID int, amount Money, date time.Time) (*PaymentAllocation, error) {
+	if amount <= 0 {
+		return nil, newBadRequestError("Allocation amount must be greater than zero")
+	}
+	if err := checkMinorUnits(amount, payment.Currency); err != nil {
+		return nil, err
+	}
+
+	invoice, err := loadInvoice(tx, tenant, invoiceID, true)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newBadRequestError("Invoice %d not found", invoiceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invoice.CustomerID != payment.CustomerID {
+		return nil, newBadRequestError("Invoice %s belongs to a different customer", invoice.InvoiceNumber)
+	}
+	if invoice.Status != "issued" && invoice.Status != "partially_paid" {
+		return nil, newBadRequestError("Invoice %s is not open for payment", invoice.InvoiceNumber)
+	}
+	if invoice.Currency != payment.Currency {
+		return nil, newBadRequestError("Invoice %s is in %s, payment is in %s",
+			invoice.InvoiceNumber, invoice.Currency, payment.Currency)
+	}
+	if amount > invoice.BalanceAmount {
+		return nil, newBadRequestError("Allocation of %s exceeds the open balance of %s on invoice %s",
+			amount, invoice.BalanceAmount, invoice.InvoiceNumber)
+	}
+
+	referenceType := "payment"
+	description := fmt.Sprintf("Payment %s applied to invoice %s", payment.PaymentNumber, invoice.InvoiceNumber)
+	txn := &AccountingTransaction{
+		TransactionDate: date,
+		ReferenceType:   &referenceType,
+		ReferenceID:     &payment.ID,
+		Description:     &description,
+		Currency:        payment.Currency,
+		CreatedBy:       payment.CreatedBy,
+		Lines: []AccountingTransactionLine{
+			{AccountID: payment.CashAccountID, DebitAmount: amount, Description: &description},
+			{AccountID: invoice.ReceivableAccountID, CreditAmount: amount, Description: &description},
+		},
+	}
+	if err := h.postTransaction(tx, tenant, txn); err != nil {
+		return nil, err
+	}
+
+	if err := changeInvoicePaid(tx, tenant, invoice.ID, amount); err != nil {
+		return nil, err
+	}
+
+	allocation := &PaymentAllocation{
+		PaymentID:     payment.ID,
+		InvoiceID:     invoice.ID,
+		InvoiceNumber: &invoice.InvoiceNumber,
+		Amount:        amount,
+		TransactionID: txn.ID,
+	}
+	err = tx.QueryRow(`
+		INSERT INTO accounting_payment_allocations (tenant_id, payment_id, invoice_id, amount, transaction_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at
+	`, tenant, payment.ID, invoice.ID, amount, txn.ID).Scan(&allocation.ID, &allocation.Status, &allocation.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	payment.AllocatedAmount += amount
+	return allocation, nil
+}
+
+// adjustCustomerCredit changes the amount of a payment held as customer
+// credit. A positive delta debits cash and credits the credit account, a
+// negative delta releases credit back against cash.
+func (h *AccountingHandler) adjustCustomerCredit(tx *sqlx.Tx, tenant string, payment *Payment, delta Money, date time.Time) error {
+	if delta == 0 {
+		return nil
+	}
+	if payment.CreditAccountID == nil {
+		return newBadRequestError("credit_account_id is required to hold unapplied amounts as customer credit")
+	}
+
+	referenceType := "payment"
+	description := fmt.Sprintf("Customer credit from payment %s", payment.PaymentNumber)
+	cash := AccountingTransactionLine{AccountID: payment.CashAccountID, Description: &description}
+	credit := AccountingTransactionLine{AccountID: *payment.CreditAccountID, Description: &description}
+	if delta > 0 {
+		cash.DebitAmount = delta
+		credit.CreditAmount = delta
+	} else {
+		cash.CreditAmount = -delta
+		credit.DebitAmount = -delta
+	}
+
+	txn := &AccountingTransaction{
+		TransactionDate: date,
+		ReferenceType:   &referenceType,
+		ReferenceID:     &payment.ID,
+		Description:     &description,
+		Currency:        payment.Currency,
+		CreatedBy:       payment.CreatedBy,
+		Lines:           []AccountingTransactionLine{cash, credit},
+	}
+	if err := h.postTransaction(tx, tenant, txn); err != nil {
+		return err
+	}
+
+	payment.UnappliedAmount += delta
+	return nil
+}
+
+// unapplyAllocation reverses the ledger entry of an allocation and restores
+// the invoice balance
+func (h *AccountingHandler) unapplyAllocation(tx *sqlx.Tx, tenant string, payment *Payment, allocation *PaymentAllocation, date time.Time) error {
+	if allocation.Status != "applied" {
+		return newBadRequestError("Allocation is already unapplied")
+	}
+
+	reversal, err := h.reverseTransaction(tx, tenant, allocation.TransactionID, date,
+		fmt.Sprintf("Unapply payment %s from invoice %d", payment.PaymentNumber, allocation.InvoiceID))
+	if err != nil {
+		return err
+	}
+
+	if err := changeInvoicePaid(tx, tenant, allocation.InvoiceID, -allocation.Amount); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(`
+		UPDATE accounting_payment_allocations
+		SET status = 'unapplied', reversal_transaction_id = $1, unapplied_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND tenant_id = $3
+		RETURNING status, unapplied_at
+	`, reversal.ID, allocation.ID, tenant).Scan(&allocation.Status, &allocation.UnappliedAt)
+	if err != nil {
+		return err
+	}
+	allocation.ReversalTransactionID = &reversal.ID
+
+	payment.AllocatedAmount -= allocation.Amount
+	return nil
+}
+
+// GetPayments retrieves payments
+func (h *AccountingHandler) GetPayments(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	customerID := r.URL.Query().Get("customer_id")
+	startDate := r.URL.Query().Get("start_date")
+	endDate := r.URL.Query().Get("end_date")
+	limit, err := listLimit(r, 100)
+	if err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	qb := sdk.NewQueryBuilder(paymentSelect + " WHERE 1=1")
+	qb.AddCondition("p.tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition(
+		"p.cash_account_id IN (SELECT id FROM chart_of_accounts WHERE company_id IS NULL OR company_id = $%d)",
+		companyID(r))
+	qb.AddOptionalCondition("p.status = $%d", status)
+	qb.AddOptionalCondition("p.customer_id = $%d", customerID)
+	qb.AddOptionalCondition("p.payment_date >= $%d", startDate)
+	qb.AddOptionalCondition("p.payment_date <= $%d", endDate)
+
+	query, args := qb.Build()
+	query += fmt.Sprintf(" ORDER BY p.payment_date DESC, p.id DESC LIMIT %d", limit)
+
+	var payments []Payment
+	if err := h.db.Select(&payments, query, args...); err != nil {
+		h.logger.Error("Failed to fetch payments", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch payments")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"payments": payments,
+		"count":    len(payments),
+	})
+}
+
+// GetPayment retrieves a single payment with its allocations
+func (h *AccountingHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid payment ID")
+		return
+	}
+
+	payment, err := loadPayment(h.db, tenantID(r), id, false)
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch payment")
+		return
+	}
+
+	sdk.WriteSuccess(w, payment)
+}
+
+// CreatePayment records a customer payment, allocates it to the given
+// invoices and holds any remainder as customer credit
+func (h *AccountingHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CustomerID      int                 `json:"customer_id"`
+		PaymentDate     string              `json:"payment_date"`
+		Amount          Money               `json:"amount"`
+		Currency        string              `json:"currency"`
+		PaymentMethod   string              `json:"payment_method"`
+		Reference       *string             `json:"reference"`
+		CashAccountID   int                 `json:"cash_account_id"`
+		CreditAccountID *int                `json:"credit_account_id"`
+		Notes           *string             `json:"notes"`
+		Allocations     []allocationRequest `json:"allocations"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if err := sdk.ValidateRequired(map[string]interface{}{
+		"customer_id":     req.CustomerID,
+		"payment_date":    req.PaymentDate,
+		"cash_account_id": req.CashAccountID,
+	}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = "bank_transfer"
+	}
+	if err := sdk.ValidateEnum("payment_method", req.PaymentMethod,
+		[]string{"cash", "check", "bank_transfer", "card", "other"}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	paymentDate, err := parseDate(req.PaymentDate)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid payment date")
+		return
+	}
+
+	if req.Amount <= 0 {
+		sdk.WriteBadRequest(w, "Payment amount must be greater than zero")
+		return
+	}
+	tenant := tenantID(r)
+	settings, err := loadSettings(h.db, tenant)
+	if err != nil {
+		h.writeError(w, err, "Failed to create payment")
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = settings.DefaultCurrency
+	}
+	if err := checkCurrency(settings, req.Currency); err != nil {
+		h.writeError(w, err, "Failed to create payment")
+		return
+	}
+	if err := checkMinorUnits(req.Amount, req.Currency); err != nil {
+		h.writeError(w, err, "Failed to create payment")
+		return
+	}
+
+	var allocated Money
+	for _, a := range req.Allocations {
+		allocated += a.Amount
+	}
+	if allocated > req.Amount {
+		sdk.WriteBadRequest(w, "Allocations exceed the payment amount")
+		return
+	}
+
+	payment := &Payment{
+		PaymentNumber:   generateNumber("PAY"),
+		CustomerID:      req.CustomerID,
+		PaymentDate:     paymentDate,
+		Amount:          req.Amount,
+		Currency:        req.Currency,
+		PaymentMethod:   req.PaymentMethod,
+		Reference:       req.Reference,
+		CashAccountID:   req.CashAccountID,
+		CreditAccountID: req.CreditAccountID,
+		Notes:           req.Notes,
+		CreatedBy:       currentUserID(r),
+	}
+
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		var exists bool
+		if err := tx.Get(&exists, "SELECT EXISTS(SELECT 1 FROM customers WHERE id = $1 AND tenant_id = $2)",
+			payment.CustomerID, tenant); err != nil {
+			return err
+		}
+		if !exists {
+			return newBadRequestError("Customer not found")
+		}
+
+		err := tx.QueryRow(`
+			INSERT INTO accounting_payments
+			(tenant_id, payment_number, customer_id, payment_date, amount, currency, payment_method, reference,
+			 cash_account_id, credit_account_id, notes, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			RETURNING id, status, created_at, updated_at
+		`, tenant, payment.PaymentNumber, payment.CustomerID, payment.PaymentDate, payment.Amount, payment.Currency,
+			payment.PaymentMethod, payment.Reference, payment.CashAccountID, payment.CreditAccountID,
+			payment.Notes, payment.CreatedBy).
+			Scan(&payment.ID, &payment.Status, &payment.CreatedAt, &payment.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range req.Allocations {
+			allocation, err := h.allocatePayment(tx, tenant, payment, a.InvoiceID, a.Amount, payment.PaymentDate)
+			if err != nil {
+				return err
+			}
+			payment.Allocations = append(payment.Allocations, *allocation)
+		}
+
+		// Overpayments and unallocated receipts are held as customer credit
+		if err := h.adjustCustomerCredit(tx, tenant, payment, payment.Amount-payment.AllocatedAmount, payment.PaymentDate); err != nil {
+			return err
+		}
+
+		return savePaymentTotals(tx, tenant, payment)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to record payment")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"payment": payment,
+		"message": "Payment recorded successfully",
+	})
+}
+
+// AllocatePayment applies the unallocated amount of a payment to open
+// invoices, releasing customer credit held on it first
+func (h *AccountingHandler) AllocatePayment(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid payment ID")
+		return
+	}
+
+	var req struct {
+		Allocations []allocationRequest `json:"allocations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+	if len(req.Allocations) == 0 {
+		sdk.WriteBadRequest(w, "At least one allocation is required")
+		return
+	}
+
+	tenant := tenantID(r)
+	var payment *Payment
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadPayment(tx, tenant, id, true)
+		if err != nil {
+			return err
+		}
+		if loaded.Status != "posted" {
+			return newBadRequestError("Only posted payments can be allocated")
+		}
+		payment = loaded
+
+		today := time.Now()
+		for _, a := range req.Allocations {
+			if available := payment.Amount - payment.AllocatedAmount; a.Amount > available {
+				return newBadRequestError("Allocation of %s exceeds the unallocated amount of %s",
+					a.Amount, available)
+			}
+
+			allocation, err := h.allocatePayment(tx, tenant, payment, a.InvoiceID, a.Amount, today)
+			if err != nil {
+				return err
+			}
+			released := allocation.Amount
+			if released > payment.UnappliedAmount {
+				released = payment.UnappliedAmount
+			}
+			if err := h.adjustCustomerCredit(tx, tenant, payment, -released, today); err != nil {
+				return err
+			}
+			payment.Allocations = append(payment.Allocations, *allocation)
+		}
+
+		return savePaymentTotals(tx, tenant, payment)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to allocate payment")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"payment": payment,
+		"message": "Payment allocated successfully",
+	})
+}
+
+// UnapplyPaymentAllocation removes an allocation from its invoice. The
+// allocation's ledger entry is reversed and the amount is held as customer
+// credit on the payment, so the payment needs a credit account; the cash it
+// received stays in the ledger.
+func (h *AccountingHandler) UnapplyPaymentAllocation(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid payment ID")
+		return
+	}
+	allocationID, err := strconv.Atoi(chi.URLParam(r, "allocation_id"))
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid allocation ID")
+		return
+	}
+
+	tenant := tenantID(r)
+	var payment *Payment
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadPayment(tx, tenant, id, true)
+		if err != nil {
+			return err
+		}
+		if loaded.Status != "posted" {
+			return newBadRequestError("Only posted payments can be unapplied")
+		}
+		payment = loaded
+
+		for i := range payment.Allocations {
+			allocation := &payment.Allocations[i]
+			if allocation.ID != allocationID {
+				continue
+			}
+
+			if payment.CreditAccountID == nil {
+				return newBadRequestError("Payment %s has no credit account to hold the unapplied amount",
+					payment.PaymentNumber)
+			}
+
+			today := time.Now()
+			if err := h.unapplyAllocation(tx, tenant, payment, allocation, today); err != nil {
+				return err
+			}
+			if err := h.adjustCustomerCredit(tx, tenant, payment, allocation.Amount, today); err != nil {
+				return err
+			}
+			return savePaymentTotals(tx, tenant, payment)
+		}
+
+		return newNotFoundError("Allocation not found")
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to unapply payment")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"payment": payment,
+		"message": "Payment allocation unapplied successfully",
+	})
+}
+
+// ReversePayment reverses a payment, for example a bounced check. Every
+// allocation is unapplied and any held credit is released, so the ledger
+// impact of the payment is cancelled without deleting any rows.
+func (h *AccountingHandler) ReversePayment(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid payment ID")
+		return
+	}
+
+	var req struct {
+		Reason *string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sdk.WriteBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		payment, err := loadPayment(tx, tenant, id, true)
+		if err != nil {
+			return err
+		}
+		if payment.Status == "reversed" {
+			return newBadRequestError("Payment is already reversed")
+		}
+
+		today := time.Now()
+		for i := range payment.Allocations {
+			allocation := &payment.Allocations[i]
+			if allocation.Status != "applied" {
+				continue
+			}
+			if err := h.unapplyAllocation(tx, tenant, payment, allocation, today); err != nil {
+				return err
+			}
+		}
+
+		if err := h.adjustCustomerCredit(tx, tenant, payment, -payment.UnappliedAmount, today); err != nil {
+			return err
+		}
+
+		if err := savePaymentTotals(tx, tenant, payment); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE accounting_payments
+			SET status = 'reversed', reversal_reason = $1, reversed_at = CURRENT_TIMESTAMP
+			WHERE id = $2 AND tenant_id = $3
+		`, req.Reason, id, tenant)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to reverse payment")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Payment reversed successfully"})
+}
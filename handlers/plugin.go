@@ -37,9 +37,10 @@ var Metadata = struct {
 
 // AccountingPlugin implements the ModulePlugin interface
 type AccountingPlugin struct {
-	db      *sqlx.DB
-	logger  *zap.Logger
-	handler *AccountingHandler
+	db        *sqlx.DB
+	logger    *zap.Logger
+	handler   *AccountingHandler
+	scheduler *scheduler
 }
 
 // NewAccountingPlugin creates a new plugin instance
@@ -52,6 +53,11 @@ func (p *AccountingPlugin) Initialize(db *sqlx.DB, logger *zap.Logger) error {
 	p.db = db
 	p.logger = logger
 	p.handler = NewAccountingHandler(db, logger)
+	p.scheduler = newScheduler(logger, schedulerInterval,
+		scheduledJob{name: "journal_entry_auto_reversal", run: p.handler.runAutoReversals},
+		scheduledJob{name: "recurring_journal_entries", run: p.handler.runRecurringTemplates},
+	)
+	p.scheduler.Start()
 	p.logger.Info("Accounting module initialized")
 	return nil
 }
@@ -69,6 +75,9 @@ func (p *AccountingPlugin) GetModuleVersion() string {
 // Cleanup performs cleanup when module is unloaded
 func (p *AccountingPlugin) Cleanup() error {
 	p.logger.Info("Cleaning up accounting module")
+	if p.scheduler != nil {
+		p.scheduler.Stop()
+	}
 	return nil
 }
 
@@ -84,13 +93,13 @@ func (p *AccountingPlugin) GetHandler(route string, method string) (http.Handler
 	// Try exact match first
 	key := method + " /" + route
 	if handler, ok := handlers[key]; ok {
-		return handler, nil
+		return p.handler.withTenant(handler), nil
 	}
 
 	// Try pattern matching for routes with parameters
 	for pattern, handler := range handlers {
 		if p.matchRoute(pattern, key) {
-			return handler, nil
+			return p.handler.withTenant(handler), nil
 		}
 	}
 
@@ -98,28 +107,165 @@ func (p *AccountingPlugin) GetHandler(route string, method string) (http.Handler
 }
 
 // buildHandlerMap creates the mapping of routes to handlers
-func (p *AccountingPlugin) buildHandlerMap() map[string]http.HandlerFunc {
-	return map[string]http.HandlerFunc{
+func (p *AccountingPlugin) buildHandlerMap() map[string]handlerFunc {
+	return map[string]handlerFunc{
 		// Chart of Accounts
-		"GET /accounts":         p.handler.GetChartOfAccounts,
-		"POST /accounts":        p.handler.CreateChartOfAccount,
-		"PUT /accounts/{id}":    p.handler.UpdateChartOfAccount,
-		"DELETE /accounts/{id}": p.handler.DeleteChartOfAccount,
+		"GET /accounts":         (*AccountingHandler).GetChartOfAccounts,
+		"POST /accounts":        (*AccountingHandler).CreateChartOfAccount,
+		"PUT /accounts/{id}":    (*AccountingHandler).UpdateChartOfAccount,
+		"DELETE /accounts/{id}": (*AccountingHandler).DeleteChartOfAccount,
+
+		// Companies
+		"GET /companies":         (*AccountingHandler).GetCompanies,
+		"POST /companies":        (*AccountingHandler).CreateCompany,
+		"GET /companies/{id}":    (*AccountingHandler).GetCompany,
+		"PUT /companies/{id}":    (*AccountingHandler).UpdateCompany,
+		"DELETE /companies/{id}": (*AccountingHandler).DeleteCompany,
+
+		// Inter-Company Transactions
+		"GET /intercompany-transactions":            (*AccountingHandler).GetIntercompanyTransactions,
+		"POST /intercompany-transactions":           (*AccountingHandler).CreateIntercompanyTransaction,
+		"GET /intercompany-transactions/{id}":       (*AccountingHandler).GetIntercompanyTransaction,
+		"POST /intercompany-transactions/{id}/void": (*AccountingHandler).VoidIntercompanyTransaction,
 
 		// Transactions
-		"GET /transactions":  p.handler.GetAccountingTransactions,
-		"POST /transactions": p.handler.CreateAccountingTransaction,
+		"GET /transactions":                (*AccountingHandler).GetAccountingTransactions,
+		"POST /transactions":               (*AccountingHandler).CreateAccountingTransaction,
+		"POST /transactions/{id}/approve":  (*AccountingHandler).ApproveTransaction,
+		"POST /transactions/{id}/reject":   (*AccountingHandler).RejectTransaction,
+		"GET /transactions/{id}/approvals": (*AccountingHandler).GetTransactionApprovals,
+		"POST /transactions/{id}/post":     (*AccountingHandler).PostDraftTransaction,
+		"POST /transactions/{id}/void":     (*AccountingHandler).VoidTransaction,
+
+		// Invoices
+		"GET /invoices":             (*AccountingHandler).GetInvoices,
+		"POST /invoices":            (*AccountingHandler).CreateInvoice,
+		"GET /invoices/{id}":        (*AccountingHandler).GetInvoice,
+		"PUT /invoices/{id}":        (*AccountingHandler).UpdateInvoice,
+		"POST /invoices/{id}/issue": (*AccountingHandler).IssueInvoice,
+		"POST /invoices/{id}/void":  (*AccountingHandler).VoidInvoice,
+		"DELETE /invoices/{id}":     (*AccountingHandler).VoidInvoice,
+
+		// Payments
+		"GET /payments":                   (*AccountingHandler).GetPayments,
+		"POST /payments":                  (*AccountingHandler).CreatePayment,
+		"GET /payments/{id}":              (*AccountingHandler).GetPayment,
+		"POST /payments/{id}/allocations": (*AccountingHandler).AllocatePayment,
+		"POST /payments/{id}/allocations/{allocation_id}/unapply": (*AccountingHandler).UnapplyPaymentAllocation,
+		"POST /payments/{id}/reverse":                             (*AccountingHandler).ReversePayment,
+		"DELETE /payments/{id}":                                   (*AccountingHandler).ReversePayment,
+
+		// Budgets
+		"GET /budgets":         (*AccountingHandler).GetBudgets,
+		"POST /budgets":        (*AccountingHandler).CreateBudget,
+		"GET /budgets/{id}":    (*AccountingHandler).GetBudget,
+		"PUT /budgets/{id}":    (*AccountingHandler).UpdateBudget,
+		"DELETE /budgets/{id}": (*AccountingHandler).DeleteBudget,
+
+		// Budget Alerts
+		"GET /budget-alerts":                   (*AccountingHandler).GetBudgetAlerts,
+		"POST /budget-alerts/{id}/acknowledge": (*AccountingHandler).AcknowledgeBudgetAlert,
 
 		// Journal Entries
-		"GET /journal-entries":  p.handler.GetJournalEntries,
-		"POST /journal-entries": p.handler.CreateJournalEntry,
+		"GET /journal-entries":                (*AccountingHandler).GetJournalEntries,
+		"POST /journal-entries":               (*AccountingHandler).CreateJournalEntry,
+		"POST /journal-entries/{id}/approve":  (*AccountingHandler).ApproveJournalEntry,
+		"POST /journal-entries/{id}/reject":   (*AccountingHandler).RejectJournalEntry,
+		"GET /journal-entries/{id}/approvals": (*AccountingHandler).GetJournalEntryApprovals,
+		"POST /journal-entries/{id}/reverse":  (*AccountingHandler).ReverseJournalEntry,
+		"POST /journal-entries/{id}/post":     (*AccountingHandler).PostDraftJournalEntry,
+
+		// Recurring Templates
+		"GET /recurring-templates":           (*AccountingHandler).GetRecurringTemplates,
+		"POST /recurring-templates":          (*AccountingHandler).CreateRecurringTemplate,
+		"GET /recurring-templates/{id}":      (*AccountingHandler).GetRecurringTemplate,
+		"PUT /recurring-templates/{id}":      (*AccountingHandler).UpdateRecurringTemplate,
+		"DELETE /recurring-templates/{id}":   (*AccountingHandler).DeleteRecurringTemplate,
+		"GET /recurring-templates/{id}/runs": (*AccountingHandler).GetRecurringTemplateRuns,
+		"POST /recurring-templates/{id}/run": (*AccountingHandler).RunRecurringTemplate,
+
+		// Approval Policies
+		"GET /approval-policies":         (*AccountingHandler).GetApprovalPolicies,
+		"POST /approval-policies":        (*AccountingHandler).CreateApprovalPolicy,
+		"GET /approval-policies/{id}":    (*AccountingHandler).GetApprovalPolicy,
+		"PUT /approval-policies/{id}":    (*AccountingHandler).UpdateApprovalPolicy,
+		"DELETE /approval-policies/{id}": (*AccountingHandler).DeleteApprovalPolicy,
+
+		// Reconciliations
+		"GET /reconciliations":                            (*AccountingHandler).GetReconciliations,
+		"POST /reconciliations":                           (*AccountingHandler).CreateReconciliation,
+		"GET /reconciliations/{id}":                       (*AccountingHandler).GetReconciliation,
+		"POST /reconciliations/{id}/statement-lines":      (*AccountingHandler).AddStatementLines,
+		"POST /reconciliations/{id}/matches":              (*AccountingHandler).MatchReconciliationLines,
+		"DELETE /reconciliations/{id}/matches/{match_id}": (*AccountingHandler).UnmatchReconciliationLines,
+		"POST /reconciliations/{id}/complete":             (*AccountingHandler).CompleteReconciliation,
+		"GET /reconciliations/{id}/report":                (*AccountingHandler).GetReconciliationReport,
+		"GET /reconciliations/{id}/suggestions":           (*AccountingHandler).GetMatchSuggestions,
+		"POST /reconciliations/{id}/auto-match":           (*AccountingHandler).AutoMatchReconciliation,
+		"POST /reconciliations/{id}/apply-rules":          (*AccountingHandler).ApplyBankRules,
+
+		// Bank Rules
+		"GET /bank-rules":         (*AccountingHandler).GetBankRules,
+		"POST /bank-rules":        (*AccountingHandler).CreateBankRule,
+		"PUT /bank-rules/{id}":    (*AccountingHandler).UpdateBankRule,
+		"DELETE /bank-rules/{id}": (*AccountingHandler).DeleteBankRule,
+
+		// Bank Statements
+		"GET /bank-statements/lines":            (*AccountingHandler).GetBankStatementLines,
+		"GET /bank-statements/imports":          (*AccountingHandler).GetBankStatementImports,
+		"POST /bank-statements/import":          (*AccountingHandler).ImportBankStatement,
+		"GET /bank-statements/profiles":         (*AccountingHandler).GetBankImportProfiles,
+		"POST /bank-statements/profiles":        (*AccountingHandler).CreateBankImportProfile,
+		"DELETE /bank-statements/profiles/{id}": (*AccountingHandler).DeleteBankImportProfile,
+
+		// Tax Codes
+		"GET /tax-codes":             (*AccountingHandler).GetTaxCodes,
+		"POST /tax-codes":            (*AccountingHandler).CreateTaxCode,
+		"POST /tax-codes/calculate":  (*AccountingHandler).CalculateTax,
+		"GET /tax-codes/{id}":        (*AccountingHandler).GetTaxCode,
+		"PUT /tax-codes/{id}":        (*AccountingHandler).UpdateTaxCode,
+		"DELETE /tax-codes/{id}":     (*AccountingHandler).DeleteTaxCode,
+		"POST /tax-codes/{id}/rates": (*AccountingHandler).AddTaxRate,
+
+		// Exchange Rates
+		"GET /exchange-rates":         (*AccountingHandler).GetExchangeRates,
+		"POST /exchange-rates":        (*AccountingHandler).CreateExchangeRate,
+		"POST /exchange-rates/import": (*AccountingHandler).ImportExchangeRates,
+		"DELETE /exchange-rates/{id}": (*AccountingHandler).DeleteExchangeRate,
+
+		// FX Revaluations
+		"GET /fx-revaluations":         (*AccountingHandler).GetFXRevaluations,
+		"GET /fx-revaluations/preview": (*AccountingHandler).PreviewFXRevaluation,
+		"POST /fx-revaluations":        (*AccountingHandler).RunFXRevaluation,
+		"GET /fx-revaluations/{id}":    (*AccountingHandler).GetFXRevaluation,
+
+		// Fiscal Periods
+		"GET /fiscal-periods":              (*AccountingHandler).GetFiscalPeriods,
+		"GET /fiscal-periods/{id}":         (*AccountingHandler).GetFiscalPeriod,
+		"POST /fiscal-periods/generate":    (*AccountingHandler).GenerateFiscalPeriods,
+		"POST /fiscal-periods/{id}/close":  (*AccountingHandler).CloseFiscalPeriod,
+		"POST /fiscal-periods/{id}/reopen": (*AccountingHandler).ReopenFiscalPeriod,
+
+		// Year-End Close
+		"GET /year-end-close":              (*AccountingHandler).GetYearEndCloses,
+		"GET /year-end-close/preview":      (*AccountingHandler).PreviewYearEndClose,
+		"POST /year-end-close":             (*AccountingHandler).CloseFiscalYear,
+		"POST /year-end-close/{id}/reopen": (*AccountingHandler).ReopenFiscalYear,
+
+		// Settings
+		"GET /settings": (*AccountingHandler).GetSettings,
+		"PUT /settings": (*AccountingHandler).UpdateSettings,
 
 		// Reports
-		"GET /reports/balance-sheet":    p.handler.GetBalanceSheet,
-		"GET /reports/income-statement": p.handler.GetIncomeStatement,
+		"GET /reports/balance-sheet":    (*AccountingHandler).GetBalanceSheet,
+		"GET /reports/income-statement": (*AccountingHandler).GetIncomeStatement,
+		"GET /reports/budget-vs-actual": (*AccountingHandler).GetBudgetVsActual,
+		"GET /reports/general-ledger":   (*AccountingHandler).GetGeneralLedger,
+		"GET /reports/trial-balance":    (*AccountingHandler).GetTrialBalance,
+		"GET /reports/cash-flow":        (*AccountingHandler).GetCashFlowStatement,
 
 		// Analytics
-		"GET /analytics": p.handler.GetAnalytics,
+		"GET /analytics": (*AccountingHandler).GetAnalytics,
 	}
 }
 
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+)
+
+// moduleRoutes reads the method and path of every API route declared in
+// module.yml
+func moduleRoutes(t *testing.T) []string {
+	t.Helper()
+	file, err := os.Open("../module.yml")
+	if err != nil {
+		t.Fatalf("open module.yml: %v", err)
+	}
+	defer file.Close()
+
+	var routes []string
+	inAPI := false
+	path := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "api:":
+			inAPI = true
+		case inAPI && strings.HasSuffix(line, ":") && !strings.HasPrefix(line, "-") && line != "routes:":
+			inAPI = false
+		case inAPI && strings.HasPrefix(line, "- path:"):
+			path = strings.TrimSpace(strings.TrimPrefix(line, "- path:"))
+		case inAPI && strings.HasPrefix(line, "methods:") && path != "":
+			methods := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "methods:")), "[]")
+			for _, method := range strings.Split(methods, ",") {
+				routes = append(routes, strings.TrimSpace(method)+" "+path)
+			}
+			path = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("read module.yml: %v", err)
+	}
+	return routes
+}
+
+// bankRuleRoutes are the bank rule and matching routes module.yml declares
+var bankRuleRoutes = []string{
+	"GET /reconciliations/{id}/suggestions",
+	"POST /reconciliations/{id}/auto-match",
+	"POST /reconciliations/{id}/apply-rules",
+	"GET /bank-rules",
+	"POST /bank-rules",
+	"PUT /bank-rules/{id}",
+	"DELETE /bank-rules/{id}",
+}
+
+func TestBankRuleRoutesHaveHandlers(t *testing.T) {
+	declared := map[string]bool{}
+	for _, route := range moduleRoutes(t) {
+		declared[route] = true
+	}
+
+	plugin := &AccountingPlugin{handler: &AccountingHandler{}}
+	for _, route := range bankRuleRoutes {
+		if !declared[route] {
+			t.Errorf("%s is not declared in module.yml", route)
+		}
+		parts := strings.SplitN(route, " ", 2)
+		if _, err := plugin.GetHandler(strings.ReplaceAll(parts[1], "{id}", "1"), parts[0]); err != nil {
+			t.Errorf("%s: %v", route, err)
+		}
+	}
+}
+
+func TestMatchRoute(t *testing.T) {
+	plugin := &AccountingPlugin{}
+	tests := []struct {
+		pattern string
+		actual  string
+		want    bool
+	}{
+		{"GET /invoices/{id}", "GET /invoices/12", true},
+		{"GET /invoices/{id}", "POST /invoices/12", false},
+		{"GET /invoices/{id}", "GET /invoices/12/issue", false},
+		{"POST /reconciliations/{id}/auto-match", "POST /reconciliations/7/auto-match", true},
+		{"POST /reconciliations/{id}/auto-match", "POST /reconciliations/7/apply-rules", false},
+	}
+	for _, tt := range tests {
+		if got := plugin.matchRoute(tt.pattern, tt.actual); got != tt.want {
+			t.Errorf("matchRoute(%q, %q) = %v, want %v", tt.pattern, tt.actual, got, tt.want)
+		}
+	}
+}
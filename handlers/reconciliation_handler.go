@@ -0,0 +1,758 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// A reconciliation compares a bank statement with the ledger of a cash
+// account. Statement lines are matched to ledger lines, which marks the ledger
+// lines cleared; the reconciliation can be completed once the statement
+// balance and the cleared balance agree within reconciliation_tolerance.
+// Completed reconciliations are locked.
+
+const reconciliationSelect = `
+	SELECT r.*, coa.account_name
+	FROM accounting_reconciliations r
+	JOIN chart_of_accounts coa ON coa.id = r.account_id
+`
+
+const ledgerLineSelect = `
+	SELECT atl.id, atl.transaction_id, at.transaction_number, at.transaction_date,
+	       COALESCE(atl.description, at.description) AS description,
+	       at.reference_type, at.reference_id,
+	       atl.debit_amount - atl.credit_amount AS amount,
+	       atl.reconciliation_id
+	FROM accounting_transaction_lines atl
+	JOIN accounting_transactions at ON at.id = atl.transaction_id
+`
+
+// loadReconciliation fetches a reconciliation, locking it when forUpdate is set
+func loadReconciliation(q sqlx.Queryer, tenant string, id int, forUpdate bool) (*Reconciliation, error) {
+	query := reconciliationSelect + " WHERE r.id = $1 AND r.tenant_id = $2"
+	if forUpdate {
+		query += " FOR UPDATE OF r"
+	}
+
+	var rec Reconciliation
+	if err := sqlx.Get(q, &rec, query, id, tenant); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// loadOpenReconciliation fetches an in-progress reconciliation for update
+func loadOpenReconciliation(tx *sqlx.Tx, tenant string, id int) (*Reconciliation, error) {
+	rec, err := loadReconciliation(tx, tenant, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Status != "in_progress" {
+		return nil, newBadRequestError("Reconciliation is completed and locked")
+	}
+	return rec, nil
+}
+
+// refreshReconciliationBalances recomputes the book and reconciled balances
+// of a reconciliation and the difference to the statement balance
+func refreshReconciliationBalances(tx *sqlx.Tx, tenant string, rec *Reconciliation) error {
+	err := tx.Get(&rec.BookBalance, `
+		SELECT COALESCE(SUM(atl.debit_amount - atl.credit_amount), 0)
+		FROM accounting_transaction_lines atl
+		JOIN accounting_transactions at ON at.id = atl.transaction_id
+		WHERE atl.tenant_id = $1 AND atl.account_id = $2 AND at.status IN ('posted', 'void')
+		  AND at.transaction_date <= $3
+	`, tenant, rec.AccountID, rec.StatementDate)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Get(&rec.ReconciledBalance, `
+		SELECT COALESCE(SUM(atl.debit_amount - atl.credit_amount), 0)
+		FROM accounting_transaction_lines atl
+		WHERE atl.tenant_id = $1 AND atl.account_id = $2 AND atl.reconciliation_id IS NOT NULL
+	`, tenant, rec.AccountID)
+	if err != nil {
+		return err
+	}
+
+	rec.Difference = rec.StatementBalance - rec.ReconciledBalance
+
+	_, err = tx.Exec(`
+		UPDATE accounting_reconciliations
+		SET book_balance = $1, reconciled_balance = $2, difference = $3
+		WHERE id = $4 AND tenant_id = $5
+	`, rec.BookBalance, rec.ReconciledBalance, rec.Difference, rec.ID, tenant)
+	return err
+}
+
+// requireBankAccount checks that an account is an active asset account that
+// bank statements can be recorded against
+func requireBankAccount(q sqlx.Queryer, tenant string, accountID int) error {
+	var accountType string
+	err := sqlx.Get(q, &accountType,
+		"SELECT account_type FROM chart_of_accounts WHERE id = $1 AND tenant_id = $2 AND is_active = true", accountID, tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newBadRequestError("Account %d not found", accountID)
+	}
+	if err != nil {
+		return err
+	}
+	if accountType != "asset" {
+		return newBadRequestError("Account %d is not an asset (cash) account", accountID)
+	}
+	return nil
+}
+
+// attachStatementLines assigns the account's unreconciled statement lines up
+// to the statement date to a reconciliation
+func attachStatementLines(tx *sqlx.Tx, tenant string, rec *Reconciliation) error {
+	_, err := tx.Exec(`
+		UPDATE accounting_bank_statement_lines
+		SET reconciliation_id = $1
+		WHERE tenant_id = $2 AND account_id = $3 AND reconciliation_id IS NULL AND status = 'unmatched'
+		  AND transaction_date <= $4
+	`, rec.ID, tenant, rec.AccountID, rec.StatementDate)
+	return err
+}
+
+// GetReconciliations retrieves reconciliations
+func (h *AccountingHandler) GetReconciliations(w http.ResponseWriter, r *http.Request) {
+	accountID := r.URL.Query().Get("account_id")
+	status := r.URL.Query().Get("status")
+
+	qb := sdk.NewQueryBuilder(reconciliationSelect + " WHERE 1=1")
+	qb.AddCondition("r.tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition(
+		"r.account_id IN (SELECT id FROM chart_of_accounts WHERE company_id IS NULL OR company_id = $%d)",
+		companyID(r))
+	qb.AddOptionalCondition("r.account_id = $%d", accountID)
+	qb.AddOptionalCondition("r.status = $%d", status)
+
+	query, args := qb.Build()
+	query += " ORDER BY r.statement_date DESC, r.id DESC"
+
+	var reconciliations []Reconciliation
+	if err := h.db.Select(&reconciliations, query, args...); err != nil {
+		h.logger.Error("Failed to fetch reconciliations", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch reconciliations")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"reconciliations": reconciliations,
+		"count":           len(reconciliations),
+	})
+}
+
+// GetReconciliation retrieves a reconciliation with its statement lines,
+// matches and the ledger lines that are still uncleared
+func (h *AccountingHandler) GetReconciliation(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid reconciliation ID")
+		return
+	}
+
+	tenant := tenantID(r)
+	rec, err := loadReconciliation(h.db, tenant, id, false)
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch reconciliation")
+		return
+	}
+
+	var statementLines []BankStatementLine
+	if err := h.db.Select(&statementLines, `
+		SELECT * FROM accounting_bank_statement_lines
+		WHERE tenant_id = $1 AND reconciliation_id = $2
+		ORDER BY transaction_date, id
+	`, tenant, id); err != nil {
+		h.writeError(w, err, "Failed to fetch reconciliation")
+		return
+	}
+
+	matches, err := loadReconciliationMatches(h.db, tenant, id)
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch reconciliation")
+		return
+	}
+
+	var uncleared []LedgerLine
+	if err := h.db.Select(&uncleared, ledgerLineSelect+`
+		WHERE atl.tenant_id = $1 AND atl.account_id = $2 AND atl.reconciliation_id IS NULL
+		  AND at.status IN ('posted', 'void') AND at.transaction_date <= $3
+		ORDER BY at.transaction_date, atl.id
+	`, tenant, rec.AccountID, rec.StatementDate); err != nil {
+		h.writeError(w, err, "Failed to fetch reconciliation")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"reconciliation":   rec,
+		"statement_lines":  statementLines,
+		"matches":          matches,
+		"uncleared_lines":  uncleared,
+		"uncleared_amount": sumLedgerLines(uncleared),
+	})
+}
+
+// loadReconciliationMatches fetches the matches of a reconciliation with the
+// statement and ledger lines in each
+func loadReconciliationMatches(q sqlx.Queryer, tenant string, reconciliationID int) ([]ReconciliationMatch, error) {
+	var matches []ReconciliationMatch
+	if err := sqlx.Select(q, &matches, `
+		SELECT * FROM accounting_reconciliation_matches WHERE tenant_id = $1 AND reconciliation_id = $2 ORDER BY id
+	`, tenant, reconciliationID); err != nil {
+		return nil, err
+	}
+
+	for i := range matches {
+		if err := sqlx.Select(q, &matches[i].StatementLineIDs, `
+			SELECT id FROM accounting_bank_statement_lines WHERE tenant_id = $1 AND match_id = $2 ORDER BY id
+		`, tenant, matches[i].ID); err != nil {
+			return nil, err
+		}
+		if err := sqlx.Select(q, &matches[i].TransactionLineIDs, `
+			SELECT transaction_line_id FROM accounting_reconciliation_match_lines
+			WHERE tenant_id = $1 AND match_id = $2
+			ORDER BY transaction_line_id
+		`, tenant, matches[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return matches, nil
+}
+
+func sumLedgerLines(lines []LedgerLine) Money {
+	var total Money
+	for _, line := range lines {
+		total += line.Amount
+	}
+	return total
+}
+
+// CreateReconciliation starts a reconciliation of a cash account against a
+// bank statement
+func (h *AccountingHandler) CreateReconciliation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AccountID        int     `json:"account_id"`
+		StatementDate    string  `json:"statement_date"`
+		StatementBalance Money   `json:"statement_balance"`
+		Notes            *string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if err := sdk.ValidateRequired(map[string]interface{}{
+		"account_id":     req.AccountID,
+		"statement_date": req.StatementDate,
+	}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	statementDate, err := parseDate(req.StatementDate)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid statement date")
+		return
+	}
+
+	tenant := tenantID(r)
+	settings, err := loadSettings(h.db, tenant)
+	if err != nil {
+		h.writeError(w, err, "Failed to start reconciliation")
+		return
+	}
+	if !settings.EnableReconciliations {
+		sdk.WriteBadRequest(w, "Bank reconciliations are disabled")
+		return
+	}
+
+	rec := &Reconciliation{
+		AccountID:        req.AccountID,
+		StatementDate:    statementDate,
+		StatementBalance: req.StatementBalance,
+		Notes:            req.Notes,
+		CreatedBy:        currentUserID(r),
+	}
+
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		if err := requireBankAccount(tx, tenant, req.AccountID); err != nil {
+			return err
+		}
+
+		var inProgress bool
+		if err := tx.Get(&inProgress, `
+			SELECT EXISTS(SELECT 1 FROM accounting_reconciliations
+			              WHERE tenant_id = $1 AND account_id = $2 AND status = 'in_progress')
+		`, tenant, req.AccountID); err != nil {
+			return err
+		}
+		if inProgress {
+			return newBadRequestError("The account already has a reconciliation in progress")
+		}
+
+		var last struct {
+			StatementDate    time.Time `db:"statement_date"`
+			StatementBalance Money     `db:"statement_balance"`
+		}
+		err := tx.Get(&last, `
+			SELECT statement_date, statement_balance FROM accounting_reconciliations
+			WHERE tenant_id = $1 AND account_id = $2 AND status = 'completed'
+			ORDER BY statement_date DESC, id DESC LIMIT 1
+		`, tenant, req.AccountID)
+		if err == nil {
+			if !statementDate.After(last.StatementDate) {
+				return newBadRequestError("Statement date must be after the last reconciled statement (%s)",
+					last.StatementDate.Format("2006-01-02"))
+			}
+			rec.OpeningBalance = last.StatementBalance
+		}
+
+		err = tx.QueryRow(`
+			INSERT INTO accounting_reconciliations
+			(tenant_id, account_id, statement_date, opening_balance, statement_balance, notes, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, status, created_at, updated_at
+		`, tenant, rec.AccountID, rec.StatementDate, rec.OpeningBalance, rec.StatementBalance, rec.Notes, rec.CreatedBy).
+			Scan(&rec.ID, &rec.Status, &rec.CreatedAt, &rec.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		if err := attachStatementLines(tx, tenant, rec); err != nil {
+			return err
+		}
+
+		return refreshReconciliationBalances(tx, tenant, rec)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to start reconciliation")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"reconciliation": rec,
+		"message":        "Reconciliation started successfully",
+	})
+}
+
+// AddStatementLines loads bank statement lines into a reconciliation
+func (h *AccountingHandler) AddStatementLines(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid reconciliation ID")
+		return
+	}
+
+	var req struct {
+		Lines []struct {
+			TransactionDate string  `json:"transaction_date"`
+			Description     *string `json:"description"`
+			Reference       *string `json:"reference"`
+			Amount          Money   `json:"amount"`
+		} `json:"lines"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+	if len(req.Lines) == 0 {
+		sdk.WriteBadRequest(w, "At least one statement line is required")
+		return
+	}
+
+	var lines []BankStatementLine
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		rec, err := loadOpenReconciliation(tx, tenant, id)
+		if err != nil {
+			return err
+		}
+
+		for i, l := range req.Lines {
+			date, err := parseDate(l.TransactionDate)
+			if err != nil {
+				return newBadRequestError("Line %d: invalid transaction date", i+1)
+			}
+			if date.After(rec.StatementDate) {
+				return newBadRequestError("Line %d: dated after the statement date", i+1)
+			}
+
+			var line BankStatementLine
+			err = tx.Get(&line, `
+				INSERT INTO accounting_bank_statement_lines
+				(tenant_id, account_id, reconciliation_id, transaction_date, description, reference, amount)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				RETURNING *
+			`, tenant, rec.AccountID, rec.ID, date, l.Description, l.Reference, l.Amount)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, line)
+		}
+		return nil
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to add statement lines")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"lines":   lines,
+		"count":   len(lines),
+		"message": "Statement lines added successfully",
+	})
+}
+
+// MatchReconciliationLines matches one or more statement lines to one or
+// more ledger lines of the reconciled account and marks the ledger lines
+// cleared. The amounts on both sides must agree. Ledger lines can also be
+// cleared without a statement line by sending only transaction_line_ids.
+func (h *AccountingHandler) MatchReconciliationLines(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid reconciliation ID")
+		return
+	}
+
+	var req struct {
+		StatementLineIDs   []int `json:"statement_line_ids"`
+		TransactionLineIDs []int `json:"transaction_line_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+	if len(req.TransactionLineIDs) == 0 {
+		sdk.WriteBadRequest(w, "At least one transaction line is required")
+		return
+	}
+
+	var match ReconciliationMatch
+	var rec *Reconciliation
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadOpenReconciliation(tx, tenant, id)
+		if err != nil {
+			return err
+		}
+		rec = loaded
+
+		match, err = h.matchReconciliationLines(tx, tenant, rec, req.StatementLineIDs, req.TransactionLineIDs,
+			currentUserID(r))
+		if err != nil {
+			return err
+		}
+
+		return refreshReconciliationBalances(tx, tenant, rec)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to match reconciliation lines")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"match":          match,
+		"reconciliation": rec,
+		"message":        "Lines matched successfully",
+	})
+}
+
+// matchReconciliationLines records a match inside an open reconciliation
+func (h *AccountingHandler) matchReconciliationLines(tx *sqlx.Tx, tenant string, rec *Reconciliation, statementLineIDs, transactionLineIDs []int, userID int) (ReconciliationMatch, error) {
+	match := ReconciliationMatch{
+		ReconciliationID:   rec.ID,
+		CreatedBy:          userID,
+		StatementLineIDs:   statementLineIDs,
+		TransactionLineIDs: transactionLineIDs,
+	}
+
+	var statementTotal Money
+	for _, lineID := range statementLineIDs {
+		var line BankStatementLine
+		err := tx.Get(&line,
+			"SELECT * FROM accounting_bank_statement_lines WHERE id = $1 AND tenant_id = $2 FOR UPDATE", lineID, tenant)
+		if err != nil {
+			return match, newBadRequestError("Statement line %d not found", lineID)
+		}
+		if line.ReconciliationID == nil || *line.ReconciliationID != rec.ID {
+			return match, newBadRequestError("Statement line %d does not belong to this reconciliation", lineID)
+		}
+		if line.Status != "unmatched" {
+			return match, newBadRequestError("Statement line %d is already matched", lineID)
+		}
+		statementTotal += line.Amount
+	}
+
+	var ledgerTotal Money
+	for _, lineID := range transactionLineIDs {
+		var line LedgerLine
+		err := tx.Get(&line, ledgerLineSelect+`
+			WHERE atl.id = $1 AND atl.tenant_id = $2 AND atl.account_id = $3 AND at.status IN ('posted', 'void')
+			FOR UPDATE OF atl
+		`, lineID, tenant, rec.AccountID)
+		if err != nil {
+			return match, newBadRequestError("Transaction line %d is not a posted line of the reconciled account", lineID)
+		}
+		if line.ReconciliationID != nil {
+			return match, newBadRequestError("Transaction line %d is already cleared", lineID)
+		}
+		if line.TransactionDate.After(rec.StatementDate) {
+			return match, newBadRequestError("Transaction line %d is dated after the statement date", lineID)
+		}
+		ledgerTotal += line.Amount
+	}
+
+	if len(statementLineIDs) > 0 && statementTotal != ledgerTotal {
+		return match, newBadRequestError("Statement amount %s does not match ledger amount %s",
+			statementTotal, ledgerTotal)
+	}
+	match.Amount = ledgerTotal
+
+	err := tx.QueryRow(`
+		INSERT INTO accounting_reconciliation_matches (tenant_id, reconciliation_id, amount, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, tenant, rec.ID, match.Amount, match.CreatedBy).Scan(&match.ID, &match.CreatedAt)
+	if err != nil {
+		return match, err
+	}
+
+	for _, lineID := range statementLineIDs {
+		if _, err := tx.Exec(`
+			UPDATE accounting_bank_statement_lines SET status = 'matched', match_id = $1
+			WHERE id = $2 AND tenant_id = $3
+		`, match.ID, lineID, tenant); err != nil {
+			return match, err
+		}
+	}
+
+	for _, lineID := range transactionLineIDs {
+		if _, err := tx.Exec(`
+			INSERT INTO accounting_reconciliation_match_lines (tenant_id, match_id, transaction_line_id)
+			VALUES ($1, $2, $3)
+		`, tenant, match.ID, lineID); err != nil {
+			return match, err
+		}
+		if _, err := tx.Exec(`
+			UPDATE accounting_transaction_lines SET reconciliation_id = $1, cleared_at = CURRENT_TIMESTAMP
+			WHERE id = $2 AND tenant_id = $3
+		`, rec.ID, lineID, tenant); err != nil {
+			return match, err
+		}
+	}
+
+	return match, nil
+}
+
+// UnmatchReconciliationLines removes a match from an open reconciliation
+func (h *AccountingHandler) UnmatchReconciliationLines(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid reconciliation ID")
+		return
+	}
+	matchID, err := strconv.Atoi(chi.URLParam(r, "match_id"))
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid match ID")
+		return
+	}
+
+	var rec *Reconciliation
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadOpenReconciliation(tx, tenant, id)
+		if err != nil {
+			return err
+		}
+		rec = loaded
+
+		var exists bool
+		if err := tx.Get(&exists, `
+			SELECT EXISTS(SELECT 1 FROM accounting_reconciliation_matches
+			              WHERE id = $1 AND reconciliation_id = $2 AND tenant_id = $3)
+		`, matchID, id, tenant); err != nil {
+			return err
+		}
+		if !exists {
+			return newNotFoundError("Match not found")
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE accounting_transaction_lines SET reconciliation_id = NULL, cleared_at = NULL
+			WHERE tenant_id = $1
+			  AND id IN (SELECT transaction_line_id FROM accounting_reconciliation_match_lines WHERE match_id = $2)
+		`, tenant, matchID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			UPDATE accounting_bank_statement_lines SET status = 'unmatched', match_id = NULL
+			WHERE tenant_id = $1 AND match_id = $2
+		`, tenant, matchID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM accounting_reconciliation_match_lines WHERE tenant_id = $1 AND match_id = $2",
+			tenant, matchID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM accounting_reconciliation_matches WHERE id = $1 AND tenant_id = $2",
+			matchID, tenant); err != nil {
+			return err
+		}
+
+		return refreshReconciliationBalances(tx, tenant, rec)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to unmatch reconciliation lines")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"reconciliation": rec,
+		"message":        "Match removed successfully",
+	})
+}
+
+// CompleteReconciliation finishes and locks a reconciliation when the
+// difference is within the reconciliation_tolerance setting. Statement lines
+// that were not matched are released to the next reconciliation.
+func (h *AccountingHandler) CompleteReconciliation(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid reconciliation ID")
+		return
+	}
+
+	var rec *Reconciliation
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadOpenReconciliation(tx, tenant, id)
+		if err != nil {
+			return err
+		}
+		rec = loaded
+
+		if err := refreshReconciliationBalances(tx, tenant, rec); err != nil {
+			return err
+		}
+
+		settings, err := loadSettings(tx, tenant)
+		if err != nil {
+			return err
+		}
+		if rec.Difference.Abs() > settings.ReconciliationTolerance {
+			return newBadRequestError("Difference of %s exceeds the reconciliation tolerance of %s",
+				rec.Difference, settings.ReconciliationTolerance)
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE accounting_bank_statement_lines SET reconciliation_id = NULL
+			WHERE tenant_id = $1 AND reconciliation_id = $2 AND status = 'unmatched'
+		`, tenant, id); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(`
+			UPDATE accounting_reconciliations
+			SET status = 'completed', completed_by = $1, completed_at = CURRENT_TIMESTAMP
+			WHERE id = $2 AND tenant_id = $3
+			RETURNING status, completed_by, completed_at
+		`, currentUserID(r), id, tenant).Scan(&rec.Status, &rec.CompletedBy, &rec.CompletedAt)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to complete reconciliation")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"reconciliation": rec,
+		"message":        "Reconciliation completed successfully",
+	})
+}
+
+// GetReconciliationReport summarizes a reconciliation: statement and book
+// balances, the cleared lines and the outstanding items that explain the
+// difference between bank and book
+func (h *AccountingHandler) GetReconciliationReport(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid reconciliation ID")
+		return
+	}
+
+	tenant := tenantID(r)
+	rec, err := loadReconciliation(h.db, tenant, id, false)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate reconciliation report")
+		return
+	}
+
+	var cleared []LedgerLine
+	if err := h.db.Select(&cleared, ledgerLineSelect+`
+		WHERE atl.tenant_id = $1 AND atl.reconciliation_id = $2
+		ORDER BY at.transaction_date, atl.id
+	`, tenant, id); err != nil {
+		h.writeError(w, err, "Failed to generate reconciliation report")
+		return
+	}
+
+	var outstanding []LedgerLine
+	if err := h.db.Select(&outstanding, ledgerLineSelect+`
+		WHERE atl.tenant_id = $1 AND atl.account_id = $2 AND atl.reconciliation_id IS NULL
+		  AND at.status IN ('posted', 'void') AND at.transaction_date <= $3
+		ORDER BY at.transaction_date, atl.id
+	`, tenant, rec.AccountID, rec.StatementDate); err != nil {
+		h.writeError(w, err, "Failed to generate reconciliation report")
+		return
+	}
+
+	var deposits, payments []LedgerLine
+	for _, line := range outstanding {
+		if line.Amount >= 0 {
+			deposits = append(deposits, line)
+		} else {
+			payments = append(payments, line)
+		}
+	}
+
+	var unmatched []BankStatementLine
+	if err := h.db.Select(&unmatched, `
+		SELECT * FROM accounting_bank_statement_lines
+		WHERE tenant_id = $1 AND account_id = $2 AND status = 'unmatched' AND transaction_date <= $3
+		ORDER BY transaction_date, id
+	`, tenant, rec.AccountID, rec.StatementDate); err != nil {
+		h.writeError(w, err, "Failed to generate reconciliation report")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"reconciliation":        rec,
+		"cleared_lines":         cleared,
+		"cleared_amount":        sumLedgerLines(cleared),
+		"deposits_in_transit":   deposits,
+		"outstanding_payments":  payments,
+		"unmatched_statement":   unmatched,
+		"total_in_transit":      sumLedgerLines(deposits),
+		"total_outstanding":     sumLedgerLines(payments),
+		"adjusted_bank_balance": rec.StatementBalance + sumLedgerLines(deposits) + sumLedgerLines(payments),
+		"book_balance":          rec.BookBalance,
+	})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurring templates run on dates only, so a schedule produces a sequence of
+// days. Interval schedules repeat every interval days, weeks, months,
+// quarters or years counted from the start date; a monthly schedule started
+// on the 31st runs on the last day of shorter months. Cron schedules use the
+// five standard fields, of which only day of month, month and day of week
+// are significant. Day of month also accepts L for the last day of the month.
+
+// maxScheduleSearchDays bounds the search for the next date of a cron
+// schedule that may never match, such as 30 February
+const maxScheduleSearchDays = 5 * 366
+
+// cronSchedule is a parsed cron expression reduced to its date fields
+type cronSchedule struct {
+	daysOfMonth [32]bool
+	lastDay     bool
+	months      [13]bool
+	daysOfWeek  [7]bool
+	domWildcard bool
+	dowWildcard bool
+}
+
+// parseCronField parses one cron field into the set of values it allows
+func parseCronField(field string, min, max int) ([]bool, bool, error) {
+	allowed := make([]bool, max+1)
+	wildcard := field == "*"
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return nil, false, newBadRequestError("Invalid cron step in %q", field)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, false, newBadRequestError("Invalid cron value in %q", field)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, false, newBadRequestError("Invalid cron range in %q", field)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, false, newBadRequestError("Cron value out of range in %q", field)
+		}
+
+		for v := lo; v <= hi; v += step {
+			allowed[v] = true
+		}
+	}
+
+	return allowed, wildcard, nil
+}
+
+// parseCronSchedule parses a five-field cron expression
+func parseCronSchedule(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, newBadRequestError("Cron expression must have 5 fields: minute hour day-of-month month day-of-week")
+	}
+	if _, _, err := parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if _, _, err := parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+
+	schedule := &cronSchedule{}
+
+	var domParts []string
+	for _, part := range strings.Split(fields[2], ",") {
+		if strings.EqualFold(part, "L") {
+			schedule.lastDay = true
+		} else {
+			domParts = append(domParts, part)
+		}
+	}
+	if len(domParts) > 0 {
+		days, wildcard, err := parseCronField(strings.Join(domParts, ","), 1, 31)
+		if err != nil {
+			return nil, err
+		}
+		copy(schedule.daysOfMonth[:], days)
+		schedule.domWildcard = wildcard
+	}
+
+	months, _, err := parseCronField(fields[3], 1, 12)
+	if err != nil {
+		return nil, err
+	}
+	copy(schedule.months[:], months)
+
+	days, wildcard, err := parseCronField(fields[4], 0, 7)
+	if err != nil {
+		return nil, err
+	}
+	for d, ok := range days {
+		if ok {
+			schedule.daysOfWeek[d%7] = true
+		}
+	}
+	schedule.dowWildcard = wildcard
+
+	return schedule, nil
+}
+
+// matches reports whether the schedule runs on date. As in cron, when both
+// day of month and day of week are restricted either one may match.
+func (c *cronSchedule) matches(date time.Time) bool {
+	if !c.months[date.Month()] {
+		return false
+	}
+
+	domMatch := c.daysOfMonth[date.Day()] || (c.lastDay && date.AddDate(0, 0, 1).Day() == 1)
+	dowMatch := c.daysOfWeek[date.Weekday()]
+
+	switch {
+	case c.domWildcard && c.dowWildcard:
+		return true
+	case c.domWildcard:
+		return dowMatch
+	case c.dowWildcard:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+// addMonthsClamped adds months to date, keeping the day of month but
+// clamping it to the last day of shorter months
+func addMonthsClamped(date time.Time, months int) time.Time {
+	first := time.Date(date.Year(), date.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	day := date.Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// validateSchedule checks the schedule of a template
+func validateSchedule(template *RecurringTemplate) error {
+	switch template.Frequency {
+	case "daily", "weekly", "monthly", "quarterly", "yearly":
+		if template.IntervalCount < 1 {
+			return newBadRequestError("interval_count must be at least 1")
+		}
+	case "cron":
+		if template.CronExpression == nil {
+			return newBadRequestError("cron_expression is required for cron schedules")
+		}
+		if _, err := parseCronSchedule(*template.CronExpression); err != nil {
+			return err
+		}
+	default:
+		return newBadRequestError("frequency must be one of daily, weekly, monthly, quarterly, yearly, cron")
+	}
+	return nil
+}
+
+// nextRunDate returns the first date on or after from on which the template
+// is scheduled, and false when there is none before its end date
+func nextRunDate(template *RecurringTemplate, from time.Time) (time.Time, bool, error) {
+	start := dateOnly(template.StartDate)
+	from = dateOnly(from)
+	if from.Before(start) {
+		from = start
+	}
+
+	var next time.Time
+	found := false
+	switch template.Frequency {
+	case "cron":
+		schedule, err := parseCronSchedule(*template.CronExpression)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		for i := 0; i < maxScheduleSearchDays; i++ {
+			date := from.AddDate(0, 0, i)
+			if schedule.matches(date) {
+				next, found = date, true
+				break
+			}
+		}
+	case "daily", "weekly":
+		days := template.IntervalCount
+		if template.Frequency == "weekly" {
+			days *= 7
+		}
+		elapsed := int(from.Sub(start).Hours() / 24)
+		steps := (elapsed + days - 1) / days
+		next, found = start.AddDate(0, 0, steps*days), true
+	default:
+		months := template.IntervalCount
+		switch template.Frequency {
+		case "quarterly":
+			months *= 3
+		case "yearly":
+			months *= 12
+		}
+		elapsed := (from.Year()-start.Year())*12 + int(from.Month()-start.Month())
+		steps := elapsed / months
+		if steps < 0 {
+			steps = 0
+		}
+		for {
+			next = addMonthsClamped(start, steps*months)
+			if !next.Before(from) {
+				break
+			}
+			steps++
+		}
+		found = true
+	}
+
+	if !found || (template.EndDate != nil && next.After(dateOnly(*template.EndDate))) {
+		return time.Time{}, false, nil
+	}
+	return next, true, nil
+}
+
+// dateOnly drops the time of day
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func mustDate(t *testing.T, value string) time.Time {
+	t.Helper()
+	date, err := parseDate(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return date
+}
+
+func TestNextRunDate(t *testing.T) {
+	tests := []struct {
+		name      string
+		frequency string
+		interval  int
+		cron      string
+		start     string
+		end       string
+		from      string
+		want      string
+	}{
+		{name: "daily on the day", frequency: "daily", interval: 1, start: "2025-01-01", from: "2025-01-10", want: "2025-01-10"},
+		{name: "every third day", frequency: "daily", interval: 3, start: "2025-01-01", from: "2025-01-05", want: "2025-01-07"},
+		{name: "before the start date", frequency: "daily", interval: 1, start: "2025-01-01", from: "2024-12-01", want: "2025-01-01"},
+		{name: "every other week", frequency: "weekly", interval: 2, start: "2025-01-01", from: "2025-01-02", want: "2025-01-15"},
+		{name: "monthly on the start day", frequency: "monthly", interval: 1, start: "2025-01-15", from: "2025-03-15", want: "2025-03-15"},
+		{name: "month end clamps to February", frequency: "monthly", interval: 1, start: "2025-01-31", from: "2025-02-01", want: "2025-02-28"},
+		{name: "month end clamps to leap day", frequency: "monthly", interval: 1, start: "2024-01-31", from: "2024-02-01", want: "2024-02-29"},
+		{name: "month end returns to the 31st", frequency: "monthly", interval: 1, start: "2025-01-31", from: "2025-03-01", want: "2025-03-31"},
+		{name: "every two months", frequency: "monthly", interval: 2, start: "2025-01-10", from: "2025-02-01", want: "2025-03-10"},
+		{name: "quarterly", frequency: "quarterly", interval: 1, start: "2025-01-15", from: "2025-01-16", want: "2025-04-15"},
+		{name: "yearly from a leap day", frequency: "yearly", interval: 1, start: "2024-02-29", from: "2025-01-01", want: "2025-02-28"},
+		{name: "on the end date", frequency: "monthly", interval: 1, start: "2025-01-01", end: "2025-03-01", from: "2025-02-15", want: "2025-03-01"},
+		{name: "after the end date", frequency: "monthly", interval: 1, start: "2025-01-01", end: "2025-03-15", from: "2025-03-16"},
+		{name: "cron last day of month", frequency: "cron", cron: "0 0 L * *", start: "2025-01-01", from: "2025-02-10", want: "2025-02-28"},
+		{name: "cron day of week", frequency: "cron", cron: "0 9 * * 1", start: "2025-01-01", from: "2025-01-01", want: "2025-01-06"},
+		{name: "cron Sunday as 7", frequency: "cron", cron: "0 0 * * 7", start: "2025-01-01", from: "2025-01-01", want: "2025-01-05"},
+		{name: "cron list of days", frequency: "cron", cron: "0 0 1,15 * *", start: "2025-01-01", from: "2025-01-02", want: "2025-01-15"},
+		{name: "cron step", frequency: "cron", cron: "0 0 */10 * *", start: "2025-01-01", from: "2025-01-02", want: "2025-01-11"},
+		{name: "cron day of month or week", frequency: "cron", cron: "0 0 1 * 5", start: "2025-01-01", from: "2025-01-02", want: "2025-01-03"},
+		{name: "cron month range", frequency: "cron", cron: "0 0 1 6-8 *", start: "2025-01-01", from: "2025-01-02", want: "2025-06-01"},
+		{name: "cron that never runs", frequency: "cron", cron: "0 0 30 2 *", start: "2025-01-01", from: "2025-01-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &RecurringTemplate{
+				Frequency:     tt.frequency,
+				IntervalCount: tt.interval,
+				StartDate:     mustDate(t, tt.start),
+			}
+			if tt.cron != "" {
+				template.CronExpression = &tt.cron
+			}
+			if tt.end != "" {
+				end := mustDate(t, tt.end)
+				template.EndDate = &end
+			}
+
+			next, found, err := nextRunDate(template, mustDate(t, tt.from))
+			if err != nil {
+				t.Fatalf("nextRunDate() error = %v", err)
+			}
+			if tt.want == "" {
+				if found {
+					t.Errorf("nextRunDate() = %s, want no next run", next.Format("2006-01-02"))
+				}
+				return
+			}
+			if !found {
+				t.Fatalf("nextRunDate() found no next run, want %s", tt.want)
+			}
+			if got := next.Format("2006-01-02"); got != tt.want {
+				t.Errorf("nextRunDate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextRunDateIgnoresTimeOfDay(t *testing.T) {
+	template := &RecurringTemplate{Frequency: "daily", IntervalCount: 1, StartDate: mustDate(t, "2025-01-01")}
+	next, found, err := nextRunDate(template, time.Date(2025, 1, 5, 23, 59, 0, 0, time.UTC))
+	if err != nil || !found {
+		t.Fatalf("nextRunDate() = %v, %v, %v", next, found, err)
+	}
+	if got := next.Format("2006-01-02"); got != "2025-01-05" {
+		t.Errorf("nextRunDate() = %s, want 2025-01-05", got)
+	}
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"0 0 * *",
+		"60 0 * * *",
+		"0 24 * * *",
+		"0 0 0 * *",
+		"0 0 32 * *",
+		"0 0 * 13 *",
+		"0 0 * * 8",
+		"0 0 */0 * *",
+		"0 0 5-1 * *",
+		"0 0 x * *",
+	} {
+		if _, err := parseCronSchedule(expr); err == nil {
+			t.Errorf("parseCronSchedule(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	cron := "0 0 1 * *"
+	invalidCron := "0 0 1 *"
+	tests := []struct {
+		name     string
+		template RecurringTemplate
+		wantErr  bool
+	}{
+		{name: "monthly", template: RecurringTemplate{Frequency: "monthly", IntervalCount: 1}},
+		{name: "zero interval", template: RecurringTemplate{Frequency: "weekly"}, wantErr: true},
+		{name: "cron", template: RecurringTemplate{Frequency: "cron", CronExpression: &cron}},
+		{name: "cron without expression", template: RecurringTemplate{Frequency: "cron"}, wantErr: true},
+		{name: "invalid cron", template: RecurringTemplate{Frequency: "cron", CronExpression: &invalidCron}, wantErr: true},
+		{name: "unknown frequency", template: RecurringTemplate{Frequency: "hourly", IntervalCount: 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSchedule(&tt.template)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,724 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// Recurring templates generate the same journal entry on every date of a
+// schedule, e.g. monthly rent or depreciation. Line amounts are fixed or
+// taken from the template's variables, which can be updated between runs or
+// overridden for a single manual run. Descriptions may contain {date},
+// {month} and {year}, replaced with the run date.
+//
+// Every generated entry is recorded as a run with the idempotency key
+// recurring-<template>-<date>, inserted in the same database transaction as
+// the entry, so a template never generates two entries for the same date
+// however often the runner is restarted or however many instances run it.
+
+// templateVariables are the named amounts of a recurring template, stored as
+// a JSON object
+type templateVariables map[string]Money
+
+// Scan implements sql.Scanner
+func (v *templateVariables) Scan(src interface{}) error {
+	var data []byte
+	switch value := src.(type) {
+	case nil:
+		*v = templateVariables{}
+		return nil
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	default:
+		return fmt.Errorf("cannot scan %T into template variables", src)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Value implements driver.Valuer
+func (v templateVariables) Value() (driver.Value, error) {
+	if v == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// recurringRunKey is the idempotency key of a template run
+func recurringRunKey(templateID int, runDate time.Time) string {
+	return fmt.Sprintf("recurring-%d-%s", templateID, runDate.Format("2006-01-02"))
+}
+
+// expandTemplateText replaces the date placeholders of a template text
+func expandTemplateText(text string, runDate time.Time) string {
+	return strings.NewReplacer(
+		"{date}", runDate.Format("2006-01-02"),
+		"{month}", runDate.Format("January 2006"),
+		"{year}", runDate.Format("2006"),
+	).Replace(text)
+}
+
+// templateEntryLines resolves the line amounts of a template with variables.
+// Lines that come to zero are left out.
+func templateEntryLines(template *RecurringTemplate, variables templateVariables) ([]JournalEntryLine, error) {
+	resolve := func(fixed Money, variable *string) (Money, error) {
+		if variable == nil {
+			return fixed, nil
+		}
+		value, ok := variables[*variable]
+		if !ok {
+			return 0, newBadRequestError("Template variable %s has no value", *variable)
+		}
+		if value < 0 {
+			return 0, newBadRequestError("Template variable %s cannot be negative", *variable)
+		}
+		return value, nil
+	}
+
+	var lines []JournalEntryLine
+	var totalDebits, totalCredits Money
+	for _, templateLine := range template.Lines {
+		debit, err := resolve(templateLine.DebitAmount, templateLine.DebitVariable)
+		if err != nil {
+			return nil, err
+		}
+		credit, err := resolve(templateLine.CreditAmount, templateLine.CreditVariable)
+		if err != nil {
+			return nil, err
+		}
+		if debit == 0 && credit == 0 {
+			continue
+		}
+
+		line := JournalEntryLine{AccountID: templateLine.AccountID, DebitAmount: debit, CreditAmount: credit}
+		if templateLine.Description != nil {
+			description := *templateLine.Description
+			line.Description = &description
+		}
+		lines = append(lines, line)
+		totalDebits += debit
+		totalCredits += credit
+	}
+
+	if len(lines) == 0 {
+		return nil, newBadRequestError("Template %s has no line with an amount", template.Name)
+	}
+	if totalDebits != totalCredits {
+		return nil, newBadRequestError("Template %s is not balanced: debits %s, credits %s",
+			template.Name, totalDebits, totalCredits)
+	}
+	return lines, nil
+}
+
+// loadRecurringTemplate fetches a template with its lines, appending lock
+// to the template query, e.g. FOR UPDATE
+func loadRecurringTemplate(q sqlx.Queryer, tenant string, id int, lock string) (*RecurringTemplate, error) {
+	query := "SELECT * FROM accounting_recurring_templates WHERE id = $1 AND tenant_id = $2 " + lock
+
+	var template RecurringTemplate
+	if err := sqlx.Get(q, &template, query, id, tenant); err != nil {
+		return nil, err
+	}
+	template.Lines = []RecurringTemplateLine{}
+	if err := sqlx.Select(q, &template.Lines, `
+		SELECT * FROM accounting_recurring_template_lines WHERE template_id = $1 AND tenant_id = $2 ORDER BY id
+	`, id, tenant); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// generateRecurringEntry creates the journal entry of a template for
+// runDate. It reports false without creating anything when the template
+// already ran for that date.
+func (h *AccountingHandler) generateRecurringEntry(tx *sqlx.Tx, tenant string, template *RecurringTemplate, runDate time.Time, overrides templateVariables) (*JournalEntry, bool, error) {
+	var runID int
+	err := tx.Get(&runID, `
+		INSERT INTO accounting_recurring_template_runs (tenant_id, template_id, run_date, idempotency_key)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (idempotency_key) DO NOTHING
+		RETURNING id
+	`, tenant, template.ID, runDate, recurringRunKey(template.ID, runDate))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	variables := templateVariables{}
+	for name, value := range template.Variables {
+		variables[name] = value
+	}
+	for name, value := range overrides {
+		variables[name] = value
+	}
+
+	lines, err := templateEntryLines(template, variables)
+	if err != nil {
+		return nil, false, err
+	}
+
+	description := template.Name
+	if template.Description != nil {
+		description = *template.Description
+	}
+	description = expandTemplateText(description, runDate)
+
+	entry := &JournalEntry{
+		EntryDate:   runDate,
+		Description: &description,
+		Reference:   template.Reference,
+		CreatedBy:   template.CreatedBy,
+		Lines:       lines,
+	}
+
+	if err := checkPostingPeriod(tx, tenant, runDate); err != nil {
+		return nil, false, err
+	}
+	if template.EntryStatus == "draft" {
+		entry.Status = "draft"
+		err = h.insertJournalEntry(tx, tenant, entry)
+	} else {
+		err = h.submitJournalEntry(tx, tenant, entry)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE accounting_recurring_template_runs SET journal_entry_id = $1 WHERE id = $2 AND tenant_id = $3
+	`, entry.ID, runID, tenant); err != nil {
+		return nil, false, err
+	}
+	return entry, true, nil
+}
+
+// advanceRecurringTemplate moves the next run date of a template past
+// runDate when runDate was its next scheduled run
+func advanceRecurringTemplate(tx *sqlx.Tx, tenant string, template *RecurringTemplate, runDate time.Time) error {
+	if template.NextRunDate == nil || !dateOnly(*template.NextRunDate).Equal(dateOnly(runDate)) {
+		return nil
+	}
+
+	var nextRun *time.Time
+	next, ok, err := nextRunDate(template, runDate.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	if ok {
+		nextRun = &next
+	}
+
+	template.NextRunDate = nextRun
+	template.LastRunDate = &runDate
+	_, err = tx.Exec(`
+		UPDATE accounting_recurring_templates SET next_run_date = $1, last_run_date = $2 WHERE id = $3 AND tenant_id = $4
+	`, nextRun, runDate, template.ID, tenant)
+	return err
+}
+
+// runRecurringTemplates generates the entries of every active template that
+// is due, catching up on dates missed while the module was not running. A
+// template whose entry cannot be generated, e.g. because the date is in a
+// closed period, is retried on the next run. Each tenant is scanned, and
+// each template run, in transactions of its own tenant.
+func (h *AccountingHandler) runRecurringTemplates(now time.Time) error {
+	today := dateOnly(now)
+	tenants, err := h.tenantIDs()
+	if err != nil {
+		return err
+	}
+
+	type dueTemplate struct {
+		ID       int    `db:"id"`
+		TenantID string `db:"tenant_id"`
+	}
+	var due []dueTemplate
+	for _, tenant := range tenants {
+		var templates []dueTemplate
+		if err := h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+			return tx.Select(&templates, `
+				SELECT id, tenant_id FROM accounting_recurring_templates
+				WHERE tenant_id = $1 AND is_active = true AND next_run_date <= $2
+				ORDER BY next_run_date, id
+			`, tenant, today)
+		}); err != nil {
+			return err
+		}
+		due = append(due, templates...)
+	}
+
+	for _, template := range due {
+		id, tenant := template.ID, template.TenantID
+		for {
+			var runDate time.Time
+			var entry *JournalEntry
+			err := h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+				// A template locked by another runner is skipped
+				template, err := loadRecurringTemplate(tx, tenant, id, "FOR UPDATE SKIP LOCKED")
+				if err != nil {
+					return err
+				}
+				if !template.IsActive || template.NextRunDate == nil || template.NextRunDate.After(today) {
+					return sql.ErrNoRows
+				}
+				runDate = dateOnly(*template.NextRunDate)
+
+				entry, _, err = h.generateRecurringEntry(tx, tenant, template, runDate, nil)
+				if err != nil {
+					return err
+				}
+				return advanceRecurringTemplate(tx, tenant, template, runDate)
+			})
+			if errors.Is(err, sql.ErrNoRows) {
+				break
+			}
+			if err != nil {
+				h.logger.Warn("Failed to run recurring template",
+					zap.Int("template_id", id),
+					zap.Time("run_date", runDate),
+					zap.Error(err))
+				break
+			}
+			if entry != nil {
+				h.logger.Info("Recurring journal entry generated",
+					zap.Int("template_id", id),
+					zap.String("entry_number", entry.EntryNumber))
+			}
+		}
+	}
+
+	return nil
+}
+
+// recurringTemplateRequest is the body of a template create or update
+type recurringTemplateRequest struct {
+	Name           string                  `json:"name"`
+	Description    *string                 `json:"description"`
+	Reference      *string                 `json:"reference"`
+	Frequency      string                  `json:"frequency"`
+	IntervalCount  int                     `json:"interval_count"`
+	CronExpression *string                 `json:"cron_expression"`
+	StartDate      string                  `json:"start_date"`
+	EndDate        string                  `json:"end_date"`
+	EntryStatus    string                  `json:"entry_status"`
+	Variables      templateVariables       `json:"variables"`
+	IsActive       *bool                   `json:"is_active"`
+	Lines          []RecurringTemplateLine `json:"lines"`
+}
+
+// decodeRecurringTemplate reads a template from a request body
+func decodeRecurringTemplate(r *http.Request) (*RecurringTemplate, error) {
+	var req recurringTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, newBadRequestError("Invalid request body")
+	}
+
+	template := &RecurringTemplate{
+		Name:           req.Name,
+		Description:    req.Description,
+		Reference:      req.Reference,
+		Frequency:      req.Frequency,
+		IntervalCount:  req.IntervalCount,
+		CronExpression: req.CronExpression,
+		EntryStatus:    req.EntryStatus,
+		Variables:      req.Variables,
+		IsActive:       req.IsActive == nil || *req.IsActive,
+		Lines:          req.Lines,
+	}
+
+	startDate, err := parseDate(req.StartDate)
+	if err != nil {
+		return nil, newBadRequestError("A valid start_date is required")
+	}
+	template.StartDate = startDate
+	if req.EndDate != "" {
+		endDate, err := parseDate(req.EndDate)
+		if err != nil {
+			return nil, newBadRequestError("Invalid end_date")
+		}
+		template.EndDate = &endDate
+	}
+
+	return template, nil
+}
+
+// validateRecurringTemplate checks a template and fills in its defaults
+func validateRecurringTemplate(q sqlx.Queryer, tenant string, template *RecurringTemplate) error {
+	if template.IntervalCount == 0 {
+		template.IntervalCount = 1
+	}
+	if template.EntryStatus == "" {
+		template.EntryStatus = "posted"
+	}
+	if template.Variables == nil {
+		template.Variables = templateVariables{}
+	}
+
+	if strings.TrimSpace(template.Name) == "" {
+		return newBadRequestError("name is required")
+	}
+	if len(template.Lines) == 0 {
+		return newBadRequestError("At least one line is required")
+	}
+	if template.EndDate != nil && template.EndDate.Before(template.StartDate) {
+		return newBadRequestError("end_date cannot be before start_date")
+	}
+	if err := sdk.ValidateEnum("entry_status", template.EntryStatus, []string{"draft", "posted"}); err != nil {
+		return newBadRequestError("%s", err.Error())
+	}
+	if err := validateSchedule(template); err != nil {
+		return err
+	}
+
+	for i, line := range template.Lines {
+		hasDebit := line.DebitAmount != 0 || line.DebitVariable != nil
+		hasCredit := line.CreditAmount != 0 || line.CreditVariable != nil
+		if hasDebit == hasCredit {
+			return newBadRequestError("Line %d must have either a debit or a credit amount or variable", i+1)
+		}
+		if line.DebitAmount < 0 || line.CreditAmount < 0 {
+			return newBadRequestError("Line %d amounts cannot be negative", i+1)
+		}
+
+		var exists bool
+		if err := sqlx.Get(q, &exists, `
+			SELECT EXISTS(SELECT 1 FROM chart_of_accounts WHERE id = $1 AND tenant_id = $2 AND is_active = true)
+		`, line.AccountID, tenant); err != nil {
+			return err
+		}
+		if !exists {
+			return newBadRequestError("Account %d not found", line.AccountID)
+		}
+	}
+
+	_, err := templateEntryLines(template, template.Variables)
+	return err
+}
+
+// saveRecurringTemplateLines replaces the lines of a template
+func saveRecurringTemplateLines(tx *sqlx.Tx, tenant string, template *RecurringTemplate) error {
+	if _, err := tx.Exec("DELETE FROM accounting_recurring_template_lines WHERE template_id = $1 AND tenant_id = $2",
+		template.ID, tenant); err != nil {
+		return err
+	}
+	for i := range template.Lines {
+		line := &template.Lines[i]
+		if err := tx.Get(line, `
+			INSERT INTO accounting_recurring_template_lines
+			(tenant_id, template_id, account_id, debit_amount, credit_amount, debit_variable, credit_variable,
+			 description)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING *
+		`, tenant, template.ID, line.AccountID, line.DebitAmount, line.CreditAmount, line.DebitVariable,
+			line.CreditVariable, line.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scheduleRecurringTemplate sets the next run date of a template from its
+// start date or, once it has run, from the day after its last run
+func scheduleRecurringTemplate(template *RecurringTemplate) error {
+	from := template.StartDate
+	if template.LastRunDate != nil && template.LastRunDate.AddDate(0, 0, 1).After(from) {
+		from = template.LastRunDate.AddDate(0, 0, 1)
+	}
+
+	next, ok, err := nextRunDate(template, from)
+	if err != nil {
+		return err
+	}
+	template.NextRunDate = nil
+	if ok {
+		template.NextRunDate = &next
+	}
+	return nil
+}
+
+// GetRecurringTemplates retrieves recurring templates with their lines
+func (h *AccountingHandler) GetRecurringTemplates(w http.ResponseWriter, r *http.Request) {
+	isActive := r.URL.Query().Get("is_active")
+
+	tenant := tenantID(r)
+	qb := sdk.NewQueryBuilder("SELECT id FROM accounting_recurring_templates WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenant)
+	qb.AddOptionalCondition("is_active = $%d", isActive)
+
+	query, args := qb.Build()
+	query += " ORDER BY name, id"
+
+	var ids []int
+	if err := h.db.Select(&ids, query, args...); err != nil {
+		h.logger.Error("Failed to fetch recurring templates", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch recurring templates")
+		return
+	}
+
+	templates := []RecurringTemplate{}
+	for _, id := range ids {
+		template, err := loadRecurringTemplate(h.db, tenant, id, "")
+		if err != nil {
+			h.logger.Error("Failed to fetch recurring templates", zap.Error(err))
+			sdk.WriteInternalError(w, "Failed to fetch recurring templates")
+			return
+		}
+		templates = append(templates, *template)
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+// GetRecurringTemplate retrieves a single recurring template
+func (h *AccountingHandler) GetRecurringTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid recurring template ID")
+		return
+	}
+
+	template, err := loadRecurringTemplate(h.db, tenantID(r), id, "")
+	if err != nil {
+		h.writeError(w, err, "Failed to fetch recurring template")
+		return
+	}
+
+	sdk.WriteSuccess(w, template)
+}
+
+// CreateRecurringTemplate creates a recurring template
+func (h *AccountingHandler) CreateRecurringTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := decodeRecurringTemplate(r)
+	if err != nil {
+		h.writeError(w, err, "Failed to create recurring template")
+		return
+	}
+	template.CreatedBy = currentUserID(r)
+
+	tenant := tenantID(r)
+	if err := validateRecurringTemplate(h.db, tenant, template); err != nil {
+		h.writeError(w, err, "Failed to create recurring template")
+		return
+	}
+	if err := scheduleRecurringTemplate(template); err != nil {
+		h.writeError(w, err, "Failed to create recurring template")
+		return
+	}
+
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		lines := template.Lines
+		if err := tx.Get(template, `
+			INSERT INTO accounting_recurring_templates
+			(tenant_id, name, description, reference, frequency, interval_count, cron_expression, start_date,
+			 end_date, next_run_date, entry_status, variables, is_active, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			RETURNING *
+		`, tenant, template.Name, template.Description, template.Reference, template.Frequency, template.IntervalCount,
+			template.CronExpression, template.StartDate, template.EndDate, template.NextRunDate,
+			template.EntryStatus, template.Variables, template.IsActive, template.CreatedBy); err != nil {
+			return err
+		}
+		template.Lines = lines
+		return saveRecurringTemplateLines(tx, tenant, template)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to create recurring template")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"template": template,
+		"message":  "Recurring template created successfully",
+	})
+}
+
+// UpdateRecurringTemplate replaces a recurring template. Its next run is
+// rescheduled from the day after its last run, so past runs are not repeated.
+func (h *AccountingHandler) UpdateRecurringTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid recurring template ID")
+		return
+	}
+
+	template, err := decodeRecurringTemplate(r)
+	if err != nil {
+		h.writeError(w, err, "Failed to update recurring template")
+		return
+	}
+
+	tenant := tenantID(r)
+	if err := validateRecurringTemplate(h.db, tenant, template); err != nil {
+		h.writeError(w, err, "Failed to update recurring template")
+		return
+	}
+
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		var lastRunDate *time.Time
+		if err := tx.Get(&lastRunDate, `
+			SELECT last_run_date FROM accounting_recurring_templates WHERE id = $1 AND tenant_id = $2 FOR UPDATE
+		`, id, tenant); err != nil {
+			return err
+		}
+		template.LastRunDate = lastRunDate
+		if err := scheduleRecurringTemplate(template); err != nil {
+			return err
+		}
+
+		lines := template.Lines
+		if err := tx.Get(template, `
+			UPDATE accounting_recurring_templates
+			SET name = $1, description = $2, reference = $3, frequency = $4, interval_count = $5,
+			    cron_expression = $6, start_date = $7, end_date = $8, next_run_date = $9, entry_status = $10,
+			    variables = $11, is_active = $12
+			WHERE id = $13 AND tenant_id = $14
+			RETURNING *
+		`, template.Name, template.Description, template.Reference, template.Frequency, template.IntervalCount,
+			template.CronExpression, template.StartDate, template.EndDate, template.NextRunDate,
+			template.EntryStatus, template.Variables, template.IsActive, id, tenant); err != nil {
+			return err
+		}
+		template.Lines = lines
+		return saveRecurringTemplateLines(tx, tenant, template)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to update recurring template")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"template": template,
+		"message":  "Recurring template updated successfully",
+	})
+}
+
+// DeleteRecurringTemplate deletes a recurring template. Entries it
+// generated are kept.
+func (h *AccountingHandler) DeleteRecurringTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid recurring template ID")
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM accounting_recurring_templates WHERE id = $1 AND tenant_id = $2", id, tenantID(r))
+	if err != nil {
+		h.logger.Error("Failed to delete recurring template", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to delete recurring template")
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		sdk.WriteNotFound(w, "Recurring template not found")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Recurring template deleted successfully"})
+}
+
+// GetRecurringTemplateRuns retrieves the runs of a recurring template
+func (h *AccountingHandler) GetRecurringTemplateRuns(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid recurring template ID")
+		return
+	}
+
+	runs := []RecurringTemplateRun{}
+	if err := h.db.Select(&runs, `
+		SELECT * FROM accounting_recurring_template_runs WHERE template_id = $1 AND tenant_id = $2 ORDER BY run_date DESC
+	`, id, tenantID(r)); err != nil {
+		h.logger.Error("Failed to fetch recurring template runs", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch recurring template runs")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"runs":  runs,
+		"count": len(runs),
+	})
+}
+
+// RunRecurringTemplate generates the entry of a template for a date now,
+// by default its next scheduled run. Variables given in the request override
+// the template's for this run only.
+func (h *AccountingHandler) RunRecurringTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid recurring template ID")
+		return
+	}
+
+	var req struct {
+		RunDate   string            `json:"run_date"`
+		Variables templateVariables `json:"variables"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sdk.WriteBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	var runDate time.Time
+	if req.RunDate != "" {
+		if runDate, err = parseDate(req.RunDate); err != nil {
+			sdk.WriteBadRequest(w, "Invalid run date")
+			return
+		}
+	}
+
+	tenant := tenantID(r)
+	var entry *JournalEntry
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadRecurringTemplate(tx, tenant, id, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+
+		if runDate.IsZero() {
+			if loaded.NextRunDate == nil {
+				return newBadRequestError("Template %s has no scheduled run left", loaded.Name)
+			}
+			runDate = dateOnly(*loaded.NextRunDate)
+		}
+
+		generated, created, err := h.generateRecurringEntry(tx, tenant, loaded, runDate, req.Variables)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return newBadRequestError("Template %s already ran for %s", loaded.Name, runDate.Format("2006-01-02"))
+		}
+		entry = generated
+		return advanceRecurringTemplate(tx, tenant, loaded, runDate)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to run recurring template")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"entry":   entry,
+		"message": "Recurring journal entry generated successfully",
+	})
+}
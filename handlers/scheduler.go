@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// schedulerInterval is how often the background jobs run
+const schedulerInterval = time.Hour
+
+// scheduledJob is a background job run by the scheduler. Jobs must be safe
+// to run again after a failure or on several module instances at once.
+type scheduledJob struct {
+	name string
+	run  func(now time.Time) error
+}
+
+// scheduler runs the module's background jobs once at start and then every
+// interval until it is stopped
+type scheduler struct {
+	logger   *zap.Logger
+	interval time.Duration
+	jobs     []scheduledJob
+	stop     chan struct{}
+	done     sync.WaitGroup
+}
+
+func newScheduler(logger *zap.Logger, interval time.Duration, jobs ...scheduledJob) *scheduler {
+	return &scheduler{
+		logger:   logger,
+		interval: interval,
+		jobs:     jobs,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start runs the jobs in the background
+func (s *scheduler) Start() {
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		s.runJobs()
+		for {
+			select {
+			case <-ticker.C:
+				s.runJobs()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler and waits for a running job to finish
+func (s *scheduler) Stop() {
+	close(s.stop)
+	s.done.Wait()
+}
+
+func (s *scheduler) runJobs() {
+	now := time.Now()
+	for _, job := range s.jobs {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		if err := job.run(now); err != nil {
+			s.logger.Error("Scheduled job failed", zap.String("job", job.name), zap.Error(err))
+		}
+	}
+}
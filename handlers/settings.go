@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// Settings holds the module settings declared in module.yml
+type Settings struct {
+	DefaultCurrency                  string       `json:"default_currency"`
+	FiscalYearStart                  int          `json:"fiscal_year_start"`
+	EnableMultiCurrency              bool         `json:"enable_multi_currency"`
+	DefaultTaxRate                   float64      `json:"default_tax_rate"`
+	EnableTaxCodes                   bool         `json:"enable_tax_codes"`
+	RequireApprovalForTransactions   bool         `json:"require_approval_for_transactions"`
+	TransactionApprovalAmount        Money        `json:"transaction_approval_amount"`
+	EnableBudgetTracking             bool         `json:"enable_budget_tracking"`
+	BudgetAlertThreshold             float64      `json:"budget_alert_threshold"`
+	EnableJournalEntries             bool         `json:"enable_journal_entries"`
+	RequireApprovalForJournalEntries bool         `json:"require_approval_for_journal_entries"`
+	EnableReconciliations            bool         `json:"enable_reconciliations"`
+	ReconciliationTolerance          Money        `json:"reconciliation_tolerance"`
+	EnableFiscalPeriods              bool         `json:"enable_fiscal_periods"`
+	FiscalPeriodLength               string       `json:"fiscal_period_length"`
+	RetainedEarningsAccountCode      string       `json:"retained_earnings_account_code"`
+	UnrealizedFXGainAccountCode      string       `json:"unrealized_fx_gain_account_code"`
+	UnrealizedFXLossAccountCode      string       `json:"unrealized_fx_loss_account_code"`
+	RoundingMode                     RoundingMode `json:"rounding_mode"`
+}
+
+// defaultSettings returns the defaults declared in module.yml
+func defaultSettings() Settings {
+	return Settings{
+		DefaultCurrency:                  "USD",
+		FiscalYearStart:                  1,
+		EnableMultiCurrency:              false,
+		DefaultTaxRate:                   0,
+		EnableTaxCodes:                   true,
+		RequireApprovalForTransactions:   false,
+		TransactionApprovalAmount:        1000 * moneyUnit,
+		EnableBudgetTracking:             true,
+		BudgetAlertThreshold:             90,
+		EnableJournalEntries:             true,
+		RequireApprovalForJournalEntries: true,
+		EnableReconciliations:            true,
+		ReconciliationTolerance:          moneyUnit / 100,
+		EnableFiscalPeriods:              true,
+		FiscalPeriodLength:               "monthly",
+		RetainedEarningsAccountCode:      "",
+		UnrealizedFXGainAccountCode:      "",
+		UnrealizedFXLossAccountCode:      "",
+		RoundingMode:                     RoundHalfUp,
+	}
+}
+
+// loadSettings reads the stored settings of a tenant, falling back to the
+// module.yml default for any key that has not been set or cannot be parsed
+func loadSettings(q sqlx.Queryer, tenant string) (Settings, error) {
+	settings := defaultSettings()
+
+	var rows []struct {
+		Key   string `db:"setting_key"`
+		Value string `db:"setting_value"`
+	}
+	if err := sqlx.Select(q, &rows, "SELECT setting_key, setting_value FROM accounting_settings WHERE tenant_id = $1", tenant); err != nil {
+		return settings, err
+	}
+
+	for _, row := range rows {
+		switch row.Key {
+		case "default_currency":
+			settings.DefaultCurrency = row.Value
+		case "fiscal_year_start":
+			settings.FiscalYearStart = parseIntSetting(row.Value, settings.FiscalYearStart)
+		case "enable_multi_currency":
+			settings.EnableMultiCurrency = parseBoolSetting(row.Value, settings.EnableMultiCurrency)
+		case "default_tax_rate":
+			settings.DefaultTaxRate = parseFloatSetting(row.Value, settings.DefaultTaxRate)
+		case "enable_tax_codes":
+			settings.EnableTaxCodes = parseBoolSetting(row.Value, settings.EnableTaxCodes)
+		case "require_approval_for_transactions":
+			settings.RequireApprovalForTransactions = parseBoolSetting(row.Value, settings.RequireApprovalForTransactions)
+		case "transaction_approval_amount":
+			settings.TransactionApprovalAmount = parseMoneySetting(row.Value, settings.TransactionApprovalAmount)
+		case "enable_budget_tracking":
+			settings.EnableBudgetTracking = parseBoolSetting(row.Value, settings.EnableBudgetTracking)
+		case "budget_alert_threshold":
+			settings.BudgetAlertThreshold = parseFloatSetting(row.Value, settings.BudgetAlertThreshold)
+		case "enable_journal_entries":
+			settings.EnableJournalEntries = parseBoolSetting(row.Value, settings.EnableJournalEntries)
+		case "require_approval_for_journal_entries":
+			settings.RequireApprovalForJournalEntries = parseBoolSetting(row.Value, settings.RequireApprovalForJournalEntries)
+		case "enable_reconciliations":
+			settings.EnableReconciliations = parseBoolSetting(row.Value, settings.EnableReconciliations)
+		case "reconciliation_tolerance":
+			settings.ReconciliationTolerance = parseMoneySetting(row.Value, settings.ReconciliationTolerance)
+		case "enable_fiscal_periods":
+			settings.EnableFiscalPeriods = parseBoolSetting(row.Value, settings.EnableFiscalPeriods)
+		case "fiscal_period_length":
+			settings.FiscalPeriodLength = row.Value
+		case "retained_earnings_account_code":
+			settings.RetainedEarningsAccountCode = row.Value
+		case "unrealized_fx_gain_account_code":
+			settings.UnrealizedFXGainAccountCode = row.Value
+		case "unrealized_fx_loss_account_code":
+			settings.UnrealizedFXLossAccountCode = row.Value
+		case "rounding_mode":
+			for _, mode := range roundingModes {
+				if row.Value == mode {
+					settings.RoundingMode = RoundingMode(mode)
+				}
+			}
+		}
+	}
+
+	if settings.FiscalYearStart < 1 || settings.FiscalYearStart > 12 {
+		settings.FiscalYearStart = 1
+	}
+
+	return settings, nil
+}
+
+func parseIntSetting(value string, fallback int) int {
+	if v, err := strconv.Atoi(value); err == nil {
+		return v
+	}
+	return fallback
+}
+
+func parseFloatSetting(value string, fallback float64) float64 {
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		return v
+	}
+	return fallback
+}
+
+func parseMoneySetting(value string, fallback Money) Money {
+	if v, err := ParseMoney(value); err == nil {
+		return v
+	}
+	return fallback
+}
+
+func parseBoolSetting(value string, fallback bool) bool {
+	if v, err := strconv.ParseBool(value); err == nil {
+		return v
+	}
+	return fallback
+}
+
+// GetSettings retrieves the effective module settings
+func (h *AccountingHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := loadSettings(h.db, tenantID(r))
+	if err != nil {
+		h.logger.Error("Failed to fetch settings", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch settings")
+		return
+	}
+
+	sdk.WriteSuccess(w, settings)
+}
+
+// UpdateSettings stores one or more module settings
+func (h *AccountingHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	known := map[string]bool{}
+	defaults, _ := json.Marshal(defaultSettings())
+	var keys map[string]interface{}
+	json.Unmarshal(defaults, &keys)
+	for key := range keys {
+		known[key] = true
+	}
+
+	for key := range req {
+		if !known[key] {
+			sdk.WriteBadRequest(w, fmt.Sprintf("Unknown setting: %s", key))
+			return
+		}
+	}
+
+	tenant := tenantID(r)
+	err := h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		for key, value := range req {
+			result, err := tx.Exec(
+				"UPDATE accounting_settings SET setting_value = $1 WHERE tenant_id = $2 AND setting_key = $3",
+				fmt.Sprint(value), tenant, key)
+			if err != nil {
+				return err
+			}
+			if updated, _ := result.RowsAffected(); updated > 0 {
+				continue
+			}
+
+			_, err = tx.Exec(
+				"INSERT INTO accounting_settings (tenant_id, setting_key, setting_value) VALUES ($1, $2, $3)",
+				tenant, key, fmt.Sprint(value))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to update settings")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Settings updated successfully"})
+}
@@ -0,0 +1,615 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// Tax codes carry effective-dated rates and map to a payable account for tax
+// collected on sales and a receivable account for recoverable tax on
+// purchases. A compound tax is charged on the amount plus the taxes applied
+// before it; an inclusive tax code means amounts already contain the tax.
+
+const taxCodeSelect = `
+	SELECT tc.*, tr.rate, tr.effective_from
+	FROM accounting_tax_codes tc
+	LEFT JOIN LATERAL (
+		SELECT rate, effective_from FROM accounting_tax_rates
+		WHERE tax_code_id = tc.id
+		  AND tenant_id = tc.tenant_id
+		  AND effective_from <= CURRENT_DATE
+		  AND (effective_to IS NULL OR effective_to >= CURRENT_DATE)
+		ORDER BY effective_from DESC
+		LIMIT 1
+	) tr ON true
+`
+
+// resolveTaxCodes loads active tax codes by code, with the rate effective on
+// date, in the order given. The order matters for compound taxes.
+func resolveTaxCodes(q sqlx.Queryer, tenant string, codes []string, date time.Time) ([]TaxCode, error) {
+	settings, err := loadSettings(q, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.EnableTaxCodes {
+		return nil, newBadRequestError("Tax codes are disabled")
+	}
+
+	var resolved []TaxCode
+	for _, code := range codes {
+		var taxCode TaxCode
+		err := sqlx.Get(q, &taxCode, `
+			SELECT tc.*, tr.rate, tr.effective_from
+			FROM accounting_tax_codes tc
+			JOIN accounting_tax_rates tr ON tr.tax_code_id = tc.id AND tr.tenant_id = tc.tenant_id
+			WHERE tc.code = $1
+			  AND tc.tenant_id = $3
+			  AND tc.is_active = true
+			  AND tr.effective_from <= $2
+			  AND (tr.effective_to IS NULL OR tr.effective_to >= $2)
+			ORDER BY tr.effective_from DESC
+			LIMIT 1
+		`, code, date.Format("2006-01-02"), tenant)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, newBadRequestError("Tax code %s has no rate effective on %s", code, date.Format("2006-01-02"))
+		}
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, taxCode)
+	}
+
+	return resolved, nil
+}
+
+// calculateTax applies tax codes to an amount in currency. direction is
+// "sales" or "purchase" and selects the tax account each component posts to.
+// Each component is rounded to the currency's minor units with mode. When the
+// codes are inclusive the amount is treated as gross and the net is backed
+// out; any rounding difference is absorbed by the net so that net plus tax
+// always equals the original amount.
+func calculateTax(amount Money, codes []TaxCode, direction, currency string, mode RoundingMode) (*TaxCalculation, error) {
+	result := &TaxCalculation{NetAmount: amount, GrossAmount: amount}
+	if len(codes) == 0 {
+		return result, nil
+	}
+
+	inclusive := codes[0].IsInclusive
+	for _, code := range codes {
+		if code.IsInclusive != inclusive {
+			return nil, newBadRequestError("Inclusive and exclusive tax codes cannot be combined on one amount")
+		}
+		if code.Type != "sales_purchase" && code.Type != direction {
+			return nil, newBadRequestError("Tax code %s cannot be used for %s", code.Code, direction)
+		}
+	}
+
+	places := minorUnits(currency)
+	base := amount
+	if inclusive {
+		// Gross is net times one plus the combined rate, with compound
+		// codes applied to the running total
+		running := big.NewRat(1, 1)
+		combined := big.NewRat(1, 1)
+		for _, code := range codes {
+			taxable := big.NewRat(1, 1)
+			if code.IsCompound {
+				taxable.Set(running)
+			}
+			rate := decimalRat(*code.Rate)
+			tax := new(big.Rat).Mul(taxable, rate.Quo(rate, big.NewRat(100, 1)))
+			running.Add(running, tax)
+			combined.Add(combined, tax)
+		}
+		base = amount.MulRat(combined.Inv(combined), places, mode)
+	}
+
+	running := base
+	var taxes []TaxComponent
+	var totalTax Money
+	for _, code := range codes {
+		taxable := base
+		if code.IsCompound {
+			taxable = running
+		}
+		tax := taxable.Percent(*code.Rate, places, mode)
+		running += tax
+		totalTax += tax
+
+		account := code.PayableAccountID
+		if direction == "purchase" {
+			account = code.ReceivableAccountID
+		}
+		if account == nil {
+			return nil, newBadRequestError("Tax code %s has no %s tax account", code.Code, direction)
+		}
+
+		taxes = append(taxes, TaxComponent{
+			TaxCodeID:     code.ID,
+			Code:          code.Code,
+			Rate:          *code.Rate,
+			IsCompound:    code.IsCompound,
+			TaxableAmount: taxable,
+			TaxAmount:     tax,
+			AccountID:     *account,
+		})
+	}
+
+	result.TaxAmount = totalTax
+	if inclusive {
+		result.NetAmount = amount - result.TaxAmount
+	} else {
+		result.NetAmount = base
+	}
+	result.GrossAmount = result.NetAmount + result.TaxAmount
+	result.Taxes = taxes
+
+	return result, nil
+}
+
+// applyTransactionTaxes expands transaction lines that carry tax codes. A
+// debit line is treated as a purchase and a credit line as a sale; the tax is
+// added as separate lines on the same side, posted to the tax code accounts.
+// With inclusive codes the original line is reduced to its net amount.
+func applyTransactionTaxes(q sqlx.Queryer, tenant string, lines []AccountingTransactionLine, date time.Time, currency string) ([]AccountingTransactionLine, error) {
+	settings, err := loadSettings(q, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	var expanded []AccountingTransactionLine
+	for _, line := range lines {
+		if len(line.TaxCodes) == 0 {
+			expanded = append(expanded, line)
+			continue
+		}
+		if line.DebitAmount != 0 && line.CreditAmount != 0 {
+			return nil, newBadRequestError("A line with tax codes must be either a debit or a credit")
+		}
+
+		codes, err := resolveTaxCodes(q, tenant, line.TaxCodes, date)
+		if err != nil {
+			return nil, err
+		}
+
+		direction, amount := "purchase", line.DebitAmount
+		if line.CreditAmount != 0 {
+			direction, amount = "sales", line.CreditAmount
+		}
+
+		calc, err := calculateTax(amount, codes, direction, currency, settings.RoundingMode)
+		if err != nil {
+			return nil, err
+		}
+
+		net := line
+		net.TaxCodes = nil
+		if direction == "purchase" {
+			net.DebitAmount = calc.NetAmount
+		} else {
+			net.CreditAmount = calc.NetAmount
+		}
+		expanded = append(expanded, net)
+
+		for _, tax := range calc.Taxes {
+			description := fmt.Sprintf("%s %.4g%%", tax.Code, tax.Rate)
+			taxLine := AccountingTransactionLine{AccountID: tax.AccountID, Description: &description}
+			if direction == "purchase" {
+				taxLine.DebitAmount = tax.TaxAmount
+			} else {
+				taxLine.CreditAmount = tax.TaxAmount
+			}
+			expanded = append(expanded, taxLine)
+		}
+	}
+
+	return expanded, nil
+}
+
+// validateTaxAccount checks that a tax account exists with the expected type
+func validateTaxAccount(q sqlx.Queryer, tenant string, accountID *int, accountType string) error {
+	if accountID == nil {
+		return nil
+	}
+
+	var actual string
+	err := sqlx.Get(q, &actual,
+		"SELECT account_type FROM chart_of_accounts WHERE id = $1 AND tenant_id = $2 AND is_active = true",
+		*accountID, tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newBadRequestError("Account %d not found", *accountID)
+	}
+	if err != nil {
+		return err
+	}
+	if actual != accountType {
+		return newBadRequestError("Account %d must be a %s account", *accountID, accountType)
+	}
+
+	return nil
+}
+
+// GetTaxCodes retrieves tax codes with their current rate
+func (h *AccountingHandler) GetTaxCodes(w http.ResponseWriter, r *http.Request) {
+	taxType := r.URL.Query().Get("type")
+	isActive := r.URL.Query().Get("is_active")
+
+	qb := sdk.NewQueryBuilder(taxCodeSelect + " WHERE 1=1")
+	qb.AddCondition("tc.tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition("tc.type = $%d", taxType)
+	if isActive != "" {
+		qb.AddCondition("tc.is_active = $%d", isActive == "true")
+	}
+
+	query, args := qb.Build()
+	query += " ORDER BY tc.code"
+
+	var taxCodes []TaxCode
+	if err := h.db.Select(&taxCodes, query, args...); err != nil {
+		h.logger.Error("Failed to fetch tax codes", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch tax codes")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"tax_codes": taxCodes,
+		"count":     len(taxCodes),
+	})
+}
+
+// GetTaxCode retrieves a tax code with its rate history
+func (h *AccountingHandler) GetTaxCode(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid tax code ID")
+		return
+	}
+
+	tenant := tenantID(r)
+	var taxCode TaxCode
+	if err := h.db.Get(&taxCode, taxCodeSelect+" WHERE tc.id = $1 AND tc.tenant_id = $2", id, tenant); err != nil {
+		h.writeError(w, err, "Failed to fetch tax code")
+		return
+	}
+
+	if err := h.db.Select(&taxCode.Rates, `
+		SELECT * FROM accounting_tax_rates WHERE tax_code_id = $1 AND tenant_id = $2 ORDER BY effective_from DESC
+	`, id, tenant); err != nil {
+		h.writeError(w, err, "Failed to fetch tax code")
+		return
+	}
+
+	sdk.WriteSuccess(w, taxCode)
+}
+
+// CreateTaxCode creates a tax code with its initial rate
+func (h *AccountingHandler) CreateTaxCode(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code                string  `json:"code"`
+		Name                string  `json:"name"`
+		Type                string  `json:"type"`
+		Description         *string `json:"description"`
+		IsCompound          bool    `json:"is_compound"`
+		IsInclusive         bool    `json:"is_inclusive"`
+		PayableAccountID    *int    `json:"payable_account_id"`
+		ReceivableAccountID *int    `json:"receivable_account_id"`
+		Rate                float64 `json:"rate"`
+		EffectiveFrom       string  `json:"effective_from"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if err := sdk.ValidateRequired(map[string]interface{}{
+		"code":           req.Code,
+		"name":           req.Name,
+		"type":           req.Type,
+		"effective_from": req.EffectiveFrom,
+	}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	if err := sdk.ValidateEnum("type", req.Type, []string{"sales", "purchase", "sales_purchase"}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	if req.Rate < 0 {
+		sdk.WriteBadRequest(w, "Rate cannot be negative")
+		return
+	}
+
+	effectiveFrom, err := parseDate(req.EffectiveFrom)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid effective_from date")
+		return
+	}
+
+	tenant := tenantID(r)
+	var id int
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		if err := validateTaxAccount(tx, tenant, req.PayableAccountID, "liability"); err != nil {
+			return err
+		}
+		if err := validateTaxAccount(tx, tenant, req.ReceivableAccountID, "asset"); err != nil {
+			return err
+		}
+
+		err := tx.QueryRow(`
+			INSERT INTO accounting_tax_codes
+			(tenant_id, code, name, type, description, is_compound, is_inclusive, payable_account_id,
+			 receivable_account_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id
+		`, tenant, req.Code, req.Name, req.Type, req.Description, req.IsCompound, req.IsInclusive,
+			req.PayableAccountID, req.ReceivableAccountID).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO accounting_tax_rates (tenant_id, tax_code_id, rate, effective_from) VALUES ($1, $2, $3, $4)
+		`, tenant, id, req.Rate, effectiveFrom)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to create tax code")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"id":      id,
+		"message": "Tax code created successfully",
+	})
+}
+
+// UpdateTaxCode updates the attributes of a tax code. Rates are changed
+// through AddTaxRate so that historical rates are kept.
+func (h *AccountingHandler) UpdateTaxCode(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid tax code ID")
+		return
+	}
+
+	var req struct {
+		Name                *string `json:"name"`
+		Description         *string `json:"description"`
+		IsCompound          *bool   `json:"is_compound"`
+		IsInclusive         *bool   `json:"is_inclusive"`
+		PayableAccountID    *int    `json:"payable_account_id"`
+		ReceivableAccountID *int    `json:"receivable_account_id"`
+		IsActive            *bool   `json:"is_active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		if err := validateTaxAccount(tx, tenant, req.PayableAccountID, "liability"); err != nil {
+			return err
+		}
+		if err := validateTaxAccount(tx, tenant, req.ReceivableAccountID, "asset"); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`
+			UPDATE accounting_tax_codes
+			SET name = COALESCE($1, name),
+			    description = COALESCE($2, description),
+			    is_compound = COALESCE($3, is_compound),
+			    is_inclusive = COALESCE($4, is_inclusive),
+			    payable_account_id = COALESCE($5, payable_account_id),
+			    receivable_account_id = COALESCE($6, receivable_account_id),
+			    is_active = COALESCE($7, is_active)
+			WHERE id = $8 AND tenant_id = $9
+		`, req.Name, req.Description, req.IsCompound, req.IsInclusive,
+			req.PayableAccountID, req.ReceivableAccountID, req.IsActive, id, tenant)
+		if err != nil {
+			return err
+		}
+		if updated, _ := result.RowsAffected(); updated == 0 {
+			return newNotFoundError("Tax code not found")
+		}
+		return nil
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to update tax code")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Tax code updated successfully"})
+}
+
+// DeleteTaxCode deactivates a tax code. Codes are never removed because
+// posted documents refer to them.
+func (h *AccountingHandler) DeleteTaxCode(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid tax code ID")
+		return
+	}
+
+	if _, err := h.db.Exec("UPDATE accounting_tax_codes SET is_active = false WHERE id = $1 AND tenant_id = $2",
+		id, tenantID(r)); err != nil {
+		h.logger.Error("Failed to delete tax code", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to delete tax code")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Tax code deleted successfully"})
+}
+
+// AddTaxRate schedules a new rate for a tax code. The rate that was in
+// effect is ended the day before the new one starts.
+func (h *AccountingHandler) AddTaxRate(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid tax code ID")
+		return
+	}
+
+	var req struct {
+		Rate          float64 `json:"rate"`
+		EffectiveFrom string  `json:"effective_from"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+	if req.Rate < 0 {
+		sdk.WriteBadRequest(w, "Rate cannot be negative")
+		return
+	}
+
+	effectiveFrom, err := parseDate(req.EffectiveFrom)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid effective_from date")
+		return
+	}
+
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		var latest time.Time
+		err := tx.Get(&latest, `
+			SELECT MAX(effective_from) FROM accounting_tax_rates WHERE tax_code_id = $1 AND tenant_id = $2
+		`, id, tenant)
+		if err != nil {
+			return newNotFoundError("Tax code not found")
+		}
+		if !effectiveFrom.After(latest) {
+			return newBadRequestError("A new rate must start after %s", latest.Format("2006-01-02"))
+		}
+
+		_, err = tx.Exec(`
+			UPDATE accounting_tax_rates SET effective_to = $1
+			WHERE tax_code_id = $2 AND tenant_id = $3 AND effective_to IS NULL
+		`, effectiveFrom.AddDate(0, 0, -1), id, tenant)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO accounting_tax_rates (tenant_id, tax_code_id, rate, effective_from) VALUES ($1, $2, $3, $4)
+		`, tenant, id, req.Rate, effectiveFrom)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to add tax rate")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{"message": "Tax rate added successfully"})
+}
+
+// CalculateTax applies tax codes to one or more amounts and returns the net,
+// tax and gross amounts along with the tax lines to post
+func (h *AccountingHandler) CalculateTax(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Date      string `json:"date"`
+		Direction string `json:"direction"`
+		Currency  string `json:"currency"`
+		Lines     []struct {
+			Amount   Money    `json:"amount"`
+			TaxCodes []string `json:"tax_codes"`
+		} `json:"lines"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Direction == "" {
+		req.Direction = "sales"
+	}
+	if err := sdk.ValidateEnum("direction", req.Direction, []string{"sales", "purchase"}); err != nil {
+		sdk.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	date := time.Now()
+	if req.Date != "" {
+		parsed, err := parseDate(req.Date)
+		if err != nil {
+			sdk.WriteBadRequest(w, "Invalid date")
+			return
+		}
+		date = parsed
+	}
+
+	tenant := tenantID(r)
+	settings, err := loadSettings(h.db, tenant)
+	if err != nil {
+		h.writeError(w, err, "Failed to calculate tax")
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = settings.DefaultCurrency
+	}
+
+	var results []TaxCalculation
+	var total TaxCalculation
+	taxByAccount := map[int]Money{}
+	var accounts []int
+
+	for _, line := range req.Lines {
+		codes, err := resolveTaxCodes(h.db, tenant, line.TaxCodes, date)
+		if err != nil {
+			h.writeError(w, err, "Failed to calculate tax")
+			return
+		}
+
+		calc, err := calculateTax(line.Amount, codes, req.Direction, req.Currency, settings.RoundingMode)
+		if err != nil {
+			h.writeError(w, err, "Failed to calculate tax")
+			return
+		}
+
+		results = append(results, *calc)
+		total.NetAmount += calc.NetAmount
+		total.TaxAmount += calc.TaxAmount
+		total.GrossAmount += calc.GrossAmount
+		for _, tax := range calc.Taxes {
+			if _, ok := taxByAccount[tax.AccountID]; !ok {
+				accounts = append(accounts, tax.AccountID)
+			}
+			taxByAccount[tax.AccountID] += tax.TaxAmount
+		}
+	}
+
+	var taxLines []AccountingTransactionLine
+	for _, accountID := range accounts {
+		line := AccountingTransactionLine{AccountID: accountID}
+		if req.Direction == "sales" {
+			line.CreditAmount = taxByAccount[accountID]
+		} else {
+			line.DebitAmount = taxByAccount[accountID]
+		}
+		taxLines = append(taxLines, line)
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"lines":        results,
+		"net_amount":   total.NetAmount,
+		"tax_amount":   total.TaxAmount,
+		"gross_amount": total.GrossAmount,
+		"tax_lines":    taxLines,
+	})
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"testing"
+)
+
+// testTaxCode builds a tax code posting sales tax to account 2100 and
+// purchase tax to account 1400
+func testTaxCode(id int, code string, rate float64, compound, inclusive bool) TaxCode {
+	payable, receivable := 2100, 1400
+	return TaxCode{
+		ID:                  id,
+		Code:                code,
+		Type:                "sales_purchase",
+		IsCompound:          compound,
+		IsInclusive:         inclusive,
+		PayableAccountID:    &payable,
+		ReceivableAccountID: &receivable,
+		Rate:                &rate,
+	}
+}
+
+func TestCalculateTax(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    string
+		currency  string
+		codes     []TaxCode
+		wantNet   string
+		wantTaxes []string
+	}{
+		{
+			name:      "no codes",
+			amount:    "100.00",
+			currency:  "USD",
+			wantNet:   "100.00",
+			wantTaxes: nil,
+		},
+		{
+			name:      "exclusive single rate",
+			amount:    "100.00",
+			currency:  "USD",
+			codes:     []TaxCode{testTaxCode(1, "GST", 5, false, false)},
+			wantNet:   "100.00",
+			wantTaxes: []string{"5.00"},
+		},
+		{
+			name:     "exclusive compound rate applies to the running total",
+			amount:   "100.00",
+			currency: "CAD",
+			codes: []TaxCode{
+				testTaxCode(1, "GST", 5, false, false),
+				testTaxCode(2, "QST", 9.975, true, false),
+			},
+			wantNet:   "100.00",
+			wantTaxes: []string{"5.00", "10.47"},
+		},
+		{
+			name:     "exclusive non-compound rates share the base",
+			amount:   "100.00",
+			currency: "CAD",
+			codes: []TaxCode{
+				testTaxCode(1, "GST", 5, false, false),
+				testTaxCode(2, "PST", 7, false, false),
+			},
+			wantNet:   "100.00",
+			wantTaxes: []string{"5.00", "7.00"},
+		},
+		{
+			name:      "inclusive single rate",
+			amount:    "113.00",
+			currency:  "CAD",
+			codes:     []TaxCode{testTaxCode(1, "HST", 13, false, true)},
+			wantNet:   "100.00",
+			wantTaxes: []string{"13.00"},
+		},
+		{
+			name:     "inclusive compound rate",
+			amount:   "115.47",
+			currency: "CAD",
+			codes: []TaxCode{
+				testTaxCode(1, "GST", 5, false, true),
+				testTaxCode(2, "QST", 9.975, true, true),
+			},
+			wantNet:   "100.00",
+			wantTaxes: []string{"5.00", "10.47"},
+		},
+		{
+			name:      "inclusive rounding is absorbed by the net",
+			amount:    "10.00",
+			currency:  "USD",
+			codes:     []TaxCode{testTaxCode(1, "VAT", 7, false, true)},
+			wantNet:   "9.35",
+			wantTaxes: []string{"0.65"},
+		},
+		{
+			name:      "zero decimal currency",
+			amount:    "1234",
+			currency:  "JPY",
+			codes:     []TaxCode{testTaxCode(1, "CT", 8, false, false)},
+			wantNet:   "1234.00",
+			wantTaxes: []string{"99.00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := ParseMoney(tt.amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			calc, err := calculateTax(amount, tt.codes, "sales", tt.currency, RoundHalfUp)
+			if err != nil {
+				t.Fatalf("calculateTax() error = %v", err)
+			}
+
+			if got := calc.NetAmount.String(); got != tt.wantNet {
+				t.Errorf("net = %s, want %s", got, tt.wantNet)
+			}
+			if len(calc.Taxes) != len(tt.wantTaxes) {
+				t.Fatalf("got %d tax components, want %d", len(calc.Taxes), len(tt.wantTaxes))
+			}
+			var total Money
+			for i, tax := range calc.Taxes {
+				if got := tax.TaxAmount.String(); got != tt.wantTaxes[i] {
+					t.Errorf("tax %s = %s, want %s", tax.Code, got, tt.wantTaxes[i])
+				}
+				if tax.AccountID != 2100 {
+					t.Errorf("tax %s posts to account %d, want the payable account 2100", tax.Code, tax.AccountID)
+				}
+				total += tax.TaxAmount
+			}
+			if calc.TaxAmount != total {
+				t.Errorf("tax amount = %s, want the sum of the components %s", calc.TaxAmount, total)
+			}
+			if calc.GrossAmount != calc.NetAmount+calc.TaxAmount {
+				t.Errorf("gross %s is not net %s plus tax %s", calc.GrossAmount, calc.NetAmount, calc.TaxAmount)
+			}
+			if len(tt.codes) > 0 && tt.codes[0].IsInclusive && calc.GrossAmount != amount {
+				t.Errorf("inclusive gross = %s, want the original amount %s", calc.GrossAmount, amount)
+			}
+		})
+	}
+}
+
+func TestCalculateTaxPurchaseAccount(t *testing.T) {
+	codes := []TaxCode{testTaxCode(1, "GST", 5, false, false)}
+	calc, err := calculateTax(1000000, codes, "purchase", "USD", RoundHalfUp)
+	if err != nil {
+		t.Fatalf("calculateTax() error = %v", err)
+	}
+	if calc.Taxes[0].AccountID != 1400 {
+		t.Errorf("purchase tax posts to account %d, want the receivable account 1400", calc.Taxes[0].AccountID)
+	}
+}
+
+func TestCalculateTaxErrors(t *testing.T) {
+	salesOnly := testTaxCode(1, "OUT", 5, false, false)
+	salesOnly.Type = "sales"
+	noAccount := testTaxCode(2, "NOACC", 5, false, false)
+	noAccount.PayableAccountID = nil
+
+	tests := []struct {
+		name      string
+		codes     []TaxCode
+		direction string
+	}{
+		{
+			name:      "inclusive and exclusive combined",
+			codes:     []TaxCode{testTaxCode(1, "A", 5, false, false), testTaxCode(2, "B", 5, false, true)},
+			direction: "sales",
+		},
+		{name: "code of the other direction", codes: []TaxCode{salesOnly}, direction: "purchase"},
+		{name: "code without a tax account", codes: []TaxCode{noAccount}, direction: "sales"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := calculateTax(1000000, tt.codes, tt.direction, "USD", RoundHalfUp); err == nil {
+				t.Error("calculateTax() succeeded, want an error")
+			}
+		})
+	}
+}
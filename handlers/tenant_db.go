@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// The row-level security policies hide every row from a session that is not
+// bound to a tenant. Route handlers therefore query through a connection of
+// the pool bound to the tenant of the request, and background jobs bind the
+// work of each tenant to a transaction of its own.
+
+// database is what the handler queries through: the connection pool, or a
+// connection bound to the tenant of a request
+type database interface {
+	sqlx.Queryer
+	sqlx.Execer
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Select(dest interface{}, query string, args ...interface{}) error
+	Get(dest interface{}, query string, args ...interface{}) error
+	Beginx() (*sqlx.Tx, error)
+}
+
+// tenantConn is a connection taken from the pool with app.tenant_id set to
+// a tenant until it is released
+type tenantConn struct {
+	conn *sqlx.Conn
+	ctx  context.Context
+}
+
+// acquireTenantConn takes a connection from the pool and binds it to tenant
+func acquireTenantConn(ctx context.Context, pool *sqlx.DB, tenant string) (*tenantConn, error) {
+	conn, err := pool.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, false)", tenant); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &tenantConn{conn: conn, ctx: ctx}, nil
+}
+
+// release unbinds the connection and returns it to the pool. A connection
+// that cannot be unbound is discarded so that no other request inherits the
+// tenant.
+func (c *tenantConn) release() error {
+	if _, err := c.conn.ExecContext(context.Background(), "RESET app.tenant_id"); err != nil {
+		c.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		return err
+	}
+	return c.conn.Close()
+}
+
+func (c *tenantConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.conn.QueryContext(c.ctx, query, args...)
+}
+
+func (c *tenantConn) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
+	return c.conn.QueryxContext(c.ctx, query, args...)
+}
+
+func (c *tenantConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.conn.QueryRowContext(c.ctx, query, args...)
+}
+
+func (c *tenantConn) QueryRowx(query string, args ...interface{}) *sqlx.Row {
+	return c.conn.QueryRowxContext(c.ctx, query, args...)
+}
+
+func (c *tenantConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.conn.ExecContext(c.ctx, query, args...)
+}
+
+func (c *tenantConn) Select(dest interface{}, query string, args ...interface{}) error {
+	return c.conn.SelectContext(c.ctx, dest, query, args...)
+}
+
+func (c *tenantConn) Get(dest interface{}, query string, args ...interface{}) error {
+	return c.conn.GetContext(c.ctx, dest, query, args...)
+}
+
+func (c *tenantConn) Beginx() (*sqlx.Tx, error) {
+	return c.conn.BeginTxx(c.ctx, nil)
+}
+
+// tenantIDs lists the tenants background jobs run for
+func (h *AccountingHandler) tenantIDs() ([]string, error) {
+	var tenants []string
+	if err := h.pool.Select(&tenants, "SELECT id FROM tenants ORDER BY id"); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
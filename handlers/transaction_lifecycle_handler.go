@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+)
+
+// A transaction starts as a draft or is submitted straight away. Submitting
+// sends it through its approval chain, if any, and posts it. Posted
+// transactions are immutable, enforced by database triggers: the only way to
+// cancel one is to void it, which posts a reversal linked to it through
+// reversal_of_id and marks it void. Void transactions and their reversals
+// both stay in the ledger, so their effects cancel out in every period.
+
+const transactionSelect = `
+	SELECT id, company_id, transaction_number, transaction_date, reference_type, reference_id, description,
+	       total_amount, currency, exchange_rate, status, created_by, approved_by, approved_at, rejected_by,
+	       rejected_at, rejection_reason, reversal_of_id, voided_by, voided_at, void_reason, created_at, updated_at
+	FROM accounting_transactions
+`
+
+// transactionApprovalChain returns the approval steps a transaction with
+// lines must go through before it is posted
+func transactionApprovalChain(q sqlx.Queryer, tenant string, total Money, lines []AccountingTransactionLine) ([]ApprovalStep, error) {
+	settings, err := loadSettings(q, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	var accountIDs []int
+	for _, line := range lines {
+		accountIDs = append(accountIDs, line.AccountID)
+	}
+	required := settings.RequireApprovalForTransactions && total >= settings.TransactionApprovalAmount
+	return approvalChain(q, tenant, "transaction", total, accountIDs, required)
+}
+
+// loadTransaction fetches a transaction with its lines and locks it
+func loadTransaction(tx *sqlx.Tx, tenant string, id int) (*AccountingTransaction, error) {
+	var txn AccountingTransaction
+	if err := tx.Get(&txn, transactionSelect+" WHERE id = $1 AND tenant_id = $2 FOR UPDATE", id, tenant); err != nil {
+		return nil, err
+	}
+	if err := tx.Select(&txn.Lines, `
+		SELECT id, transaction_id, account_id, debit_amount, credit_amount, currency_debit_amount,
+		       currency_credit_amount, description, created_at
+		FROM accounting_transaction_lines WHERE transaction_id = $1 AND tenant_id = $2 ORDER BY id
+	`, id, tenant); err != nil {
+		return nil, err
+	}
+	return &txn, nil
+}
+
+// PostDraftTransaction submits a draft transaction. It is posted, or held
+// for approval when an approval chain applies to it.
+func (h *AccountingHandler) PostDraftTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid transaction ID")
+		return
+	}
+
+	tenant := tenantID(r)
+	var txn *AccountingTransaction
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadTransaction(tx, tenant, id)
+		if err != nil {
+			return err
+		}
+		txn = loaded
+
+		if txn.Status != "draft" {
+			return newBadRequestError("Only draft transactions can be posted, status is %s", txn.Status)
+		}
+		if err := checkPostingPeriod(tx, tenant, txn.TransactionDate); err != nil {
+			return err
+		}
+
+		steps, err := transactionApprovalChain(tx, tenant, txn.TotalAmount, txn.Lines)
+		if err != nil {
+			return err
+		}
+		if len(steps) > 0 {
+			txn.Status = "pending_approval"
+		} else {
+			txn.Status = "posted"
+		}
+
+		if _, err := tx.Exec("UPDATE accounting_transactions SET status = $1 WHERE id = $2 AND tenant_id = $3",
+			txn.Status, id, tenant); err != nil {
+			return err
+		}
+		if len(steps) > 0 {
+			return createApprovalSteps(tx, tenant, id, steps)
+		}
+		return h.checkBudgets(tx, tenant, txn)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to post transaction")
+		return
+	}
+
+	message := "Transaction posted successfully"
+	if txn.Status == "pending_approval" {
+		message = "Transaction submitted for approval"
+	}
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"transaction": txn,
+		"message":     message,
+	})
+}
+
+// VoidTransaction voids a posted transaction by posting a reversal of it,
+// dated today unless a date is given. Transactions that belong to an invoice,
+// payment, year-end close, journal entry or inter-company transaction are
+// voided or reversed through those instead.
+func (h *AccountingHandler) VoidTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid transaction ID")
+		return
+	}
+
+	var req struct {
+		Date   string `json:"date"`
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sdk.WriteBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	date := time.Now()
+	if req.Date != "" {
+		if date, err = parseDate(req.Date); err != nil {
+			sdk.WriteBadRequest(w, "Invalid date")
+			return
+		}
+	}
+	var reason *string
+	if trimmed := strings.TrimSpace(req.Reason); trimmed != "" {
+		reason = &trimmed
+	}
+
+	userID := currentUserID(r)
+	var original *AccountingTransaction
+	var reversal *AccountingTransaction
+	tenant := tenantID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		loaded, err := loadTransaction(tx, tenant, id)
+		if err != nil {
+			return err
+		}
+		original = loaded
+
+		if original.Status != "posted" {
+			return newBadRequestError("Only posted transactions can be voided, status is %s", original.Status)
+		}
+		if original.ReversalOfID != nil {
+			return newBadRequestError("Transaction %s is a reversal and cannot be voided", original.TransactionNumber)
+		}
+		if original.ReferenceType != nil {
+			switch *original.ReferenceType {
+			case "invoice", "payment", "year_end_close":
+				return newBadRequestError("Transaction %s belongs to a %s and must be voided through it",
+					original.TransactionNumber, strings.ReplaceAll(*original.ReferenceType, "_", " "))
+			case "journal_entry":
+				return newBadRequestError("Transaction %s belongs to a journal entry and must be reversed through it",
+					original.TransactionNumber)
+			case "intercompany":
+				return newBadRequestError("Transaction %s belongs to an inter-company transaction and must be voided through it",
+					original.TransactionNumber)
+			}
+		}
+		if date.Before(original.TransactionDate) {
+			return newBadRequestError("Void date cannot be before the transaction date")
+		}
+
+		description := "Void of " + original.TransactionNumber
+		if reason != nil {
+			description += ": " + *reason
+		}
+		reversal, err = h.reverseTransaction(tx, tenant, id, date, description)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		original.Status = "void"
+		original.VoidedBy = &userID
+		original.VoidedAt = &now
+		original.VoidReason = reason
+		_, err = tx.Exec(`
+			UPDATE accounting_transactions
+			SET status = 'void', voided_by = $1, voided_at = $2, void_reason = $3
+			WHERE id = $4 AND tenant_id = $5
+		`, userID, now, reason, id, tenant)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to void transaction")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"transaction": original,
+		"reversal":    reversal,
+		"message":     "Transaction voided successfully",
+	})
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+)
+
+// GetTrialBalance lists, per account, the balance brought forward, the
+// debits and credits of a period and the closing balance, for the company
+// of the request or for every company. The period is a fiscal_year and
+// optional fiscal_period, or a start_date and end_date. Every posting is
+// balanced, so the debits equal the credits and the balances sum to zero;
+// is_balanced is false when they do not. With rollup=true each account's
+// figures include its descendants and rows are returned in hierarchy order.
+// Accounts without a balance or activity are left out.
+func (h *AccountingHandler) GetTrialBalance(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	tenant := tenantID(r)
+
+	var startDate, endDate time.Time
+	if value := params.Get("fiscal_year"); value != "" {
+		fiscalYear, err := strconv.Atoi(value)
+		if err != nil {
+			sdk.WriteBadRequest(w, "Invalid fiscal_year")
+			return
+		}
+		fiscalPeriod := 0
+		if value := params.Get("fiscal_period"); value != "" {
+			if fiscalPeriod, err = strconv.Atoi(value); err != nil {
+				sdk.WriteBadRequest(w, "Invalid fiscal_period")
+				return
+			}
+		}
+		if startDate, endDate, err = fiscalPeriodBounds(h.db, tenant, fiscalYear, fiscalPeriod); err != nil {
+			h.writeError(w, err, "Failed to generate trial balance")
+			return
+		}
+	} else {
+		var err error
+		if startDate, err = parseDate(params.Get("start_date")); err != nil {
+			sdk.WriteBadRequest(w, "A fiscal_year or a valid start_date and end_date are required")
+			return
+		}
+		if endDate, err = parseDate(params.Get("end_date")); err != nil {
+			sdk.WriteBadRequest(w, "A fiscal_year or a valid start_date and end_date are required")
+			return
+		}
+		if endDate.Before(startDate) {
+			sdk.WriteBadRequest(w, "end_date cannot be before start_date")
+			return
+		}
+	}
+	rollup := params.Get("rollup") == "true"
+
+	settings, err := loadSettings(h.db, tenant)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate trial balance")
+		return
+	}
+	scope, err := requestReportScope(h.db, r)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate trial balance")
+		return
+	}
+	activity, err := loadAccountActivity(h.db, tenant, startDate, endDate, scope, nil)
+	if err != nil {
+		h.writeError(w, err, "Failed to generate trial balance")
+		return
+	}
+
+	var trialBalance struct {
+		StartDate string                `json:"start_date"`
+		EndDate   string                `json:"end_date"`
+		Currency  string                `json:"currency"`
+		Accounts  []TrialBalanceAccount `json:"accounts"`
+		Totals    struct {
+			OpeningBalance Money `json:"opening_balance"`
+			DebitAmount    Money `json:"debit_amount"`
+			CreditAmount   Money `json:"credit_amount"`
+			ClosingBalance Money `json:"closing_balance"`
+		} `json:"totals"`
+		Difference Money `json:"difference"`
+		IsBalanced bool  `json:"is_balanced"`
+	}
+	trialBalance.StartDate = startDate.Format("2006-01-02")
+	trialBalance.EndDate = endDate.Format("2006-01-02")
+	trialBalance.Currency = settings.DefaultCurrency
+	trialBalance.Accounts = []TrialBalanceAccount{}
+
+	// Totals come from each account's own figures, so that rolled up
+	// amounts are not counted twice
+	rows := make(map[int]*TrialBalanceAccount, len(activity))
+	byID := map[int]*accountActivity{}
+	for i := range activity {
+		account := &activity[i]
+		byID[account.AccountID] = account
+		rows[account.AccountID] = &TrialBalanceAccount{
+			AccountID:   account.AccountID,
+			AccountCode: account.AccountCode,
+			AccountName: account.AccountName,
+			AccountType: account.AccountType,
+			ParentID:    account.ParentID,
+		}
+		trialBalance.Totals.OpeningBalance += account.OpeningBalance
+		trialBalance.Totals.DebitAmount += account.Debit
+		trialBalance.Totals.CreditAmount += account.Credit
+	}
+	trialBalance.Totals.ClosingBalance = trialBalance.Totals.OpeningBalance +
+		trialBalance.Totals.DebitAmount - trialBalance.Totals.CreditAmount
+	trialBalance.Difference = trialBalance.Totals.DebitAmount - trialBalance.Totals.CreditAmount
+	trialBalance.IsBalanced = trialBalance.Difference == 0 && trialBalance.Totals.OpeningBalance == 0 &&
+		trialBalance.Totals.ClosingBalance == 0
+
+	// Add each account's own figures to its row and, when rolling up, to
+	// the rows of its ancestors
+	for _, account := range activity {
+		seen := map[int]bool{}
+		for id := account.AccountID; byID[id] != nil && !seen[id]; {
+			seen[id] = true
+			row := rows[id]
+			row.OpeningBalance += account.OpeningBalance
+			row.DebitAmount += account.Debit
+			row.CreditAmount += account.Credit
+			row.ClosingBalance += account.OpeningBalance + account.Debit - account.Credit
+			if !rollup || byID[id].ParentID == nil {
+				break
+			}
+			id = *byID[id].ParentID
+		}
+	}
+
+	include := func(row *TrialBalanceAccount) bool {
+		return row.OpeningBalance != 0 || row.DebitAmount != 0 || row.CreditAmount != 0
+	}
+	if !rollup {
+		for _, account := range activity {
+			if row := rows[account.AccountID]; include(row) {
+				trialBalance.Accounts = append(trialBalance.Accounts, *row)
+			}
+		}
+		sdk.WriteSuccess(w, trialBalance)
+		return
+	}
+
+	children := map[int][]int{}
+	var roots []int
+	for _, account := range activity {
+		if account.ParentID != nil && byID[*account.ParentID] != nil {
+			children[*account.ParentID] = append(children[*account.ParentID], account.AccountID)
+		} else {
+			roots = append(roots, account.AccountID)
+		}
+	}
+
+	var walk func(id, level int)
+	walk = func(id, level int) {
+		row := rows[id]
+		if include(row) {
+			row.Level = level
+			trialBalance.Accounts = append(trialBalance.Accounts, *row)
+		}
+		kids := children[id]
+		sort.Slice(kids, func(i, j int) bool { return byID[kids[i]].AccountCode < byID[kids[j]].AccountCode })
+		for _, child := range kids {
+			walk(child, level+1)
+		}
+	}
+	for _, id := range roots {
+		walk(id, 0)
+	}
+
+	sdk.WriteSuccess(w, trialBalance)
+}
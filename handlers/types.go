@@ -4,80 +4,103 @@ import "time"
 
 // ChartOfAccount represents an account in the chart of accounts
 type ChartOfAccount struct {
-	ID              int       `json:"id" db:"id"`
-	TenantID        *string   `json:"tenant_id,omitempty" db:"tenant_id"`
-	CompanyID       *string   `json:"company_id,omitempty" db:"company_id"`
-	AccountCode     string    `json:"account_code" db:"account_code"`
-	AccountName     string    `json:"account_name" db:"account_name"`
-	AccountType     string    `json:"account_type" db:"account_type"` // asset, liability, equity, revenue, expense
-	ParentID        *int      `json:"parent_id" db:"parent_id"`
-	Description     *string   `json:"description" db:"description"`
-	IsActive        bool      `json:"is_active" db:"is_active"`
-	IsSystemAccount bool      `json:"is_system_account" db:"is_system_account"`
-	CreatedAt       time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+	ID               int       `json:"id" db:"id"`
+	TenantID         *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	CompanyID        *string   `json:"company_id,omitempty" db:"company_id"`
+	AccountCode      string    `json:"account_code" db:"account_code"`
+	AccountName      string    `json:"account_name" db:"account_name"`
+	AccountType      string    `json:"account_type" db:"account_type"` // asset, liability, equity, revenue, expense
+	ParentID         *int      `json:"parent_id" db:"parent_id"`
+	Description      *string   `json:"description" db:"description"`
+	IsActive         bool      `json:"is_active" db:"is_active"`
+	IsSystemAccount  bool      `json:"is_system_account" db:"is_system_account"`
+	IsMonetary       bool      `json:"is_monetary" db:"is_monetary"`               // revalued at period-end exchange rates
+	CashFlowCategory *string   `json:"cash_flow_category" db:"cash_flow_category"` // cash, operating, investing, financing
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // AccountingTransaction represents a financial transaction
 type AccountingTransaction struct {
 	ID                int                         `json:"id" db:"id"`
-	TenantID          string                      `json:"tenant_id" db:"tenant_id"`
+	TenantID          *string                     `json:"tenant_id,omitempty" db:"tenant_id"`
 	CompanyID         *string                     `json:"company_id,omitempty" db:"company_id"`
 	TransactionNumber string                      `json:"transaction_number" db:"transaction_number"`
 	TransactionDate   time.Time                   `json:"transaction_date" db:"transaction_date"`
 	ReferenceType     *string                     `json:"reference_type" db:"reference_type"`
 	ReferenceID       *int                        `json:"reference_id" db:"reference_id"`
 	Description       *string                     `json:"description" db:"description"`
-	TotalAmount       float64                     `json:"total_amount" db:"total_amount"`
+	TotalAmount       Money                       `json:"total_amount" db:"total_amount"`
 	Currency          string                      `json:"currency" db:"currency"`
-	Status            string                      `json:"status" db:"status"`
+	ExchangeRate      float64                     `json:"exchange_rate" db:"exchange_rate"`
+	Status            string                      `json:"status" db:"status"` // draft, pending_approval, posted, rejected, void
 	CreatedBy         int                         `json:"created_by" db:"created_by"`
+	ApprovedBy        *int                        `json:"approved_by" db:"approved_by"`
+	ApprovedAt        *time.Time                  `json:"approved_at" db:"approved_at"`
+	RejectedBy        *int                        `json:"rejected_by" db:"rejected_by"`
+	RejectedAt        *time.Time                  `json:"rejected_at" db:"rejected_at"`
+	RejectionReason   *string                     `json:"rejection_reason" db:"rejection_reason"`
+	ReversalOfID      *int                        `json:"reversal_of_id" db:"reversal_of_id"`
+	VoidedBy          *int                        `json:"voided_by" db:"voided_by"`
+	VoidedAt          *time.Time                  `json:"voided_at" db:"voided_at"`
+	VoidReason        *string                     `json:"void_reason" db:"void_reason"`
 	CreatedAt         time.Time                   `json:"created_at" db:"created_at"`
 	UpdatedAt         time.Time                   `json:"updated_at" db:"updated_at"`
 	Lines             []AccountingTransactionLine `json:"lines,omitempty"`
 }
 
-// AccountingTransactionLine represents a line in a transaction
+// AccountingTransactionLine represents a line in a transaction. Debit and
+// credit amounts are in the functional currency once posted; the currency
+// amounts are the same line in the currency of the transaction.
 type AccountingTransactionLine struct {
-	ID            int             `json:"id" db:"id"`
-	TenantID      string          `json:"tenant_id" db:"tenant_id"`
-	TransactionID int             `json:"transaction_id" db:"transaction_id"`
-	AccountID     int             `json:"account_id" db:"account_id"`
-	DebitAmount   float64         `json:"debit_amount" db:"debit_amount"`
-	CreditAmount  float64         `json:"credit_amount" db:"credit_amount"`
-	Description   *string         `json:"description" db:"description"`
-	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
-	Account       *ChartOfAccount `json:"account,omitempty"`
+	ID                   int             `json:"id" db:"id"`
+	TenantID             *string         `json:"tenant_id,omitempty" db:"tenant_id"`
+	TransactionID        int             `json:"transaction_id" db:"transaction_id"`
+	AccountID            int             `json:"account_id" db:"account_id"`
+	DebitAmount          Money           `json:"debit_amount" db:"debit_amount"`
+	CreditAmount         Money           `json:"credit_amount" db:"credit_amount"`
+	CurrencyDebitAmount  Money           `json:"currency_debit_amount" db:"currency_debit_amount"`
+	CurrencyCreditAmount Money           `json:"currency_credit_amount" db:"currency_credit_amount"`
+	Description          *string         `json:"description" db:"description"`
+	TaxCodes             []string        `json:"tax_codes,omitempty" db:"-"`
+	CreatedAt            time.Time       `json:"created_at" db:"created_at"`
+	Account              *ChartOfAccount `json:"account,omitempty"`
 }
 
 // JournalEntry represents a journal entry
 type JournalEntry struct {
-	ID          int                `json:"id" db:"id"`
-	TenantID    string             `json:"tenant_id" db:"tenant_id"`
-	CompanyID   string             `json:"company_id" db:"company_id"`
-	EntryNumber string             `json:"entry_number" db:"entry_number"`
-	EntryDate   time.Time          `json:"entry_date" db:"entry_date"`
-	Description *string            `json:"description" db:"description"`
-	Reference   *string            `json:"reference" db:"reference"`
-	TotalDebit  float64            `json:"total_debit" db:"total_debit"`
-	TotalCredit float64            `json:"total_credit" db:"total_credit"`
-	Status      string             `json:"status" db:"status"`
-	CreatedBy   int                `json:"created_by" db:"created_by"`
-	ApprovedBy  *int               `json:"approved_by" db:"approved_by"`
-	ApprovedAt  *time.Time         `json:"approved_at" db:"approved_at"`
-	CreatedAt   time.Time          `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time          `json:"updated_at" db:"updated_at"`
-	Lines       []JournalEntryLine `json:"lines,omitempty"`
+	ID              int                `json:"id" db:"id"`
+	TenantID        *string            `json:"tenant_id,omitempty" db:"tenant_id"`
+	CompanyID       *string            `json:"company_id,omitempty" db:"company_id"`
+	EntryNumber     string             `json:"entry_number" db:"entry_number"`
+	EntryDate       time.Time          `json:"entry_date" db:"entry_date"`
+	Description     *string            `json:"description" db:"description"`
+	Reference       *string            `json:"reference" db:"reference"`
+	TotalDebit      Money              `json:"total_debit" db:"total_debit"`
+	TotalCredit     Money              `json:"total_credit" db:"total_credit"`
+	Status          string             `json:"status" db:"status"` // draft, pending_approval, posted, rejected
+	CreatedBy       int                `json:"created_by" db:"created_by"`
+	ApprovedBy      *int               `json:"approved_by" db:"approved_by"`
+	ApprovedAt      *time.Time         `json:"approved_at" db:"approved_at"`
+	RejectedBy      *int               `json:"rejected_by" db:"rejected_by"`
+	RejectedAt      *time.Time         `json:"rejected_at" db:"rejected_at"`
+	RejectionReason *string            `json:"rejection_reason" db:"rejection_reason"`
+	ReversalOfID    *int               `json:"reversal_of_id" db:"reversal_of_id"`
+	AutoReverseOn   *time.Time         `json:"auto_reverse_on" db:"auto_reverse_on"`
+	TransactionID   *int               `json:"transaction_id" db:"transaction_id"` // ledger transaction once posted
+	CreatedAt       time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at" db:"updated_at"`
+	Lines           []JournalEntryLine `json:"lines,omitempty"`
 }
 
 // JournalEntryLine represents a line in a journal entry
 type JournalEntryLine struct {
 	ID             int             `json:"id" db:"id"`
-	TenantID       string          `json:"tenant_id" db:"tenant_id"`
-	JournalEntryID int             `json:"journal_entry_id" db:"entry_id"`
+	TenantID       *string         `json:"tenant_id,omitempty" db:"tenant_id"`
+	JournalEntryID int             `json:"journal_entry_id" db:"journal_entry_id"`
 	AccountID      int             `json:"account_id" db:"account_id"`
-	DebitAmount    float64         `json:"debit_amount" db:"debit_amount"`
-	CreditAmount   float64         `json:"credit_amount" db:"credit_amount"`
+	DebitAmount    Money           `json:"debit_amount" db:"debit_amount"`
+	CreditAmount   Money           `json:"credit_amount" db:"credit_amount"`
 	Description    *string         `json:"description" db:"description"`
 	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
 	Account        *ChartOfAccount `json:"account,omitempty"`
@@ -86,15 +109,19 @@ type JournalEntryLine struct {
 // AccountingBudget represents a budget for an account
 type AccountingBudget struct {
 	ID              int             `json:"id" db:"id"`
-	TenantID        string          `json:"tenant_id" db:"tenant_id"`
-	CompanyID       string          `json:"company_id" db:"company_id"`
+	TenantID        *string         `json:"tenant_id,omitempty" db:"tenant_id"`
+	CompanyID       *string         `json:"company_id,omitempty" db:"company_id"`
 	BudgetName      string          `json:"budget_name" db:"budget_name"`
 	FiscalYear      int             `json:"fiscal_year" db:"fiscal_year"`
-	FiscalPeriod    int             `json:"fiscal_period" db:"fiscal_period"`
+	FiscalPeriod    int             `json:"fiscal_period" db:"fiscal_period"` // 0 for the whole fiscal year
 	AccountID       int             `json:"account_id" db:"account_id"`
-	BudgetAmount    float64         `json:"budget_amount" db:"budget_amount"`
-	ActualAmount    float64         `json:"actual_amount" db:"actual_amount"`
-	VarianceAmount  float64         `json:"variance_amount" db:"variance_amount"`
+	AccountCode     string          `json:"account_code" db:"account_code"`
+	AccountName     string          `json:"account_name" db:"account_name"`
+	AccountType     string          `json:"account_type" db:"account_type"`
+	BudgetAmount    Money           `json:"budget_amount" db:"budget_amount"`
+	IsHardLimit     bool            `json:"is_hard_limit" db:"is_hard_limit"`
+	ActualAmount    Money           `json:"actual_amount" db:"actual_amount"`
+	VarianceAmount  Money           `json:"variance_amount" db:"variance_amount"`
 	VariancePercent float64         `json:"variance_percent" db:"variance_percent"`
 	CreatedBy       int             `json:"created_by" db:"created_by"`
 	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
@@ -104,14 +131,609 @@ type AccountingBudget struct {
 
 // AccountBalance represents account balance for reports
 type AccountBalance struct {
-	AccountCode string  `json:"account_code"`
-	AccountName string  `json:"account_name"`
-	Balance     float64 `json:"balance"`
+	AccountCode string `json:"account_code"`
+	AccountName string `json:"account_name"`
+	Balance     Money  `json:"balance"`
 }
 
 // AccountAmount represents account amount for reports
 type AccountAmount struct {
-	AccountCode string  `json:"account_code"`
-	AccountName string  `json:"account_name"`
-	Amount      float64 `json:"amount"`
+	AccountCode string `json:"account_code"`
+	AccountName string `json:"account_name"`
+	Amount      Money  `json:"amount"`
+}
+
+// CashFlowActivity is a section of the cash flow statement. Amounts are
+// positive for cash received and negative for cash paid.
+type CashFlowActivity struct {
+	Items []AccountAmount `json:"items"`
+	Total Money           `json:"total"`
+}
+
+// TrialBalanceAccount is a row of the trial balance. Balances are debit
+// minus credit, so credit balances are negative.
+type TrialBalanceAccount struct {
+	AccountID      int    `json:"account_id"`
+	AccountCode    string `json:"account_code"`
+	AccountName    string `json:"account_name"`
+	AccountType    string `json:"account_type"`
+	ParentID       *int   `json:"parent_id"`
+	Level          int    `json:"level"`
+	OpeningBalance Money  `json:"opening_balance"`
+	DebitAmount    Money  `json:"debit_amount"`
+	CreditAmount   Money  `json:"credit_amount"`
+	ClosingBalance Money  `json:"closing_balance"`
+}
+
+// GeneralLedgerAccount summarizes an account in the general ledger report
+type GeneralLedgerAccount struct {
+	AccountID      int    `json:"account_id"`
+	AccountCode    string `json:"account_code"`
+	AccountName    string `json:"account_name"`
+	AccountType    string `json:"account_type"`
+	OpeningBalance Money  `json:"opening_balance"`
+	TotalDebit     Money  `json:"total_debit"`
+	TotalCredit    Money  `json:"total_credit"`
+	ClosingBalance Money  `json:"closing_balance"`
+}
+
+// GeneralLedgerLine is a posted transaction line in the general ledger
+// report, with the balance of its account after it
+type GeneralLedgerLine struct {
+	LineID            int       `json:"line_id" db:"line_id"`
+	AccountID         int       `json:"account_id" db:"account_id"`
+	AccountCode       string    `json:"account_code" db:"account_code"`
+	TransactionID     int       `json:"transaction_id" db:"transaction_id"`
+	TransactionNumber string    `json:"transaction_number" db:"transaction_number"`
+	TransactionDate   time.Time `json:"transaction_date" db:"transaction_date"`
+	ReferenceType     *string   `json:"reference_type" db:"reference_type"`
+	ReferenceID       *int      `json:"reference_id" db:"reference_id"`
+	Description       *string   `json:"description" db:"description"`
+	DebitAmount       Money     `json:"debit_amount" db:"debit_amount"`
+	CreditAmount      Money     `json:"credit_amount" db:"credit_amount"`
+	Movement          Money     `json:"-" db:"movement"`
+	RunningBalance    Money     `json:"running_balance" db:"-"`
+}
+
+// Invoice represents a customer invoice
+type Invoice struct {
+	ID                  int           `json:"id" db:"id"`
+	TenantID            *string       `json:"tenant_id,omitempty" db:"tenant_id"`
+	InvoiceNumber       string        `json:"invoice_number" db:"invoice_number"`
+	CustomerID          int           `json:"customer_id" db:"customer_id"`
+	CustomerName        *string       `json:"customer_name" db:"customer_name"`
+	InvoiceDate         time.Time     `json:"invoice_date" db:"invoice_date"`
+	DueDate             time.Time     `json:"due_date" db:"due_date"`
+	Currency            string        `json:"currency" db:"currency"`
+	ReceivableAccountID int           `json:"receivable_account_id" db:"receivable_account_id"`
+	TaxAccountID        *int          `json:"tax_account_id" db:"tax_account_id"`
+	Subtotal            Money         `json:"subtotal" db:"subtotal"`
+	TaxAmount           Money         `json:"tax_amount" db:"tax_amount"`
+	TotalAmount         Money         `json:"total_amount" db:"total_amount"`
+	PaidAmount          Money         `json:"paid_amount" db:"paid_amount"`
+	BalanceAmount       Money         `json:"balance_amount" db:"balance_amount"`
+	Status              string        `json:"status" db:"status"` // draft, issued, partially_paid, paid, void
+	Notes               *string       `json:"notes" db:"notes"`
+	TransactionID       *int          `json:"transaction_id" db:"transaction_id"`
+	VoidTransactionID   *int          `json:"void_transaction_id" db:"void_transaction_id"`
+	VoidReason          *string       `json:"void_reason" db:"void_reason"`
+	CreatedBy           int           `json:"created_by" db:"created_by"`
+	IssuedAt            *time.Time    `json:"issued_at" db:"issued_at"`
+	VoidedAt            *time.Time    `json:"voided_at" db:"voided_at"`
+	CreatedAt           time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time     `json:"updated_at" db:"updated_at"`
+	Lines               []InvoiceLine `json:"lines,omitempty"`
+}
+
+// InvoiceLine represents a line on an invoice
+type InvoiceLine struct {
+	ID               int            `json:"id" db:"id"`
+	TenantID         *string        `json:"tenant_id,omitempty" db:"tenant_id"`
+	InvoiceID        int            `json:"invoice_id" db:"invoice_id"`
+	LineNumber       int            `json:"line_number" db:"line_number"`
+	Description      string         `json:"description" db:"description"`
+	Quantity         float64        `json:"quantity" db:"quantity"`
+	UnitPrice        Money          `json:"unit_price" db:"unit_price"`
+	TaxRate          float64        `json:"tax_rate" db:"tax_rate"`
+	LineSubtotal     Money          `json:"line_subtotal" db:"line_subtotal"`
+	TaxAmount        Money          `json:"tax_amount" db:"tax_amount"`
+	LineTotal        Money          `json:"line_total" db:"line_total"`
+	RevenueAccountID int            `json:"revenue_account_id" db:"revenue_account_id"`
+	CreatedAt        time.Time      `json:"created_at" db:"created_at"`
+	Taxes            []TaxComponent `json:"taxes,omitempty"`
+}
+
+// Payment represents a customer payment received
+type Payment struct {
+	ID              int                 `json:"id" db:"id"`
+	TenantID        *string             `json:"tenant_id,omitempty" db:"tenant_id"`
+	PaymentNumber   string              `json:"payment_number" db:"payment_number"`
+	CustomerID      int                 `json:"customer_id" db:"customer_id"`
+	CustomerName    *string             `json:"customer_name" db:"customer_name"`
+	PaymentDate     time.Time           `json:"payment_date" db:"payment_date"`
+	Amount          Money               `json:"amount" db:"amount"`
+	Currency        string              `json:"currency" db:"currency"`
+	PaymentMethod   string              `json:"payment_method" db:"payment_method"` // cash, check, bank_transfer, card, other
+	Reference       *string             `json:"reference" db:"reference"`
+	CashAccountID   int                 `json:"cash_account_id" db:"cash_account_id"`
+	CreditAccountID *int                `json:"credit_account_id" db:"credit_account_id"`
+	AllocatedAmount Money               `json:"allocated_amount" db:"allocated_amount"`
+	UnappliedAmount Money               `json:"unapplied_amount" db:"unapplied_amount"`
+	Status          string              `json:"status" db:"status"` // posted, reversed
+	Notes           *string             `json:"notes" db:"notes"`
+	ReversalReason  *string             `json:"reversal_reason" db:"reversal_reason"`
+	CreatedBy       int                 `json:"created_by" db:"created_by"`
+	ReversedAt      *time.Time          `json:"reversed_at" db:"reversed_at"`
+	CreatedAt       time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at" db:"updated_at"`
+	Allocations     []PaymentAllocation `json:"allocations,omitempty"`
+}
+
+// PaymentAllocation represents part of a payment applied to an invoice
+type PaymentAllocation struct {
+	ID                    int        `json:"id" db:"id"`
+	TenantID              *string    `json:"tenant_id,omitempty" db:"tenant_id"`
+	PaymentID             int        `json:"payment_id" db:"payment_id"`
+	InvoiceID             int        `json:"invoice_id" db:"invoice_id"`
+	InvoiceNumber         *string    `json:"invoice_number,omitempty" db:"invoice_number"`
+	Amount                Money      `json:"amount" db:"amount"`
+	Status                string     `json:"status" db:"status"` // applied, unapplied
+	TransactionID         int        `json:"transaction_id" db:"transaction_id"`
+	ReversalTransactionID *int       `json:"reversal_transaction_id" db:"reversal_transaction_id"`
+	CreatedAt             time.Time  `json:"created_at" db:"created_at"`
+	UnappliedAt           *time.Time `json:"unapplied_at" db:"unapplied_at"`
+}
+
+// FiscalPeriod represents a period of the fiscal calendar
+type FiscalPeriod struct {
+	ID           int        `json:"id" db:"id"`
+	TenantID     *string    `json:"tenant_id,omitempty" db:"tenant_id"`
+	PeriodName   string     `json:"period_name" db:"period_name"`
+	FiscalYear   int        `json:"fiscal_year" db:"fiscal_year"`
+	PeriodNumber int        `json:"period_number" db:"period_number"`
+	StartDate    time.Time  `json:"start_date" db:"start_date"`
+	EndDate      time.Time  `json:"end_date" db:"end_date"`
+	Status       string     `json:"status" db:"status"` // open, soft_closed, hard_closed
+	IsCurrent    bool       `json:"is_current" db:"is_current"`
+	ClosedBy     *int       `json:"closed_by" db:"closed_by"`
+	ClosedAt     *time.Time `json:"closed_at" db:"closed_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// YearEndClose records the closing of a fiscal year into retained earnings
+type YearEndClose struct {
+	ID                        int        `json:"id" db:"id"`
+	TenantID                  *string    `json:"tenant_id,omitempty" db:"tenant_id"`
+	CompanyID                 *string    `json:"company_id,omitempty" db:"company_id"`
+	FiscalYear                int        `json:"fiscal_year" db:"fiscal_year"`
+	StartDate                 time.Time  `json:"start_date" db:"start_date"`
+	EndDate                   time.Time  `json:"end_date" db:"end_date"`
+	RetainedEarningsAccountID int        `json:"retained_earnings_account_id" db:"retained_earnings_account_id"`
+	TotalRevenue              Money      `json:"total_revenue" db:"total_revenue"`
+	TotalExpenses             Money      `json:"total_expenses" db:"total_expenses"`
+	NetIncome                 Money      `json:"net_income" db:"net_income"`
+	TransactionID             *int       `json:"transaction_id" db:"transaction_id"`
+	ReversalTransactionID     *int       `json:"reversal_transaction_id" db:"reversal_transaction_id"`
+	Status                    string     `json:"status" db:"status"` // closed, reopened
+	ClosedBy                  int        `json:"closed_by" db:"closed_by"`
+	ClosedAt                  time.Time  `json:"closed_at" db:"closed_at"`
+	ReopenedBy                *int       `json:"reopened_by" db:"reopened_by"`
+	ReopenedAt                *time.Time `json:"reopened_at" db:"reopened_at"`
+	ReopenReason              *string    `json:"reopen_reason" db:"reopen_reason"`
+}
+
+// TaxCode represents a tax code with effective-dated rates
+type TaxCode struct {
+	ID                  int        `json:"id" db:"id"`
+	TenantID            *string    `json:"tenant_id,omitempty" db:"tenant_id"`
+	Code                string     `json:"code" db:"code"`
+	Name                string     `json:"name" db:"name"`
+	Type                string     `json:"type" db:"type"` // sales, purchase, sales_purchase
+	Description         *string    `json:"description" db:"description"`
+	IsCompound          bool       `json:"is_compound" db:"is_compound"`
+	IsInclusive         bool       `json:"is_inclusive" db:"is_inclusive"`
+	PayableAccountID    *int       `json:"payable_account_id" db:"payable_account_id"`
+	ReceivableAccountID *int       `json:"receivable_account_id" db:"receivable_account_id"`
+	IsActive            bool       `json:"is_active" db:"is_active"`
+	Rate                *float64   `json:"rate" db:"rate"`
+	EffectiveFrom       *time.Time `json:"effective_from" db:"effective_from"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+	Rates               []TaxRate  `json:"rates,omitempty"`
+}
+
+// TaxRate represents the rate of a tax code from a given date
+type TaxRate struct {
+	ID            int        `json:"id" db:"id"`
+	TenantID      *string    `json:"tenant_id,omitempty" db:"tenant_id"`
+	TaxCodeID     int        `json:"tax_code_id" db:"tax_code_id"`
+	Rate          float64    `json:"rate" db:"rate"`
+	EffectiveFrom time.Time  `json:"effective_from" db:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to" db:"effective_to"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ExchangeRate is the number of units of ToCurrency one unit of
+// FromCurrency buys on RateDate
+type ExchangeRate struct {
+	ID           int       `json:"id" db:"id"`
+	TenantID     *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	FromCurrency string    `json:"from_currency" db:"from_currency"`
+	ToCurrency   string    `json:"to_currency" db:"to_currency"`
+	Rate         float64   `json:"rate" db:"rate"`
+	RateDate     time.Time `json:"rate_date" db:"rate_date"`
+	Source       string    `json:"source" db:"source"` // manual, import
+	CreatedBy    *int      `json:"created_by" db:"created_by"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// FXRevaluation records a revaluation of foreign currency balances on
+// monetary accounts and the auto-reversing journal entry that posted it
+type FXRevaluation struct {
+	ID              int                 `json:"id" db:"id"`
+	TenantID        *string             `json:"tenant_id,omitempty" db:"tenant_id"`
+	CompanyID       *string             `json:"company_id,omitempty" db:"company_id"`
+	RevaluationDate time.Time           `json:"revaluation_date" db:"revaluation_date"`
+	ReverseOn       time.Time           `json:"reverse_on" db:"reverse_on"`
+	Currency        string              `json:"currency" db:"currency"`
+	GainAccountID   int                 `json:"gain_account_id" db:"gain_account_id"`
+	LossAccountID   int                 `json:"loss_account_id" db:"loss_account_id"`
+	TotalGain       Money               `json:"total_gain" db:"total_gain"`
+	TotalLoss       Money               `json:"total_loss" db:"total_loss"`
+	NetAdjustment   Money               `json:"net_adjustment" db:"net_adjustment"`
+	JournalEntryID  *int                `json:"journal_entry_id" db:"journal_entry_id"`
+	CreatedBy       int                 `json:"created_by" db:"created_by"`
+	CreatedAt       time.Time           `json:"created_at" db:"created_at"`
+	Lines           []FXRevaluationLine `json:"lines,omitempty"`
+}
+
+// FXRevaluationLine is the revaluation of the balance of one monetary account
+// in one foreign currency. The adjustment is the revalued amount less the
+// amount booked in the functional currency, positive when it debits the
+// account.
+type FXRevaluationLine struct {
+	ID             int     `json:"id" db:"id"`
+	TenantID       *string `json:"tenant_id,omitempty" db:"tenant_id"`
+	RevaluationID  int     `json:"revaluation_id" db:"revaluation_id"`
+	AccountID      int     `json:"account_id" db:"account_id"`
+	AccountCode    string  `json:"account_code,omitempty" db:"account_code"`
+	AccountName    string  `json:"account_name,omitempty" db:"account_name"`
+	Currency       string  `json:"currency" db:"currency"`
+	ForeignBalance Money   `json:"foreign_balance" db:"foreign_balance"`
+	BookedAmount   Money   `json:"booked_amount" db:"booked_amount"`
+	ExchangeRate   float64 `json:"exchange_rate" db:"exchange_rate"`
+	RevaluedAmount Money   `json:"revalued_amount" db:"revalued_amount"`
+	Adjustment     Money   `json:"adjustment" db:"adjustment"`
+}
+
+// TaxComponent is the tax computed for one tax code on an amount
+type TaxComponent struct {
+	TaxCodeID     int     `json:"tax_code_id" db:"tax_code_id"`
+	Code          string  `json:"code" db:"code"`
+	Rate          float64 `json:"rate" db:"rate"`
+	IsCompound    bool    `json:"is_compound" db:"is_compound"`
+	TaxableAmount Money   `json:"taxable_amount" db:"taxable_amount"`
+	TaxAmount     Money   `json:"tax_amount" db:"tax_amount"`
+	AccountID     int     `json:"account_id" db:"account_id"`
+}
+
+// TaxCalculation is the result of applying tax codes to an amount
+type TaxCalculation struct {
+	NetAmount   Money          `json:"net_amount"`
+	TaxAmount   Money          `json:"tax_amount"`
+	GrossAmount Money          `json:"gross_amount"`
+	Taxes       []TaxComponent `json:"taxes"`
+}
+
+// Reconciliation represents a bank reconciliation of a cash account
+type Reconciliation struct {
+	ID                int        `json:"id" db:"id"`
+	TenantID          *string    `json:"tenant_id,omitempty" db:"tenant_id"`
+	AccountID         int        `json:"account_id" db:"account_id"`
+	AccountName       *string    `json:"account_name" db:"account_name"`
+	StatementDate     time.Time  `json:"statement_date" db:"statement_date"`
+	OpeningBalance    Money      `json:"opening_balance" db:"opening_balance"`
+	StatementBalance  Money      `json:"statement_balance" db:"statement_balance"`
+	BookBalance       Money      `json:"book_balance" db:"book_balance"`
+	ReconciledBalance Money      `json:"reconciled_balance" db:"reconciled_balance"`
+	Difference        Money      `json:"difference" db:"difference"`
+	Status            string     `json:"status" db:"status"` // in_progress, completed
+	Notes             *string    `json:"notes" db:"notes"`
+	CreatedBy         int        `json:"created_by" db:"created_by"`
+	CompletedBy       *int       `json:"completed_by" db:"completed_by"`
+	CompletedAt       *time.Time `json:"completed_at" db:"completed_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// BankStatementLine represents a line of a bank statement for a cash account
+type BankStatementLine struct {
+	ID                 int       `json:"id" db:"id"`
+	TenantID           *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	AccountID          int       `json:"account_id" db:"account_id"`
+	ReconciliationID   *int      `json:"reconciliation_id" db:"reconciliation_id"`
+	MatchID            *int      `json:"match_id" db:"match_id"`
+	ImportID           *int      `json:"import_id" db:"import_id"`
+	TransactionDate    time.Time `json:"transaction_date" db:"transaction_date"`
+	Description        *string   `json:"description" db:"description"`
+	Reference          *string   `json:"reference" db:"reference"`
+	BankReference      *string   `json:"bank_reference" db:"bank_reference"`
+	Amount             Money     `json:"amount" db:"amount"` // positive for deposits, negative for withdrawals
+	Status             string    `json:"status" db:"status"` // unmatched, matched
+	RuleID             *int      `json:"rule_id" db:"rule_id"`
+	DraftTransactionID *int      `json:"draft_transaction_id" db:"draft_transaction_id"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+}
+
+// ReconciliationMatch groups statement lines with the ledger lines they clear
+type ReconciliationMatch struct {
+	ID                 int       `json:"id" db:"id"`
+	TenantID           *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	ReconciliationID   int       `json:"reconciliation_id" db:"reconciliation_id"`
+	Amount             Money     `json:"amount" db:"amount"`
+	CreatedBy          int       `json:"created_by" db:"created_by"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	StatementLineIDs   []int     `json:"statement_line_ids"`
+	TransactionLineIDs []int     `json:"transaction_line_ids"`
+}
+
+// LedgerLine is a general ledger line of a single account with its
+// transaction details, amount signed as debit minus credit
+type LedgerLine struct {
+	ID                int       `json:"id" db:"id"`
+	TransactionID     int       `json:"transaction_id" db:"transaction_id"`
+	TransactionNumber string    `json:"transaction_number" db:"transaction_number"`
+	TransactionDate   time.Time `json:"transaction_date" db:"transaction_date"`
+	Description       *string   `json:"description" db:"description"`
+	ReferenceType     *string   `json:"reference_type" db:"reference_type"`
+	ReferenceID       *int      `json:"reference_id" db:"reference_id"`
+	Amount            Money     `json:"amount" db:"amount"`
+	ReconciliationID  *int      `json:"reconciliation_id" db:"reconciliation_id"`
+}
+
+// BankImportProfile is a saved column mapping for CSV bank statements.
+// Columns are header names, or 1-based positions for files without a header.
+type BankImportProfile struct {
+	ID                int       `json:"id" db:"id"`
+	TenantID          *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	Name              string    `json:"name" db:"name"`
+	AccountID         *int      `json:"account_id" db:"account_id"`
+	Delimiter         string    `json:"delimiter" db:"delimiter"`
+	HasHeader         bool      `json:"has_header" db:"has_header"`
+	SkipRows          int       `json:"skip_rows" db:"skip_rows"`
+	DateColumn        string    `json:"date_column" db:"date_column"`
+	DateFormat        string    `json:"date_format" db:"date_format"`
+	DescriptionColumn *string   `json:"description_column" db:"description_column"`
+	ReferenceColumn   *string   `json:"reference_column" db:"reference_column"`
+	AmountColumn      *string   `json:"amount_column" db:"amount_column"`
+	DebitColumn       *string   `json:"debit_column" db:"debit_column"`
+	CreditColumn      *string   `json:"credit_column" db:"credit_column"`
+	DecimalSeparator  string    `json:"decimal_separator" db:"decimal_separator"`
+	NegateAmounts     bool      `json:"negate_amounts" db:"negate_amounts"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BankStatementImport records an imported bank statement file
+type BankStatementImport struct {
+	ID            int       `json:"id" db:"id"`
+	TenantID      *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	AccountID     int       `json:"account_id" db:"account_id"`
+	Format        string    `json:"format" db:"format"` // csv, ofx, qfx, camt053
+	FileName      *string   `json:"file_name" db:"file_name"`
+	ProfileID     *int      `json:"profile_id" db:"profile_id"`
+	LinesImported int       `json:"lines_imported" db:"lines_imported"`
+	LinesSkipped  int       `json:"lines_skipped" db:"lines_skipped"`
+	CreatedBy     int       `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// BankRule categorizes bank statement lines, e.g. descriptions containing
+// STRIPE go to a revenue account. A matching line without a ledger match can
+// be turned into a draft transaction against the rule's target account.
+type BankRule struct {
+	ID              int       `json:"id" db:"id"`
+	TenantID        *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	Name            string    `json:"name" db:"name"`
+	AccountID       *int      `json:"account_id" db:"account_id"`   // bank account, all accounts when nil
+	MatchField      string    `json:"match_field" db:"match_field"` // description, reference, any
+	MatchType       string    `json:"match_type" db:"match_type"`   // contains, equals, starts_with, regex
+	Pattern         string    `json:"pattern" db:"pattern"`
+	Direction       string    `json:"direction" db:"direction"` // any, deposit, withdrawal
+	AmountMin       *Money    `json:"amount_min" db:"amount_min"`
+	AmountMax       *Money    `json:"amount_max" db:"amount_max"`
+	TargetAccountID int       `json:"target_account_id" db:"target_account_id"`
+	Description     *string   `json:"description" db:"description"`
+	Priority        int       `json:"priority" db:"priority"`
+	IsActive        bool      `json:"is_active" db:"is_active"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// MatchCandidate is a ledger line proposed as the match of a statement line
+type MatchCandidate struct {
+	LedgerLine
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// MatchSuggestion lists the proposed matches for a statement line, and the
+// bank rule it falls under when no ledger line matches
+type MatchSuggestion struct {
+	StatementLine BankStatementLine `json:"statement_line"`
+	Candidates    []MatchCandidate  `json:"candidates"`
+	Rule          *BankRule         `json:"rule,omitempty"`
+}
+
+// BudgetVsActual is a row of the budget-vs-actual report. Budget and actual
+// amounts include the account's descendants in the ParentID hierarchy.
+type BudgetVsActual struct {
+	AccountID       int     `json:"account_id"`
+	AccountCode     string  `json:"account_code"`
+	AccountName     string  `json:"account_name"`
+	AccountType     string  `json:"account_type"`
+	ParentID        *int    `json:"parent_id"`
+	Level           int     `json:"level"`
+	BudgetAmount    Money   `json:"budget_amount"`
+	ActualAmount    Money   `json:"actual_amount"`
+	VarianceAmount  Money   `json:"variance_amount"`
+	VariancePercent float64 `json:"variance_percent"`
+}
+
+// BudgetAlert records a posting that pushed an account past its budget alert
+// threshold or past the budget itself
+type BudgetAlert struct {
+	ID               int        `json:"id" db:"id"`
+	TenantID         *string    `json:"tenant_id,omitempty" db:"tenant_id"`
+	BudgetID         int        `json:"budget_id" db:"budget_id"`
+	BudgetName       string     `json:"budget_name" db:"budget_name"`
+	AccountID        int        `json:"account_id" db:"account_id"`
+	AccountName      string     `json:"account_name" db:"account_name"`
+	TransactionID    *int       `json:"transaction_id" db:"transaction_id"`
+	AlertType        string     `json:"alert_type" db:"alert_type"` // threshold, exceeded
+	ThresholdPercent float64    `json:"threshold_percent" db:"threshold_percent"`
+	BudgetAmount     Money      `json:"budget_amount" db:"budget_amount"`
+	ActualAmount     Money      `json:"actual_amount" db:"actual_amount"`
+	PercentUsed      float64    `json:"percent_used" db:"percent_used"`
+	Status           string     `json:"status" db:"status"` // open, acknowledged
+	AcknowledgedBy   *int       `json:"acknowledged_by" db:"acknowledged_by"`
+	AcknowledgedAt   *time.Time `json:"acknowledged_at" db:"acknowledged_at"`
+	AcknowledgeNote  *string    `json:"acknowledge_note" db:"acknowledge_note"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ApprovalPolicy decides which approval chain an entry goes through. A
+// policy matches entries within its amount band that touch an account of its
+// account type or the account itself when those are set.
+type ApprovalPolicy struct {
+	ID          int                  `json:"id" db:"id"`
+	TenantID    *string              `json:"tenant_id,omitempty" db:"tenant_id"`
+	Name        string               `json:"name" db:"name"`
+	EntityType  string               `json:"entity_type" db:"entity_type"` // any, transaction, journal_entry
+	MinAmount   Money                `json:"min_amount" db:"min_amount"`
+	MaxAmount   *Money               `json:"max_amount" db:"max_amount"` // exclusive, unbounded when nil
+	AccountType *string              `json:"account_type" db:"account_type"`
+	AccountID   *int                 `json:"account_id" db:"account_id"`
+	Priority    int                  `json:"priority" db:"priority"`
+	IsActive    bool                 `json:"is_active" db:"is_active"`
+	CreatedAt   time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at" db:"updated_at"`
+	Steps       []ApprovalPolicyStep `json:"steps"`
+}
+
+// ApprovalPolicyStep is one step of an approval policy's chain
+type ApprovalPolicyStep struct {
+	ID         int       `json:"id" db:"id"`
+	TenantID   *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	PolicyID   int       `json:"policy_id" db:"policy_id"`
+	StepOrder  int       `json:"step_order" db:"step_order"`
+	Name       string    `json:"name" db:"name"`
+	ApproverID *int      `json:"approver_id" db:"approver_id"` // any user but the creator when nil
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// ApprovalStep is a step of the approval chain of a transaction or journal
+// entry together with its decision
+type ApprovalStep struct {
+	ID         int        `json:"id" db:"id"`
+	TenantID   *string    `json:"tenant_id,omitempty" db:"tenant_id"`
+	EntityType string     `json:"entity_type" db:"entity_type"` // transaction, journal_entry
+	EntityID   int        `json:"entity_id" db:"entity_id"`
+	PolicyID   *int       `json:"policy_id" db:"policy_id"`
+	StepOrder  int        `json:"step_order" db:"step_order"`
+	StepName   string     `json:"step_name" db:"step_name"`
+	ApproverID *int       `json:"approver_id" db:"approver_id"`
+	Status     string     `json:"status" db:"status"` // pending, approved, rejected, cancelled
+	DecidedBy  *int       `json:"decided_by" db:"decided_by"`
+	DecidedAt  *time.Time `json:"decided_at" db:"decided_at"`
+	Comment    *string    `json:"comment" db:"comment"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// RecurringTemplate generates a journal entry on every date of its schedule
+type RecurringTemplate struct {
+	ID             int                     `json:"id" db:"id"`
+	TenantID       *string                 `json:"tenant_id,omitempty" db:"tenant_id"`
+	Name           string                  `json:"name" db:"name"`
+	Description    *string                 `json:"description" db:"description"`
+	Reference      *string                 `json:"reference" db:"reference"`
+	Frequency      string                  `json:"frequency" db:"frequency"` // daily, weekly, monthly, quarterly, yearly, cron
+	IntervalCount  int                     `json:"interval_count" db:"interval_count"`
+	CronExpression *string                 `json:"cron_expression" db:"cron_expression"`
+	StartDate      time.Time               `json:"start_date" db:"start_date"`
+	EndDate        *time.Time              `json:"end_date" db:"end_date"`
+	NextRunDate    *time.Time              `json:"next_run_date" db:"next_run_date"` // nil once the schedule has ended
+	LastRunDate    *time.Time              `json:"last_run_date" db:"last_run_date"`
+	EntryStatus    string                  `json:"entry_status" db:"entry_status"` // draft, posted
+	Variables      templateVariables       `json:"variables" db:"variables"`
+	IsActive       bool                    `json:"is_active" db:"is_active"`
+	CreatedBy      int                     `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time               `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time               `json:"updated_at" db:"updated_at"`
+	Lines          []RecurringTemplateLine `json:"lines"`
+}
+
+// RecurringTemplateLine is a line of a recurring template. A line amount is
+// fixed or taken from a template variable.
+type RecurringTemplateLine struct {
+	ID             int       `json:"id" db:"id"`
+	TenantID       *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	TemplateID     int       `json:"template_id" db:"template_id"`
+	AccountID      int       `json:"account_id" db:"account_id"`
+	DebitAmount    Money     `json:"debit_amount" db:"debit_amount"`
+	CreditAmount   Money     `json:"credit_amount" db:"credit_amount"`
+	DebitVariable  *string   `json:"debit_variable" db:"debit_variable"`
+	CreditVariable *string   `json:"credit_variable" db:"credit_variable"`
+	Description    *string   `json:"description" db:"description"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// RecurringTemplateRun records the journal entry generated by a template for
+// one scheduled date
+type RecurringTemplateRun struct {
+	ID             int       `json:"id" db:"id"`
+	TenantID       *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	TemplateID     int       `json:"template_id" db:"template_id"`
+	RunDate        time.Time `json:"run_date" db:"run_date"`
+	IdempotencyKey string    `json:"idempotency_key" db:"idempotency_key"`
+	JournalEntryID *int      `json:"journal_entry_id" db:"journal_entry_id"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// Company is a legal entity of a tenant. Inter-company transactions post to
+// its due-from account when it is owed and to its due-to account when it owes.
+type Company struct {
+	ID               string    `json:"id" db:"id"`
+	TenantID         *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	Code             string    `json:"code" db:"code"`
+	Name             string    `json:"name" db:"name"`
+	DueFromAccountID *int      `json:"due_from_account_id" db:"due_from_account_id"`
+	DueToAccountID   *int      `json:"due_to_account_id" db:"due_to_account_id"`
+	IsActive         bool      `json:"is_active" db:"is_active"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// IntercompanyTransaction is an amount charged by one company to another,
+// posted as a mirrored transaction in each of them
+type IntercompanyTransaction struct {
+	ID                int        `json:"id" db:"id"`
+	TenantID          *string    `json:"tenant_id,omitempty" db:"tenant_id"`
+	FromCompanyID     string     `json:"from_company_id" db:"from_company_id"`
+	ToCompanyID       string     `json:"to_company_id" db:"to_company_id"`
+	TransactionDate   time.Time  `json:"transaction_date" db:"transaction_date"`
+	Description       *string    `json:"description" db:"description"`
+	Amount            Money      `json:"amount" db:"amount"`
+	Currency          string     `json:"currency" db:"currency"`
+	FromAccountID     int        `json:"from_account_id" db:"from_account_id"`
+	ToAccountID       int        `json:"to_account_id" db:"to_account_id"`
+	FromTransactionID *int       `json:"from_transaction_id" db:"from_transaction_id"`
+	ToTransactionID   *int       `json:"to_transaction_id" db:"to_transaction_id"`
+	Status            string     `json:"status" db:"status"` // posted, void
+	CreatedBy         int        `json:"created_by" db:"created_by"`
+	VoidedBy          *int       `json:"voided_by" db:"voided_by"`
+	VoidedAt          *time.Time `json:"voided_at" db:"voided_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
 }
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	sdk "github.com/linearbits/erp-backend/pkg/module-sdk"
+	"go.uber.org/zap"
+)
+
+// yearEndClosePlan is the closing entry computed for a fiscal year. Every
+// revenue and expense account is brought to zero and the net income is
+// moved to the retained earnings account.
+type yearEndClosePlan struct {
+	FiscalYear              int                         `json:"fiscal_year"`
+	StartDate               string                      `json:"start_date"`
+	EndDate                 string                      `json:"end_date"`
+	RetainedEarningsAccount ChartOfAccount              `json:"retained_earnings_account"`
+	TotalRevenue            Money                       `json:"total_revenue"`
+	TotalExpenses           Money                       `json:"total_expenses"`
+	NetIncome               Money                       `json:"net_income"`
+	Lines                   []AccountingTransactionLine `json:"lines"`
+}
+
+// buildYearEndClose computes the closing entry for a fiscal year using the
+// same revenue and expense totals as the income statement. Each company
+// closes its own books into its own retained earnings account, or the shared
+// one when it has none; without a company the books of no company are
+// closed.
+func buildYearEndClose(q sqlx.Queryer, tenant, company string, fiscalYear int) (*yearEndClosePlan, error) {
+	settings, err := loadSettings(q, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if settings.RetainedEarningsAccountCode == "" {
+		return nil, newBadRequestError("The retained_earnings_account_code setting is not configured")
+	}
+	if company != "" {
+		if _, err := requireCompany(q, tenant, company); err != nil {
+			return nil, err
+		}
+	}
+
+	retained, err := accountByCode(q, tenant, company, settings.RetainedEarningsAccountCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newBadRequestError("Retained earnings account %s not found", settings.RetainedEarningsAccountCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if retained.AccountType != "equity" {
+		return nil, newBadRequestError("Retained earnings account %s must be an equity account", retained.AccountCode)
+	}
+
+	var closed bool
+	err = sqlx.Get(q, &closed, `
+		SELECT EXISTS(
+			SELECT 1 FROM accounting_year_end_closes
+			WHERE tenant_id = $1 AND fiscal_year = $2 AND status = 'closed'
+			  AND company_id IS NOT DISTINCT FROM NULLIF($3, '')::uuid
+		)
+	`, tenant, fiscalYear, company)
+	if err != nil {
+		return nil, err
+	}
+	if closed {
+		return nil, newBadRequestError("Fiscal year %d is already closed", fiscalYear)
+	}
+
+	start, end := fiscalYearBounds(fiscalYear, settings.FiscalYearStart)
+	period, err := postingPeriod(q, tenant, end)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkYearEndPeriod(period, fiscalYear, "closed"); err != nil {
+		return nil, err
+	}
+
+	amounts, err := incomeAccountAmounts(q, tenant, companyBooks(company), start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &yearEndClosePlan{
+		FiscalYear:              fiscalYear,
+		StartDate:               start.Format("2006-01-02"),
+		EndDate:                 end.Format("2006-01-02"),
+		RetainedEarningsAccount: *retained,
+	}
+
+	description := fmt.Sprintf("Year-end close FY%d", fiscalYear)
+	for _, row := range amounts {
+		amount := row.Amount
+		if amount == 0 {
+			continue
+		}
+
+		line := AccountingTransactionLine{
+			AccountID:   row.AccountID,
+			Description: &description,
+			Account: &ChartOfAccount{
+				ID:          row.AccountID,
+				AccountCode: row.AccountCode,
+				AccountName: row.AccountName,
+				AccountType: row.AccountType,
+			},
+		}
+
+		// Revenue carries a credit balance and expense a debit balance;
+		// the closing line posts the opposite side to bring it to zero
+		if row.AccountType == "revenue" {
+			plan.TotalRevenue += amount
+			if amount > 0 {
+				line.DebitAmount = amount
+			} else {
+				line.CreditAmount = -amount
+			}
+		} else {
+			plan.TotalExpenses += amount
+			if amount > 0 {
+				line.CreditAmount = amount
+			} else {
+				line.DebitAmount = -amount
+			}
+		}
+
+		plan.Lines = append(plan.Lines, line)
+	}
+
+	if len(plan.Lines) == 0 {
+		return nil, newBadRequestError("Fiscal year %d has no revenue or expense balances to close", fiscalYear)
+	}
+
+	plan.NetIncome = plan.TotalRevenue - plan.TotalExpenses
+
+	retainedLine := AccountingTransactionLine{
+		AccountID:   retained.ID,
+		Description: &description,
+		Account:     retained,
+	}
+	if plan.NetIncome >= 0 {
+		retainedLine.CreditAmount = plan.NetIncome
+	} else {
+		retainedLine.DebitAmount = -plan.NetIncome
+	}
+	if plan.NetIncome != 0 {
+		plan.Lines = append(plan.Lines, retainedLine)
+	}
+
+	return plan, nil
+}
+
+// checkYearEndPeriod rejects closing or reopening a fiscal year while the
+// fiscal period holding its last day is closed, since the closing entry and
+// its reversal are dated that day
+func checkYearEndPeriod(period *FiscalPeriod, fiscalYear int, action string) error {
+	if period == nil || period.Status == "open" {
+		return nil
+	}
+	return newBadRequestError("Fiscal period %s, which holds the last day of fiscal year %d, is %s; the year can only be %s while it is open",
+		period.PeriodName, fiscalYear, period.Status, action)
+}
+
+// checkReopener enforces segregation of duties: whoever closed a fiscal year
+// cannot reopen it
+func checkReopener(closedBy, userID int) error {
+	if closedBy == userID {
+		return newBadRequestError("The user who closed a fiscal year cannot reopen it")
+	}
+	return nil
+}
+
+// GetYearEndCloses lists year-end closes
+func (h *AccountingHandler) GetYearEndCloses(w http.ResponseWriter, r *http.Request) {
+	qb := sdk.NewQueryBuilder("SELECT * FROM accounting_year_end_closes WHERE 1=1")
+	qb.AddCondition("tenant_id = $%d", tenantID(r))
+	qb.AddOptionalCondition("company_id = $%d", companyID(r))
+
+	query, args := qb.Build()
+	query += " ORDER BY fiscal_year DESC, id DESC"
+
+	var closes []YearEndClose
+	if err := h.db.Select(&closes, query, args...); err != nil {
+		h.logger.Error("Failed to fetch year-end closes", zap.Error(err))
+		sdk.WriteInternalError(w, "Failed to fetch year-end closes")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{
+		"closes": closes,
+		"count":  len(closes),
+	})
+}
+
+// PreviewYearEndClose shows the closing entry for a fiscal year without posting it
+func (h *AccountingHandler) PreviewYearEndClose(w http.ResponseWriter, r *http.Request) {
+	fiscalYear, err := strconv.Atoi(r.URL.Query().Get("fiscal_year"))
+	if err != nil {
+		sdk.WriteBadRequest(w, "A valid fiscal_year is required")
+		return
+	}
+
+	plan, err := buildYearEndClose(h.db, tenantID(r), companyID(r), fiscalYear)
+	if err != nil {
+		h.writeError(w, err, "Failed to preview year-end close")
+		return
+	}
+
+	sdk.WriteSuccess(w, plan)
+}
+
+// CloseFiscalYear posts the closing entry for a fiscal year, dated the last
+// day of the year, and records the close
+func (h *AccountingHandler) CloseFiscalYear(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FiscalYear int `json:"fiscal_year"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	tenant := tenantID(r)
+	company := companyID(r)
+	userID := currentUserID(r)
+	var yearEnd YearEndClose
+	err := h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		// Serialize concurrent closes of the same year
+		if _, err := tx.Exec("LOCK TABLE accounting_year_end_closes IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+
+		plan, err := buildYearEndClose(tx, tenant, company, req.FiscalYear)
+		if err != nil {
+			return err
+		}
+
+		endDate, _ := parseDate(plan.EndDate)
+		err = tx.QueryRow(`
+			INSERT INTO accounting_year_end_closes
+			(tenant_id, company_id, fiscal_year, start_date, end_date, retained_earnings_account_id, total_revenue,
+			 total_expenses, net_income, closed_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id
+		`, tenant, optionalCompany(company), plan.FiscalYear, plan.StartDate, plan.EndDate,
+			plan.RetainedEarningsAccount.ID, plan.TotalRevenue, plan.TotalExpenses, plan.NetIncome, userID).Scan(&yearEnd.ID)
+		if err != nil {
+			return err
+		}
+
+		referenceType := "year_end_close"
+		description := fmt.Sprintf("Year-end close FY%d", plan.FiscalYear)
+		txn := &AccountingTransaction{
+			CompanyID:       optionalCompany(company),
+			TransactionDate: endDate,
+			ReferenceType:   &referenceType,
+			ReferenceID:     &yearEnd.ID,
+			Description:     &description,
+			CreatedBy:       userID,
+			Lines:           plan.Lines,
+		}
+		if err := h.postTransaction(tx, tenant, txn); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE accounting_year_end_closes SET transaction_id = $1 WHERE id = $2 AND tenant_id = $3",
+			txn.ID, yearEnd.ID, tenant); err != nil {
+			return err
+		}
+
+		return tx.Get(&yearEnd, "SELECT * FROM accounting_year_end_closes WHERE id = $1 AND tenant_id = $2", yearEnd.ID, tenant)
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to close fiscal year")
+		return
+	}
+
+	sdk.WriteCreated(w, map[string]interface{}{
+		"close":   yearEnd,
+		"message": "Fiscal year closed successfully",
+	})
+}
+
+// ReopenFiscalYear reverses the closing entry of a fiscal year so that its
+// revenue and expense balances are restored. The year must be reopened by a
+// user other than the one who closed it.
+func (h *AccountingHandler) ReopenFiscalYear(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamID(r)
+	if err != nil {
+		sdk.WriteBadRequest(w, "Invalid year-end close ID")
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sdk.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+	if req.Reason == "" {
+		sdk.WriteBadRequest(w, "A reason is required to reopen a fiscal year")
+		return
+	}
+
+	tenant := tenantID(r)
+	userID := currentUserID(r)
+	err = h.withTransaction(tenant, func(tx *sqlx.Tx) error {
+		var yearEnd YearEndClose
+		if err := tx.Get(&yearEnd, "SELECT * FROM accounting_year_end_closes WHERE id = $1 AND tenant_id = $2 FOR UPDATE",
+			id, tenant); err != nil {
+			return err
+		}
+		if yearEnd.Status != "closed" || yearEnd.TransactionID == nil {
+			return newBadRequestError("Fiscal year %d is not closed", yearEnd.FiscalYear)
+		}
+		if err := checkReopener(yearEnd.ClosedBy, userID); err != nil {
+			return err
+		}
+		period, err := postingPeriod(tx, tenant, yearEnd.EndDate)
+		if err != nil {
+			return err
+		}
+		if err := checkYearEndPeriod(period, yearEnd.FiscalYear, "reopened"); err != nil {
+			return err
+		}
+
+		reversal, err := h.reverseTransaction(tx, tenant, *yearEnd.TransactionID, yearEnd.EndDate,
+			fmt.Sprintf("Reopen FY%d: %s", yearEnd.FiscalYear, req.Reason))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE accounting_year_end_closes
+			SET status = 'reopened', reversal_transaction_id = $1, reopened_by = $2,
+			    reopened_at = CURRENT_TIMESTAMP, reopen_reason = $3
+			WHERE id = $4 AND tenant_id = $5
+		`, reversal.ID, userID, req.Reason, id, tenant)
+		return err
+	})
+
+	if err != nil {
+		h.writeError(w, err, "Failed to reopen fiscal year")
+		return
+	}
+
+	sdk.WriteSuccess(w, map[string]interface{}{"message": "Fiscal year reopened successfully"})
+}
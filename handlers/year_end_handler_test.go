@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCheckYearEndPeriod(t *testing.T) {
+	tests := []struct {
+		name    string
+		period  *FiscalPeriod
+		wantErr string
+	}{
+		{name: "fiscal periods disabled", period: nil},
+		{name: "open", period: &FiscalPeriod{PeriodName: "December 2025", Status: "open"}},
+		{
+			name:    "soft closed",
+			period:  &FiscalPeriod{PeriodName: "December 2025", Status: "soft_closed"},
+			wantErr: "Fiscal period December 2025, which holds the last day of fiscal year 2025, is soft_closed",
+		},
+		{
+			name:    "hard closed",
+			period:  &FiscalPeriod{PeriodName: "December 2025", Status: "hard_closed"},
+			wantErr: "is hard_closed; the year can only be closed while it is open",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkYearEndPeriod(tt.period, 2025, "closed")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkYearEndPeriod() = %v, want nil", err)
+				}
+				return
+			}
+			var reqErr *requestError
+			if !errors.As(err, &reqErr) || reqErr.status != http.StatusBadRequest {
+				t.Fatalf("checkYearEndPeriod() = %v, want a bad request", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkYearEndPeriod() = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckReopener(t *testing.T) {
+	if err := checkReopener(7, 8); err != nil {
+		t.Errorf("checkReopener(7, 8) = %v, want nil", err)
+	}
+	if err := checkReopener(7, 7); err == nil {
+		t.Error("checkReopener(7, 7) = nil, want an error")
+	}
+}
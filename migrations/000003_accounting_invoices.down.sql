@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS update_accounting_invoices_updated_at ON accounting_invoices;
+DROP TABLE IF EXISTS accounting_invoice_lines CASCADE;
+DROP TABLE IF EXISTS accounting_invoices CASCADE;
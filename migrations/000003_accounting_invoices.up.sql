@@ -0,0 +1,60 @@
+-- Invoices
+-- Customer invoices with lines; issuing an invoice posts an accounting transaction
+
+CREATE TABLE IF NOT EXISTS accounting_invoices (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    invoice_number VARCHAR(50) NOT NULL,
+    customer_id INTEGER NOT NULL REFERENCES customers(id),
+    invoice_date DATE NOT NULL,
+    due_date DATE NOT NULL,
+    currency VARCHAR(3) DEFAULT 'USD',
+    receivable_account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    tax_account_id INTEGER REFERENCES chart_of_accounts(id),
+    subtotal DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    tax_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    total_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    paid_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    balance_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft',
+    notes TEXT,
+    transaction_id INTEGER REFERENCES accounting_transactions(id),
+    void_transaction_id INTEGER REFERENCES accounting_transactions(id),
+    void_reason TEXT,
+    created_by INTEGER NOT NULL,
+    issued_at TIMESTAMP,
+    voided_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_invoices_tenant_number_unique UNIQUE(tenant_id, invoice_number),
+    CONSTRAINT accounting_invoices_status_check CHECK (status IN ('draft', 'issued', 'paid', 'void'))
+);
+
+-- Invoice Lines
+CREATE TABLE IF NOT EXISTS accounting_invoice_lines (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    invoice_id INTEGER NOT NULL REFERENCES accounting_invoices(id) ON DELETE CASCADE,
+    line_number INTEGER NOT NULL,
+    description TEXT,
+    quantity DECIMAL(15,4) NOT NULL DEFAULT 1,
+    unit_price DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    tax_rate DECIMAL(7,4) NOT NULL DEFAULT 0,
+    line_subtotal DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    tax_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    line_total DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    revenue_account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_invoices_tenant ON accounting_invoices(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_invoices_customer ON accounting_invoices(customer_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_invoices_status ON accounting_invoices(status);
+CREATE INDEX IF NOT EXISTS idx_accounting_invoices_date ON accounting_invoices(invoice_date);
+CREATE INDEX IF NOT EXISTS idx_accounting_invoice_lines_invoice ON accounting_invoice_lines(invoice_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_invoice_lines_tenant ON accounting_invoice_lines(tenant_id);
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_invoices_updated_at ON accounting_invoices;
+CREATE TRIGGER update_accounting_invoices_updated_at BEFORE UPDATE ON accounting_invoices FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
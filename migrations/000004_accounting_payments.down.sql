@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS update_accounting_payments_updated_at ON accounting_payments;
+DROP TABLE IF EXISTS accounting_payment_allocations CASCADE;
+DROP TABLE IF EXISTS accounting_payments CASCADE;
+
+UPDATE accounting_invoices SET status = 'issued' WHERE status = 'partially_paid';
+ALTER TABLE accounting_invoices DROP CONSTRAINT IF EXISTS accounting_invoices_status_check;
+ALTER TABLE accounting_invoices ADD CONSTRAINT accounting_invoices_status_check
+    CHECK (status IN ('draft', 'issued', 'paid', 'void'));
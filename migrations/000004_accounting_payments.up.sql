@@ -0,0 +1,59 @@
+-- Payments
+-- Customer payments and their allocations against invoices
+
+-- Invoices can now be partially paid
+ALTER TABLE accounting_invoices DROP CONSTRAINT IF EXISTS accounting_invoices_status_check;
+ALTER TABLE accounting_invoices ADD CONSTRAINT accounting_invoices_status_check
+    CHECK (status IN ('draft', 'issued', 'partially_paid', 'paid', 'void'));
+
+CREATE TABLE IF NOT EXISTS accounting_payments (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    payment_number VARCHAR(50) NOT NULL,
+    customer_id INTEGER NOT NULL REFERENCES customers(id),
+    payment_date DATE NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    currency VARCHAR(3) DEFAULT 'USD',
+    payment_method VARCHAR(20) NOT NULL DEFAULT 'bank_transfer',
+    reference VARCHAR(100),
+    cash_account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    credit_account_id INTEGER REFERENCES chart_of_accounts(id),
+    allocated_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    unapplied_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    status VARCHAR(20) NOT NULL DEFAULT 'posted',
+    notes TEXT,
+    reversal_reason TEXT,
+    created_by INTEGER NOT NULL,
+    reversed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_payments_tenant_number_unique UNIQUE(tenant_id, payment_number),
+    CONSTRAINT accounting_payments_status_check CHECK (status IN ('posted', 'reversed'))
+);
+
+-- Payment Allocations
+CREATE TABLE IF NOT EXISTS accounting_payment_allocations (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    payment_id INTEGER NOT NULL REFERENCES accounting_payments(id),
+    invoice_id INTEGER NOT NULL REFERENCES accounting_invoices(id),
+    amount DECIMAL(15,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'applied',
+    transaction_id INTEGER NOT NULL REFERENCES accounting_transactions(id),
+    reversal_transaction_id INTEGER REFERENCES accounting_transactions(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    unapplied_at TIMESTAMP,
+    CONSTRAINT accounting_payment_allocations_status_check CHECK (status IN ('applied', 'unapplied'))
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_payments_tenant ON accounting_payments(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_payments_customer ON accounting_payments(customer_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_payments_date ON accounting_payments(payment_date);
+CREATE INDEX IF NOT EXISTS idx_accounting_payment_allocations_payment ON accounting_payment_allocations(payment_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_payment_allocations_invoice ON accounting_payment_allocations(invoice_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_payment_allocations_tenant ON accounting_payment_allocations(tenant_id);
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_payments_updated_at ON accounting_payments;
+CREATE TRIGGER update_accounting_payments_updated_at BEFORE UPDATE ON accounting_payments FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
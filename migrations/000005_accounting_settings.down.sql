@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_accounting_settings_updated_at ON accounting_settings;
+DROP TABLE IF EXISTS accounting_settings CASCADE;
@@ -0,0 +1,17 @@
+-- Accounting Settings
+-- Stored values for the settings declared in module.yml; missing keys fall back to the declared defaults
+
+CREATE TABLE IF NOT EXISTS accounting_settings (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    setting_key VARCHAR(100) NOT NULL,
+    setting_value TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_settings_tenant_key_unique UNIQUE(tenant_id, setting_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_accounting_settings_tenant ON accounting_settings(tenant_id);
+
+DROP TRIGGER IF EXISTS update_accounting_settings_updated_at ON accounting_settings;
+CREATE TRIGGER update_accounting_settings_updated_at BEFORE UPDATE ON accounting_settings FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS accounting_transactions_fiscal_period_check ON accounting_transactions;
+DROP FUNCTION IF EXISTS accounting_check_fiscal_period();
+DROP FUNCTION IF EXISTS accounting_fiscal_periods_enabled(UUID);
+DROP TRIGGER IF EXISTS update_accounting_fiscal_periods_updated_at ON accounting_fiscal_periods;
+DROP TABLE IF EXISTS accounting_fiscal_periods CASCADE;
@@ -0,0 +1,91 @@
+-- Fiscal Periods
+-- Fiscal calendar with open/soft_closed/hard_closed periods; postings into closed periods are rejected
+
+CREATE TABLE IF NOT EXISTS accounting_fiscal_periods (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    period_name VARCHAR(50) NOT NULL,
+    fiscal_year INTEGER NOT NULL,
+    period_number INTEGER NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    closed_by INTEGER,
+    closed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_fiscal_periods_tenant_period_unique UNIQUE(tenant_id, fiscal_year, period_number),
+    CONSTRAINT accounting_fiscal_periods_status_check CHECK (status IN ('open', 'soft_closed', 'hard_closed')),
+    CONSTRAINT accounting_fiscal_periods_dates_check CHECK (end_date >= start_date)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_fiscal_periods_tenant ON accounting_fiscal_periods(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_fiscal_periods_dates ON accounting_fiscal_periods(start_date, end_date);
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_fiscal_periods_updated_at ON accounting_fiscal_periods;
+CREATE TRIGGER update_accounting_fiscal_periods_updated_at BEFORE UPDATE ON accounting_fiscal_periods FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Fiscal periods are enforced unless the enable_fiscal_periods setting of the
+-- tenant is false, read the way the module parses boolean settings
+CREATE OR REPLACE FUNCTION accounting_fiscal_periods_enabled(tenant UUID) RETURNS BOOLEAN AS $$
+    SELECT NOT EXISTS(
+        SELECT 1 FROM accounting_settings
+        WHERE tenant_id IS NOT DISTINCT FROM tenant
+          AND setting_key = 'enable_fiscal_periods'
+          AND setting_value IN ('0', 'f', 'F', 'false', 'FALSE', 'False')
+    );
+$$ LANGUAGE sql STABLE;
+
+-- Guard closed periods at the database level so that transactions dated into
+-- them cannot be inserted, changed or removed outside the API either. The
+-- error code AC001 lets the module report the rejection to the client.
+CREATE OR REPLACE FUNCTION accounting_check_fiscal_period() RETURNS TRIGGER AS $$
+DECLARE
+    closed_period VARCHAR(50);
+    row_tenant UUID;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_tenant := OLD.tenant_id;
+    ELSE
+        row_tenant := NEW.tenant_id;
+    END IF;
+    IF NOT accounting_fiscal_periods_enabled(row_tenant) THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        SELECT period_name INTO closed_period
+        FROM accounting_fiscal_periods
+        WHERE tenant_id IS NOT DISTINCT FROM OLD.tenant_id
+          AND OLD.transaction_date BETWEEN start_date AND end_date
+          AND status <> 'open';
+        IF closed_period IS NOT NULL THEN
+            RAISE EXCEPTION 'transaction % is in closed fiscal period %', OLD.transaction_number, closed_period
+                USING ERRCODE = 'AC001';
+        END IF;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        SELECT period_name INTO closed_period
+        FROM accounting_fiscal_periods
+        WHERE tenant_id IS NOT DISTINCT FROM NEW.tenant_id
+          AND NEW.transaction_date BETWEEN start_date AND end_date
+          AND status <> 'open';
+        IF closed_period IS NOT NULL THEN
+            RAISE EXCEPTION 'transaction date % is in closed fiscal period %', NEW.transaction_date, closed_period
+                USING ERRCODE = 'AC001';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS accounting_transactions_fiscal_period_check ON accounting_transactions;
+CREATE TRIGGER accounting_transactions_fiscal_period_check BEFORE INSERT OR UPDATE OR DELETE ON accounting_transactions FOR EACH ROW EXECUTE FUNCTION accounting_check_fiscal_period();
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS accounting_year_end_closes CASCADE;
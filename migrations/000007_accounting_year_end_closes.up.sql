@@ -0,0 +1,28 @@
+-- Year-End Closes
+-- Records each fiscal year closed into retained earnings and the transaction that closed it
+
+CREATE TABLE IF NOT EXISTS accounting_year_end_closes (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    fiscal_year INTEGER NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    retained_earnings_account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    total_revenue DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    total_expenses DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    net_income DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    transaction_id INTEGER REFERENCES accounting_transactions(id),
+    reversal_transaction_id INTEGER REFERENCES accounting_transactions(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'closed',
+    closed_by INTEGER NOT NULL,
+    closed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    reopened_by INTEGER,
+    reopened_at TIMESTAMP,
+    reopen_reason TEXT,
+    CONSTRAINT accounting_year_end_closes_status_check CHECK (status IN ('closed', 'reopened'))
+);
+
+-- Only one active close per fiscal year
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounting_year_end_closes_active
+    ON accounting_year_end_closes(tenant_id, fiscal_year) WHERE status = 'closed';
+CREATE INDEX IF NOT EXISTS idx_accounting_year_end_closes_tenant ON accounting_year_end_closes(tenant_id);
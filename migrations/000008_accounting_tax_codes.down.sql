@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS update_accounting_tax_codes_updated_at ON accounting_tax_codes;
+DROP TABLE IF EXISTS accounting_invoice_line_taxes CASCADE;
+DROP TABLE IF EXISTS accounting_tax_rates CASCADE;
+DROP TABLE IF EXISTS accounting_tax_codes CASCADE;
@@ -0,0 +1,59 @@
+-- Tax Codes
+-- Tax codes with effective-dated rates and their payable/receivable tax accounts
+
+CREATE TABLE IF NOT EXISTS accounting_tax_codes (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    code VARCHAR(20) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL DEFAULT 'sales',
+    description TEXT,
+    is_compound BOOLEAN NOT NULL DEFAULT false,
+    is_inclusive BOOLEAN NOT NULL DEFAULT false,
+    payable_account_id INTEGER REFERENCES chart_of_accounts(id),
+    receivable_account_id INTEGER REFERENCES chart_of_accounts(id),
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_tax_codes_tenant_code_unique UNIQUE(tenant_id, code),
+    CONSTRAINT accounting_tax_codes_type_check CHECK (type IN ('sales', 'purchase', 'sales_purchase'))
+);
+
+-- Tax Rates
+CREATE TABLE IF NOT EXISTS accounting_tax_rates (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    tax_code_id INTEGER NOT NULL REFERENCES accounting_tax_codes(id) ON DELETE CASCADE,
+    rate DECIMAL(7,4) NOT NULL,
+    effective_from DATE NOT NULL,
+    effective_to DATE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_tax_rates_code_from_unique UNIQUE(tax_code_id, effective_from),
+    CONSTRAINT accounting_tax_rates_dates_check CHECK (effective_to IS NULL OR effective_to >= effective_from)
+);
+
+-- Invoice Line Taxes
+CREATE TABLE IF NOT EXISTS accounting_invoice_line_taxes (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    invoice_line_id INTEGER NOT NULL REFERENCES accounting_invoice_lines(id) ON DELETE CASCADE,
+    tax_code_id INTEGER NOT NULL REFERENCES accounting_tax_codes(id),
+    code VARCHAR(20) NOT NULL,
+    rate DECIMAL(7,4) NOT NULL,
+    is_compound BOOLEAN NOT NULL DEFAULT false,
+    taxable_amount DECIMAL(15,2) NOT NULL,
+    tax_amount DECIMAL(15,2) NOT NULL,
+    account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_tax_codes_tenant ON accounting_tax_codes(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_tax_rates_code ON accounting_tax_rates(tax_code_id, effective_from);
+CREATE INDEX IF NOT EXISTS idx_accounting_tax_rates_tenant ON accounting_tax_rates(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_invoice_line_taxes_line ON accounting_invoice_line_taxes(invoice_line_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_invoice_line_taxes_tenant ON accounting_invoice_line_taxes(tenant_id);
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_tax_codes_updated_at ON accounting_tax_codes;
+CREATE TRIGGER update_accounting_tax_codes_updated_at BEFORE UPDATE ON accounting_tax_codes FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS update_accounting_reconciliations_updated_at ON accounting_reconciliations;
+DROP INDEX IF EXISTS idx_accounting_transaction_lines_reconciliation;
+ALTER TABLE accounting_transaction_lines
+    DROP COLUMN IF EXISTS cleared_at,
+    DROP COLUMN IF EXISTS reconciliation_id;
+DROP TABLE IF EXISTS accounting_reconciliation_match_lines CASCADE;
+DROP TABLE IF EXISTS accounting_bank_statement_lines CASCADE;
+DROP TABLE IF EXISTS accounting_reconciliation_matches CASCADE;
+DROP TABLE IF EXISTS accounting_reconciliations CASCADE;
@@ -0,0 +1,78 @@
+-- Bank Reconciliations
+-- Reconciliations of cash accounts against bank statements, with statement lines matched to ledger lines
+
+CREATE TABLE IF NOT EXISTS accounting_reconciliations (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    statement_date DATE NOT NULL,
+    opening_balance DECIMAL(15,2) NOT NULL DEFAULT 0,
+    statement_balance DECIMAL(15,2) NOT NULL DEFAULT 0,
+    book_balance DECIMAL(15,2) NOT NULL DEFAULT 0,
+    reconciled_balance DECIMAL(15,2) NOT NULL DEFAULT 0,
+    difference DECIMAL(15,2) NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'in_progress',
+    notes TEXT,
+    created_by INTEGER NOT NULL,
+    completed_by INTEGER,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_reconciliations_status_check CHECK (status IN ('in_progress', 'completed'))
+);
+
+-- Only one reconciliation per account can be in progress
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounting_reconciliations_in_progress
+    ON accounting_reconciliations(tenant_id, account_id) WHERE status = 'in_progress';
+
+-- Reconciliation Matches
+CREATE TABLE IF NOT EXISTS accounting_reconciliation_matches (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    reconciliation_id INTEGER NOT NULL REFERENCES accounting_reconciliations(id) ON DELETE CASCADE,
+    amount DECIMAL(15,2) NOT NULL,
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Bank Statement Lines
+CREATE TABLE IF NOT EXISTS accounting_bank_statement_lines (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    reconciliation_id INTEGER REFERENCES accounting_reconciliations(id) ON DELETE SET NULL,
+    match_id INTEGER REFERENCES accounting_reconciliation_matches(id) ON DELETE SET NULL,
+    transaction_date DATE NOT NULL,
+    description TEXT,
+    reference VARCHAR(255),
+    amount DECIMAL(15,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'unmatched',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_bank_statement_lines_status_check CHECK (status IN ('unmatched', 'matched'))
+);
+
+-- Ledger lines cleared by each match
+CREATE TABLE IF NOT EXISTS accounting_reconciliation_match_lines (
+    match_id INTEGER NOT NULL REFERENCES accounting_reconciliation_matches(id) ON DELETE CASCADE,
+    transaction_line_id INTEGER NOT NULL REFERENCES accounting_transaction_lines(id),
+    PRIMARY KEY (match_id, transaction_line_id)
+);
+
+-- Cleared state of ledger lines
+ALTER TABLE accounting_transaction_lines
+    ADD COLUMN IF NOT EXISTS reconciliation_id INTEGER REFERENCES accounting_reconciliations(id),
+    ADD COLUMN IF NOT EXISTS cleared_at TIMESTAMP;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_reconciliations_tenant ON accounting_reconciliations(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_reconciliations_account ON accounting_reconciliations(account_id, statement_date);
+CREATE INDEX IF NOT EXISTS idx_accounting_reconciliation_matches_tenant ON accounting_reconciliation_matches(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_reconciliation_matches_reconciliation ON accounting_reconciliation_matches(reconciliation_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_bank_statement_lines_tenant ON accounting_bank_statement_lines(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_bank_statement_lines_account ON accounting_bank_statement_lines(account_id, transaction_date);
+CREATE INDEX IF NOT EXISTS idx_accounting_bank_statement_lines_reconciliation ON accounting_bank_statement_lines(reconciliation_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_transaction_lines_reconciliation ON accounting_transaction_lines(reconciliation_id);
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_reconciliations_updated_at ON accounting_reconciliations;
+CREATE TRIGGER update_accounting_reconciliations_updated_at BEFORE UPDATE ON accounting_reconciliations FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS update_accounting_bank_import_profiles_updated_at ON accounting_bank_import_profiles;
+DROP INDEX IF EXISTS idx_accounting_bank_statement_lines_bank_reference;
+DROP INDEX IF EXISTS idx_accounting_bank_statement_lines_import;
+ALTER TABLE accounting_bank_statement_lines
+    DROP COLUMN IF EXISTS bank_reference,
+    DROP COLUMN IF EXISTS import_id;
+DROP TABLE IF EXISTS accounting_bank_statement_imports CASCADE;
+DROP TABLE IF EXISTS accounting_bank_import_profiles CASCADE;
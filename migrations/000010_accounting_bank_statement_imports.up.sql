@@ -0,0 +1,54 @@
+-- Bank Statement Imports
+-- Saved CSV column mappings, imported statement files and bank references used to skip duplicate lines
+
+CREATE TABLE IF NOT EXISTS accounting_bank_import_profiles (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    account_id INTEGER REFERENCES chart_of_accounts(id) ON DELETE SET NULL,
+    delimiter VARCHAR(1) NOT NULL DEFAULT ',',
+    has_header BOOLEAN NOT NULL DEFAULT true,
+    skip_rows INTEGER NOT NULL DEFAULT 0,
+    date_column VARCHAR(100) NOT NULL,
+    date_format VARCHAR(50) NOT NULL DEFAULT 'YYYY-MM-DD',
+    description_column VARCHAR(100),
+    reference_column VARCHAR(100),
+    amount_column VARCHAR(100),
+    debit_column VARCHAR(100),
+    credit_column VARCHAR(100),
+    decimal_separator VARCHAR(1) NOT NULL DEFAULT '.',
+    negate_amounts BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_bank_import_profiles_tenant_name_unique UNIQUE(tenant_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS accounting_bank_statement_imports (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    format VARCHAR(20) NOT NULL,
+    file_name VARCHAR(255),
+    profile_id INTEGER REFERENCES accounting_bank_import_profiles(id) ON DELETE SET NULL,
+    lines_imported INTEGER NOT NULL DEFAULT 0,
+    lines_skipped INTEGER NOT NULL DEFAULT 0,
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_bank_statement_imports_format_check CHECK (format IN ('csv', 'ofx', 'qfx', 'camt053'))
+);
+
+ALTER TABLE accounting_bank_statement_lines
+    ADD COLUMN IF NOT EXISTS import_id INTEGER REFERENCES accounting_bank_statement_imports(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS bank_reference VARCHAR(255);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_bank_import_profiles_tenant ON accounting_bank_import_profiles(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_bank_statement_imports_tenant ON accounting_bank_statement_imports(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_bank_statement_imports_account ON accounting_bank_statement_imports(account_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_bank_statement_lines_import ON accounting_bank_statement_lines(import_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounting_bank_statement_lines_bank_reference
+    ON accounting_bank_statement_lines(tenant_id, account_id, bank_reference) WHERE bank_reference IS NOT NULL;
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_bank_import_profiles_updated_at ON accounting_bank_import_profiles;
+CREATE TRIGGER update_accounting_bank_import_profiles_updated_at BEFORE UPDATE ON accounting_bank_import_profiles FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS update_accounting_bank_rules_updated_at ON accounting_bank_rules;
+DROP INDEX IF EXISTS idx_accounting_transaction_lines_account;
+ALTER TABLE accounting_bank_statement_lines
+    DROP COLUMN IF EXISTS draft_transaction_id,
+    DROP COLUMN IF EXISTS rule_id;
+DROP TABLE IF EXISTS accounting_bank_rules CASCADE;
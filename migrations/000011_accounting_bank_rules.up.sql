@@ -0,0 +1,37 @@
+-- Bank Rules
+-- Rules that categorize bank statement lines and the draft transactions created from them
+
+CREATE TABLE IF NOT EXISTS accounting_bank_rules (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    account_id INTEGER REFERENCES chart_of_accounts(id) ON DELETE CASCADE,
+    match_field VARCHAR(20) NOT NULL DEFAULT 'description',
+    match_type VARCHAR(20) NOT NULL DEFAULT 'contains',
+    pattern VARCHAR(255) NOT NULL,
+    direction VARCHAR(20) NOT NULL DEFAULT 'any',
+    amount_min DECIMAL(15,2),
+    amount_max DECIMAL(15,2),
+    target_account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    description TEXT,
+    priority INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_bank_rules_match_field_check CHECK (match_field IN ('description', 'reference', 'any')),
+    CONSTRAINT accounting_bank_rules_match_type_check CHECK (match_type IN ('contains', 'equals', 'starts_with', 'regex')),
+    CONSTRAINT accounting_bank_rules_direction_check CHECK (direction IN ('any', 'deposit', 'withdrawal'))
+);
+
+ALTER TABLE accounting_bank_statement_lines
+    ADD COLUMN IF NOT EXISTS rule_id INTEGER REFERENCES accounting_bank_rules(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS draft_transaction_id INTEGER REFERENCES accounting_transactions(id) ON DELETE SET NULL;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_bank_rules_tenant ON accounting_bank_rules(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_bank_rules_account ON accounting_bank_rules(account_id, priority);
+CREATE INDEX IF NOT EXISTS idx_accounting_transaction_lines_account ON accounting_transaction_lines(account_id);
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_bank_rules_updated_at ON accounting_bank_rules;
+CREATE TRIGGER update_accounting_bank_rules_updated_at BEFORE UPDATE ON accounting_bank_rules FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
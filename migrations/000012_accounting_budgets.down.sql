@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_accounting_budgets_updated_at ON accounting_budgets;
+DROP TABLE IF EXISTS accounting_budgets CASCADE;
@@ -0,0 +1,26 @@
+-- Budgets
+-- Budgets per account per fiscal period; period 0 budgets the whole fiscal year
+
+CREATE TABLE IF NOT EXISTS accounting_budgets (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    budget_name VARCHAR(255) NOT NULL,
+    fiscal_year INTEGER NOT NULL,
+    fiscal_period INTEGER NOT NULL DEFAULT 0,
+    account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    budget_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_budgets_unique UNIQUE(tenant_id, budget_name, fiscal_year, fiscal_period, account_id),
+    CONSTRAINT accounting_budgets_period_check CHECK (fiscal_period >= 0)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_budgets_tenant ON accounting_budgets(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_budgets_period ON accounting_budgets(fiscal_year, fiscal_period);
+CREATE INDEX IF NOT EXISTS idx_accounting_budgets_account ON accounting_budgets(account_id);
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_budgets_updated_at ON accounting_budgets;
+CREATE TRIGGER update_accounting_budgets_updated_at BEFORE UPDATE ON accounting_budgets FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS accounting_events CASCADE;
+DROP TABLE IF EXISTS accounting_budget_alerts CASCADE;
+ALTER TABLE accounting_budgets DROP COLUMN IF EXISTS is_hard_limit;
@@ -0,0 +1,41 @@
+-- Budget Alerts
+-- Hard-limit budgets, alerts raised when postings cross the alert threshold, and the module event outbox
+
+ALTER TABLE accounting_budgets
+    ADD COLUMN IF NOT EXISTS is_hard_limit BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS accounting_budget_alerts (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    budget_id INTEGER NOT NULL REFERENCES accounting_budgets(id) ON DELETE CASCADE,
+    transaction_id INTEGER REFERENCES accounting_transactions(id) ON DELETE SET NULL,
+    alert_type VARCHAR(20) NOT NULL,
+    threshold_percent DECIMAL(7,2) NOT NULL,
+    budget_amount DECIMAL(15,2) NOT NULL,
+    actual_amount DECIMAL(15,2) NOT NULL,
+    percent_used DECIMAL(9,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    acknowledged_by INTEGER,
+    acknowledged_at TIMESTAMP,
+    acknowledge_note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_budget_alerts_type_check CHECK (alert_type IN ('threshold', 'exceeded')),
+    CONSTRAINT accounting_budget_alerts_status_check CHECK (status IN ('open', 'acknowledged'))
+);
+
+-- Event Outbox
+CREATE TABLE IF NOT EXISTS accounting_events (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    published_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_budget_alerts_tenant ON accounting_budget_alerts(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_budget_alerts_budget ON accounting_budget_alerts(budget_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_budget_alerts_status ON accounting_budget_alerts(status);
+CREATE INDEX IF NOT EXISTS idx_accounting_events_tenant ON accounting_events(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_events_unpublished ON accounting_events(created_at) WHERE published_at IS NULL;
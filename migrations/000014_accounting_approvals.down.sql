@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS accounting_journal_entry_lines CASCADE;
+DROP TABLE IF EXISTS accounting_journal_entries CASCADE;
+DROP INDEX IF EXISTS idx_accounting_transactions_status;
+ALTER TABLE accounting_transactions
+    DROP COLUMN IF EXISTS approved_by,
+    DROP COLUMN IF EXISTS approved_at,
+    DROP COLUMN IF EXISTS rejected_by,
+    DROP COLUMN IF EXISTS rejected_at,
+    DROP COLUMN IF EXISTS rejection_reason;
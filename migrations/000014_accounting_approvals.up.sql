@@ -0,0 +1,55 @@
+-- Approvals
+-- Approval and rejection tracking for transactions and journal entries held for approval
+
+ALTER TABLE accounting_transactions
+    ADD COLUMN IF NOT EXISTS approved_by INTEGER,
+    ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS rejected_by INTEGER,
+    ADD COLUMN IF NOT EXISTS rejected_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS rejection_reason TEXT;
+
+-- Journal Entries
+CREATE TABLE IF NOT EXISTS accounting_journal_entries (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    entry_number VARCHAR(50) NOT NULL,
+    entry_date DATE NOT NULL,
+    description TEXT,
+    reference VARCHAR(100),
+    total_debit DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    total_credit DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    status VARCHAR(20) NOT NULL DEFAULT 'posted',
+    created_by INTEGER NOT NULL,
+    approved_by INTEGER,
+    approved_at TIMESTAMP,
+    rejected_by INTEGER,
+    rejected_at TIMESTAMP,
+    rejection_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_journal_entries_tenant_number_unique UNIQUE (tenant_id, entry_number),
+    CONSTRAINT accounting_journal_entries_status_check CHECK (status IN ('pending_approval', 'posted', 'rejected'))
+);
+
+-- Journal Entry Lines
+CREATE TABLE IF NOT EXISTS accounting_journal_entry_lines (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    journal_entry_id INTEGER NOT NULL REFERENCES accounting_journal_entries(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    debit_amount DECIMAL(15,2) DEFAULT 0.00,
+    credit_amount DECIMAL(15,2) DEFAULT 0.00,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_transactions_status ON accounting_transactions(status);
+CREATE INDEX IF NOT EXISTS idx_accounting_journal_entries_tenant ON accounting_journal_entries(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_journal_entries_date ON accounting_journal_entries(entry_date);
+CREATE INDEX IF NOT EXISTS idx_accounting_journal_entries_status ON accounting_journal_entries(status);
+CREATE INDEX IF NOT EXISTS idx_accounting_journal_entry_lines_entry ON accounting_journal_entry_lines(journal_entry_id);
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_journal_entries_updated_at ON accounting_journal_entries;
+CREATE TRIGGER update_accounting_journal_entries_updated_at BEFORE UPDATE ON accounting_journal_entries FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
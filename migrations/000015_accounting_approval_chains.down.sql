@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS accounting_approval_steps CASCADE;
+DROP TABLE IF EXISTS accounting_approval_policy_steps CASCADE;
+DROP TABLE IF EXISTS accounting_approval_policies CASCADE;
@@ -0,0 +1,60 @@
+-- Approval Chains
+-- Approval policies with ordered steps, and the approval history of each transaction and journal entry
+
+CREATE TABLE IF NOT EXISTS accounting_approval_policies (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    entity_type VARCHAR(20) NOT NULL DEFAULT 'any',
+    min_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    max_amount DECIMAL(15,2),
+    account_type VARCHAR(50),
+    account_id INTEGER REFERENCES chart_of_accounts(id) ON DELETE CASCADE,
+    priority INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_approval_policies_entity_type_check CHECK (entity_type IN ('any', 'transaction', 'journal_entry')),
+    CONSTRAINT accounting_approval_policies_account_type_check CHECK (account_type IN ('asset', 'liability', 'equity', 'revenue', 'expense'))
+);
+
+CREATE TABLE IF NOT EXISTS accounting_approval_policy_steps (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    policy_id INTEGER NOT NULL REFERENCES accounting_approval_policies(id) ON DELETE CASCADE,
+    step_order INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    approver_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(policy_id, step_order)
+);
+
+-- Approval Steps
+CREATE TABLE IF NOT EXISTS accounting_approval_steps (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    entity_type VARCHAR(20) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    policy_id INTEGER REFERENCES accounting_approval_policies(id) ON DELETE SET NULL,
+    step_order INTEGER NOT NULL,
+    step_name VARCHAR(100) NOT NULL,
+    approver_id INTEGER,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    decided_by INTEGER,
+    decided_at TIMESTAMP,
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(entity_type, entity_id, step_order),
+    CONSTRAINT accounting_approval_steps_entity_type_check CHECK (entity_type IN ('transaction', 'journal_entry')),
+    CONSTRAINT accounting_approval_steps_status_check CHECK (status IN ('pending', 'approved', 'rejected', 'cancelled'))
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_approval_policies_tenant ON accounting_approval_policies(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_approval_policy_steps_policy ON accounting_approval_policy_steps(policy_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_approval_steps_tenant ON accounting_approval_steps(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_approval_steps_pending ON accounting_approval_steps(approver_id) WHERE status = 'pending';
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_approval_policies_updated_at ON accounting_approval_policies;
+CREATE TRIGGER update_accounting_approval_policies_updated_at BEFORE UPDATE ON accounting_approval_policies FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,59 @@
+DROP TRIGGER IF EXISTS accounting_transaction_lines_immutable ON accounting_transaction_lines;
+DROP TRIGGER IF EXISTS accounting_transactions_immutable ON accounting_transactions;
+DROP FUNCTION IF EXISTS accounting_protect_posted_transaction_line();
+DROP FUNCTION IF EXISTS accounting_protect_posted_transaction();
+
+CREATE OR REPLACE FUNCTION accounting_check_fiscal_period() RETURNS TRIGGER AS $$
+DECLARE
+    closed_period VARCHAR(50);
+    row_tenant UUID;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_tenant := OLD.tenant_id;
+    ELSE
+        row_tenant := NEW.tenant_id;
+    END IF;
+    IF NOT accounting_fiscal_periods_enabled(row_tenant) THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        SELECT period_name INTO closed_period
+        FROM accounting_fiscal_periods
+        WHERE tenant_id IS NOT DISTINCT FROM OLD.tenant_id
+          AND OLD.transaction_date BETWEEN start_date AND end_date
+          AND status <> 'open';
+        IF closed_period IS NOT NULL THEN
+            RAISE EXCEPTION 'transaction % is in closed fiscal period %', OLD.transaction_number, closed_period
+                USING ERRCODE = 'AC001';
+        END IF;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        SELECT period_name INTO closed_period
+        FROM accounting_fiscal_periods
+        WHERE tenant_id IS NOT DISTINCT FROM NEW.tenant_id
+          AND NEW.transaction_date BETWEEN start_date AND end_date
+          AND status <> 'open';
+        IF closed_period IS NOT NULL THEN
+            RAISE EXCEPTION 'transaction date % is in closed fiscal period %', NEW.transaction_date, closed_period
+                USING ERRCODE = 'AC001';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_accounting_transactions_reversal_of;
+ALTER TABLE accounting_transactions DROP CONSTRAINT IF EXISTS accounting_transactions_status_check;
+ALTER TABLE accounting_transactions ALTER COLUMN status DROP NOT NULL;
+ALTER TABLE accounting_transactions
+    DROP COLUMN IF EXISTS reversal_of_id,
+    DROP COLUMN IF EXISTS voided_by,
+    DROP COLUMN IF EXISTS voided_at,
+    DROP COLUMN IF EXISTS void_reason;
@@ -0,0 +1,132 @@
+-- Transaction Lifecycle
+-- Draft, posted and void transactions; posted transactions are immutable and voided through a linked reversal
+
+ALTER TABLE accounting_transactions
+    ADD COLUMN IF NOT EXISTS reversal_of_id INTEGER REFERENCES accounting_transactions(id),
+    ADD COLUMN IF NOT EXISTS voided_by INTEGER,
+    ADD COLUMN IF NOT EXISTS voided_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS void_reason TEXT;
+
+UPDATE accounting_transactions SET status = 'posted' WHERE status IS NULL;
+ALTER TABLE accounting_transactions ALTER COLUMN status SET NOT NULL;
+ALTER TABLE accounting_transactions DROP CONSTRAINT IF EXISTS accounting_transactions_status_check;
+ALTER TABLE accounting_transactions ADD CONSTRAINT accounting_transactions_status_check
+    CHECK (status IN ('draft', 'pending_approval', 'posted', 'rejected', 'void'));
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_transactions_reversal_of ON accounting_transactions(reversal_of_id);
+
+-- Posted and void transactions and their lines cannot be changed or deleted.
+-- The only change allowed is voiding a posted transaction, and on lines the
+-- reconciliation columns.
+CREATE OR REPLACE FUNCTION accounting_protect_posted_transaction() RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status NOT IN ('posted', 'void') THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RAISE EXCEPTION 'transaction % is % and cannot be deleted', OLD.transaction_number, OLD.status;
+    END IF;
+
+    IF NOT (OLD.status = 'posted' AND NEW.status = 'void')
+       OR ROW(NEW.transaction_number, NEW.transaction_date, NEW.reference_type, NEW.reference_id, NEW.description,
+              NEW.total_amount, NEW.currency, NEW.created_by, NEW.reversal_of_id)
+          IS DISTINCT FROM
+          ROW(OLD.transaction_number, OLD.transaction_date, OLD.reference_type, OLD.reference_id, OLD.description,
+              OLD.total_amount, OLD.currency, OLD.created_by, OLD.reversal_of_id)
+    THEN
+        RAISE EXCEPTION 'transaction % is % and cannot be changed', OLD.transaction_number, OLD.status;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION accounting_protect_posted_transaction_line() RETURNS TRIGGER AS $$
+DECLARE
+    parent_status VARCHAR(20);
+BEGIN
+    SELECT status INTO parent_status FROM accounting_transactions WHERE id = OLD.transaction_id;
+
+    IF parent_status IN ('posted', 'void') THEN
+        IF TG_OP = 'DELETE' THEN
+            RAISE EXCEPTION 'lines of % transaction % cannot be deleted', parent_status, OLD.transaction_id;
+        END IF;
+        IF ROW(NEW.transaction_id, NEW.account_id, NEW.debit_amount, NEW.credit_amount, NEW.description)
+           IS DISTINCT FROM
+           ROW(OLD.transaction_id, OLD.account_id, OLD.debit_amount, OLD.credit_amount, OLD.description)
+        THEN
+            RAISE EXCEPTION 'lines of % transaction % cannot be changed', parent_status, OLD.transaction_id;
+        END IF;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Voiding only changes the status of the original, so it is allowed in
+-- closed periods; the reversal is dated into an open one
+CREATE OR REPLACE FUNCTION accounting_check_fiscal_period() RETURNS TRIGGER AS $$
+DECLARE
+    closed_period VARCHAR(50);
+    row_tenant UUID;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_tenant := OLD.tenant_id;
+    ELSE
+        row_tenant := NEW.tenant_id;
+    END IF;
+    IF NOT accounting_fiscal_periods_enabled(row_tenant) THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'UPDATE' AND OLD.status = 'posted' AND NEW.status = 'void'
+       AND NEW.transaction_date = OLD.transaction_date THEN
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        SELECT period_name INTO closed_period
+        FROM accounting_fiscal_periods
+        WHERE tenant_id IS NOT DISTINCT FROM OLD.tenant_id
+          AND OLD.transaction_date BETWEEN start_date AND end_date
+          AND status <> 'open';
+        IF closed_period IS NOT NULL THEN
+            RAISE EXCEPTION 'transaction % is in closed fiscal period %', OLD.transaction_number, closed_period
+                USING ERRCODE = 'AC001';
+        END IF;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        SELECT period_name INTO closed_period
+        FROM accounting_fiscal_periods
+        WHERE tenant_id IS NOT DISTINCT FROM NEW.tenant_id
+          AND NEW.transaction_date BETWEEN start_date AND end_date
+          AND status <> 'open';
+        IF closed_period IS NOT NULL THEN
+            RAISE EXCEPTION 'transaction date % is in closed fiscal period %', NEW.transaction_date, closed_period
+                USING ERRCODE = 'AC001';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Create triggers
+DROP TRIGGER IF EXISTS accounting_transactions_immutable ON accounting_transactions;
+CREATE TRIGGER accounting_transactions_immutable BEFORE UPDATE OR DELETE ON accounting_transactions FOR EACH ROW EXECUTE FUNCTION accounting_protect_posted_transaction();
+
+DROP TRIGGER IF EXISTS accounting_transaction_lines_immutable ON accounting_transaction_lines;
+CREATE TRIGGER accounting_transaction_lines_immutable BEFORE UPDATE OR DELETE ON accounting_transaction_lines FOR EACH ROW EXECUTE FUNCTION accounting_protect_posted_transaction_line();
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_accounting_journal_entries_auto_reverse;
+DROP INDEX IF EXISTS idx_accounting_journal_entries_reversal_of;
+ALTER TABLE accounting_journal_entries
+    DROP COLUMN IF EXISTS reversal_of_id,
+    DROP COLUMN IF EXISTS auto_reverse_on;
@@ -0,0 +1,12 @@
+-- Journal Entry Reversals
+-- Reversing journal entries linked to their source, and automatic reversal on a scheduled date
+
+ALTER TABLE accounting_journal_entries
+    ADD COLUMN IF NOT EXISTS reversal_of_id INTEGER REFERENCES accounting_journal_entries(id),
+    ADD COLUMN IF NOT EXISTS auto_reverse_on DATE;
+
+-- Create indexes
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounting_journal_entries_reversal_of
+    ON accounting_journal_entries(reversal_of_id) WHERE reversal_of_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_accounting_journal_entries_auto_reverse
+    ON accounting_journal_entries(auto_reverse_on) WHERE auto_reverse_on IS NOT NULL;
@@ -0,0 +1,6 @@
+ALTER TABLE accounting_journal_entries DROP CONSTRAINT IF EXISTS accounting_journal_entries_status_check;
+ALTER TABLE accounting_journal_entries ADD CONSTRAINT accounting_journal_entries_status_check
+    CHECK (status IN ('pending_approval', 'posted', 'rejected'));
+DROP TABLE IF EXISTS accounting_recurring_template_runs CASCADE;
+DROP TABLE IF EXISTS accounting_recurring_template_lines CASCADE;
+DROP TABLE IF EXISTS accounting_recurring_templates CASCADE;
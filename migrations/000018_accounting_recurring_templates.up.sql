@@ -0,0 +1,65 @@
+-- Recurring Templates
+-- Journal entry templates generated on a schedule, with one recorded run per template and date
+
+CREATE TABLE IF NOT EXISTS accounting_recurring_templates (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    reference VARCHAR(100),
+    frequency VARCHAR(20) NOT NULL,
+    interval_count INTEGER NOT NULL DEFAULT 1,
+    cron_expression VARCHAR(100),
+    start_date DATE NOT NULL,
+    end_date DATE,
+    next_run_date DATE,
+    last_run_date DATE,
+    entry_status VARCHAR(20) NOT NULL DEFAULT 'posted',
+    variables JSONB NOT NULL DEFAULT '{}',
+    is_active BOOLEAN DEFAULT true,
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_recurring_templates_frequency_check CHECK (frequency IN ('daily', 'weekly', 'monthly', 'quarterly', 'yearly', 'cron')),
+    CONSTRAINT accounting_recurring_templates_entry_status_check CHECK (entry_status IN ('draft', 'posted')),
+    CONSTRAINT accounting_recurring_templates_dates_check CHECK (end_date IS NULL OR end_date >= start_date)
+);
+
+CREATE TABLE IF NOT EXISTS accounting_recurring_template_lines (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    template_id INTEGER NOT NULL REFERENCES accounting_recurring_templates(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    debit_amount DECIMAL(15,2) DEFAULT 0.00,
+    credit_amount DECIMAL(15,2) DEFAULT 0.00,
+    debit_variable VARCHAR(100),
+    credit_variable VARCHAR(100),
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Recurring Template Runs
+CREATE TABLE IF NOT EXISTS accounting_recurring_template_runs (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    template_id INTEGER NOT NULL REFERENCES accounting_recurring_templates(id) ON DELETE CASCADE,
+    run_date DATE NOT NULL,
+    idempotency_key VARCHAR(100) NOT NULL UNIQUE,
+    journal_entry_id INTEGER REFERENCES accounting_journal_entries(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Journal entries generated as drafts are posted later
+ALTER TABLE accounting_journal_entries DROP CONSTRAINT IF EXISTS accounting_journal_entries_status_check;
+ALTER TABLE accounting_journal_entries ADD CONSTRAINT accounting_journal_entries_status_check
+    CHECK (status IN ('draft', 'pending_approval', 'posted', 'rejected'));
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_recurring_templates_tenant ON accounting_recurring_templates(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_recurring_templates_due ON accounting_recurring_templates(next_run_date) WHERE is_active = true;
+CREATE INDEX IF NOT EXISTS idx_accounting_recurring_template_lines_template ON accounting_recurring_template_lines(template_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_recurring_template_runs_template ON accounting_recurring_template_runs(template_id, run_date);
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_recurring_templates_updated_at ON accounting_recurring_templates;
+CREATE TRIGGER update_accounting_recurring_templates_updated_at BEFORE UPDATE ON accounting_recurring_templates FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,76 @@
+ALTER TABLE accounting_transactions
+    ALTER COLUMN total_amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_transaction_lines
+    ALTER COLUMN debit_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN credit_amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_invoices
+    ALTER COLUMN subtotal TYPE DECIMAL(15,2),
+    ALTER COLUMN tax_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN total_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN paid_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN balance_amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_invoice_lines
+    ALTER COLUMN unit_price TYPE DECIMAL(15,2),
+    ALTER COLUMN line_subtotal TYPE DECIMAL(15,2),
+    ALTER COLUMN tax_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN line_total TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_invoice_line_taxes
+    ALTER COLUMN taxable_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN tax_amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_payments
+    ALTER COLUMN amount TYPE DECIMAL(15,2),
+    ALTER COLUMN allocated_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN unapplied_amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_payment_allocations
+    ALTER COLUMN amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_year_end_closes
+    ALTER COLUMN total_revenue TYPE DECIMAL(15,2),
+    ALTER COLUMN total_expenses TYPE DECIMAL(15,2),
+    ALTER COLUMN net_income TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_reconciliations
+    ALTER COLUMN opening_balance TYPE DECIMAL(15,2),
+    ALTER COLUMN statement_balance TYPE DECIMAL(15,2),
+    ALTER COLUMN book_balance TYPE DECIMAL(15,2),
+    ALTER COLUMN reconciled_balance TYPE DECIMAL(15,2),
+    ALTER COLUMN difference TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_reconciliation_matches
+    ALTER COLUMN amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_bank_statement_lines
+    ALTER COLUMN amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_bank_rules
+    ALTER COLUMN amount_min TYPE DECIMAL(15,2),
+    ALTER COLUMN amount_max TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_budgets
+    ALTER COLUMN budget_amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_budget_alerts
+    ALTER COLUMN budget_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN actual_amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_journal_entries
+    ALTER COLUMN total_debit TYPE DECIMAL(15,2),
+    ALTER COLUMN total_credit TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_journal_entry_lines
+    ALTER COLUMN debit_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN credit_amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_approval_policies
+    ALTER COLUMN min_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN max_amount TYPE DECIMAL(15,2);
+
+ALTER TABLE accounting_recurring_template_lines
+    ALTER COLUMN debit_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN credit_amount TYPE DECIMAL(15,2);
@@ -0,0 +1,60 @@
+CREATE OR REPLACE FUNCTION accounting_protect_posted_transaction() RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status NOT IN ('posted', 'void') THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RAISE EXCEPTION 'transaction % is % and cannot be deleted', OLD.transaction_number, OLD.status;
+    END IF;
+
+    IF NOT (OLD.status = 'posted' AND NEW.status = 'void')
+       OR ROW(NEW.transaction_number, NEW.transaction_date, NEW.reference_type, NEW.reference_id, NEW.description,
+              NEW.total_amount, NEW.currency, NEW.created_by, NEW.reversal_of_id)
+          IS DISTINCT FROM
+          ROW(OLD.transaction_number, OLD.transaction_date, OLD.reference_type, OLD.reference_id, OLD.description,
+              OLD.total_amount, OLD.currency, OLD.created_by, OLD.reversal_of_id)
+    THEN
+        RAISE EXCEPTION 'transaction % is % and cannot be changed', OLD.transaction_number, OLD.status;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION accounting_protect_posted_transaction_line() RETURNS TRIGGER AS $$
+DECLARE
+    parent_status VARCHAR(20);
+BEGIN
+    SELECT status INTO parent_status FROM accounting_transactions WHERE id = OLD.transaction_id;
+
+    IF parent_status IN ('posted', 'void') THEN
+        IF TG_OP = 'DELETE' THEN
+            RAISE EXCEPTION 'lines of % transaction % cannot be deleted', parent_status, OLD.transaction_id;
+        END IF;
+        IF ROW(NEW.transaction_id, NEW.account_id, NEW.debit_amount, NEW.credit_amount, NEW.description)
+           IS DISTINCT FROM
+           ROW(OLD.transaction_id, OLD.account_id, OLD.debit_amount, OLD.credit_amount, OLD.description)
+        THEN
+            RAISE EXCEPTION 'lines of % transaction % cannot be changed', parent_status, OLD.transaction_id;
+        END IF;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE accounting_transaction_lines
+    DROP COLUMN IF EXISTS currency_debit_amount,
+    DROP COLUMN IF EXISTS currency_credit_amount;
+
+ALTER TABLE accounting_transactions DROP COLUMN IF EXISTS exchange_rate;
+
+DROP TRIGGER IF EXISTS update_accounting_exchange_rates_updated_at ON accounting_exchange_rates;
+DROP TABLE IF EXISTS accounting_exchange_rates;
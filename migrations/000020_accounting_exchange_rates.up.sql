@@ -0,0 +1,96 @@
+-- Exchange Rates
+-- Dated exchange rates, and transaction lines kept in both the transaction and the functional currency
+
+CREATE TABLE IF NOT EXISTS accounting_exchange_rates (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    from_currency VARCHAR(3) NOT NULL,
+    to_currency VARCHAR(3) NOT NULL,
+    rate DECIMAL(20,10) NOT NULL,
+    rate_date DATE NOT NULL,
+    source VARCHAR(20) NOT NULL DEFAULT 'manual',
+    created_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_exchange_rates_pair_date_unique UNIQUE(tenant_id, from_currency, to_currency, rate_date),
+    CONSTRAINT accounting_exchange_rates_rate_check CHECK (rate > 0),
+    CONSTRAINT accounting_exchange_rates_pair_check CHECK (from_currency <> to_currency),
+    CONSTRAINT accounting_exchange_rates_source_check CHECK (source IN ('manual', 'import'))
+);
+
+-- debit_amount and credit_amount are in the functional currency; the
+-- currency_ columns keep the amounts in the currency of the transaction.
+-- Existing transactions are taken to be in the functional currency.
+ALTER TABLE accounting_transactions
+    ADD COLUMN IF NOT EXISTS exchange_rate DECIMAL(20,10) NOT NULL DEFAULT 1;
+
+ALTER TABLE accounting_transaction_lines
+    ADD COLUMN IF NOT EXISTS currency_debit_amount DECIMAL(18,4) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS currency_credit_amount DECIMAL(18,4) NOT NULL DEFAULT 0;
+
+UPDATE accounting_transaction_lines
+SET currency_debit_amount = debit_amount, currency_credit_amount = credit_amount;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_exchange_rates_tenant ON accounting_exchange_rates(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_exchange_rates_lookup ON accounting_exchange_rates(from_currency, to_currency, rate_date DESC);
+
+-- The exchange rate and currency amounts of posted transactions are as
+-- immutable as the rest of them
+CREATE OR REPLACE FUNCTION accounting_protect_posted_transaction() RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status NOT IN ('posted', 'void') THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RAISE EXCEPTION 'transaction % is % and cannot be deleted', OLD.transaction_number, OLD.status;
+    END IF;
+
+    IF NOT (OLD.status = 'posted' AND NEW.status = 'void')
+       OR ROW(NEW.transaction_number, NEW.transaction_date, NEW.reference_type, NEW.reference_id, NEW.description,
+              NEW.total_amount, NEW.currency, NEW.exchange_rate, NEW.created_by, NEW.reversal_of_id)
+          IS DISTINCT FROM
+          ROW(OLD.transaction_number, OLD.transaction_date, OLD.reference_type, OLD.reference_id, OLD.description,
+              OLD.total_amount, OLD.currency, OLD.exchange_rate, OLD.created_by, OLD.reversal_of_id)
+    THEN
+        RAISE EXCEPTION 'transaction % is % and cannot be changed', OLD.transaction_number, OLD.status;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION accounting_protect_posted_transaction_line() RETURNS TRIGGER AS $$
+DECLARE
+    parent_status VARCHAR(20);
+BEGIN
+    SELECT status INTO parent_status FROM accounting_transactions WHERE id = OLD.transaction_id;
+
+    IF parent_status IN ('posted', 'void') THEN
+        IF TG_OP = 'DELETE' THEN
+            RAISE EXCEPTION 'lines of % transaction % cannot be deleted', parent_status, OLD.transaction_id;
+        END IF;
+        IF ROW(NEW.transaction_id, NEW.account_id, NEW.debit_amount, NEW.credit_amount,
+               NEW.currency_debit_amount, NEW.currency_credit_amount, NEW.description)
+           IS DISTINCT FROM
+           ROW(OLD.transaction_id, OLD.account_id, OLD.debit_amount, OLD.credit_amount,
+               OLD.currency_debit_amount, OLD.currency_credit_amount, OLD.description)
+        THEN
+            RAISE EXCEPTION 'lines of % transaction % cannot be changed', parent_status, OLD.transaction_id;
+        END IF;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_exchange_rates_updated_at ON accounting_exchange_rates;
+CREATE TRIGGER update_accounting_exchange_rates_updated_at BEFORE UPDATE ON accounting_exchange_rates FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS accounting_fx_revaluation_lines;
+DROP TABLE IF EXISTS accounting_fx_revaluations;
+
+ALTER TABLE chart_of_accounts DROP COLUMN IF EXISTS is_monetary;
@@ -0,0 +1,41 @@
+-- FX Revaluations
+-- Period-end revaluation of foreign currency balances on monetary accounts, posted as auto-reversing journal entries
+
+ALTER TABLE chart_of_accounts
+    ADD COLUMN IF NOT EXISTS is_monetary BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS accounting_fx_revaluations (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    revaluation_date DATE NOT NULL,
+    reverse_on DATE NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    gain_account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    loss_account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    total_gain DECIMAL(18,4) NOT NULL DEFAULT 0,
+    total_loss DECIMAL(18,4) NOT NULL DEFAULT 0,
+    net_adjustment DECIMAL(18,4) NOT NULL DEFAULT 0,
+    journal_entry_id INTEGER REFERENCES accounting_journal_entries(id),
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_fx_revaluations_dates_check CHECK (reverse_on > revaluation_date)
+);
+
+CREATE TABLE IF NOT EXISTS accounting_fx_revaluation_lines (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    revaluation_id INTEGER NOT NULL REFERENCES accounting_fx_revaluations(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    currency VARCHAR(3) NOT NULL,
+    foreign_balance DECIMAL(18,4) NOT NULL,
+    booked_amount DECIMAL(18,4) NOT NULL,
+    exchange_rate DECIMAL(20,10) NOT NULL,
+    revalued_amount DECIMAL(18,4) NOT NULL,
+    adjustment DECIMAL(18,4) NOT NULL
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_fx_revaluations_tenant ON accounting_fx_revaluations(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_fx_revaluations_date ON accounting_fx_revaluations(revaluation_date);
+CREATE INDEX IF NOT EXISTS idx_accounting_fx_revaluation_lines_revaluation ON accounting_fx_revaluation_lines(revaluation_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_fx_revaluation_lines_tenant ON accounting_fx_revaluation_lines(tenant_id);
@@ -0,0 +1,50 @@
+DO $$
+DECLARE
+    table_name TEXT;
+BEGIN
+    FOREACH table_name IN ARRAY ARRAY[
+        'chart_of_accounts',
+        'accounting_transactions',
+        'accounting_transaction_lines',
+        'accounting_invoices',
+        'accounting_invoice_lines',
+        'accounting_invoice_line_taxes',
+        'accounting_payments',
+        'accounting_payment_allocations',
+        'accounting_settings',
+        'accounting_fiscal_periods',
+        'accounting_year_end_closes',
+        'accounting_tax_codes',
+        'accounting_tax_rates',
+        'accounting_reconciliations',
+        'accounting_reconciliation_matches',
+        'accounting_reconciliation_match_lines',
+        'accounting_bank_statement_lines',
+        'accounting_bank_import_profiles',
+        'accounting_bank_statement_imports',
+        'accounting_bank_rules',
+        'accounting_budgets',
+        'accounting_budget_alerts',
+        'accounting_events',
+        'accounting_journal_entries',
+        'accounting_journal_entry_lines',
+        'accounting_approval_policies',
+        'accounting_approval_policy_steps',
+        'accounting_approval_steps',
+        'accounting_recurring_templates',
+        'accounting_recurring_template_lines',
+        'accounting_recurring_template_runs',
+        'accounting_exchange_rates',
+        'accounting_fx_revaluations',
+        'accounting_fx_revaluation_lines'
+    ] LOOP
+        EXECUTE format('DROP POLICY IF EXISTS accounting_tenant_isolation ON %I', table_name);
+        EXECUTE format('ALTER TABLE %I NO FORCE ROW LEVEL SECURITY', table_name);
+        EXECUTE format('ALTER TABLE %I DISABLE ROW LEVEL SECURITY', table_name);
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN tenant_id DROP NOT NULL', table_name);
+    END LOOP;
+END $$;
+
+DROP FUNCTION IF EXISTS accounting_current_tenant();
+
+ALTER TABLE accounting_reconciliation_match_lines DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,110 @@
+-- Tenant Isolation
+-- tenant_id on every accounting table and row-level security policies restricting rows to the current tenant
+
+ALTER TABLE accounting_reconciliation_match_lines
+    ADD COLUMN IF NOT EXISTS tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE;
+
+UPDATE accounting_reconciliation_match_lines ml
+SET tenant_id = m.tenant_id
+FROM accounting_reconciliation_matches m
+WHERE m.id = ml.match_id AND ml.tenant_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_accounting_reconciliation_match_lines_tenant ON accounting_reconciliation_match_lines(tenant_id);
+
+-- The tenant of the current session or transaction, set by the module with
+-- set_config('app.tenant_id', ...). NULL when no tenant is set.
+CREATE OR REPLACE FUNCTION accounting_current_tenant() RETURNS UUID AS $$
+    SELECT NULLIF(current_setting('app.tenant_id', true), '')::uuid;
+$$ LANGUAGE sql STABLE;
+
+-- Rows written before the module scoped its queries to a tenant have no
+-- tenant_id and would be hidden from every tenant once the policies below
+-- apply. Lines take the tenant of their transaction; on an installation with
+-- a single tenant every remaining row belongs to it. Otherwise the rows must
+-- be assigned to their tenant before this migration can run. Triggers are
+-- disabled for the backfill so that posted transactions and closed fiscal
+-- periods do not reject it.
+--
+-- A session bound to a tenant can neither read nor write the rows of another
+-- tenant, on top of the tenant_id condition of every query, and a session
+-- bound to no tenant sees no rows at all. Migrations and maintenance run as
+-- a role with BYPASSRLS.
+DO $$
+DECLARE
+    accounting_tables TEXT[] := ARRAY[
+        'chart_of_accounts',
+        'accounting_transactions',
+        'accounting_transaction_lines',
+        'accounting_invoices',
+        'accounting_invoice_lines',
+        'accounting_invoice_line_taxes',
+        'accounting_payments',
+        'accounting_payment_allocations',
+        'accounting_settings',
+        'accounting_fiscal_periods',
+        'accounting_year_end_closes',
+        'accounting_tax_codes',
+        'accounting_tax_rates',
+        'accounting_reconciliations',
+        'accounting_reconciliation_matches',
+        'accounting_reconciliation_match_lines',
+        'accounting_bank_statement_lines',
+        'accounting_bank_import_profiles',
+        'accounting_bank_statement_imports',
+        'accounting_bank_rules',
+        'accounting_budgets',
+        'accounting_budget_alerts',
+        'accounting_events',
+        'accounting_journal_entries',
+        'accounting_journal_entry_lines',
+        'accounting_approval_policies',
+        'accounting_approval_policy_steps',
+        'accounting_approval_steps',
+        'accounting_recurring_templates',
+        'accounting_recurring_template_lines',
+        'accounting_recurring_template_runs',
+        'accounting_exchange_rates',
+        'accounting_fx_revaluations',
+        'accounting_fx_revaluation_lines'
+    ];
+    table_name TEXT;
+    only_tenant UUID;
+    missing BIGINT;
+BEGIN
+    FOREACH table_name IN ARRAY accounting_tables LOOP
+        EXECUTE format('ALTER TABLE %I DISABLE TRIGGER USER', table_name);
+    END LOOP;
+
+    UPDATE accounting_transaction_lines tl
+    SET tenant_id = t.tenant_id
+    FROM accounting_transactions t
+    WHERE t.id = tl.transaction_id AND tl.tenant_id IS NULL;
+
+    IF (SELECT count(*) FROM tenants) = 1 THEN
+        SELECT id INTO only_tenant FROM tenants;
+        FOREACH table_name IN ARRAY accounting_tables LOOP
+            EXECUTE format('UPDATE %I SET tenant_id = $1 WHERE tenant_id IS NULL', table_name) USING only_tenant;
+        END LOOP;
+    END IF;
+
+    FOREACH table_name IN ARRAY accounting_tables LOOP
+        EXECUTE format('SELECT count(*) FROM %I WHERE tenant_id IS NULL', table_name) INTO missing;
+        IF missing > 0 THEN
+            RAISE EXCEPTION '% rows of % have no tenant_id; assign them to their tenant before enabling tenant isolation',
+                missing, table_name;
+        END IF;
+    END LOOP;
+
+    FOREACH table_name IN ARRAY accounting_tables LOOP
+        EXECUTE format('ALTER TABLE %I ENABLE TRIGGER USER', table_name);
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN tenant_id SET NOT NULL', table_name);
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', table_name);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', table_name);
+        EXECUTE format('DROP POLICY IF EXISTS accounting_tenant_isolation ON %I', table_name);
+        EXECUTE format(
+            'CREATE POLICY accounting_tenant_isolation ON %I
+                USING (tenant_id = accounting_current_tenant())
+                WITH CHECK (tenant_id = accounting_current_tenant())',
+            table_name);
+    END LOOP;
+END $$;
@@ -0,0 +1,45 @@
+CREATE OR REPLACE FUNCTION accounting_protect_posted_transaction() RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status NOT IN ('posted', 'void') THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RAISE EXCEPTION 'transaction % is % and cannot be deleted', OLD.transaction_number, OLD.status;
+    END IF;
+
+    IF NOT (OLD.status = 'posted' AND NEW.status = 'void')
+       OR ROW(NEW.transaction_number, NEW.transaction_date, NEW.reference_type, NEW.reference_id, NEW.description,
+              NEW.total_amount, NEW.currency, NEW.exchange_rate, NEW.created_by, NEW.reversal_of_id)
+          IS DISTINCT FROM
+          ROW(OLD.transaction_number, OLD.transaction_date, OLD.reference_type, OLD.reference_id, OLD.description,
+              OLD.total_amount, OLD.currency, OLD.exchange_rate, OLD.created_by, OLD.reversal_of_id)
+    THEN
+        RAISE EXCEPTION 'transaction % is % and cannot be changed', OLD.transaction_number, OLD.status;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS accounting_intercompany_transactions;
+
+DROP INDEX IF EXISTS idx_accounting_year_end_closes_active;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounting_year_end_closes_active
+    ON accounting_year_end_closes(tenant_id, fiscal_year) WHERE status = 'closed';
+
+DROP INDEX IF EXISTS idx_chart_of_accounts_company_code;
+ALTER TABLE chart_of_accounts ADD CONSTRAINT chart_of_accounts_tenant_code_unique UNIQUE(tenant_id, account_code);
+
+ALTER TABLE accounting_fx_revaluations DROP COLUMN IF EXISTS company_id;
+ALTER TABLE accounting_year_end_closes DROP COLUMN IF EXISTS company_id;
+ALTER TABLE accounting_budgets DROP COLUMN IF EXISTS company_id;
+ALTER TABLE accounting_journal_entries DROP COLUMN IF EXISTS company_id;
+ALTER TABLE accounting_transactions DROP COLUMN IF EXISTS company_id;
+ALTER TABLE chart_of_accounts DROP COLUMN IF EXISTS company_id;
+
+DROP TRIGGER IF EXISTS update_accounting_companies_updated_at ON accounting_companies;
+DROP TABLE IF EXISTS accounting_companies;
@@ -0,0 +1,127 @@
+-- Companies
+-- Legal entities within a tenant, company charts of accounts and inter-company transactions posted to both companies
+
+CREATE TABLE IF NOT EXISTS accounting_companies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    code VARCHAR(20) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    due_from_account_id INTEGER REFERENCES chart_of_accounts(id),
+    due_to_account_id INTEGER REFERENCES chart_of_accounts(id),
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_companies_tenant_code_unique UNIQUE(tenant_id, code)
+);
+
+-- Accounts without a company are shared by every company of the tenant.
+-- Transactions, journal entries and budgets take the company of their
+-- accounts.
+ALTER TABLE chart_of_accounts
+    ADD COLUMN IF NOT EXISTS company_id UUID REFERENCES accounting_companies(id);
+ALTER TABLE accounting_transactions
+    ADD COLUMN IF NOT EXISTS company_id UUID REFERENCES accounting_companies(id);
+ALTER TABLE accounting_journal_entries
+    ADD COLUMN IF NOT EXISTS company_id UUID REFERENCES accounting_companies(id);
+ALTER TABLE accounting_budgets
+    ADD COLUMN IF NOT EXISTS company_id UUID REFERENCES accounting_companies(id);
+ALTER TABLE accounting_year_end_closes
+    ADD COLUMN IF NOT EXISTS company_id UUID REFERENCES accounting_companies(id);
+ALTER TABLE accounting_fx_revaluations
+    ADD COLUMN IF NOT EXISTS company_id UUID REFERENCES accounting_companies(id);
+
+-- Account codes are unique per company; shared accounts count as one more
+-- company
+ALTER TABLE chart_of_accounts DROP CONSTRAINT IF EXISTS chart_of_accounts_tenant_code_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chart_of_accounts_company_code
+    ON chart_of_accounts(tenant_id, COALESCE(company_id, '00000000-0000-0000-0000-000000000000'), account_code);
+
+-- Each company closes its own fiscal years
+DROP INDEX IF EXISTS idx_accounting_year_end_closes_active;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounting_year_end_closes_active
+    ON accounting_year_end_closes(tenant_id, COALESCE(company_id, '00000000-0000-0000-0000-000000000000'), fiscal_year)
+    WHERE status = 'closed';
+
+-- An inter-company transaction posts a mirrored transaction in each company:
+-- the from company is owed the amount (due from) and the to company owes it
+-- (due to). Both are eliminated from consolidated reports.
+CREATE TABLE IF NOT EXISTS accounting_intercompany_transactions (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    from_company_id UUID NOT NULL REFERENCES accounting_companies(id),
+    to_company_id UUID NOT NULL REFERENCES accounting_companies(id),
+    transaction_date DATE NOT NULL,
+    description TEXT,
+    amount DECIMAL(18,4) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    from_account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    to_account_id INTEGER NOT NULL REFERENCES chart_of_accounts(id),
+    from_transaction_id INTEGER REFERENCES accounting_transactions(id),
+    to_transaction_id INTEGER REFERENCES accounting_transactions(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'posted',
+    created_by INTEGER NOT NULL,
+    voided_by INTEGER,
+    voided_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_intercompany_transactions_companies_check CHECK (from_company_id <> to_company_id),
+    CONSTRAINT accounting_intercompany_transactions_amount_check CHECK (amount > 0),
+    CONSTRAINT accounting_intercompany_transactions_status_check CHECK (status IN ('posted', 'void'))
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_companies_tenant ON accounting_companies(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_chart_of_accounts_company ON chart_of_accounts(company_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_transactions_company ON accounting_transactions(company_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_journal_entries_company ON accounting_journal_entries(company_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_budgets_company ON accounting_budgets(company_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_intercompany_transactions_tenant ON accounting_intercompany_transactions(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_intercompany_transactions_date ON accounting_intercompany_transactions(transaction_date);
+
+-- The company of a posted transaction is as immutable as the rest of it
+CREATE OR REPLACE FUNCTION accounting_protect_posted_transaction() RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status NOT IN ('posted', 'void') THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RAISE EXCEPTION 'transaction % is % and cannot be deleted', OLD.transaction_number, OLD.status;
+    END IF;
+
+    IF NOT (OLD.status = 'posted' AND NEW.status = 'void')
+       OR ROW(NEW.transaction_number, NEW.transaction_date, NEW.reference_type, NEW.reference_id, NEW.description,
+              NEW.total_amount, NEW.currency, NEW.exchange_rate, NEW.created_by, NEW.reversal_of_id, NEW.company_id)
+          IS DISTINCT FROM
+          ROW(OLD.transaction_number, OLD.transaction_date, OLD.reference_type, OLD.reference_id, OLD.description,
+              OLD.total_amount, OLD.currency, OLD.exchange_rate, OLD.created_by, OLD.reversal_of_id, OLD.company_id)
+    THEN
+        RAISE EXCEPTION 'transaction % is % and cannot be changed', OLD.transaction_number, OLD.status;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Row-level security, as for the other accounting tables
+DO $$
+DECLARE
+    table_name TEXT;
+BEGIN
+    FOREACH table_name IN ARRAY ARRAY['accounting_companies', 'accounting_intercompany_transactions'] LOOP
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', table_name);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', table_name);
+        EXECUTE format('DROP POLICY IF EXISTS accounting_tenant_isolation ON %I', table_name);
+        EXECUTE format(
+            'CREATE POLICY accounting_tenant_isolation ON %I
+                USING (tenant_id = accounting_current_tenant())
+                WITH CHECK (tenant_id = accounting_current_tenant())',
+            table_name);
+    END LOOP;
+END $$;
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_companies_updated_at ON accounting_companies;
+CREATE TRIGGER update_accounting_companies_updated_at BEFORE UPDATE ON accounting_companies FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_accounting_journal_entries_transaction;
+ALTER TABLE accounting_journal_entries DROP COLUMN IF EXISTS transaction_id;
+
+-- Journal entries are taken back out of the ledger. Their transactions are
+-- posted and protected by triggers, which are suspended for the removal.
+ALTER TABLE accounting_transactions DISABLE TRIGGER USER;
+ALTER TABLE accounting_transaction_lines DISABLE TRIGGER USER;
+
+DELETE FROM accounting_transaction_lines
+WHERE transaction_id IN (SELECT id FROM accounting_transactions WHERE reference_type = 'journal_entry');
+DELETE FROM accounting_transactions WHERE reference_type = 'journal_entry';
+
+ALTER TABLE accounting_transactions ENABLE TRIGGER USER;
+ALTER TABLE accounting_transaction_lines ENABLE TRIGGER USER;
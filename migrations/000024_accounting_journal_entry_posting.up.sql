@@ -0,0 +1,53 @@
+-- Journal Entry Posting
+-- Posted journal entries post a transaction to the general ledger, linked back to the entry
+
+ALTER TABLE accounting_journal_entries
+    ADD COLUMN IF NOT EXISTS transaction_id INTEGER REFERENCES accounting_transactions(id);
+
+-- Entries posted before now are posted to the ledger in entry order, so that
+-- the transaction of a reversal can refer to the transaction it reverses.
+-- Journal entries are in the functional currency of their tenant. They were
+-- accepted when they were posted, so the fiscal period check is suspended
+-- for entries that now fall in a closed period.
+ALTER TABLE accounting_transactions DISABLE TRIGGER accounting_transactions_fiscal_period_check;
+
+DO $$
+DECLARE
+    entry RECORD;
+    new_transaction_id INTEGER;
+BEGIN
+    FOR entry IN
+        SELECT * FROM accounting_journal_entries
+        WHERE status = 'posted' AND transaction_id IS NULL
+        ORDER BY id
+    LOOP
+        INSERT INTO accounting_transactions
+        (tenant_id, company_id, transaction_number, transaction_date, reference_type, reference_id, description,
+         total_amount, currency, exchange_rate, status, created_by, reversal_of_id)
+        VALUES (
+            entry.tenant_id, entry.company_id, 'TXN-' || entry.entry_number, entry.entry_date, 'journal_entry', entry.id,
+            'Journal entry ' || entry.entry_number || COALESCE(': ' || entry.description, ''), entry.total_debit,
+            COALESCE((SELECT setting_value FROM accounting_settings
+                      WHERE tenant_id IS NOT DISTINCT FROM entry.tenant_id AND setting_key = 'default_currency'), 'USD'),
+            1, 'posted', entry.created_by,
+            (SELECT transaction_id FROM accounting_journal_entries WHERE id = entry.reversal_of_id))
+        RETURNING id INTO new_transaction_id;
+
+        INSERT INTO accounting_transaction_lines
+        (tenant_id, transaction_id, account_id, debit_amount, credit_amount, currency_debit_amount,
+         currency_credit_amount, description)
+        SELECT tenant_id, new_transaction_id, account_id, debit_amount, credit_amount, debit_amount, credit_amount,
+               description
+        FROM accounting_journal_entry_lines
+        WHERE journal_entry_id = entry.id
+        ORDER BY id;
+
+        UPDATE accounting_journal_entries SET transaction_id = new_transaction_id WHERE id = entry.id;
+    END LOOP;
+END $$;
+
+ALTER TABLE accounting_transactions ENABLE TRIGGER accounting_transactions_fiscal_period_check;
+
+-- Create indexes
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounting_journal_entries_transaction
+    ON accounting_journal_entries(transaction_id) WHERE transaction_id IS NOT NULL;
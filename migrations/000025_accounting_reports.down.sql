@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_accounting_reports_updated_at ON accounting_reports;
+DROP TABLE IF EXISTS accounting_reports;
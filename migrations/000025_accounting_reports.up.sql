@@ -0,0 +1,36 @@
+-- Reports
+-- Saved financial reports with the parameters they were run with and their generated output
+
+CREATE TABLE IF NOT EXISTS accounting_reports (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    company_id UUID REFERENCES accounting_companies(id),
+    name VARCHAR(255) NOT NULL,
+    report_type VARCHAR(50) NOT NULL,
+    parameters JSONB NOT NULL DEFAULT '{}',
+    data JSONB,
+    generated_at TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT accounting_reports_type_check CHECK (report_type IN (
+        'balance_sheet', 'income_statement', 'cash_flow', 'trial_balance', 'general_ledger', 'budget_vs_actual'
+    ))
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_accounting_reports_tenant ON accounting_reports(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_reports_company ON accounting_reports(company_id);
+CREATE INDEX IF NOT EXISTS idx_accounting_reports_type ON accounting_reports(tenant_id, report_type);
+
+-- Row-level security, as for the other accounting tables
+ALTER TABLE accounting_reports ENABLE ROW LEVEL SECURITY;
+ALTER TABLE accounting_reports FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS accounting_tenant_isolation ON accounting_reports;
+CREATE POLICY accounting_tenant_isolation ON accounting_reports
+    USING (tenant_id = accounting_current_tenant())
+    WITH CHECK (tenant_id = accounting_current_tenant());
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_accounting_reports_updated_at ON accounting_reports;
+CREATE TRIGGER update_accounting_reports_updated_at BEFORE UPDATE ON accounting_reports FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,2 @@
+ALTER TABLE chart_of_accounts DROP CONSTRAINT IF EXISTS chart_of_accounts_cash_flow_category_check;
+ALTER TABLE chart_of_accounts DROP COLUMN IF EXISTS cash_flow_category;
@@ -0,0 +1,23 @@
+-- Cash Flow Categories
+-- Classification of accounts into cash and operating, investing and financing activities for the cash flow statement
+
+ALTER TABLE chart_of_accounts
+    ADD COLUMN IF NOT EXISTS cash_flow_category VARCHAR(20);
+
+ALTER TABLE chart_of_accounts DROP CONSTRAINT IF EXISTS chart_of_accounts_cash_flow_category_check;
+ALTER TABLE chart_of_accounts ADD CONSTRAINT chart_of_accounts_cash_flow_category_check CHECK (
+    cash_flow_category IS NULL
+    OR cash_flow_category IN ('operating', 'investing', 'financing')
+    OR (cash_flow_category = 'cash' AND account_type = 'asset')
+);
+
+-- Asset accounts that receive payments or are reconciled against bank
+-- statements hold cash
+UPDATE chart_of_accounts SET cash_flow_category = 'cash'
+WHERE cash_flow_category IS NULL
+  AND account_type = 'asset'
+  AND id IN (
+      SELECT cash_account_id FROM accounting_payments
+      UNION SELECT account_id FROM accounting_reconciliations
+      UNION SELECT account_id FROM accounting_bank_statement_imports
+  );